@@ -19,6 +19,7 @@ const (
 	_Unit
 	_Storage
 	_Backup
+	_Credential
 )
 
 type category int
@@ -42,6 +42,7 @@ var (
 		"_Service":    _Service,
 		"_Storage":    _Storage,
 		"_Networking": _Networking,
+		"_Credential": _Credential,
 	}
 
 	categoryMap = map[string]category{
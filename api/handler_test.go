@@ -1,6 +1,7 @@
 package api
 
 import (
+	"errors"
 	"testing"
 	"time"
 
@@ -63,3 +64,31 @@ func TestExpiredFiles(t *testing.T) {
 		t.Errorf("%s,got %d files but want %d", date, got, 3)
 	}
 }
+
+func TestVerifyBackupChecksum(t *testing.T) {
+	bf := database.BackupFile{ID: "f1", Checksum: "sum1"}
+
+	if resp := verifyBackupChecksum(bf, "sum1", nil); resp.Status != database.BackupFileVerifyPassed {
+		t.Errorf("expected passed,%+v", resp)
+	}
+
+	if resp := verifyBackupChecksum(bf, "sum2", nil); resp.Status != database.BackupFileVerifyFailed {
+		t.Errorf("expected failed by mismatch,%+v", resp)
+	}
+
+	if resp := verifyBackupChecksum(bf, "", errors.New("unreadable")); resp.Status != database.BackupFileVerifyFailed {
+		t.Errorf("expected failed by error,%+v", resp)
+	}
+
+	// recorded before checksum supported
+	bf.Checksum = ""
+	if resp := verifyBackupChecksum(bf, "sum1", nil); resp.Status != database.BackupFileVerifyPassed || resp.Checksum != "sum1" {
+		t.Errorf("expected baseline taken,%+v", resp)
+	}
+
+	// checksum failed in the callback
+	bf.Verify = database.BackupFileVerifyFailed
+	if resp := verifyBackupChecksum(bf, "sum1", nil); resp.Status != database.BackupFileVerifyFailed || resp.Checksum != "" {
+		t.Errorf("expected failed stays failed,%+v", resp)
+	}
+}
@@ -4,18 +4,25 @@ import (
 	"encoding/json"
 	stderr "errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net"
 	"net/http"
+	"os"
 	"path/filepath"
 	"strings"
 	"time"
 
 	"github.com/Sirupsen/logrus"
+	"github.com/docker/distribution/reference"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/pkg/stdcopy"
 	"github.com/docker/swarm/cluster"
 	"github.com/docker/swarm/garden"
+	"github.com/docker/swarm/garden/backup"
 	"github.com/docker/swarm/garden/database"
 	"github.com/docker/swarm/garden/deploy"
+	"github.com/docker/swarm/garden/kvstore"
 	"github.com/docker/swarm/garden/resource"
 	"github.com/docker/swarm/garden/resource/alloc"
 	"github.com/docker/swarm/garden/resource/alloc/driver"
@@ -28,7 +35,12 @@ import (
 	goctx "golang.org/x/net/context"
 )
 
-const dateLayout = "2006-01-02"
+const (
+	dateLayout = "2006-01-02"
+
+	backupUploadRetries  = 3
+	backupUploadInterval = 30 * time.Second
+)
 
 var (
 	nilGardenCode = errCode{
@@ -124,20 +136,6 @@ func getNFSSPace(ctx goctx.Context, w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	nfs := database.NFS{}
-
-	nfs.Addr = r.FormValue("nfs_ip")
-	nfs.Dir = r.FormValue("nfs_dir")
-	nfs.MountDir = r.FormValue("nfs_mount_dir")
-	nfs.Options = r.FormValue("nfs_mount_opts")
-
-	err := validNFSParams(nfs)
-	if err != nil {
-		ec := errCodeV1(_NFS, invalidParamsError, 12, "URL parameters are invalid", "URL参数校验错误，包含无效参数")
-		httpJSONError(w, err, ec, http.StatusBadRequest)
-		return
-	}
-
 	ok, _, gd := fromContext(ctx, _Garden)
 	if !ok || gd == nil ||
 		gd.Ormer() == nil {
@@ -153,6 +151,37 @@ func getNFSSPace(ctx goctx.Context, w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if sys.BackupTarget.Type == database.BackupTargetS3 {
+		target, err := backup.NewTarget(sys, nil, gd.BackupSecretKey)
+		if err == nil {
+			var space backup.Space
+
+			space, err = target.Space(ctx)
+			if err == nil {
+				writeJSON(w, space, http.StatusOK)
+				return
+			}
+		}
+
+		ec := errCodeV1(_NFS, internalError, 16, "fail to get backup target space info", "备份存储容量信息查询错误")
+		httpJSONError(w, err, ec, http.StatusInternalServerError)
+		return
+	}
+
+	nfs := database.NFS{}
+
+	nfs.Addr = r.FormValue("nfs_ip")
+	nfs.Dir = r.FormValue("nfs_dir")
+	nfs.MountDir = r.FormValue("nfs_mount_dir")
+	nfs.Options = r.FormValue("nfs_mount_opts")
+
+	err = validNFSParams(nfs)
+	if err != nil {
+		ec := errCodeV1(_NFS, invalidParamsError, 12, "URL parameters are invalid", "URL参数校验错误，包含无效参数")
+		httpJSONError(w, err, ec, http.StatusBadRequest)
+		return
+	}
+
 	abs, err := utils.GetAbsolutePath(true, sys.SourceDir)
 	if err != nil {
 		ec := errCodeV1(_NFS, objectNotExist, 14, sys.SourceDir+":dir is not exist", sys.SourceDir+":目录不存在")
@@ -172,6 +201,87 @@ func getNFSSPace(ctx goctx.Context, w http.ResponseWriter, r *http.Request) {
 	writeJSONFprintf(w, http.StatusOK, `{"total_space": %d,"free_space": %d}`, space.Total, space.Free)
 }
 
+func validBackupTarget(t structs.BackupTarget) error {
+	errs := make([]string, 0, 3)
+
+	switch t.Type {
+	case "", database.BackupTargetNFS:
+	case database.BackupTargetS3:
+		if t.Endpoint == "" || t.Bucket == "" {
+			errs = append(errs, "s3:endpoint and bucket are required")
+		}
+
+		if t.AccessKey == "" || t.SecretKey == "" {
+			errs = append(errs, "s3:access key and secret key are required")
+		}
+
+		if t.Quota < 0 {
+			errs = append(errs, fmt.Sprintf("s3:invalid quota %d", t.Quota))
+		}
+	default:
+		errs = append(errs, fmt.Sprintf("unsupported backup target '%s'", t.Type))
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+
+	return fmt.Errorf("backup target:%s,%s", t.Type, errs)
+}
+
+// PUT /nfs_backups/target
+func putBackupTarget(ctx goctx.Context, w http.ResponseWriter, r *http.Request) {
+	req := structs.BackupTarget{}
+
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		ec := errCodeV1(_NFS, decodeError, 21, "JSON Decode Request Body error", "JSON解析请求Body错误")
+		httpJSONError(w, err, ec, http.StatusBadRequest)
+		return
+	}
+
+	if err := validBackupTarget(req); err != nil {
+		ec := errCodeV1(_NFS, invalidParamsError, 22, "Body parameters are invalid", "Body参数校验错误，包含无效参数")
+		httpJSONError(w, err, ec, http.StatusBadRequest)
+		return
+	}
+
+	ok, _, gd := fromContext(ctx, _Garden)
+	if !ok || gd == nil ||
+		gd.Ormer() == nil {
+
+		httpJSONNilGarden(w)
+		return
+	}
+
+	t := database.BackupTarget(req)
+	if t.Type == "" {
+		t.Type = database.BackupTargetNFS
+	}
+
+	if t.Type == database.BackupTargetS3 {
+		// check the bucket is accessible
+		target, err := backup.NewTarget(database.SysConfig{BackupTarget: t}, nil, nil)
+		if err == nil {
+			_, err = target.Space(ctx)
+		}
+		if err != nil {
+			ec := errCodeV1(_NFS, internalError, 23, "fail to access the backup target", "备份存储访问错误")
+			httpJSONError(w, err, ec, http.StatusBadRequest)
+			return
+		}
+	}
+
+	err = gd.SetBackupTarget(t)
+	if err != nil {
+		ec := errCodeV1(_NFS, dbExecError, 24, "fail to update records into database", "数据库更新错误（配置参数表）")
+		httpJSONError(w, err, ec, http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
 // -----------------/tasks handlers-----------------
 func getTask(ctx goctx.Context, w http.ResponseWriter, r *http.Request) {
 	ok, _, gd := fromContext(ctx, _Garden)
@@ -199,6 +309,31 @@ func getTask(ctx goctx.Context, w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, t, http.StatusOK)
 }
 
+func getTaskSteps(ctx goctx.Context, w http.ResponseWriter, r *http.Request) {
+	ok, _, gd := fromContext(ctx, _Garden)
+	if !ok || gd == nil ||
+		gd.Ormer() == nil {
+
+		httpJSONNilGarden(w)
+		return
+	}
+
+	name := mux.Vars(r)["name"]
+
+	steps, err := gd.Ormer().ListTaskSteps(name)
+	if err != nil {
+		ec := errCodeV1(_Task, dbQueryError, 51, "fail to query database", "数据库查询错误（任务步骤表）")
+		httpJSONError(w, err, ec, http.StatusInternalServerError)
+		return
+	}
+
+	if steps == nil {
+		steps = []database.TaskStep{}
+	}
+
+	writeJSON(w, steps, http.StatusOK)
+}
+
 func getTasks(ctx goctx.Context, w http.ResponseWriter, r *http.Request) {
 	if err := r.ParseForm(); err != nil {
 		ec := errCodeV1(_Task, urlParamError, 21, "parse Request URL parameter error", "解析请求URL参数错误")
@@ -249,6 +384,10 @@ func validBackupTaskCallback(bt structs.BackupTaskCallback) error {
 		errs = append(errs, "TaskID is required")
 	}
 
+	if bt.Token == "" {
+		errs = append(errs, "Token is required")
+	}
+
 	if bt.Path == "" {
 		errs = append(errs, "Path is required")
 	}
@@ -284,15 +423,66 @@ func postBackupCallback(ctx goctx.Context, w http.ResponseWriter, r *http.Reques
 	}
 	orm := gd.Ormer()
 	now := time.Now()
-	t := database.Task{}
+
+	c, err := orm.GetBackupCallback(req.TaskID)
+	if err != nil && !database.IsNotFound(err) {
+		ec := errCodeV1(_Task, dbQueryError, 37, "fail to query database", "数据库查询错误（备份回调表）")
+		httpJSONError(w, err, ec, http.StatusInternalServerError)
+		return
+	}
+
+	// the failed callback has no backup file
+	result := req.Path
+	if req.Code != 0 {
+		result = ""
+	}
+
+	repeat := false
+	if err == nil {
+		repeat, err = backup.VerifyCallback(c, req.Token, req.UnitID, result, now)
+	}
+	if err != nil {
+		if errors.Cause(err) == backup.ErrTokenReplayed {
+			ec := errCodeV1(_Task, invalidParamsError, 38, "backup callback token has been used", "备份回调令牌已被使用")
+			httpJSONError(w, err, ec, http.StatusConflict)
+			return
+		}
+
+		ec := errCodeV1(_Task, invalidParamsError, 36, "invalid backup callback token", "备份回调令牌无效")
+		httpJSONError(w, err, ec, http.StatusUnauthorized)
+		return
+	}
+
+	t, err := orm.GetTask(req.TaskID)
+	if err != nil {
+		ec := errCodeV1(_Task, dbQueryError, 39, "fail to query database", "数据库查询错误（任务表）")
+		httpJSONError(w, err, ec, http.StatusInternalServerError)
+		return
+	}
+
+	if !repeat && t.Status != database.TaskRunningStatus {
+		err := errors.Errorf("Task %s is finished,status=%d", t.ID, t.Status)
+		ec := errCodeV1(_Task, invalidParamsError, 40, "backup task is finished or timeout", "备份任务已结束或超时")
+		httpJSONError(w, err, ec, http.StatusConflict)
+		return
+	}
+
 	t.FinishedAt = now
-	t.ID = req.TaskID
+	c.Path = result
+	c.UsedAt = now
 
 	if req.Code != 0 {
 		t.Status = database.TaskFailedStatus
 		t.Errors = req.Msg
 
-		err := orm.SetTask(t)
+		if repeat {
+			err = orm.SetTask(t)
+		} else {
+			err = orm.SetTaskWithBackupCallback(t, c)
+		}
+		if httpJSONBackupCallbackUsed(w, err) {
+			return
+		}
 		if err != nil {
 			ec := errCodeV1(_Task, dbExecError, 33, "fail to exec records into database", "数据库更新错误（任务表）")
 			httpJSONError(w, err, ec, http.StatusInternalServerError)
@@ -321,19 +511,164 @@ func postBackupCallback(ctx goctx.Context, w http.ResponseWriter, r *http.Reques
 		FinishedAt: time.Unix(req.Finished, 0),
 	}
 
+	if repeat {
+		// idempotent update of the backup file recorded by the first callback
+		prev, err := orm.GetBackupFile(c.BackupFileID)
+		if err != nil {
+			ec := errCodeV1(_Task, dbQueryError, 42, "fail to query database", "数据库查询错误（备份文件表）")
+			httpJSONError(w, err, ec, http.StatusInternalServerError)
+			return
+		}
+
+		prev.Tag = bf.Tag
+		prev.Remark = bf.Remark
+		prev.Retention = prev.CreatedAt.AddDate(0, 0, req.Retention)
+		prev.FinishedAt = bf.FinishedAt
+		if !backup.IsRemote(prev.Path) {
+			// the uploaded backup file is unchanged
+			prev.SizeByte = bf.SizeByte
+		}
+
+		bf = prev
+	}
+
+	sys, err := orm.GetSysConfig()
+	if err != nil {
+		ec := errCodeV1(_Task, dbQueryError, 35, "fail to query database", "数据库查询错误（配置参数表）")
+		httpJSONError(w, err, ec, http.StatusInternalServerError)
+		return
+	}
+
+	// the checksum is recorded before the callback answered,the uploaded backup file is unchanged
+	if !repeat || !backup.IsRemote(bf.Path) {
+		bf.Checksum, err = backupFileChecksum(ctx, bf, sys, gd.BackupSecretKey)
+		bf.Verify = database.BackupFileUnverified
+
+		if err != nil {
+			logrus.WithField("BackupFile", bf.Path).Errorf("checksum backup file:%+v", err)
+
+			bf.Verify = database.BackupFileVerifyFailed
+			bf.VerifiedAt = now
+		}
+	}
+
 	t.Status = database.TaskDoneStatus
 	t.SetErrors(nil)
 
-	err = orm.InsertBackupFileWithTask(bf, t)
+	if repeat {
+		err = orm.SetBackupFileWithTask(bf, t)
+	} else {
+		c.BackupFileID = bf.ID
+		err = orm.InsertBackupFileWithCallback(bf, t, c)
+	}
+	if httpJSONBackupCallbackUsed(w, err) {
+		return
+	}
 	if err != nil {
 		ec := errCodeV1(_Task, dbTxError, 34, "fail to exec records in into database in a Tx", "数据库事务处理错误（备份文件表）")
 		httpJSONError(w, err, ec, http.StatusInternalServerError)
 		return
 	}
 
+	if repeat {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	// the backup file failed in checksum is kept in the backup dir
+	if sys.BackupTarget.Type == database.BackupTargetS3 && bf.Verify != database.BackupFileVerifyFailed {
+		go uploadBackupFile(orm, sys, gd.BackupSecretKey, bf)
+	}
+
 	w.WriteHeader(http.StatusCreated)
 }
 
+// httpJSONBackupCallbackUsed writes 409 if the callback token has been used by a concurrent callback,
+// the callback recorded nothing.
+func httpJSONBackupCallbackUsed(w http.ResponseWriter, err error) bool {
+	if errors.Cause(err) != database.ErrBackupCallbackUsed {
+		return false
+	}
+
+	ec := errCodeV1(_Task, invalidParamsError, 43, "backup callback token has been used", "备份回调令牌已被使用")
+	httpJSONError(w, err, ec, http.StatusConflict)
+
+	return true
+}
+
+// uploadBackupFile uploads the backup file to the backup target in the BackupUploadTask,
+// retries backupUploadRetries times,then records the location and removes the local backup file.
+// The backup file is kept in the backup dir if the upload failed.
+func uploadBackupFile(orm database.Ormer, sys database.SysConfig, secret backup.SecretKeyFunc, bf database.BackupFile) {
+	entry := logrus.WithFields(logrus.Fields{
+		"BackupFile": bf.ID,
+		"path":       bf.Path,
+	})
+
+	task := database.NewTask(bf.ID, database.BackupUploadTask, bf.UnitID, "upload "+bf.Path, nil, 3600)
+	if err := orm.InsertTask(task); err != nil {
+		entry.Errorf("upload backup file:%+v", err)
+		return
+	}
+
+	location, err := func() (string, error) {
+		target, err := backup.NewTarget(sys, nil, secret)
+		if err != nil {
+			return "", err
+		}
+
+		local, err := localBackupFile(bf, sys.BackupDir)
+		if err != nil {
+			return "", err
+		}
+
+		var location string
+
+		for i := 0; i < backupUploadRetries; i++ {
+			if i > 0 {
+				entry.Warnf("upload backup file,retry %d:%+v", i, err)
+				time.Sleep(time.Duration(i) * backupUploadInterval)
+			}
+
+			location, err = target.Upload(goctx.Background(), local, backup.Key(bf.UnitID, bf.Path))
+			if err == nil && location == "" {
+				err = errors.Errorf("upload %s:empty location", local)
+			}
+			if err == nil {
+				break
+			}
+		}
+		if err != nil {
+			return "", err
+		}
+
+		err = orm.SetBackupFilePath(bf.ID, location)
+		if err != nil {
+			return "", err
+		}
+
+		out, err := utils.ExecContextTimeout(nil, 0, "sudo rm -rf", local)
+		if err != nil {
+			entry.Warnf("remove local backup file after uploaded:%s,%s", out, err)
+		}
+
+		return location, nil
+	}()
+
+	if err == nil {
+		task.Status = database.TaskDoneStatus
+		entry.WithField("location", location).Info("backup file uploaded")
+	} else {
+		task.Status = database.TaskFailedStatus
+		task.SetErrors(err)
+		entry.Errorf("upload backup file:%+v", err)
+	}
+
+	if _err := orm.SetTask(task); _err != nil {
+		entry.Errorf("record upload task:%+v", _err)
+	}
+}
+
 func setTaskFailed(ctx goctx.Context, w http.ResponseWriter, r *http.Request) {
 	ok, _, gd := fromContext(ctx, _Garden)
 	if !ok || gd == nil {
@@ -447,6 +782,9 @@ func listImages(ctx goctx.Context, w http.ResponseWriter, r *http.Request) {
 			ImageID:  images[i].ImageID,
 			Labels:   images[i].Labels,
 			UploadAt: utils.TimeToString(images[i].UploadAt),
+
+			Deprecated:       images[i].Deprecated,
+			DeprecatedReason: images[i].DeprecatedReason,
 		}
 	}
 
@@ -482,8 +820,19 @@ func validLoadImageRequest(v structs.PostLoadImageRequest) error {
 		errs = append(errs, "ImageVersion is required")
 	}
 
-	if v.Path == "" {
-		errs = append(errs, "Path is required")
+	switch {
+	case v.Path == "" && v.Reference == "":
+		errs = append(errs, "Path or Reference is required")
+	case v.Path != "" && v.Reference != "":
+		errs = append(errs, "either Path or Reference")
+	case v.Reference != "":
+		if _, err := reference.ParseNormalizedNamed(v.Reference); err != nil {
+			errs = append(errs, fmt.Sprintf("invalid Reference:%s", err))
+		}
+
+		if v.Template == nil || v.Template.Content == "" {
+			errs = append(errs, "Template with content is required to import from Reference")
+		}
 	}
 
 	if len(errs) == 0 {
@@ -628,6 +977,9 @@ func getImage(ctx goctx.Context, w http.ResponseWriter, r *http.Request) {
 		ImageID:  im.ImageID,
 		Labels:   im.Labels,
 		UploadAt: utils.TimeToString(im.UploadAt),
+
+		Deprecated:       im.Deprecated,
+		DeprecatedReason: im.DeprecatedReason,
 	}
 
 	t, err := gd.PluginClient().GetImage(ctx, im.Image())
@@ -721,58 +1073,155 @@ func syncImageToEngines(ctx goctx.Context, w http.ResponseWriter, r *http.Reques
 		images = append(images, out[i].Image())
 	}
 
+	engines, err = findEngines(gd, node)
+	if err != nil {
+		ec := errCodeV1(_Image, internalError, 73, "fail to find Engine", "找不到指定Engine")
+		httpJSONError(w, err, ec, http.StatusInternalServerError)
+		return
+	}
+
+	go resource.SyncEnginesImages(engines, images, ormer)
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// findEngines returns the engine of node,all engines if node is empty
+func findEngines(gd *garden.Garden, node string) (map[string]*cluster.Engine, error) {
 	if node == "" {
-		engines = gd.Cluster.ListEngines()
-	} else {
-		n, err := ormer.GetNode(node)
-		if err == nil {
-			node = n.EngineID
-		}
+		return gd.Cluster.ListEngines(), nil
+	}
 
-		e := gd.Cluster.Engine(node)
-		if e == nil {
-			e = gd.Cluster.EngineByAddr(node)
-		}
+	n, err := gd.Ormer().GetNode(node)
+	if err == nil {
+		node = n.EngineID
+	}
 
-		if e != nil {
-			engines = map[string]*cluster.Engine{e.IP: e}
-		} else {
-			ec := errCodeV1(_Image, internalError, 73, "fail to find Engine", "找不到指定Engine")
-			httpJSONError(w, errors.Errorf("not found Engine by '%s',%+v", node, err), ec, http.StatusInternalServerError)
-			return
-		}
+	e := gd.Cluster.Engine(node)
+	if e == nil {
+		e = gd.Cluster.EngineByAddr(node)
 	}
 
-	go resource.SyncEnginesImages(engines, images, ormer)
+	if e == nil {
+		return nil, errors.Errorf("not found Engine by '%s',%+v", node, err)
+	}
 
-	w.WriteHeader(http.StatusOK)
+	return map[string]*cluster.Engine{e.IP: e}, nil
 }
 
-// -----------------/clusters handlers-----------------
-func getClustersByID(ctx goctx.Context, w http.ResponseWriter, r *http.Request) {
-	name := mux.Vars(r)["name"]
+func setImageDeprecated(ctx goctx.Context, w http.ResponseWriter, r *http.Request, deprecated bool) {
+	name := mux.Vars(r)["image"]
+
+	req := structs.ImageDeprecateRequest{}
+
+	if deprecated && r.ContentLength != 0 {
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			ec := errCodeV1(_Image, decodeError, 81, "JSON Decode Request Body error", "JSON解析请求Body错误")
+			httpJSONError(w, err, ec, http.StatusBadRequest)
+			return
+		}
+	}
 
 	ok, _, gd := fromContext(ctx, _Garden)
-	if !ok || gd == nil || gd.Ormer() == nil || gd.Cluster == nil {
+	if !ok || gd == nil ||
+		gd.Ormer() == nil {
 
 		httpJSONNilGarden(w)
 		return
 	}
 
-	orm := gd.Ormer()
-	c, err := orm.GetCluster(name)
+	im, err := gd.Ormer().GetImageVersion(name)
 	if err != nil {
 		if database.IsNotFound(err) {
-			writeJSONNull(w, http.StatusOK)
+			ec := errCodeV1(_Image, objectNotExist, 82, "not found the image", "镜像不存在")
+			httpJSONError(w, err, ec, http.StatusNotFound)
 			return
 		}
 
-		ec := errCodeV1(_Cluster, dbQueryError, 11, "fail to query database", "数据库查询错误（集群表）")
+		ec := errCodeV1(_Image, dbQueryError, 83, "fail to query database", "数据库查询错误（镜像表）")
 		httpJSONError(w, err, ec, http.StatusInternalServerError)
 		return
 	}
 
-	n, err := orm.CountNodeByCluster(c.ID)
+	err = gd.Ormer().SetImageDeprecated(im.ID, deprecated, req.Reason)
+	if err != nil {
+		ec := errCodeV1(_Image, dbExecError, 84, "fail to update records into database", "数据库更新错误（镜像表）")
+		httpJSONError(w, err, ec, http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// PUT /softwares/images/{image}/deprecate
+func putImageDeprecate(ctx goctx.Context, w http.ResponseWriter, r *http.Request) {
+	setImageDeprecated(ctx, w, r, true)
+}
+
+// PUT /softwares/images/{image}/undeprecate
+func putImageUndeprecate(ctx goctx.Context, w http.ResponseWriter, r *http.Request) {
+	setImageDeprecated(ctx, w, r, false)
+}
+
+// POST /softwares/images/gc
+func postImagesGC(ctx goctx.Context, w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		ec := errCodeV1(_Image, urlParamError, 91, "parse Request URL parameter error", "解析请求URL参数错误")
+		httpJSONError(w, err, ec, http.StatusBadRequest)
+		return
+	}
+
+	ok, _, gd := fromContext(ctx, _Garden)
+	if !ok || gd == nil ||
+		gd.Ormer() == nil || gd.Cluster == nil {
+
+		httpJSONNilGarden(w)
+		return
+	}
+
+	engines, err := findEngines(gd, r.FormValue("host"))
+	if err != nil {
+		ec := errCodeV1(_Image, internalError, 92, "fail to find Engine", "找不到指定Engine")
+		httpJSONError(w, err, ec, http.StatusInternalServerError)
+		return
+	}
+
+	out, err := resource.ImagesGC(engines, gd.Ormer(), boolValue(r, "dry_run"))
+	if err != nil {
+		ec := errCodeV1(_Image, internalError, 93, "fail to collect unused images", "清理主机无用镜像错误")
+		httpJSONError(w, err, ec, http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, out, http.StatusOK)
+}
+
+// -----------------/clusters handlers-----------------
+func getClustersByID(ctx goctx.Context, w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+
+	ok, _, gd := fromContext(ctx, _Garden)
+	if !ok || gd == nil || gd.Ormer() == nil || gd.Cluster == nil {
+
+		httpJSONNilGarden(w)
+		return
+	}
+
+	orm := gd.Ormer()
+	c, err := orm.GetCluster(name)
+	if err != nil {
+		if database.IsNotFound(err) {
+			writeJSONNull(w, http.StatusOK)
+			return
+		}
+
+		ec := errCodeV1(_Cluster, dbQueryError, 11, "fail to query database", "数据库查询错误（集群表）")
+		httpJSONError(w, err, ec, http.StatusInternalServerError)
+		return
+	}
+
+	n, err := orm.CountNodeByCluster(c.ID)
 	if err != nil {
 		ec := errCodeV1(_Cluster, dbQueryError, 12, "fail to query database", "数据库查询错误（主机表）")
 		httpJSONError(w, err, ec, http.StatusInternalServerError)
@@ -962,8 +1411,10 @@ func getNodeInfo(ormer database.Ormer, n database.Node, e *cluster.Engine) struc
 		Cluster:       n.ClusterID,
 		Room:          n.Room,
 		Seat:          n.Seat,
+		Labels:        n.TopologyLabels(),
 		MaxContainer:  n.MaxContainer,
 		Enabled:       n.Enabled,
+		Credential:    n.Credential,
 		RegisterAt:    utils.TimeToString(n.RegisterAt),
 		VolumeDrivers: []structs.VolumeDriver{},
 	}
@@ -1052,6 +1503,10 @@ func getNode(ctx goctx.Context, w http.ResponseWriter, r *http.Request) {
 
 	info := getNodeInfo(gd.Ormer(), n, e)
 
+	if h, ok := gd.NodeHealth(n.ID); ok {
+		info.Health = &h
+	}
+
 	writeJSON(w, info, http.StatusOK)
 }
 
@@ -1103,7 +1558,13 @@ func getAllNodes(ctx goctx.Context, w http.ResponseWriter, r *http.Request) {
 
 		go func(n database.Node) {
 
-			ch <- getNodeInfo(ormer, n, engines[n.Addr])
+			info := getNodeInfo(ormer, n, engines[n.Addr])
+
+			if h, ok := gd.NodeHealth(n.ID); ok {
+				info.Health = &h
+			}
+
+			ch <- info
 
 		}(nodes[i])
 	}
@@ -1131,8 +1592,12 @@ func validNodeRequest(node structs.Node) error {
 	}
 
 	// valid ssh config
-	if node.SSHConfig.Username == "" {
-		errs = append(errs, "SSHConfig.Username is required")
+	if node.Credential == "" && node.SSHConfig.Username == "" {
+		errs = append(errs, "SSHConfig.Username or Credential is required")
+	}
+
+	if err := validNodeLabels(node.Labels); err != nil {
+		errs = append(errs, err.Error())
 	}
 
 	if len(errs) == 0 {
@@ -1142,6 +1607,16 @@ func validNodeRequest(node structs.Node) error {
 	return fmt.Errorf("PostNodeRequest:%v,%s", node, errs)
 }
 
+func validNodeLabels(labels map[string]string) error {
+	for k, v := range labels {
+		if strings.TrimSpace(k) == "" || strings.TrimSpace(v) == "" {
+			return fmt.Errorf("label '%s=%s' is invalid,key and value are required", k, v)
+		}
+	}
+
+	return nil
+}
+
 func postNode(ctx goctx.Context, w http.ResponseWriter, r *http.Request) {
 	n := structs.Node{}
 	err := json.NewDecoder(r.Body).Decode(&n)
@@ -1196,6 +1671,55 @@ func postNode(ctx goctx.Context, w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	vaulted := ""
+
+	if n.Credential != "" {
+		c, err := gd.GetCredential(n.Credential)
+		if err != nil {
+			if database.IsNotFound(err) {
+				ec := errCodeV1(_Host, objectNotExist, 42, "not found the host credential", "主机登录凭证不存在")
+				httpJSONError(w, err, ec, http.StatusNotFound)
+				return
+			}
+
+			ec := errCodeV1(_Host, dbQueryError, 39, "fail to query database", "数据库查询错误（凭证表）")
+			httpJSONError(w, err, ec, http.StatusInternalServerError)
+			return
+		}
+
+		n.SSHConfig, err = gd.CredentialSSHConfig(c.ID)
+		if err != nil {
+			ec := errCodeV1(_Host, internalError, 40, "fail to decrypt host credential", "解密主机登录凭证错误")
+			httpJSONError(w, err, ec, http.StatusInternalServerError)
+			return
+		}
+
+		n.Credential = c.ID
+	} else if gd.VaultConfigured() {
+		// the SSHConfig isnot kept in plain,the host logins by the vaulted credential later,
+		// the SSHConfig is used as before if the vault isnot configured
+		n.Credential, err = gd.VaultHostCredential(n.Addr, n.SSHConfig)
+		if err != nil {
+			ec := errCodeV1(_Host, internalError, 43, "fail to vault host credential", "加密保存主机登录凭证错误")
+			httpJSONError(w, err, ec, http.StatusInternalServerError)
+			return
+		}
+
+		vaulted = n.Credential
+	}
+
+	labels := ""
+	if len(n.Labels) > 0 {
+		dat, err := json.Marshal(n.Labels)
+		if err != nil {
+			ec := errCodeV1(_Host, internalError, 105, "JSON Encode host labels error", "主机标签JSON编码错误")
+			httpJSONError(w, err, ec, http.StatusInternalServerError)
+			return
+		}
+
+		labels = string(dat)
+	}
+
 	node := database.Node{
 		ID:           utils.Generate32UUID(),
 		ClusterID:    n.Cluster,
@@ -1203,10 +1727,12 @@ func postNode(ctx goctx.Context, w http.ResponseWriter, r *http.Request) {
 		EngineID:     "",
 		Room:         n.Room,
 		Seat:         n.Seat,
+		Labels:       labels,
 		Storage:      n.Storage,
 		MaxContainer: n.MaxContainer,
 		Status:       0,
 		Enabled:      false,
+		Credential:   n.Credential,
 		NFS: database.NFS{
 			Addr:     n.NFS.Address,
 			Dir:      n.NFS.Dir,
@@ -1218,7 +1744,7 @@ func postNode(ctx goctx.Context, w http.ResponseWriter, r *http.Request) {
 	nodes := resource.NewNodeWithTaskList(1)
 	nodes[0] = resource.NewNodeWithTask(node, n.HDD, n.SSD, n.SSHConfig)
 
-	horus, err := gd.KVClient().GetHorusAddr(ctx)
+	horus, err := kvstore.GetHorusAddr(ctx, gd.KVClient())
 	if err != nil {
 		ec := errCodeV1(_Host, internalError, 37, "fail to query third-part monitor server addr", "获取第三方监控服务地址错误")
 		httpJSONError(w, err, ec, http.StatusInternalServerError)
@@ -1235,6 +1761,13 @@ func postNode(ctx goctx.Context, w http.ResponseWriter, r *http.Request) {
 	master := resource.NewHostManager(orm, gd.Cluster, nodes)
 	err = master.InstallNodes(ctx, horus, gd.KVClient())
 	if err != nil {
+		if vaulted != "" {
+			// kept if referenced by the host
+			if _err := gd.RemoveCredential(vaulted); _err != nil {
+				logrus.WithField("host", n.Addr).Warnf("remove vaulted credential,%+v", _err)
+			}
+		}
+
 		ec := errCodeV1(_Host, internalError, 38, "fail to install host", "主机入库错误")
 		httpJSONError(w, err, ec, http.StatusInternalServerError)
 		return
@@ -1291,6 +1824,167 @@ func putNodeDisable(ctx goctx.Context, w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 }
 
+func putNodeDrain(ctx goctx.Context, w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+
+	var req structs.NodeDrainRequest
+
+	if r.ContentLength != 0 {
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			ec := errCodeV1(_Host, decodeError, 81, "JSON Decode Request Body error", "JSON解析请求Body错误")
+			httpJSONError(w, err, ec, http.StatusBadRequest)
+			return
+		}
+	}
+
+	ok, _, gd := fromContext(ctx, _Garden)
+	if !ok || gd == nil ||
+		gd.Ormer() == nil || gd.Cluster == nil {
+
+		httpJSONNilGarden(w)
+		return
+	}
+
+	// new Context with deadline
+	if deadline, ok := ctx.Deadline(); !ok {
+		ctx = goctx.Background()
+	} else {
+		ctx, _ = goctx.WithDeadline(goctx.Background(), deadline)
+	}
+
+	d := deploy.New(gd)
+
+	id, err := d.DrainNode(ctx, name, req.Candidates, true)
+	if err != nil {
+		ec := errCodeV1(_Host, internalError, 82, "fail to drain host", "主机维护迁移单元错误")
+		httpJSONError(w, err, ec, http.StatusInternalServerError)
+		return
+	}
+
+	writeJSONFprintf(w, http.StatusCreated, "{%q:%q}", "task_id", id)
+}
+
+func postNodeReinstall(ctx goctx.Context, w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+
+	var req structs.NodeReinstallRequest
+
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		ec := errCodeV1(_Host, decodeError, 101, "JSON Decode Request Body error", "JSON解析请求Body错误")
+		httpJSONError(w, err, ec, http.StatusBadRequest)
+		return
+	}
+
+	ok, _, gd := fromContext(ctx, _Garden)
+	if !ok || gd == nil ||
+		gd.Ormer() == nil ||
+		gd.KVClient() == nil {
+		httpJSONNilGarden(w)
+		return
+	}
+
+	if req.Username == "" {
+		// login by the host credential
+		req.SSHConfig, err = gd.NodeSSHConfig(name)
+		if err != nil {
+			ec := errCodeV1(_Host, invalidParamsError, 102, "SSHConfig.Username is required if host has no available credential", "主机没有可用的登录凭证时，SSH用户名不能为空")
+			httpJSONError(w, err, ec, http.StatusBadRequest)
+			return
+		}
+	}
+
+	horus, err := kvstore.GetHorusAddr(ctx, gd.KVClient())
+	if err != nil {
+		ec := errCodeV1(_Host, internalError, 103, "fail to query third-part monitor server addr", "获取第三方监控服务地址错误")
+		httpJSONError(w, err, ec, http.StatusInternalServerError)
+		return
+	}
+
+	// new Context with deadline
+	if deadline, ok := ctx.Deadline(); !ok {
+		ctx = goctx.Background()
+	} else {
+		ctx, _ = goctx.WithDeadline(goctx.Background(), deadline)
+	}
+
+	master := resource.NewHostManager(gd.Ormer(), gd.Cluster, nil)
+
+	id, err := master.ReinstallNode(ctx, horus, name, req, gd.KVClient())
+	if err != nil {
+		ec := errCodeV1(_Host, internalError, 104, "fail to reinstall host", "主机重新安装错误")
+		httpJSONError(w, err, ec, http.StatusInternalServerError)
+		return
+	}
+
+	writeJSONFprintf(w, http.StatusCreated, "{%q:%q}", "task_id", id)
+}
+
+func putNodeUndrain(ctx goctx.Context, w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+
+	ok, _, gd := fromContext(ctx, _Garden)
+	if !ok || gd == nil ||
+		gd.Ormer() == nil {
+
+		httpJSONNilGarden(w)
+		return
+	}
+
+	err := gd.UndrainNode(name)
+	if err != nil {
+		ec := errCodeV1(_Host, dbExecError, 91, "fail to update records into database", "数据库更新记录错误")
+		httpJSONError(w, err, ec, http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// PUT /hosts/{name}/labels
+func putNodeLabels(ctx goctx.Context, w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+
+	req := structs.NodeLabelsRequest{}
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		ec := errCodeV1(_Host, decodeError, 106, "JSON Decode Request Body error", "JSON解析请求Body错误")
+		httpJSONError(w, err, ec, http.StatusBadRequest)
+		return
+	}
+
+	if err := validNodeLabels(req.Labels); err != nil {
+		ec := errCodeV1(_Host, invalidParamsError, 107, "Body parameters are invalid", "Body参数校验错误，包含无效参数")
+		httpJSONError(w, err, ec, http.StatusBadRequest)
+		return
+	}
+
+	ok, _, gd := fromContext(ctx, _Garden)
+	if !ok || gd == nil ||
+		gd.Ormer() == nil {
+
+		httpJSONNilGarden(w)
+		return
+	}
+
+	n, err := gd.Ormer().GetNode(name)
+	if err != nil {
+		ec := errCodeV1(_Host, dbQueryError, 108, "fail to query database", "数据库查询错误（物理主机表）")
+		httpJSONError(w, err, ec, http.StatusInternalServerError)
+		return
+	}
+
+	err = gd.Ormer().SetNodeLabels(n.ID, req.Labels)
+	if err != nil {
+		ec := errCodeV1(_Host, dbExecError, 109, "fail to update records into database", "数据库更新记录错误")
+		httpJSONError(w, err, ec, http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
 func putNodeParam(ctx goctx.Context, w http.ResponseWriter, r *http.Request) {
 	name := mux.Vars(r)["name"]
 
@@ -1367,14 +2061,10 @@ func deleteNode(ctx goctx.Context, w http.ResponseWriter, r *http.Request) {
 	t := intValueOrZero(r, "timeout")
 	timeout := time.Duration(t) * time.Second
 
-	port := r.FormValue("port")
-	username := r.FormValue("username")
-	password := r.FormValue("password")
-
-	if err := validDelNodesRequest(node, username); err != nil {
-		ec := errCodeV1(_Host, invalidParamsError, 72, "URL parameters are invalid", "URL参数校验错误，包含无效参数")
-		httpJSONError(w, err, ec, http.StatusBadRequest)
-		return
+	ssh := structs.SSHConfig{
+		Port:     intValueOrZero(r, "port"),
+		Username: r.FormValue("username"),
+		Password: r.FormValue("password"),
 	}
 
 	ok, _, gd := fromContext(ctx, _Garden)
@@ -1386,7 +2076,23 @@ func deleteNode(ctx goctx.Context, w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	horus, err := gd.KVClient().GetHorusAddr(ctx)
+	if ssh.Username == "" {
+		// login by the host credential
+		config, err := gd.NodeSSHConfig(node)
+		if err == nil {
+			ssh = config
+		} else if !database.IsNotFound(err) {
+			logrus.WithField("host", node).Warnf("host credential,%+v", err)
+		}
+	}
+
+	if err := validDelNodesRequest(node, ssh.Username); err != nil {
+		ec := errCodeV1(_Host, invalidParamsError, 72, "URL parameters are invalid", "URL参数校验错误，包含无效参数")
+		httpJSONError(w, err, ec, http.StatusBadRequest)
+		return
+	}
+
+	horus, err := kvstore.GetHorusAddr(ctx, gd.KVClient())
 	if err != nil {
 		ec := errCodeV1(_Host, internalError, 73, "fail to query third-part monitor server addr", "获取第三方监控服务地址错误")
 		httpJSONError(w, err, ec, http.StatusInternalServerError)
@@ -1394,7 +2100,7 @@ func deleteNode(ctx goctx.Context, w http.ResponseWriter, r *http.Request) {
 	}
 
 	m := resource.NewHostManager(gd.Ormer(), gd.Cluster, nil)
-	err = m.RemoveNode(ctx, port, horus, node, username, password, force, timeout, gd.KVClient())
+	err = m.RemoveNode(ctx, horus, node, ssh, force, timeout, gd.KVClient())
 	if err != nil {
 		ec := errCodeV1(_Host, internalError, 74, "fail to uninstall host agents", "主机出库错误")
 		httpJSONError(w, err, ec, http.StatusInternalServerError)
@@ -1404,9 +2110,189 @@ func deleteNode(ctx goctx.Context, w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
-// -----------------/networkings handlers-----------------
-func vailPostNetworkingRequest(v structs.PostNetworkingRequest) error {
-	errs := make([]string, 0, 5)
+// -----------------/credentials handlers-----------------
+func getCredentials(ctx goctx.Context, w http.ResponseWriter, r *http.Request) {
+	ok, _, gd := fromContext(ctx, _Garden)
+	if !ok || gd == nil ||
+		gd.Ormer() == nil {
+
+		httpJSONNilGarden(w)
+		return
+	}
+
+	out, err := gd.ListCredentials()
+	if err != nil {
+		ec := errCodeV1(_Credential, dbQueryError, 11, "fail to query database", "数据库查询错误（凭证表）")
+		httpJSONError(w, err, ec, http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, out, http.StatusOK)
+}
+
+func getCredential(ctx goctx.Context, w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+
+	ok, _, gd := fromContext(ctx, _Garden)
+	if !ok || gd == nil ||
+		gd.Ormer() == nil {
+
+		httpJSONNilGarden(w)
+		return
+	}
+
+	c, err := gd.GetCredential(name)
+	if err != nil {
+		if database.IsNotFound(err) {
+			writeJSONNull(w, http.StatusOK)
+			return
+		}
+
+		ec := errCodeV1(_Credential, dbQueryError, 21, "fail to query database", "数据库查询错误（凭证表）")
+		httpJSONError(w, err, ec, http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, c, http.StatusOK)
+}
+
+func postCredential(ctx goctx.Context, w http.ResponseWriter, r *http.Request) {
+	req := structs.PostCredentialRequest{}
+
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		ec := errCodeV1(_Credential, decodeError, 31, "JSON Decode Request Body error", "JSON解析请求Body错误")
+		httpJSONError(w, err, ec, http.StatusBadRequest)
+		return
+	}
+
+	ok, _, gd := fromContext(ctx, _Garden)
+	if !ok || gd == nil ||
+		gd.Ormer() == nil {
+
+		httpJSONNilGarden(w)
+		return
+	}
+
+	id, err := gd.CreateCredential(req)
+	if err != nil {
+		ec := errCodeV1(_Credential, invalidParamsError, 32, "fail to create host credential", "创建主机登录凭证错误")
+		httpJSONError(w, err, ec, http.StatusBadRequest)
+		return
+	}
+
+	writeJSONFprintf(w, http.StatusCreated, "{%q:%q}", "id", id)
+}
+
+func putCredential(ctx goctx.Context, w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+	req := structs.PutCredentialRequest{}
+
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		ec := errCodeV1(_Credential, decodeError, 41, "JSON Decode Request Body error", "JSON解析请求Body错误")
+		httpJSONError(w, err, ec, http.StatusBadRequest)
+		return
+	}
+
+	ok, _, gd := fromContext(ctx, _Garden)
+	if !ok || gd == nil ||
+		gd.Ormer() == nil {
+
+		httpJSONNilGarden(w)
+		return
+	}
+
+	err = gd.RotateCredential(name, req)
+	if err != nil {
+		if database.IsNotFound(err) {
+			ec := errCodeV1(_Credential, objectNotExist, 42, "not found the credential", "找不到该主机登录凭证")
+			httpJSONError(w, err, ec, http.StatusNotFound)
+			return
+		}
+
+		ec := errCodeV1(_Credential, internalError, 43, "fail to rotate host credential", "更新主机登录凭证错误")
+		httpJSONError(w, err, ec, http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func putNodeCredential(ctx goctx.Context, w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+
+	var req = struct {
+		Credential string `json:"credential_id"`
+	}{}
+
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		ec := errCodeV1(_Credential, decodeError, 51, "JSON Decode Request Body error", "JSON解析请求Body错误")
+		httpJSONError(w, err, ec, http.StatusBadRequest)
+		return
+	}
+
+	ok, _, gd := fromContext(ctx, _Garden)
+	if !ok || gd == nil ||
+		gd.Ormer() == nil {
+
+		httpJSONNilGarden(w)
+		return
+	}
+
+	n, err := gd.Ormer().GetNode(name)
+	if err != nil {
+		ec := errCodeV1(_Credential, dbQueryError, 52, "fail to query database", "数据库查询错误（物理主机表）")
+		httpJSONError(w, err, ec, http.StatusInternalServerError)
+		return
+	}
+
+	if req.Credential != "" {
+		c, err := gd.GetCredential(req.Credential)
+		if err != nil {
+			ec := errCodeV1(_Credential, dbQueryError, 53, "fail to query database", "数据库查询错误（凭证表）")
+			httpJSONError(w, err, ec, http.StatusInternalServerError)
+			return
+		}
+
+		req.Credential = c.ID
+	}
+
+	err = gd.Ormer().SetNodeCredential(n.ID, req.Credential)
+	if err != nil {
+		ec := errCodeV1(_Credential, dbExecError, 54, "fail to update records into database", "数据库更新记录错误")
+		httpJSONError(w, err, ec, http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func deleteCredential(ctx goctx.Context, w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+
+	ok, _, gd := fromContext(ctx, _Garden)
+	if !ok || gd == nil ||
+		gd.Ormer() == nil {
+
+		httpJSONNilGarden(w)
+		return
+	}
+
+	err := gd.RemoveCredential(name)
+	if err != nil {
+		ec := errCodeV1(_Credential, dbExecError, 61, "fail to remove host credential", "删除主机登录凭证错误")
+		httpJSONError(w, err, ec, http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// -----------------/networkings handlers-----------------
+func vailPostNetworkingRequest(v structs.PostNetworkingRequest) error {
+	errs := make([]string, 0, 5)
 
 	if v.Prefix < 0 || v.Prefix > 32 {
 		errs = append(errs, fmt.Sprintf("illegal Prefix:%d not in 1~32", v.Prefix))
@@ -1601,6 +2487,7 @@ loop:
 					Engine:    list[i].Engine,
 					Bond:      list[i].Bond,
 					Bandwidth: list[i].Bandwidth,
+					Reserved:  list[i].Reserved,
 				})
 
 				continue loop
@@ -1622,6 +2509,7 @@ loop:
 			Engine:    list[i].Engine,
 			Bond:      list[i].Bond,
 			Bandwidth: list[i].Bandwidth,
+			Reserved:  list[i].Reserved,
 		}
 
 		nws = append(nws, nw)
@@ -1631,6 +2519,12 @@ loop:
 }
 
 func listNetworkings(ctx goctx.Context, w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		ec := errCodeV1(_Networking, urlParamError, 52, "parse Request URL parameter error", "解析请求URL参数错误")
+		httpJSONError(w, err, ec, http.StatusBadRequest)
+		return
+	}
+
 	ok, _, gd := fromContext(ctx, _Garden)
 	if !ok || gd == nil ||
 		gd.Ormer() == nil {
@@ -1639,16 +2533,28 @@ func listNetworkings(ctx goctx.Context, w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	out, err := gd.Ormer().ListIPs()
+	if !boolValue(r, "usage") {
+		out, err := gd.Ormer().ListIPs()
+		if err != nil {
+			ec := errCodeV1(_Networking, dbQueryError, 51, "fail to query database", "数据库查询错误（网络IP表）")
+			httpJSONError(w, err, ec, http.StatusInternalServerError)
+			return
+		}
+
+		nws := convertNetworking(out)
+
+		writeJSON(w, nws, http.StatusOK)
+		return
+	}
+
+	out, err := resource.NewNetworks(gd.Ormer()).Usage("", intValueOrZero(r, "threshold"))
 	if err != nil {
-		ec := errCodeV1(_Networking, dbQueryError, 51, "fail to query database", "数据库查询错误（网络IP表）")
+		ec := errCodeV1(_Networking, dbQueryError, 53, "fail to query database", "数据库查询错误（网络IP表）")
 		httpJSONError(w, err, ec, http.StatusInternalServerError)
 		return
 	}
 
-	nws := convertNetworking(out)
-
-	writeJSON(w, nws, http.StatusOK)
+	writeJSON(w, out, http.StatusOK)
 }
 
 func getNetworking(ctx goctx.Context, w http.ResponseWriter, r *http.Request) {
@@ -1685,113 +2591,236 @@ func getNetworking(ctx goctx.Context, w http.ResponseWriter, r *http.Request) {
 	writeJSONNull(w, http.StatusOK)
 }
 
-// -----------------/services handlers-----------------
-func getServices(ctx goctx.Context, w http.ResponseWriter, r *http.Request) {
+func getNetworkingUsage(ctx goctx.Context, w http.ResponseWriter, r *http.Request) {
 	if err := r.ParseForm(); err != nil {
-		ec := errCodeV1(_Service, urlParamError, 11, "parse Request URL parameter error", "解析请求URL参数错误")
+		ec := errCodeV1(_Networking, urlParamError, 71, "parse Request URL parameter error", "解析请求URL参数错误")
 		httpJSONError(w, err, ec, http.StatusBadRequest)
 		return
 	}
 
+	name := mux.Vars(r)["name"]
+
 	ok, _, gd := fromContext(ctx, _Garden)
-	if !ok || gd == nil || gd.Ormer() == nil || gd.Cluster == nil {
+	if !ok || gd == nil ||
+		gd.Ormer() == nil {
+
 		httpJSONNilGarden(w)
 		return
 	}
 
-	services, err := gd.ListServices(ctx)
+	out, err := resource.NewNetworks(gd.Ormer()).Usage(name, intValueOrZero(r, "threshold"))
 	if err != nil {
-		ec := errCodeV1(_Service, dbQueryError, 12, "fail to query database", "数据库查询错误（服务表）")
+		ec := errCodeV1(_Networking, dbQueryError, 72, "fail to query database", "数据库查询错误（网络IP表）")
 		httpJSONError(w, err, ec, http.StatusInternalServerError)
 		return
 	}
 
-	if len(services) == 0 {
-		writeJSON(w, services, http.StatusOK)
+	if len(out) == 0 {
+		ec := errCodeV1(_Networking, objectNotExist, 73, fmt.Sprintf("networking %s is not exist", name), fmt.Sprintf("网络集群(%s)不存在", name))
+		httpJSONError(w, stderr.New(ec.comment), ec, http.StatusNotFound)
 		return
 	}
 
-	var out []structs.ServiceSpec
-	name := r.FormValue("image_name")
-	tag := r.FormValue("tag")
+	writeJSON(w, out[0], http.StatusOK)
+}
 
-	if name == "" && tag == "" {
-		out = services
-	} else {
-		out = make([]structs.ServiceSpec, 0, len(services))
+func validReserveIPRequest(v structs.ReserveIPRequest) error {
+	errs := make([]string, 0, 2)
 
-		if name == "" && tag != "" {
-			for k := range services {
-				if services[k].Tag == tag {
-					out = append(out, services[k])
-				}
-			}
-		} else {
+	if v.Service == "" {
+		errs = append(errs, "service is required")
+	}
 
-			images := strings.Split(name, ",")
-		loop:
-			for k := range services {
-				if tag != "" && services[k].Tag != tag {
-					continue
-				}
+	if len(v.IPs) == 0 {
+		errs = append(errs, "IPs is required")
+	}
 
-				for i := range images {
-					if services[k].Image.Name == images[i] {
-						out = append(out, services[k])
-						continue loop
-					}
-				}
-			}
+	for i := range v.IPs {
+		if ip := net.ParseIP(v.IPs[i]); ip == nil {
+			errs = append(errs, fmt.Sprintf("illegal IP:'%s' error", v.IPs[i]))
 		}
 	}
 
-	bfs, err := gd.Ormer().ListBackupFiles()
-	if err != nil {
-		ec := errCodeV1(_Service, dbQueryError, 13, "fail to query database", "数据库查询错误（备份表）")
-		httpJSONError(w, err, ec, http.StatusInternalServerError)
-		return
+	if len(errs) == 0 {
+		return nil
 	}
 
-	resp := make([]structs.ServiceResponse, 0, len(out))
+	return fmt.Errorf("ReserveIPRequest:%v,%s", v, errs)
+}
 
-	for i := range out {
-		sum := 0
-		for j := range bfs {
+func putNetworkingReserve(ctx goctx.Context, w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
 
-		units:
-			for k := range out[i].Units {
-				if bfs[j].UnitID == out[i].Units[k].ID {
-					sum += bfs[j].SizeByte
-					break units
-				}
-			}
-		}
+	var req structs.ReserveIPRequest
 
-		resp = append(resp, structs.ServiceResponse{
-			ServiceSpec:   out[i],
-			BuckupFileSum: sum,
-		})
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		ec := errCodeV1(_Networking, decodeError, 81, "JSON Decode Request Body error", "JSON解析请求Body错误")
+		httpJSONError(w, err, ec, http.StatusBadRequest)
+		return
 	}
 
-	writeJSON(w, resp, http.StatusOK)
-}
-
-func getServicesByNameOrID(ctx goctx.Context, w http.ResponseWriter, r *http.Request) {
-	name := mux.Vars(r)["name"]
+	if err := validReserveIPRequest(req); err != nil {
+		ec := errCodeV1(_Networking, invalidParamsError, 82, "Body parameters are invalid", "Body参数校验错误，包含无效参数")
+		httpJSONError(w, err, ec, http.StatusBadRequest)
+		return
+	}
 
 	ok, _, gd := fromContext(ctx, _Garden)
-	if !ok || gd == nil || gd.Ormer() == nil || gd.Cluster == nil {
+	if !ok || gd == nil ||
+		gd.Ormer() == nil {
+
 		httpJSONNilGarden(w)
 		return
 	}
 
-	spec, err := gd.ServiceSpec(name)
+	err = resource.NewNetworks(gd.Ormer()).ReserveIPs(name, req.Service, req.IPs)
 	if err != nil {
-		if database.IsNotFound(err) {
-			writeJSONNull(w, http.StatusOK)
-			return
-		}
-
+		ec := errCodeV1(_Networking, dbTxError, 83, "fail to reserve IPs", "预留IP错误")
+		httpJSONError(w, err, ec, http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func putNetworkingUnreserve(ctx goctx.Context, w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+
+	var body []string
+
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		ec := errCodeV1(_Networking, decodeError, 91, "JSON Decode Request Body error", "JSON解析请求Body错误")
+		httpJSONError(w, err, ec, http.StatusBadRequest)
+		return
+	}
+
+	ok, _, gd := fromContext(ctx, _Garden)
+	if !ok || gd == nil ||
+		gd.Ormer() == nil {
+
+		httpJSONNilGarden(w)
+		return
+	}
+
+	err = resource.NewNetworks(gd.Ormer()).ReserveIPs(name, "", body)
+	if err != nil {
+		ec := errCodeV1(_Networking, dbTxError, 92, "fail to release reserved IPs", "释放预留IP错误")
+		httpJSONError(w, err, ec, http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// -----------------/services handlers-----------------
+func getServices(ctx goctx.Context, w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		ec := errCodeV1(_Service, urlParamError, 11, "parse Request URL parameter error", "解析请求URL参数错误")
+		httpJSONError(w, err, ec, http.StatusBadRequest)
+		return
+	}
+
+	ok, _, gd := fromContext(ctx, _Garden)
+	if !ok || gd == nil || gd.Ormer() == nil || gd.Cluster == nil {
+		httpJSONNilGarden(w)
+		return
+	}
+
+	services, err := gd.ListServices(ctx)
+	if err != nil {
+		ec := errCodeV1(_Service, dbQueryError, 12, "fail to query database", "数据库查询错误（服务表）")
+		httpJSONError(w, err, ec, http.StatusInternalServerError)
+		return
+	}
+
+	if len(services) == 0 {
+		writeJSON(w, services, http.StatusOK)
+		return
+	}
+
+	var out []structs.ServiceSpec
+	name := r.FormValue("image_name")
+	tag := r.FormValue("tag")
+
+	if name == "" && tag == "" {
+		out = services
+	} else {
+		out = make([]structs.ServiceSpec, 0, len(services))
+
+		if name == "" && tag != "" {
+			for k := range services {
+				if services[k].Tag == tag {
+					out = append(out, services[k])
+				}
+			}
+		} else {
+
+			images := strings.Split(name, ",")
+		loop:
+			for k := range services {
+				if tag != "" && services[k].Tag != tag {
+					continue
+				}
+
+				for i := range images {
+					if services[k].Image.Name == images[i] {
+						out = append(out, services[k])
+						continue loop
+					}
+				}
+			}
+		}
+	}
+
+	bfs, err := gd.Ormer().ListBackupFiles()
+	if err != nil {
+		ec := errCodeV1(_Service, dbQueryError, 13, "fail to query database", "数据库查询错误（备份表）")
+		httpJSONError(w, err, ec, http.StatusInternalServerError)
+		return
+	}
+
+	resp := make([]structs.ServiceResponse, 0, len(out))
+
+	for i := range out {
+		sum := 0
+		for j := range bfs {
+
+		units:
+			for k := range out[i].Units {
+				if bfs[j].UnitID == out[i].Units[k].ID {
+					sum += bfs[j].SizeByte
+					break units
+				}
+			}
+		}
+
+		resp = append(resp, structs.ServiceResponse{
+			ServiceSpec:   out[i],
+			BuckupFileSum: sum,
+		})
+	}
+
+	writeJSON(w, resp, http.StatusOK)
+}
+
+func getServicesByNameOrID(ctx goctx.Context, w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+
+	ok, _, gd := fromContext(ctx, _Garden)
+	if !ok || gd == nil || gd.Ormer() == nil || gd.Cluster == nil {
+		httpJSONNilGarden(w)
+		return
+	}
+
+	spec, err := gd.ServiceSpec(name)
+	if err != nil {
+		if database.IsNotFound(err) {
+			writeJSONNull(w, http.StatusOK)
+			return
+		}
+
 		ec := errCodeV1(_Service, dbQueryError, 21, "fail to query database", "数据库查询错误（服务表）")
 		httpJSONError(w, err, ec, http.StatusInternalServerError)
 		return
@@ -1845,10 +2874,33 @@ func validPostServiceRequest(spec structs.ServiceSpec) error {
 		errs = append(errs, "networkings is required")
 	}
 
+	if spec.Require != nil {
+		if err := spec.Require.Policy.Valid(); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+
 	if len(spec.Clusters) == 0 {
 		errs = append(errs, "clusters is required")
 	}
 
+	for i := range spec.Spread {
+		switch r := spec.Spread[i]; {
+		case strings.TrimSpace(r.Label) == "" || r.Max < 0:
+			errs = append(errs, fmt.Sprintf("spread rule invalid,%+v", r))
+
+		case r.Masters:
+			// compose elects the masters after deployed,the masters cannot be spread on deploy and scale
+			errs = append(errs, fmt.Sprintf("spread rule of masters is unsupported,%+v", r))
+		}
+	}
+
+	for i := range spec.Affinities {
+		if r := spec.Affinities[i]; r.Service == "" || r.Service == spec.Name || (spec.ID != "" && r.Service == spec.ID) {
+			errs = append(errs, fmt.Sprintf("affinity rule invalid,%+v", r))
+		}
+	}
+
 	if len(errs) == 0 {
 		return nil
 	}
@@ -1900,6 +2952,18 @@ func postService(ctx goctx.Context, w http.ResponseWriter, r *http.Request) {
 		ctx, _ = goctx.WithDeadline(goctx.Background(), deadline)
 	}
 
+	if err := affinityServicesExist(gd.Ormer(), spec.Affinities); err != nil {
+		if database.IsNotFound(err) {
+			ec := errCodeV1(_Service, invalidParamsError, 35, "not found the service of affinity rule", "亲和性规则的服务不存在")
+			httpJSONError(w, err, ec, http.StatusBadRequest)
+			return
+		}
+
+		ec := errCodeV1(_Service, dbQueryError, 36, "fail to query database", "数据库查询错误（服务表）")
+		httpJSONError(w, err, ec, http.StatusInternalServerError)
+		return
+	}
+
 	d := deploy.New(gd)
 
 	out, err := d.Deploy(ctx, spec, compose)
@@ -1912,6 +2976,17 @@ func postService(ctx goctx.Context, w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, out, http.StatusCreated)
 }
 
+// affinityServicesExist returns error if the service of any affinity rule is not found.
+func affinityServicesExist(iface database.ServiceIface, rules []structs.AffinityRule) error {
+	for _, r := range rules {
+		if _, err := iface.GetService(r.Service); err != nil {
+			return errors.WithMessage(err, "affinity rule service "+r.Service)
+		}
+	}
+
+	return nil
+}
+
 func validPostServiceScaledRequest(v structs.ServiceScaleRequest) error {
 	errs := make([]string, 0, 2)
 
@@ -1978,6 +3053,56 @@ func postServiceScaled(ctx goctx.Context, w http.ResponseWriter, r *http.Request
 	writeJSON(w, resp, http.StatusCreated)
 }
 
+func postServiceSwitchover(ctx goctx.Context, w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+
+	req := structs.ServiceSwitchoverRequest{}
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		ec := errCodeV1(_Service, decodeError, 181, "JSON Decode Request Body error", "JSON解析请求Body错误")
+		httpJSONError(w, err, ec, http.StatusBadRequest)
+		return
+	}
+
+	if req.MaxLag < 0 {
+		err = fmt.Errorf("invalid params,max_lag:%d", req.MaxLag)
+	} else if req.Links != nil {
+		err = validPostServiceLinkRequest(*req.Links)
+	}
+	if err != nil {
+		ec := errCodeV1(_Service, invalidParamsError, 182, "Body parameters are invalid", "Body参数校验错误，包含无效参数")
+		httpJSONError(w, err, ec, http.StatusBadRequest)
+		return
+	}
+
+	ok, _, gd := fromContext(ctx, _Garden)
+	if !ok || gd == nil ||
+		gd.Ormer() == nil ||
+		gd.PluginClient() == nil {
+
+		httpJSONNilGarden(w)
+		return
+	}
+
+	// new Context with deadline
+	if deadline, ok := ctx.Deadline(); !ok {
+		ctx = goctx.Background()
+	} else {
+		ctx, _ = goctx.WithDeadline(goctx.Background(), deadline)
+	}
+
+	d := deploy.New(gd)
+
+	id, err := d.Switchover(ctx, name, req)
+	if err != nil {
+		ec := errCodeV1(_Service, internalError, 183, "fail to switchover service", "服务主从切换错误")
+		httpJSONError(w, err, ec, http.StatusInternalServerError)
+		return
+	}
+
+	writeJSONFprintf(w, http.StatusCreated, "{%q:%q}", "task_id", id)
+}
+
 func validPostServiceLinkRequest(v structs.ServicesLink) error {
 	if v.Len() == 0 {
 		return fmt.Errorf("invalid params")
@@ -1998,7 +3123,14 @@ func validPostServiceLinkRequest(v structs.ServicesLink) error {
 	return fmt.Errorf("ServicesLink:%v,%s", v, errs)
 }
 
+// POST /services/link?record=
 func postServiceLink(ctx goctx.Context, w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		ec := errCodeV1(_Service, urlParamError, 54, "parse Request URL parameter error", "解析请求URL参数错误")
+		httpJSONError(w, err, ec, http.StatusBadRequest)
+		return
+	}
+
 	links := structs.ServicesLink{}
 	err := json.NewDecoder(r.Body).Decode(&links)
 	if err != nil {
@@ -2032,6 +3164,25 @@ func postServiceLink(ctx goctx.Context, w http.ResponseWriter, r *http.Request)
 
 	d := deploy.New(gd)
 
+	// backfill the definitions of the links deployed before
+	if boolValue(r, "record") {
+		err := d.RecordLink(ctx, links)
+		if err != nil {
+			if database.IsNotFound(err) {
+				ec := errCodeV1(_Service, objectNotExist, 55, "not found the service", "服务不存在")
+				httpJSONError(w, err, ec, http.StatusNotFound)
+				return
+			}
+
+			ec := errCodeV1(_Service, internalError, 56, "fail to record services link", "记录服务关联错误")
+			httpJSONError(w, err, ec, http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
 	// task ID
 	id, err := d.Link(ctx, links)
 	if err != nil {
@@ -2071,6 +3222,11 @@ func postServiceVersionUpdate(ctx goctx.Context, w http.ResponseWriter, r *http.
 	d := deploy.New(gd)
 
 	id, err := d.ServiceUpdateImage(ctx, name, version, true)
+	if errors.Cause(err) == structs.ErrUnsupportedUpgrade {
+		ec := errCodeV1(_Service, invalidParamsError, 63, "unsupported image upgrade path", "不支持的镜像版本升级路径")
+		httpJSONError(w, err, ec, http.StatusBadRequest)
+		return
+	}
 	if err != nil {
 		ec := errCodeV1(_Service, internalError, 62, "fail to update service units image version", "服务容器镜像版本升级错误")
 		httpJSONError(w, err, ec, http.StatusInternalServerError)
@@ -2442,153 +3598,763 @@ func postServiceExec(ctx goctx.Context, w http.ResponseWriter, r *http.Request)
 	writeJSONFprintf(w, http.StatusCreated, "{%q:%q}", "task_id", task.ID)
 }
 
-func postServiceStop(ctx goctx.Context, w http.ResponseWriter, r *http.Request) {
-	if err := r.ParseForm(); err != nil {
-		ec := errCodeV1(_Service, urlParamError, 111, "parse Request URL parameter error", "解析请求URL参数错误")
+// PUT /services/{name}/exec_policy
+func putServiceExecPolicy(ctx goctx.Context, w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+
+	policy := &structs.ExecPolicy{}
+	err := json.NewDecoder(r.Body).Decode(policy)
+	if err != nil {
+		ec := errCodeV1(_Service, decodeError, 241, "JSON Decode Request Body error", "JSON解析请求Body错误")
 		httpJSONError(w, err, ec, http.StatusBadRequest)
 		return
 	}
 
-	name := mux.Vars(r)["name"]
-	unit := r.FormValue("unit")
+	// empty commands without logs deny all exec sessions and logs
+	if len(policy.Cmds) == 0 && !policy.Logs {
+		policy = nil
+	}
 
 	ok, _, gd := fromContext(ctx, _Garden)
-	if !ok || gd == nil ||
-		gd.Ormer() == nil {
-
+	if !ok || gd == nil || gd.Ormer() == nil {
 		httpJSONNilGarden(w)
 		return
 	}
 
-	svc, err := gd.Service(name)
+	err = gd.SetExecPolicy(name, policy)
 	if err != nil {
-		ec := errCodeV1(_Service, dbQueryError, 112, "fail to query database", "数据库查询错误（服务表）")
+		if database.IsNotFound(err) {
+			ec := errCodeV1(_Service, objectNotExist, 242, "not found the service", "服务不存在")
+			httpJSONError(w, err, ec, http.StatusNotFound)
+			return
+		}
+
+		ec := errCodeV1(_Service, dbExecError, 243, "fail to update the service exec policy", "更新服务交互式会话策略错误")
 		httpJSONError(w, err, ec, http.StatusInternalServerError)
 		return
 	}
 
-	// new Context with deadline
-	if deadline, ok := ctx.Deadline(); !ok {
-		ctx = goctx.Background()
-	} else {
-		ctx, _ = goctx.WithDeadline(goctx.Background(), deadline)
-	}
+	w.WriteHeader(http.StatusOK)
+}
 
-	task := database.NewTask(svc.Name(), database.ServiceStopTask, svc.ID(), "", nil, 300)
+// POST /services/{name}/units/{unit}/exec
+func postUnitExecSession(ctx goctx.Context, w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+	unit := mux.Vars(r)["unit"]
 
-	err = svc.Stop(ctx, unit, true, true, &task)
+	config := structs.UnitExecConfig{}
+	err := json.NewDecoder(r.Body).Decode(&config)
 	if err != nil {
-		ec := errCodeV1(_Service, internalError, 113, "fail to stop service", "服务关闭错误")
+		ec := errCodeV1(_Unit, decodeError, 21, "JSON Decode Request Body error", "JSON解析请求Body错误")
+		httpJSONError(w, err, ec, http.StatusBadRequest)
+		return
+	}
+
+	if len(config.Cmd) == 0 {
+		ec := errCodeV1(_Unit, invalidParamsError, 22, "Body parameters are invalid,cmd is required", "Body参数校验错误，cmd不能为空")
+		httpJSONError(w, stderr.New(ec.comment), ec, http.StatusBadRequest)
+		return
+	}
+
+	ok, _, gd := fromContext(ctx, _Garden)
+	if !ok || gd == nil ||
+		gd.Ormer() == nil || gd.Cluster == nil {
+
+		httpJSONNilGarden(w)
+		return
+	}
+
+	session, err := gd.CreateExecSession(ctx, name, unit, config)
+	if err != nil {
+		if database.IsNotFound(err) {
+			ec := errCodeV1(_Unit, objectNotExist, 23, "not found the service or unit", "服务或单元不存在")
+			httpJSONError(w, err, ec, http.StatusNotFound)
+			return
+		}
+
+		if errors.Cause(err) == garden.ErrExecNotAllowed {
+			ec := errCodeV1(_Unit, invalidParamsError, 24, "exec is not allowed by the service exec policy", "服务的交互式会话策略不允许执行")
+			httpJSONError(w, err, ec, http.StatusForbidden)
+			return
+		}
+
+		ec := errCodeV1(_Unit, internalError, 25, "fail to create exec session in unit container", "创建单元容器交互式会话错误")
+		httpJSONError(w, err, ec, http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, session, http.StatusCreated)
+}
+
+// POST /services/{name}/exec/{id}/start
+// the exec session token is in the header X-Exec-Session-Token
+func postExecSessionStart(ctx goctx.Context, w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		ec := errCodeV1(_Unit, urlParamError, 31, "parse Request URL parameter error", "解析请求URL参数错误")
+		httpJSONError(w, err, ec, http.StatusBadRequest)
+		return
+	}
+
+	name := mux.Vars(r)["name"]
+	id := mux.Vars(r)["id"]
+
+	ok, _, gd := fromContext(ctx, _Garden)
+	if !ok || gd == nil ||
+		gd.Ormer() == nil || gd.Cluster == nil {
+
+		httpJSONNilGarden(w)
+		return
+	}
+
+	resp, _, err := gd.StartExecSession(ctx, name, id, r.Header.Get("X-Exec-Session-Token"))
+	if err != nil {
+		switch {
+		case database.IsNotFound(err) || errors.Cause(err) == garden.ErrExecSessionNotFound:
+			ec := errCodeV1(_Unit, objectNotExist, 32, "not found the exec session of the service", "服务的交互式会话不存在")
+			httpJSONError(w, err, ec, http.StatusNotFound)
+		case errors.Cause(err) == garden.ErrExecSessionToken:
+			ec := errCodeV1(_Unit, invalidParamsError, 33, "invalid exec session token", "交互式会话令牌无效")
+			httpJSONError(w, err, ec, http.StatusUnauthorized)
+		case errors.Cause(err) == garden.ErrExecSessionStarted:
+			ec := errCodeV1(_Unit, invalidParamsError, 34, "exec session has been started", "交互式会话已启动")
+			httpJSONError(w, err, ec, http.StatusConflict)
+		default:
+			ec := errCodeV1(_Unit, internalError, 35, "fail to start exec session in unit container", "启动单元容器交互式会话错误")
+			httpJSONError(w, err, ec, http.StatusInternalServerError)
+		}
+		return
+	}
+	defer gd.CloseExecSession(id)
+	defer resp.Close()
+
+	err = holdExecSession(w, r, resp)
+	if err != nil {
+		logrus.WithField("exec", id).Errorf("hold exec session,%+v", err)
+	}
+}
+
+// POST /services/{name}/exec/{id}/resize?h=&w=
+// the exec session token is in the header X-Exec-Session-Token
+func postExecSessionResize(ctx goctx.Context, w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		ec := errCodeV1(_Unit, urlParamError, 41, "parse Request URL parameter error", "解析请求URL参数错误")
+		httpJSONError(w, err, ec, http.StatusBadRequest)
+		return
+	}
+
+	name := mux.Vars(r)["name"]
+	id := mux.Vars(r)["id"]
+	height := intValueOrZero(r, "h")
+	width := intValueOrZero(r, "w")
+
+	if height <= 0 || width <= 0 {
+		ec := errCodeV1(_Unit, invalidParamsError, 42, "URL parameters are invalid,h and w must be positive", "URL参数校验错误，h和w必须大于0")
+		httpJSONError(w, stderr.New(ec.comment), ec, http.StatusBadRequest)
+		return
+	}
+
+	ok, _, gd := fromContext(ctx, _Garden)
+	if !ok || gd == nil ||
+		gd.Ormer() == nil || gd.Cluster == nil {
+
+		httpJSONNilGarden(w)
+		return
+	}
+
+	err := gd.ResizeExecSession(ctx, name, id, r.Header.Get("X-Exec-Session-Token"), uint(height), uint(width))
+	if err != nil {
+		switch {
+		case database.IsNotFound(err) || errors.Cause(err) == garden.ErrExecSessionNotFound:
+			ec := errCodeV1(_Unit, objectNotExist, 43, "not found the exec session of the service", "服务的交互式会话不存在")
+			httpJSONError(w, err, ec, http.StatusNotFound)
+		case errors.Cause(err) == garden.ErrExecSessionToken:
+			ec := errCodeV1(_Unit, invalidParamsError, 44, "invalid exec session token", "交互式会话令牌无效")
+			httpJSONError(w, err, ec, http.StatusUnauthorized)
+		default:
+			ec := errCodeV1(_Unit, internalError, 45, "fail to resize exec session tty", "调整交互式会话终端大小错误")
+			httpJSONError(w, err, ec, http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// GET /services/{name}/units/{unit}/logs?follow=&tail=&since=&timestamps=&stdout=&stderr=
+// logs are allowed by the exec policy of the service
+func getUnitLogs(ctx goctx.Context, w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		ec := errCodeV1(_Unit, urlParamError, 51, "parse Request URL parameter error", "解析请求URL参数错误")
+		httpJSONError(w, err, ec, http.StatusBadRequest)
+		return
+	}
+
+	name := mux.Vars(r)["name"]
+	unit := mux.Vars(r)["unit"]
+
+	options := types.ContainerLogsOptions{
+		ShowStdout: boolValue(r, "stdout"),
+		ShowStderr: boolValue(r, "stderr"),
+		Since:      r.FormValue("since"),
+		Timestamps: boolValue(r, "timestamps"),
+		Follow:     boolValue(r, "follow"),
+		Tail:       r.FormValue("tail"),
+	}
+	if !options.ShowStdout && !options.ShowStderr {
+		options.ShowStdout, options.ShowStderr = true, true
+	}
+
+	ok, _, gd := fromContext(ctx, _Garden)
+	if !ok || gd == nil ||
+		gd.Ormer() == nil || gd.Cluster == nil {
+
+		httpJSONNilGarden(w)
+		return
+	}
+
+	svc, err := gd.Service(name)
+	if err != nil {
+		if database.IsNotFound(err) {
+			ec := errCodeV1(_Unit, objectNotExist, 52, "not found the service", "服务不存在")
+			httpJSONError(w, err, ec, http.StatusNotFound)
+			return
+		}
+
+		ec := errCodeV1(_Unit, dbQueryError, 53, "fail to query database", "数据库查询错误（服务表）")
+		httpJSONError(w, err, ec, http.StatusInternalServerError)
+		return
+	}
+
+	rc, tty, err := svc.UnitLogs(ctx, unit, options)
+	if err != nil {
+		if database.IsNotFound(err) {
+			ec := errCodeV1(_Unit, objectNotExist, 54, "not found the unit", "单元不存在")
+			httpJSONError(w, err, ec, http.StatusNotFound)
+			return
+		}
+
+		if errors.Cause(err) == garden.ErrExecNotAllowed {
+			ec := errCodeV1(_Unit, invalidParamsError, 55, "logs is not allowed by the service exec policy", "服务的交互式会话策略不允许查看日志")
+			httpJSONError(w, err, ec, http.StatusForbidden)
+			return
+		}
+
+		ec := errCodeV1(_Unit, internalError, 56, "fail to get unit container logs", "获取单元容器日志错误")
+		httpJSONError(w, err, ec, http.StatusInternalServerError)
+		return
+	}
+	defer rc.Close()
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+
+	wf := NewWriteFlusher(w)
+
+	if tty {
+		_, err = io.Copy(wf, rc)
+	} else {
+		_, err = stdcopy.StdCopy(wf, wf, rc)
+	}
+	if err != nil && err != io.EOF {
+		logrus.WithField("unit", unit).Debugf("stream unit logs,%s", err)
+	}
+}
+
+func postServiceStop(ctx goctx.Context, w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		ec := errCodeV1(_Service, urlParamError, 111, "parse Request URL parameter error", "解析请求URL参数错误")
+		httpJSONError(w, err, ec, http.StatusBadRequest)
+		return
+	}
+
+	name := mux.Vars(r)["name"]
+	unit := r.FormValue("unit")
+
+	ok, _, gd := fromContext(ctx, _Garden)
+	if !ok || gd == nil ||
+		gd.Ormer() == nil {
+
+		httpJSONNilGarden(w)
+		return
+	}
+
+	svc, err := gd.Service(name)
+	if err != nil {
+		ec := errCodeV1(_Service, dbQueryError, 112, "fail to query database", "数据库查询错误（服务表）")
+		httpJSONError(w, err, ec, http.StatusInternalServerError)
+		return
+	}
+
+	// new Context with deadline
+	if deadline, ok := ctx.Deadline(); !ok {
+		ctx = goctx.Background()
+	} else {
+		ctx, _ = goctx.WithDeadline(goctx.Background(), deadline)
+	}
+
+	task := database.NewTask(svc.Name(), database.ServiceStopTask, svc.ID(), "", nil, 300)
+
+	err = svc.Stop(ctx, unit, true, true, &task)
+	if err != nil {
+		ec := errCodeV1(_Service, internalError, 113, "fail to stop service", "服务关闭错误")
+		httpJSONError(w, err, ec, http.StatusInternalServerError)
+		return
+	}
+
+	writeJSONFprintf(w, http.StatusCreated, "{%q:%q}", "task_id", task.ID)
+}
+
+func deleteService(ctx goctx.Context, w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		ec := errCodeV1(_Service, urlParamError, 121, "parse Request URL parameter error", "解析请求URL参数错误")
+		httpJSONError(w, err, ec, http.StatusBadRequest)
+		return
+	}
+
+	name := mux.Vars(r)["name"]
+	force := boolValue(r, "force")
+
+	ok, _, gd := fromContext(ctx, _Garden)
+	if !ok || gd == nil ||
+		gd.Ormer() == nil || gd.KVClient() == nil {
+
+		httpJSONNilGarden(w)
+		return
+	}
+
+	table, err := gd.Ormer().GetService(name)
+	if err != nil {
+		if database.IsNotFound(err) {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		ec := errCodeV1(_Service, dbQueryError, 122, "fail to query database", "数据库查询错误（服务表）")
+		httpJSONError(w, err, ec, http.StatusInternalServerError)
+		return
+	}
+
+	ctx, cancel := goctx.WithTimeout(ctx, time.Minute*5)
+	defer cancel()
+
+	svc := gd.NewService(nil, &table)
+	err = svc.Remove(ctx, gd.KVClient(), force)
+	if err != nil {
+		ec := errCodeV1(_Service, internalError, 123, "fail to remove service", "删除服务错误")
+		httpJSONError(w, err, ec, http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func validPostServiceBackupRequest(v structs.ServiceBackupConfig) error {
+	if v.Container == "" {
+		return stderr.New("not assigned unit nameOrID")
+	}
+
+	return nil
+}
+
+func postServiceBackup(ctx goctx.Context, w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+
+	config := structs.ServiceBackupConfig{}
+	err := json.NewDecoder(r.Body).Decode(&config)
+	if err != nil {
+		ec := errCodeV1(_Service, decodeError, 131, "JSON Decode Request Body error", "JSON解析请求Body错误")
+		httpJSONError(w, err, ec, http.StatusBadRequest)
+		return
+	}
+
+	if err := validPostServiceBackupRequest(config); err != nil {
+		ec := errCodeV1(_Service, invalidParamsError, 132, "Body parameters are invalid", "Body参数校验错误，包含无效参数")
+		httpJSONError(w, err, ec, http.StatusBadRequest)
+		return
+	}
+
+	ok, _, gd := fromContext(ctx, _Garden)
+	if !ok || gd == nil ||
+		gd.Ormer() == nil || gd.Cluster == nil {
+
+		httpJSONNilGarden(w)
+		return
+	}
+
+	if config.BackupDir == "" {
+		sys, err := gd.Ormer().GetSysConfig()
+		if err != nil {
+			ec := errCodeV1(_Service, dbQueryError, 133, "fail to query database", "数据库查询错误（系统配置表）")
+			httpJSONError(w, err, ec, http.StatusInternalServerError)
+			return
+		}
+
+		config.BackupDir = sys.BackupDir
+	}
+	if config.Type == "" {
+		config.Type = "full"
+	}
+	if config.Tables == "" {
+		config.Tables = "null"
+	}
+	if config.FilesRetention == 0 {
+		config.FilesRetention = 7
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 2 * 60 * 60
+	}
+
+	svc, err := gd.Service(name)
+	if err != nil {
+		ec := errCodeV1(_Service, dbQueryError, 134, "fail to query database", "数据库查询错误（服务表）")
+		httpJSONError(w, err, ec, http.StatusInternalServerError)
+		return
+	}
+
+	// new Context with deadline
+	if deadline, ok := ctx.Deadline(); !ok {
+		ctx = goctx.Background()
+	} else {
+		ctx, _ = goctx.WithDeadline(goctx.Background(), deadline)
+	}
+
+	task := database.NewTask(svc.Name(), database.ServiceBackupTask, svc.ID(), "", nil, config.Timeout)
+
+	err = svc.Backup(ctx, r.Host, config, true, &task)
+	if err != nil {
+		ec := errCodeV1(_Service, internalError, 135, "fail to back service", "服务备份错误")
+		httpJSONError(w, err, ec, http.StatusInternalServerError)
+		return
+	}
+
+	writeJSONFprintf(w, http.StatusCreated, "{%q:%q}", "task_id", task.ID)
+}
+
+func validPostServiceRestoreRequest(v structs.ServiceRestoreRequest) error {
+	errs := make([]string, 0, 2)
+
+	if v.File == "" {
+		errs = append(errs, "restore file is required")
+	}
+
+	if len(v.Units) == 0 {
+		errs = append(errs, "restore unit without assigned")
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+
+	return fmt.Errorf("ServiceRestoreRequest:%v,%s", v, errs)
+}
+
+func postServiceRestore(ctx goctx.Context, w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+
+	req := structs.ServiceRestoreRequest{}
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		ec := errCodeV1(_Service, decodeError, 141, "JSON Decode Request Body error", "JSON解析请求Body错误")
+		httpJSONError(w, err, ec, http.StatusBadRequest)
+		return
+	}
+
+	if err := validPostServiceRestoreRequest(req); err != nil {
+		ec := errCodeV1(_Service, invalidParamsError, 142, "Body parameters are invalid", "Body参数校验错误，包含无效参数")
+		httpJSONError(w, err, ec, http.StatusBadRequest)
+		return
+	}
+
+	ok, _, gd := fromContext(ctx, _Garden)
+	if !ok || gd == nil ||
+		gd.Ormer() == nil {
+
+		httpJSONNilGarden(w)
+		return
+	}
+
+	// new Context with deadline
+	if deadline, ok := ctx.Deadline(); !ok {
+		ctx = goctx.Background()
+	} else {
+		ctx, _ = goctx.WithDeadline(goctx.Background(), deadline)
+	}
+
+	svc, err := gd.Service(name)
+	if err != nil {
+		ec := errCodeV1(_Service, dbQueryError, 143, "fail to query database", "数据库查询错误（服务表）")
+		httpJSONError(w, err, ec, http.StatusInternalServerError)
+		return
+	}
+
+	id, err := svc.UnitRestore(ctx, req.Units, req.File, true)
+	if err != nil {
+		ec := errCodeV1(_Service, internalError, 144, "fail to restore unit data", "服务单元数据恢复错误")
+		httpJSONError(w, err, ec, http.StatusInternalServerError)
+		return
+	}
+
+	writeJSONFprintf(w, http.StatusCreated, "{%q:%q}", "task_id", id)
+}
+
+// GET /services/{name}/export?backup_file=
+func getServiceExport(ctx goctx.Context, w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		ec := errCodeV1(_Service, urlParamError, 211, "parse Request URL parameter error", "解析请求URL参数错误")
+		httpJSONError(w, err, ec, http.StatusBadRequest)
+		return
+	}
+
+	name := mux.Vars(r)["name"]
+	backup := r.FormValue("backup_file")
+
+	ok, _, gd := fromContext(ctx, _Garden)
+	if !ok || gd == nil ||
+		gd.Ormer() == nil ||
+		gd.PluginClient() == nil {
+
+		httpJSONNilGarden(w)
+		return
+	}
+
+	out, err := gd.ExportService(ctx, name, backup)
+	if err != nil {
+		if database.IsNotFound(err) {
+			ec := errCodeV1(_Service, objectNotExist, 212, "not found the service", "服务不存在")
+			httpJSONError(w, err, ec, http.StatusNotFound)
+			return
+		}
+
+		ec := errCodeV1(_Service, internalError, 213, "fail to export service", "导出服务错误")
+		httpJSONError(w, err, ec, http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, out, http.StatusOK)
+}
+
+func validPostServiceImportRequest(v structs.ServiceImportRequest) error {
+	errs := make([]string, 0, 4)
+
+	if v.Bundle.Image == "" {
+		errs = append(errs, "bundle image is required")
+	}
+
+	if v.Bundle.Spec.Name == "" && v.Mapping.Name == "" {
+		errs = append(errs, "service name is required")
+	}
+
+	if len(v.Users) == 0 {
+		errs = append(errs, "users are required,the users aren't exported")
+	}
+
+	spec := v.Bundle.ImportSpec(v.Mapping, v.Users)
+
+	if spec.Require == nil {
+		errs = append(errs, "unit require is nil")
+	} else if err := validPostServiceRequest(spec); err != nil {
+		errs = append(errs, err.Error())
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+
+	return fmt.Errorf("ServiceImportRequest:%s,%s", spec.Name, errs)
+}
+
+// POST /services/import
+func postServiceImport(ctx goctx.Context, w http.ResponseWriter, r *http.Request) {
+	req := structs.ServiceImportRequest{}
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		ec := errCodeV1(_Service, decodeError, 221, "JSON Decode Request Body error", "JSON解析请求Body错误")
+		httpJSONError(w, err, ec, http.StatusBadRequest)
+		return
+	}
+
+	if err := validPostServiceImportRequest(req); err != nil {
+		ec := errCodeV1(_Service, invalidParamsError, 222, "Body parameters are invalid", "Body参数校验错误，包含无效参数")
+		httpJSONError(w, err, ec, http.StatusBadRequest)
+		return
+	}
+
+	ok, _, gd := fromContext(ctx, _Garden)
+	if !ok || gd == nil ||
+		gd.Ormer() == nil ||
+		gd.KVClient() == nil ||
+		gd.PluginClient() == nil {
+
+		httpJSONNilGarden(w)
+		return
+	}
+
+	// new Context with deadline
+	if deadline, ok := ctx.Deadline(); !ok {
+		ctx = goctx.Background()
+	} else {
+		ctx, _ = goctx.WithDeadline(goctx.Background(), deadline)
+	}
+
+	d := deploy.New(gd)
+
+	out, err := d.Import(ctx, req)
+	if err != nil {
+		ec := errCodeV1(_Service, internalError, 223, "fail to import service", "导入服务错误")
+		httpJSONError(w, err, ec, http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, out, http.StatusCreated)
+}
+
+// POST /services/{name}/manifest?dry_run=
+func postServiceManifest(ctx goctx.Context, w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		ec := errCodeV1(_Service, urlParamError, 231, "parse Request URL parameter error", "解析请求URL参数错误")
+		httpJSONError(w, err, ec, http.StatusBadRequest)
+		return
+	}
+
+	name := mux.Vars(r)["name"]
+	dryRun := boolValue(r, "dry_run")
+
+	m := structs.ServiceManifest{}
+	err := json.NewDecoder(r.Body).Decode(&m)
+	if err != nil {
+		ec := errCodeV1(_Service, decodeError, 232, "JSON Decode Request Body error", "JSON解析请求Body错误")
+		httpJSONError(w, err, ec, http.StatusBadRequest)
+		return
+	}
+
+	ok, _, gd := fromContext(ctx, _Garden)
+	if !ok || gd == nil ||
+		gd.Ormer() == nil ||
+		gd.KVClient() == nil ||
+		gd.PluginClient() == nil {
+
+		httpJSONNilGarden(w)
+		return
+	}
+
+	// new Context with deadline
+	if deadline, ok := ctx.Deadline(); !ok {
+		ctx = goctx.Background()
+	} else {
+		ctx, _ = goctx.WithDeadline(goctx.Background(), deadline)
+	}
+
+	d := deploy.New(gd)
+
+	var plan structs.ManifestPlan
+
+	if dryRun {
+		plan, err = d.PlanManifest(ctx, name, m)
+	} else {
+		plan, err = d.ApplyManifest(ctx, name, m)
+	}
+	if err != nil {
+		if database.IsNotFound(err) {
+			ec := errCodeV1(_Service, objectNotExist, 233, "not found the service", "服务不存在")
+			httpJSONError(w, err, ec, http.StatusNotFound)
+			return
+		}
+
+		if errors.Cause(err) == deploy.ErrUnsupportedManifest {
+			ec := errCodeV1(_Service, invalidParamsError, 234, "unsupported manifest changes", "不支持的服务声明变更")
+			httpJSONError(w, err, ec, http.StatusBadRequest)
+			return
+		}
+
+		ec := errCodeV1(_Service, internalError, 235, "fail to reconcile service to the manifest", "服务声明调和错误")
 		httpJSONError(w, err, ec, http.StatusInternalServerError)
 		return
 	}
 
-	writeJSONFprintf(w, http.StatusCreated, "{%q:%q}", "task_id", task.ID)
-}
-
-func deleteService(ctx goctx.Context, w http.ResponseWriter, r *http.Request) {
-	if err := r.ParseForm(); err != nil {
-		ec := errCodeV1(_Service, urlParamError, 121, "parse Request URL parameter error", "解析请求URL参数错误")
-		httpJSONError(w, err, ec, http.StatusBadRequest)
+	if plan.TaskID == "" {
+		writeJSON(w, plan, http.StatusOK)
 		return
 	}
 
+	writeJSON(w, plan, http.StatusCreated)
+}
+
+func getServiceTopology(ctx goctx.Context, w http.ResponseWriter, r *http.Request) {
 	name := mux.Vars(r)["name"]
-	force := boolValue(r, "force")
 
 	ok, _, gd := fromContext(ctx, _Garden)
 	if !ok || gd == nil ||
-		gd.Ormer() == nil || gd.KVClient() == nil {
+		gd.Ormer() == nil ||
+		gd.PluginClient() == nil {
 
 		httpJSONNilGarden(w)
 		return
 	}
 
-	table, err := gd.Ormer().GetService(name)
+	svc, err := gd.Service(name)
 	if err != nil {
-		if database.IsNotFound(err) {
-			w.WriteHeader(http.StatusNoContent)
-			return
-		}
-		ec := errCodeV1(_Service, dbQueryError, 122, "fail to query database", "数据库查询错误（服务表）")
+		ec := errCodeV1(_Service, dbQueryError, 191, "fail to query database", "数据库查询错误（服务表）")
 		httpJSONError(w, err, ec, http.StatusInternalServerError)
 		return
 	}
 
-	ctx, cancel := goctx.WithTimeout(ctx, time.Minute*5)
-	defer cancel()
-
-	svc := gd.NewService(nil, &table)
-	err = svc.Remove(ctx, gd.KVClient(), force)
+	out, err := svc.Topology(ctx)
 	if err != nil {
-		ec := errCodeV1(_Service, internalError, 123, "fail to remove service", "删除服务错误")
+		ec := errCodeV1(_Service, internalError, 192, "fail to probe service topology", "获取服务拓扑错误")
 		httpJSONError(w, err, ec, http.StatusInternalServerError)
 		return
 	}
 
-	w.WriteHeader(http.StatusNoContent)
+	writeJSON(w, out, http.StatusOK)
 }
 
-func validPostServiceBackupRequest(v structs.ServiceBackupConfig) error {
-	if v.Container == "" {
-		return stderr.New("not assigned unit nameOrID")
+func validServiceRestoreDrillRequest(v structs.ServiceRestoreDrillRequest, schedule bool) error {
+	errs := make([]string, 0, 2)
+
+	if v.Interval < 0 || (schedule && v.Interval == 0) {
+		errs = append(errs, fmt.Sprintf("invalid interval:%d", v.Interval))
 	}
 
-	return nil
+	for i := range v.Cmd {
+		if strings.TrimSpace(v.Cmd[i]) == "" {
+			errs = append(errs, "validation command contains null value")
+			break
+		}
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+
+	return fmt.Errorf("ServiceRestoreDrillRequest:%v,%s", v, errs)
 }
 
-func postServiceBackup(ctx goctx.Context, w http.ResponseWriter, r *http.Request) {
+// POST /services/{name}/restore_drill
+func postServiceRestoreDrill(ctx goctx.Context, w http.ResponseWriter, r *http.Request) {
 	name := mux.Vars(r)["name"]
 
-	config := structs.ServiceBackupConfig{}
-	err := json.NewDecoder(r.Body).Decode(&config)
-	if err != nil {
-		ec := errCodeV1(_Service, decodeError, 131, "JSON Decode Request Body error", "JSON解析请求Body错误")
+	req := structs.ServiceRestoreDrillRequest{}
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil && err != io.EOF {
+		ec := errCodeV1(_Service, decodeError, 193, "JSON Decode Request Body error", "JSON解析请求Body错误")
 		httpJSONError(w, err, ec, http.StatusBadRequest)
 		return
 	}
 
-	if err := validPostServiceBackupRequest(config); err != nil {
-		ec := errCodeV1(_Service, invalidParamsError, 132, "Body parameters are invalid", "Body参数校验错误，包含无效参数")
+	if err := validServiceRestoreDrillRequest(req, false); err != nil {
+		ec := errCodeV1(_Service, invalidParamsError, 194, "Body parameters are invalid", "Body参数校验错误，包含无效参数")
 		httpJSONError(w, err, ec, http.StatusBadRequest)
 		return
 	}
 
 	ok, _, gd := fromContext(ctx, _Garden)
 	if !ok || gd == nil ||
-		gd.Ormer() == nil || gd.Cluster == nil {
+		gd.Ormer() == nil ||
+		gd.PluginClient() == nil {
 
 		httpJSONNilGarden(w)
 		return
 	}
 
-	if config.BackupDir == "" {
-		sys, err := gd.Ormer().GetSysConfig()
-		if err != nil {
-			ec := errCodeV1(_Service, dbQueryError, 133, "fail to query database", "数据库查询错误（系统配置表）")
-			httpJSONError(w, err, ec, http.StatusInternalServerError)
-			return
-		}
-
-		config.BackupDir = sys.BackupDir
-	}
-	if config.Type == "" {
-		config.Type = "full"
-	}
-	if config.Tables == "" {
-		config.Tables = "null"
-	}
-	if config.FilesRetention == 0 {
-		config.FilesRetention = 7
-	}
-
-	svc, err := gd.Service(name)
-	if err != nil {
-		ec := errCodeV1(_Service, dbQueryError, 134, "fail to query database", "数据库查询错误（服务表）")
-		httpJSONError(w, err, ec, http.StatusInternalServerError)
-		return
-	}
-
 	// new Context with deadline
 	if deadline, ok := ctx.Deadline(); !ok {
 		ctx = goctx.Background()
@@ -2596,83 +4362,122 @@ func postServiceBackup(ctx goctx.Context, w http.ResponseWriter, r *http.Request
 		ctx, _ = goctx.WithDeadline(goctx.Background(), deadline)
 	}
 
-	task := database.NewTask(svc.Name(), database.ServiceBackupTask, svc.ID(), "", nil, 300)
+	svc, err := gd.Service(name)
+	if err != nil {
+		ec := errCodeV1(_Service, dbQueryError, 195, "fail to query database", "数据库查询错误（服务表）")
+		httpJSONError(w, err, ec, http.StatusInternalServerError)
+		return
+	}
 
-	err = svc.Backup(ctx, r.Host, config, true, &task)
+	id, err := gd.RestoreDrill(ctx, svc, req, true)
 	if err != nil {
-		ec := errCodeV1(_Service, internalError, 135, "fail to back service", "服务备份错误")
+		ec := errCodeV1(_Service, internalError, 196, "fail to run restore drill", "服务备份恢复演练错误")
 		httpJSONError(w, err, ec, http.StatusInternalServerError)
 		return
 	}
 
-	writeJSONFprintf(w, http.StatusCreated, "{%q:%q}", "task_id", task.ID)
+	writeJSONFprintf(w, http.StatusCreated, "{%q:%q}", "task_id", id)
 }
 
-func validPostServiceRestoreRequest(v structs.ServiceRestoreRequest) error {
-	errs := make([]string, 0, 2)
+// PUT /services/{name}/restore_drill
+func putServiceRestoreDrill(ctx goctx.Context, w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
 
-	if v.File == "" {
-		errs = append(errs, "restore file is required")
+	req := structs.ServiceRestoreDrillRequest{}
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		ec := errCodeV1(_Service, decodeError, 197, "JSON Decode Request Body error", "JSON解析请求Body错误")
+		httpJSONError(w, err, ec, http.StatusBadRequest)
+		return
 	}
 
-	if len(v.Units) == 0 {
-		errs = append(errs, "restore unit without assigned")
+	if err := validServiceRestoreDrillRequest(req, true); err != nil {
+		ec := errCodeV1(_Service, invalidParamsError, 198, "Body parameters are invalid", "Body参数校验错误，包含无效参数")
+		httpJSONError(w, err, ec, http.StatusBadRequest)
+		return
 	}
 
-	if len(errs) == 0 {
-		return nil
+	ok, _, gd := fromContext(ctx, _Garden)
+	if !ok || gd == nil || gd.Ormer() == nil {
+		httpJSONNilGarden(w)
+		return
 	}
 
-	return fmt.Errorf("ServiceRestoreRequest:%v,%s", v, errs)
+	svc, err := gd.Service(name)
+	if err != nil {
+		ec := errCodeV1(_Service, dbQueryError, 199, "fail to query database", "数据库查询错误（服务表）")
+		httpJSONError(w, err, ec, http.StatusInternalServerError)
+		return
+	}
+
+	err = gd.SetRestoreDrill(svc, req)
+	if err != nil {
+		ec := errCodeV1(_Service, dbExecError, 200, "fail to schedule restore drill", "设置服务备份恢复演练计划错误")
+		httpJSONError(w, err, ec, http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
 }
 
-func postServiceRestore(ctx goctx.Context, w http.ResponseWriter, r *http.Request) {
+// GET /services/{name}/restore_drill
+func getServiceRestoreDrill(ctx goctx.Context, w http.ResponseWriter, r *http.Request) {
 	name := mux.Vars(r)["name"]
 
-	req := structs.ServiceRestoreRequest{}
-	err := json.NewDecoder(r.Body).Decode(&req)
-	if err != nil {
-		ec := errCodeV1(_Service, decodeError, 141, "JSON Decode Request Body error", "JSON解析请求Body错误")
-		httpJSONError(w, err, ec, http.StatusBadRequest)
+	ok, _, gd := fromContext(ctx, _Garden)
+	if !ok || gd == nil || gd.Ormer() == nil {
+		httpJSONNilGarden(w)
 		return
 	}
 
-	if err := validPostServiceRestoreRequest(req); err != nil {
-		ec := errCodeV1(_Service, invalidParamsError, 142, "Body parameters are invalid", "Body参数校验错误，包含无效参数")
-		httpJSONError(w, err, ec, http.StatusBadRequest)
+	svc, err := gd.Ormer().GetService(name)
+	if err != nil {
+		ec := errCodeV1(_Service, dbQueryError, 201, "fail to query database", "数据库查询错误（服务表）")
+		httpJSONError(w, err, ec, http.StatusInternalServerError)
 		return
 	}
 
-	ok, _, gd := fromContext(ctx, _Garden)
-	if !ok || gd == nil ||
-		gd.Ormer() == nil {
+	d, err := gd.Ormer().GetRestoreDrill(svc.ID)
+	if err != nil {
+		if database.IsNotFound(err) {
+			ec := errCodeV1(_Service, objectNotExist, 202, "not found the restore drill of the service", "找不到该服务的备份恢复演练计划")
+			httpJSONError(w, err, ec, http.StatusNotFound)
+			return
+		}
 
-		httpJSONNilGarden(w)
+		ec := errCodeV1(_Service, dbQueryError, 203, "fail to query database", "数据库查询错误（备份恢复演练表）")
+		httpJSONError(w, err, ec, http.StatusInternalServerError)
 		return
 	}
 
-	// new Context with deadline
-	if deadline, ok := ctx.Deadline(); !ok {
-		ctx = goctx.Background()
-	} else {
-		ctx, _ = goctx.WithDeadline(goctx.Background(), deadline)
+	writeJSON(w, garden.ConvertRestoreDrill(d), http.StatusOK)
+}
+
+// DELETE /services/{name}/restore_drill
+func deleteServiceRestoreDrill(ctx goctx.Context, w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+
+	ok, _, gd := fromContext(ctx, _Garden)
+	if !ok || gd == nil || gd.Ormer() == nil {
+		httpJSONNilGarden(w)
+		return
 	}
 
-	svc, err := gd.Service(name)
+	svc, err := gd.Ormer().GetService(name)
 	if err != nil {
-		ec := errCodeV1(_Service, dbQueryError, 143, "fail to query database", "数据库查询错误（服务表）")
+		ec := errCodeV1(_Service, dbQueryError, 204, "fail to query database", "数据库查询错误（服务表）")
 		httpJSONError(w, err, ec, http.StatusInternalServerError)
 		return
 	}
 
-	id, err := svc.UnitRestore(ctx, req.Units, req.File, true)
+	err = gd.Ormer().DelRestoreDrill(svc.ID)
 	if err != nil {
-		ec := errCodeV1(_Service, internalError, 144, "fail to restore unit data", "服务单元数据恢复错误")
+		ec := errCodeV1(_Service, dbExecError, 205, "fail to delete restore drill", "删除服务备份恢复演练计划错误")
 		httpJSONError(w, err, ec, http.StatusInternalServerError)
 		return
 	}
 
-	writeJSONFprintf(w, http.StatusCreated, "{%q:%q}", "task_id", id)
+	w.WriteHeader(http.StatusNoContent)
 }
 
 func validPostUnitRebuildRequest(v structs.UnitRebuildRequest) error {
@@ -2884,6 +4689,10 @@ func getServiceConfigFiles(ctx goctx.Context, w http.ResponseWriter, r *http.Req
 }
 
 // -----------------/units handlers-----------------
+// /units/{name}/proxy/{proxy:.*}
+// the unit port is selected by name or number in header X-Service-Port,
+// the unit IP is selected by networking ID or IP in header X-Service-Networking,
+// the request with header Upgrade:tcp is tunnelled,others are proxied as HTTP.
 func proxySpecialLogic(ctx goctx.Context, w http.ResponseWriter, r *http.Request) {
 	if err := r.ParseForm(); err != nil {
 		ec := errCodeV1(_Unit, urlParamError, 11, "parse Request URL parameter error", "解析请求URL参数错误")
@@ -2901,34 +4710,69 @@ func proxySpecialLogic(ctx goctx.Context, w http.ResponseWriter, r *http.Request
 
 	name := mux.Vars(r)["name"]
 	proxyURL := mux.Vars(r)["proxy"]
-	port := r.Header.Get("X-Service-Port")
-	orm := gd.Ormer()
 
-	u, err := orm.GetUnit(name)
-	if err != nil {
-		ec := errCodeV1(_Unit, dbQueryError, 11, "fail to query database", "数据库查询错误（服务单元表）")
-		httpJSONError(w, err, ec, http.StatusInternalServerError)
-		return
+	mode := garden.UnitProxyHTTP
+	if strings.EqualFold(r.Header.Get("Upgrade"), garden.UnitProxyTCP) {
+		mode = garden.UnitProxyTCP
 	}
 
-	ips, err := orm.ListIPByUnitID(u.ID)
+	target, err := gd.UnitProxyTarget(ctx, name, r.Header.Get("X-Service-Port"), r.Header.Get("X-Service-Networking"), mode)
 	if err != nil {
-		ec := errCodeV1(_Unit, dbQueryError, 12, "fail to query database", "数据库查询错误（网络IP表）")
-		httpJSONError(w, err, ec, http.StatusInternalServerError)
+		switch {
+		case errors.Cause(err) == garden.ErrProxyNetworking:
+			ec := errCodeV1(_Unit, objectNotExist, 13, fmt.Sprintf("not found the unit %s server addr", name), "找不到单元的服务地址")
+			httpJSONError(w, err, ec, http.StatusBadRequest)
+		case database.IsNotFound(err):
+			ec := errCodeV1(_Unit, objectNotExist, 15, "not found the unit", "单元不存在")
+			httpJSONError(w, err, ec, http.StatusNotFound)
+		case errors.Cause(err) == garden.ErrProxyPortDenied:
+			ec := errCodeV1(_Unit, invalidParamsError, 16, "the unit port is not allowed to proxy", "单元端口不允许代理")
+			httpJSONError(w, err, ec, http.StatusForbidden)
+		default:
+			ec := errCodeV1(_Unit, internalError, 17, "fail to get the unit proxy target", "获取单元代理目标错误")
+			httpJSONError(w, err, ec, http.StatusInternalServerError)
+		}
 		return
 	}
 
-	if len(ips) == 0 {
-		ec := errCodeV1(_Unit, objectNotExist, 13, fmt.Sprintf("not found the unit %s server addr", name), "找不到单元的服务地址")
-		httpJSONError(w, stderr.New(ec.comment), ec, http.StatusInternalServerError)
+	entry := logrus.WithFields(logrus.Fields{
+		"service": target.Service,
+		"unit":    target.Unit,
+		"port":    target.Port.Name,
+		"addr":    target.Addr,
+		"mode":    mode,
+		"remote":  r.RemoteAddr,
+	})
+	start := time.Now()
+
+	entry.Info("unit proxy session start")
+
+	if mode == garden.UnitProxyTCP {
+		d, err := net.DialTimeout("tcp", target.Addr, 10*time.Second)
+		if err != nil {
+			ec := errCodeV1(_Unit, internalError, 18, "fail to connect the unit port", "连接单元端口错误")
+			httpJSONError(w, err, ec, http.StatusInternalServerError)
+			return
+		}
+		defer d.Close()
+
+		in, out, err := tunnel(w, d)
+
+		entry.WithFields(logrus.Fields{
+			"sent":     in,
+			"received": out,
+			"since":    time.Since(start).String(),
+		}).Infof("unit proxy session end,%v", err)
+
 		return
 	}
 
 	r.URL.Path = "/" + proxyURL
-	addr := utils.Uint32ToIP(ips[0].IPAddr).String()
-	addr = net.JoinHostPort(addr, port)
 
-	err = hijack(nil, addr, w, r)
+	err = hijack(nil, target.Addr, w, r)
+
+	entry.WithField("since", time.Since(start).String()).Infof("unit proxy session end,%v", err)
+
 	if err != nil {
 		ec := errCodeV1(_Unit, internalError, 14, "fail to connect the special container", "连接容器服务错误")
 		httpJSONError(w, err, ec, http.StatusInternalServerError)
@@ -3223,7 +5067,7 @@ func deleteBackupFiles(ctx goctx.Context, w http.ResponseWriter, r *http.Request
 
 		files = []database.BackupFile{bf}
 
-		err = removeBackupFiles(orm, files)
+		err = removeBackupFiles(orm, gd.BackupSecretKey, files)
 		if err != nil {
 			ec := errCodeV1(_Backup, dbExecError, 13, "fail to remove backup files", "删除备份文件错误")
 			httpJSONError(w, err, ec, http.StatusInternalServerError)
@@ -3253,7 +5097,7 @@ func deleteBackupFiles(ctx goctx.Context, w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	err = removeBackupFiles(orm, expired)
+	err = removeBackupFiles(orm, gd.BackupSecretKey, expired)
 	if err != nil {
 		ec := errCodeV1(_Backup, dbExecError, 16, "fail to remove backup files", "删除备份文件错误")
 		httpJSONError(w, err, ec, http.StatusInternalServerError)
@@ -3287,7 +5131,7 @@ func expiredFiles(files []database.BackupFile, rt string) []database.BackupFile
 	return expired
 }
 
-func removeBackupFiles(orm rmBackupFilesIface, files []database.BackupFile) error {
+func removeBackupFiles(orm rmBackupFilesIface, secret backup.SecretKeyFunc, files []database.BackupFile) error {
 	sys, err := orm.GetSysConfig()
 	if err != nil {
 		return err
@@ -3298,11 +5142,37 @@ func removeBackupFiles(orm rmBackupFilesIface, files []database.BackupFile) erro
 		return err
 	}
 
-	mounts := string(out)
-	rm := make([]database.BackupFile, 0, len(files))
-	errs := make([]string, 0, len(files))
+	var (
+		target backup.Target
+		mounts = string(out)
+		rm     = make([]database.BackupFile, 0, len(files))
+		errs   = make([]string, 0, len(files))
+	)
 
 	for i := range files {
+		if backup.IsRemote(files[i].Path) {
+			if target == nil {
+				target, err = backup.NewTarget(sys, nil, secret)
+				if err != nil {
+					return err
+				}
+			}
+
+			logrus.WithFields(logrus.Fields{
+				"file":      files[i].Path,
+				"epiration": files[i].Retention,
+			}).Info("force remove backup file")
+
+			err := target.Remove(goctx.Background(), files[i].Path)
+			if err == nil {
+				rm = append(rm, files[i])
+			} else {
+				errs = append(errs, fmt.Sprintf("fail to delete backup file:%s,%s", files[i].Path, err))
+			}
+
+			continue
+		}
+
 		path := getNFSBackupFile(files[i].Path, sys.BackupDir, files[i].Mount, mounts)
 
 		logrus.WithFields(logrus.Fields{
@@ -3335,21 +5205,55 @@ func removeBackupFiles(orm rmBackupFilesIface, files []database.BackupFile) erro
 	return nil
 }
 
-func getNFSBackupFile(file, backup, mount, mounts string) string {
-	index := strings.Index(mounts, mount)
-	if index != -1 {
-		parts := strings.SplitN(mounts[index:], "\n", 2)
-		mounts = parts[0]
+// localBackupFile returns the path of the backup file on the NFS mounted by the manager
+func localBackupFile(bf database.BackupFile, backupDir string) (string, error) {
+	out, err := ioutil.ReadFile("/proc/mounts")
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	return getNFSBackupFile(bf.Path, backupDir, bf.Mount, string(out)), nil
+}
+
+// backupFileChecksum re-reads the backup file,
+// the uploaded backup file is downloaded into a temporary directory first.
+func backupFileChecksum(ctx goctx.Context, bf database.BackupFile, sys database.SysConfig, secret backup.SecretKeyFunc) (string, error) {
+	if !backup.IsRemote(bf.Path) {
+		path, err := localBackupFile(bf, sys.BackupDir)
+		if err != nil {
+			return "", err
+		}
+
+		sum, err := utils.ChecksumPath(path)
+
+		return sum, errors.Wrap(err, "checksum backup file "+path)
+	}
+
+	target, err := backup.NewTarget(sys, nil, secret)
+	if err != nil {
+		return "", err
+	}
+
+	dir, err := ioutil.TempDir("", "backup-verify")
+	if err != nil {
+		return "", errors.WithStack(err)
 	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, filepath.Base(bf.Path))
 
-	parts := strings.Split(mounts, " ")
-	if len(parts) > 1 {
-		mount = parts[1]
+	err = target.Download(ctx, bf.Path, path)
+	if err != nil {
+		return "", err
 	}
 
-	file = strings.Replace(file, backup, mount, 1)
+	sum, err := utils.ChecksumPath(path)
+
+	return sum, errors.Wrap(err, "checksum backup file "+bf.Path)
+}
 
-	return filepath.Clean(file)
+func getNFSBackupFile(file, backupDir, mount, mounts string) string {
+	return backup.LocalPath(file, backupDir, mount, mounts)
 }
 
 func getBackupFiles(ctx goctx.Context, w http.ResponseWriter, r *http.Request) {
@@ -3448,6 +5352,81 @@ func filterBackupFilesByTime(files []database.BackupFile, start, end string) []d
 	return out
 }
 
+// POST /backupfiles/{name}/verify
+func postBackupFileVerify(ctx goctx.Context, w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["name"]
+
+	ok, _, gd := fromContext(ctx, _Garden)
+	if !ok || gd == nil || gd.Ormer() == nil {
+		httpJSONNilGarden(w)
+		return
+	}
+
+	orm := gd.Ormer()
+
+	bf, err := orm.GetBackupFile(id)
+	if err != nil {
+		ec := errCodeV1(_Backup, dbQueryError, 41, "fail to query database", "数据库查询错误（备份文件表）")
+		httpJSONError(w, err, ec, http.StatusInternalServerError)
+		return
+	}
+
+	sys, err := orm.GetSysConfig()
+	if err != nil {
+		ec := errCodeV1(_Backup, dbQueryError, 42, "fail to query database", "数据库查询错误（系统配置表）")
+		httpJSONError(w, err, ec, http.StatusInternalServerError)
+		return
+	}
+
+	actual, err := backupFileChecksum(ctx, bf, sys, gd.BackupSecretKey)
+
+	resp := verifyBackupChecksum(bf, actual, err)
+
+	bf.Checksum = resp.Checksum
+	bf.Verify = resp.Status
+	bf.VerifiedAt = time.Now()
+
+	err = orm.SetBackupFileVerified(bf)
+	if err != nil {
+		ec := errCodeV1(_Backup, dbExecError, 43, "fail to update records into database", "数据库更新错误（备份文件表）")
+		httpJSONError(w, err, ec, http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, resp, http.StatusOK)
+}
+
+// verifyBackupChecksum compares the checksum re-read with the recorded checksum,
+// the file recorded before checksum supported takes the readable file as the baseline,
+// the file failed in the checksum of the callback stays failed.
+func verifyBackupChecksum(bf database.BackupFile, actual string, err error) structs.BackupFileVerifyResponse {
+	resp := structs.BackupFileVerifyResponse{
+		ID:       bf.ID,
+		Status:   database.BackupFileVerifyPassed,
+		Checksum: bf.Checksum,
+		Actual:   actual,
+	}
+
+	switch {
+	case err != nil:
+		resp.Status = database.BackupFileVerifyFailed
+		resp.Error = err.Error()
+
+	case bf.Checksum == "" && bf.Verify == database.BackupFileVerifyFailed:
+		resp.Status = database.BackupFileVerifyFailed
+		resp.Error = "checksum failed when the backup file recorded,no baseline to verify"
+
+	case bf.Checksum == "":
+		resp.Checksum = actual
+
+	case bf.Checksum != actual:
+		resp.Status = database.BackupFileVerifyFailed
+		resp.Error = fmt.Sprintf("checksum mismatch,recorded %s but read %s", bf.Checksum, actual)
+	}
+
+	return resp
+}
+
 func getBackupFile(ctx goctx.Context, w http.ResponseWriter, r *http.Request) {
 	id := mux.Vars(r)["name"]
 
@@ -53,6 +53,8 @@ type ctxHandler func(ctx goctx.Context, w http.ResponseWriter, r *http.Request)
 
 var masterRoutes = map[string]map[string]ctxHandler{
 	http.MethodGet: {
+		"/metrics": getMetrics,
+
 		"/configs/system": getSystemConfig,
 
 		"/nfs_backups/space": getNFSSPace,
@@ -62,19 +64,30 @@ var masterRoutes = map[string]map[string]ctxHandler{
 		"/hosts":           getAllNodes,
 		"/hosts/{name:.*}": getNode,
 
-		"/networkings":        listNetworkings,
-		"/networkings/{name}": getNetworking,
+		"/networkings":              listNetworkings,
+		"/networkings/{name}":       getNetworking,
+		"/networkings/{name}/usage": getNetworkingUsage,
+
+		"/credentials":        getCredentials,
+		"/credentials/{name}": getCredential,
 
-		"/tasks":        getTasks,
-		"/tasks/{name}": getTask,
+		"/tasks":              getTasks,
+		"/tasks/{name}":       getTask,
+		"/tasks/{name}/steps": getTaskSteps,
 
 		"/softwares/images":           listImages,
 		"/softwares/images/{name:.*}": getImage,
 		"/softwares/images/supported": getSupportImages,
 
-		"/services":                getServices,
-		"/services/{name}":         getServicesByNameOrID,
-		"/services/{name}/configs": getServiceConfigFiles,
+		"/services":                 getServices,
+		"/services/{name}":          getServicesByNameOrID,
+		"/services/{name}/configs":  getServiceConfigFiles,
+		"/services/{name}/topology": getServiceTopology,
+
+		"/services/{name}/restore_drill": getServiceRestoreDrill,
+		"/services/{name}/export":        getServiceExport,
+
+		"/services/{name}/units/{unit}/logs": getUnitLogs,
 
 		"/storage/san":           getSANStoragesInfo,
 		"/storage/san/{name:.*}": getSANStorageInfo,
@@ -85,12 +98,18 @@ var masterRoutes = map[string]map[string]ctxHandler{
 	http.MethodPost: {
 		"/clusters": postCluster,
 
-		"/hosts": postNode,
+		"/hosts":                  postNode,
+		"/hosts/{name}/reinstall": postNodeReinstall,
+
+		"/credentials": postCredential,
 
 		"/services":      postService,
 		"/services/link": postServiceLink,
 
+		"/services/import": postServiceImport,
+
 		"/services/{name}/scale":         postServiceScaled,
+		"/services/{name}/switchover":    postServiceSwitchover,
 		"/services/{name}/update":        postServiceUpdate,
 		"/services/{name}/image/update":  postServiceVersionUpdate,
 		"/services/{name}/start":         postServiceStart,
@@ -99,15 +118,25 @@ var masterRoutes = map[string]map[string]ctxHandler{
 		"/services/{name}/exec":          postServiceExec,
 		"/services/{name}/backup":        postServiceBackup,
 		"/services/{name}/restore":       postServiceRestore,
+		"/services/{name}/restore_drill": postServiceRestoreDrill,
 		"/services/{name}/rebuild":       postUnitRebuild,
 		"/services/{name}/migrate":       postUnitMigrate,
 
+		"/services/{name}/manifest": postServiceManifest,
+
+		"/services/{name}/units/{unit}/exec": postUnitExecSession,
+		"/services/{name}/exec/{id}/start":   postExecSessionStart,
+		"/services/{name}/exec/{id}/resize":  postExecSessionResize,
+
 		"/networkings/{name}/ips": postNetworking,
 
-		"/softwares/images": postImageLoad,
+		"/softwares/images":    postImageLoad,
+		"/softwares/images/gc": postImagesGC,
 
 		"/tasks/backup/callback": postBackupCallback,
 
+		"/backupfiles/{name}/verify": postBackupFileVerify,
+
 		"/storage/san":                        postSanStorage,
 		"/storage/san/{name}/raid_group/{rg}": postRGToSanStorage,
 	},
@@ -115,28 +144,46 @@ var masterRoutes = map[string]map[string]ctxHandler{
 	http.MethodPut: {
 		"/tasks/{name}/cancel": setTaskFailed,
 
+		"/nfs_backups/target": putBackupTarget,
+
 		"/clusters/{name}": putClusterParams,
 
-		"/softwares/images":      putImageTemplate,
-		"/softwares/images/sync": syncImageToEngines,
+		"/softwares/images":                     putImageTemplate,
+		"/softwares/images/sync":                syncImageToEngines,
+		"/softwares/images/{image}/deprecate":   putImageDeprecate,
+		"/softwares/images/{image}/undeprecate": putImageUndeprecate,
 
 		"/hosts/{name}":         putNodeParam,
 		"/hosts/{name}/enable":  putNodeEnable,
 		"/hosts/{name}/disable": putNodeDisable,
+		"/hosts/{name}/drain":   putNodeDrain,
+		"/hosts/{name}/undrain": putNodeUndrain,
+		"/hosts/{name}/labels":  putNodeLabels,
+
+		"/services/{name}/restore_drill": putServiceRestoreDrill,
+		"/services/{name}/exec_policy":   putServiceExecPolicy,
+
+		"/hosts/{name}/credential": putNodeCredential,
+		"/credentials/{name}":      putCredential,
 
-		"/networkings/{name}/ips/enable":  putNetworkingEnable,
-		"/networkings/{name}/ips/disable": putNetworkingDisable,
+		"/networkings/{name}/ips/enable":    putNetworkingEnable,
+		"/networkings/{name}/ips/disable":   putNetworkingDisable,
+		"/networkings/{name}/ips/reserve":   putNetworkingReserve,
+		"/networkings/{name}/ips/unreserve": putNetworkingUnreserve,
 
 		"/storage/san/{name}/raid_group/{rg:.*}/enable":  putEnableRaidGroup,
 		"/storage/san/{name}/raid_group/{rg:.*}/disable": putDisableRaidGroup,
 	},
 
 	http.MethodDelete: {
-		"/services/{name}": deleteService,
+		"/services/{name}":               deleteService,
+		"/services/{name}/restore_drill": deleteServiceRestoreDrill,
 
 		"/clusters/{name}": deleteCluster,
 		"/hosts/{node:.*}": deleteNode,
 
+		"/credentials/{name}": deleteCredential,
+
 		"/networkings/{name}/ips": deleteNetworking,
 
 		"/storage/san/{name}":                    deleteStorage,
@@ -149,9 +196,14 @@ var masterRoutes = map[string]map[string]ctxHandler{
 }
 
 func setupMasterRouter(r *mux.Router, context *context, debug, enableCors bool) {
-	wrap := func(fct ctxHandler) func(w http.ResponseWriter, r *http.Request) {
-		return func(w http.ResponseWriter, r *http.Request) {
+	const proxyRoute = "/units/{name}/proxy/{proxy:.*}"
+
+	wrap := func(route string, fct ctxHandler) func(w http.ResponseWriter, r *http.Request) {
+		return func(rw http.ResponseWriter, r *http.Request) {
 			start := time.Now()
+			w := &statusWriter{ResponseWriter: rw}
+
+			defer observeRequest(route, r, w, start)
 
 			defer func() {
 				if msg := recover(); msg != nil {
@@ -182,9 +234,9 @@ func setupMasterRouter(r *mux.Router, context *context, debug, enableCors bool)
 	}
 
 	if debug {
-		r.HandleFunc("/v{version:[0-9]+.[0-9]+}"+"/units/{name}/proxy/{proxy:.*}", DebugRequestMiddleware(wrap(proxySpecialLogic)))
+		r.HandleFunc("/v{version:[0-9]+.[0-9]+}"+proxyRoute, DebugRequestMiddleware(wrap(proxyRoute, proxySpecialLogic)))
 	} else {
-		r.HandleFunc("/v{version:[0-9]+.[0-9]+}"+"/units/{name}/proxy/{proxy:.*}", wrap(proxySpecialLogic))
+		r.HandleFunc("/v{version:[0-9]+.[0-9]+}"+proxyRoute, wrap(proxyRoute, proxySpecialLogic))
 	}
 	for method, mappings := range masterRoutes {
 		for route, fct := range mappings {
@@ -192,7 +244,7 @@ func setupMasterRouter(r *mux.Router, context *context, debug, enableCors bool)
 
 			localRoute := route
 			localMethod := method
-			localFct := wrap(fct)
+			localFct := wrap(localRoute, fct)
 
 			if debug {
 				r.Path("/v{version:[0-9]+.[0-9]+}" + localRoute).Methods(localMethod).HandlerFunc(DebugRequestMiddleware(localFct))
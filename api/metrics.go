@@ -0,0 +1,101 @@
+package api
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/swarm/garden/metrics"
+	"github.com/pkg/errors"
+	goctx "golang.org/x/net/context"
+)
+
+// statusWriter records the response status code for metrics
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *statusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *statusWriter) CloseNotify() <-chan bool {
+	if cn, ok := w.ResponseWriter.(http.CloseNotifier); ok {
+		return cn.CloseNotify()
+	}
+
+	// never closed
+	return make(chan bool)
+}
+
+func (w *statusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("ResponseWriter is not a http.Hijacker")
+	}
+
+	// hijacked connection has no status code
+	w.status = http.StatusSwitchingProtocols
+
+	return h.Hijack()
+}
+
+// observeRequest records the request of route,route is the template registered in router.
+func observeRequest(route string, r *http.Request, w *statusWriter, start time.Time) {
+	status := w.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	metrics.APIRequests.Inc(route, r.Method, strconv.Itoa(status))
+	metrics.APIRequestDuration.Observe(time.Since(start).Seconds(), route, r.Method)
+}
+
+// GET /metrics
+func getMetrics(ctx goctx.Context, w http.ResponseWriter, r *http.Request) {
+	if ok, _, gd := fromContext(ctx, _Garden); ok && gd != nil {
+		metrics.Engines.Reset()
+
+		for _, eng := range gd.Cluster.ListEngines() {
+			if eng == nil {
+				continue
+			}
+
+			status := "unhealthy"
+			if eng.IsHealthy() {
+				status = "healthy"
+			}
+
+			metrics.Engines.Add(1, status)
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	w.WriteHeader(http.StatusOK)
+
+	if err := metrics.WriteTo(w); err != nil {
+		logrus.Warnf("write metrics,%+v", err)
+	}
+}
@@ -14,6 +14,7 @@ import (
 	"time"
 
 	log "github.com/Sirupsen/logrus"
+	"github.com/docker/docker/api/types"
 	"github.com/docker/swarm/cluster"
 )
 
@@ -338,6 +339,91 @@ func hijack(tlsConfig *tls.Config, addr string, w http.ResponseWriter, r *http.R
 	return nil
 }
 
+// holdExecSession hijacks the client connection and pipes it with the exec connection,
+// the response is upgraded to the raw stream as docker exec start.
+func holdExecSession(w http.ResponseWriter, r *http.Request, resp types.HijackedResponse) error {
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		return errors.New("ResponseWriter is not a http.Hijacker")
+	}
+
+	nc, bufrw, err := hj.Hijack()
+	if err != nil {
+		return err
+	}
+
+	defer nc.Close()
+
+	if r.Header.Get("Upgrade") != "" {
+		fmt.Fprint(nc, "HTTP/1.1 101 UPGRADED\r\nContent-Type: application/vnd.docker.raw-stream\r\nConnection: Upgrade\r\nUpgrade: tcp\r\n\r\n")
+	} else {
+		fmt.Fprint(nc, "HTTP/1.1 200 OK\r\nContent-Type: application/vnd.docker.raw-stream\r\n\r\n")
+	}
+
+	bufferedReader := io.LimitReader(bufrw, int64(bufrw.Reader.Buffered()))
+	pipeHijacked(nc, io.MultiReader(bufferedReader, nc), resp.Conn, resp.Reader)
+
+	return nil
+}
+
+// tunnel hijacks the client connection upgraded to tcp and pipes it with the connection d,
+// returns the bytes sent to d and received from d.
+func tunnel(w http.ResponseWriter, d net.Conn) (int64, int64, error) {
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		return 0, 0, errors.New("ResponseWriter is not a http.Hijacker")
+	}
+
+	nc, bufrw, err := hj.Hijack()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	defer nc.Close()
+
+	_, err = fmt.Fprint(nc, "HTTP/1.1 101 UPGRADED\r\nConnection: Upgrade\r\nUpgrade: tcp\r\n\r\n")
+	if err != nil {
+		return 0, 0, err
+	}
+
+	bufferedReader := io.LimitReader(bufrw, int64(bufrw.Reader.Buffered()))
+	in, out := pipeHijacked(nc, io.MultiReader(bufferedReader, nc), d, d)
+
+	return in, out, nil
+}
+
+// pipeHijacked copies the hijacked client stream to dst and src to the client until both are done,
+// returns the bytes copied to dst and to the client.
+func pipeHijacked(nc net.Conn, client io.Reader, dst io.Writer, src io.Reader) (int64, int64) {
+	var in, out int64
+
+	cp := func(dst io.Writer, src io.Reader, n *int64, chDone chan struct{}) {
+		*n, _ = io.Copy(dst, src)
+		if conn, ok := dst.(interface {
+			CloseWrite() error
+		}); ok {
+			conn.CloseWrite()
+		}
+		close(chDone)
+	}
+	inDone := make(chan struct{})
+	outDone := make(chan struct{})
+
+	go cp(dst, client, &in, inDone)
+
+	go cp(nc, src, &out, outDone)
+
+	select {
+	case <-inDone:
+		<-outDone
+	case <-outDone:
+		nc.Close()
+		<-inDone
+	}
+
+	return in, out
+}
+
 func boolValue(r *http.Request, k string) bool {
 	s := strings.ToLower(strings.TrimSpace(r.FormValue(k)))
 	return !(s == "" || s == "0" || s == "no" || s == "false" || s == "none")
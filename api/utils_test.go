@@ -1,7 +1,11 @@
 package api
 
 import (
+	"bufio"
+	"io"
+	"net"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"testing"
 )
@@ -89,3 +93,59 @@ func TestMatchImageOSError(t *testing.T) {
 		}
 	}
 }
+
+func TestTunnel(t *testing.T) {
+	backend, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer backend.Close()
+
+	go func() {
+		c, err := backend.Accept()
+		if err != nil {
+			return
+		}
+		io.Copy(c, c)
+		c.Close()
+	}()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		d, err := net.Dial("tcp", backend.Addr().String())
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		defer d.Close()
+
+		tunnel(w, d)
+	}))
+	defer srv.Close()
+
+	conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/units/unit001/proxy/", nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "tcp")
+	req.Write(conn)
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("expected 101 but got %d", resp.StatusCode)
+	}
+
+	conn.Write([]byte("PING\n"))
+
+	line, err := br.ReadString('\n')
+	if err != nil || line != "PING\n" {
+		t.Errorf("unexpected echo %q,%v", line, err)
+	}
+}
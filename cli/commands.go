@@ -31,6 +31,7 @@ var (
 				flEnableCors,
 				flConfigurePluginAddr,
 				flDBDriver, flDBName, flDBAuth, flDBHost, flDBPort, flDBMaxIdle, flDBTablePrefix,
+				flHostHealthInterval, flRestoreDrillInterval, flCredentialKeyFile,
 				flCluster, flDiscoveryOpt, flClusterOpt, flRefreshOnNodeFilter, flContainerNameRefreshFilter},
 			Action: manage,
 		},
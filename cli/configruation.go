@@ -7,6 +7,7 @@ import (
 	"strings"
 
 	log "github.com/Sirupsen/logrus"
+	"github.com/docker/libkv/store/etcd"
 	"github.com/docker/swarm/api"
 	"github.com/docker/swarm/garden/kvstore"
 	"github.com/docker/swarm/plugin/parser"
@@ -66,6 +67,9 @@ func configruation(c *cli.Context) {
 
 	kvpath := strings.Join([]string{uri, leaderElectionPath}, "/")
 
+	// etcd backend of kvstore.Client
+	etcd.Register()
+
 	kvClient, err := kvstore.NewClient(uri, getDiscoveryOpt(c))
 	if err != nil {
 		log.Fatalf("fail to connect to kv store:'%s',%+v", uri, err)
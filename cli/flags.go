@@ -206,6 +206,23 @@ var (
 		Usage: "address of configure plugin server",
 	}
 
+	flHostHealthInterval = cli.StringFlag{
+		Name:  "host-health-interval",
+		Value: "1m",
+		Usage: "period between each hosts health check,hosts stay unhealthy would be quarantined,0 to disable",
+	}
+
+	flRestoreDrillInterval = cli.StringFlag{
+		Name:  "restore-drill-interval",
+		Value: "10m",
+		Usage: "period between each check of the services restore drill schedules,0 to disable",
+	}
+
+	flCredentialKeyFile = cli.StringFlag{
+		Name:  "credential-key-file",
+		Usage: "path to the master key file used to encrypt hosts credentials",
+	}
+
 	flSeedAddr = cli.StringFlag{
 		Name:  "seedAddr",
 		Value: "0.0.0.0:5685",
@@ -24,6 +24,7 @@ import (
 	"github.com/docker/swarm/garden/resource"
 	"github.com/docker/swarm/garden/resource/storage"
 	"github.com/docker/swarm/garden/utils"
+	"github.com/docker/swarm/garden/vault"
 	"github.com/docker/swarm/plugin/client"
 	pluginapi "github.com/docker/swarm/plugin/parser/api"
 	"github.com/docker/swarm/scheduler"
@@ -33,6 +34,7 @@ import (
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/gorilla/mux"
 	"github.com/urfave/cli"
+	"golang.org/x/net/context"
 )
 
 const (
@@ -186,13 +188,58 @@ func getCandidateAndFollower(discovery discovery.Backend, addr string, leaderTTL
 	return candidate, follower
 }
 
-func setupReplication(c *cli.Context, cluster cluster.Cluster, server *api.Server, candidate *leadership.Candidate, follower *leadership.Follower, addr string, tlsConfig *tls.Config, ormer database.Ormer) {
+// startHealthMonitor starts hosts health check if cl is a garden cluster,
+// returns the cancel func to stop it.
+func startHealthMonitor(cl cluster.Cluster, interval time.Duration) context.CancelFunc {
+	gd, ok := cl.(*garden.Garden)
+	if !ok || interval <= 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	go gd.RunHealthMonitor(ctx, interval)
+
+	return cancel
+}
+
+// startRegistryReporter reports the liveness of hosts and services to the registry if cl is a garden cluster,
+// returns the cancel func to stop it.
+func startRegistryReporter(cl cluster.Cluster) context.CancelFunc {
+	gd, ok := cl.(*garden.Garden)
+	if !ok {
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	go gd.RunRegistryReporter(ctx, kvstore.DefaultCheckTTL)
+
+	return cancel
+}
+
+// startRestoreDrills runs the scheduled restore drills if cl is a garden cluster,
+// returns the cancel func to stop it.
+func startRestoreDrills(cl cluster.Cluster, interval time.Duration) context.CancelFunc {
+	gd, ok := cl.(*garden.Garden)
+	if !ok || interval <= 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	go gd.RunRestoreDrills(ctx, interval)
+
+	return cancel
+}
+
+func setupReplication(c *cli.Context, cluster cluster.Cluster, server *api.Server, candidate *leadership.Candidate, follower *leadership.Follower, addr string, tlsConfig *tls.Config, ormer database.Ormer, healthInterval, drillInterval time.Duration) {
 	primary := api.NewPrimary(cluster, tlsConfig, &statusHandler{cluster, candidate, follower}, c.GlobalBool("debug"), c.Bool("cors"))
 	replica := api.NewReplica(primary, tlsConfig, addr)
 
 	go func() {
 		for {
-			run(cluster, candidate, server, primary, replica, ormer)
+			run(cluster, candidate, server, primary, replica, ormer, healthInterval, drillInterval)
 			time.Sleep(defaultRecoverTime)
 		}
 	}()
@@ -207,13 +254,28 @@ func setupReplication(c *cli.Context, cluster cluster.Cluster, server *api.Serve
 	server.SetHandler(primary)
 }
 
-func run(cl cluster.Cluster, candidate *leadership.Candidate, server *api.Server, primary *mux.Router, replica *api.Replica, ormer database.Ormer) {
+func run(cl cluster.Cluster, candidate *leadership.Candidate, server *api.Server, primary *mux.Router, replica *api.Replica, ormer database.Ormer, healthInterval, drillInterval time.Duration) {
 	electedCh, errCh := candidate.RunForElection()
 	var (
-		watchdog *cluster.Watchdog
-		eh       cluster.EventHandler
+		watchdog     *cluster.Watchdog
+		eh           cluster.EventHandler
+		stopHealth   context.CancelFunc
+		stopDrills   context.CancelFunc
+		stopReporter context.CancelFunc
 	)
 
+	defer func() {
+		if stopHealth != nil {
+			stopHealth()
+		}
+		if stopReporter != nil {
+			stopReporter()
+		}
+		if stopDrills != nil {
+			stopDrills()
+		}
+	}()
+
 	for {
 		select {
 		case isElected := <-electedCh:
@@ -233,12 +295,35 @@ func run(cl cluster.Cluster, candidate *leadership.Candidate, server *api.Server
 						log.Errorf("%+v", err)
 					}
 				}
+
+				if stopReporter == nil {
+					stopReporter = startRegistryReporter(cl)
+				}
+				if stopHealth == nil {
+					stopHealth = startHealthMonitor(cl, healthInterval)
+				}
+				if stopDrills == nil {
+					stopDrills = startRestoreDrills(cl, drillInterval)
+				}
 			} else {
 				log.Info("Leader Election: Cluster leadership lost")
 				cl.UnregisterEventHandler(watchdog)
 
 				cl.UnregisterEventHandler(eh)
 
+				if stopReporter != nil {
+					stopReporter()
+					stopReporter = nil
+				}
+				if stopHealth != nil {
+					stopHealth()
+					stopHealth = nil
+				}
+				if stopDrills != nil {
+					stopDrills()
+					stopDrills = nil
+				}
+
 				// TODO(nishanttotla): perhaps EventHandler for subscription events should
 				// also be unregistered here
 
@@ -345,7 +430,7 @@ func manage(c *cli.Context) {
 	}
 
 	var ormer database.Ormer
-	sched := scheduler.New(s, fs)
+	sched := scheduler.New(s, garden.CountFilterFailures(fs))
 	var cl cluster.Cluster
 	switch c.String("cluster-driver") {
 	case "swarm":
@@ -388,7 +473,18 @@ func manage(c *cli.Context) {
 		caddr := c.String("configureAddr")
 		pClient := pluginapi.NewPlugin(caddr, client.NewClient(caddr, 0, tlsConfig))
 
-		cl = garden.NewGarden(kvc, cl, sched, ormer, pClient, tlsConfig)
+		gd := garden.NewGarden(kvc, cl, sched, ormer, pClient, tlsConfig)
+
+		if file := c.String("credential-key-file"); file != "" {
+			v, err := vault.NewFromFile(file)
+			if err != nil {
+				log.Fatalf("%+v", err)
+			}
+
+			gd.SetVault(v)
+		}
+
+		cl = gd
 
 	default:
 		log.Fatalf("unsupported cluster %q", c.String("cluster-driver"))
@@ -406,6 +502,16 @@ func manage(c *cli.Context) {
 	api.ShouldRefreshOnNodeFilter = c.Bool("refresh-on-node-filter")
 	api.ContainerNameRefreshFilter = c.String("container-name-refresh-filter")
 
+	healthInterval, err := time.ParseDuration(c.String("host-health-interval"))
+	if err != nil {
+		log.Fatalf("invalid --host-health-interval: %v", err)
+	}
+
+	drillInterval, err := time.ParseDuration(c.String("restore-drill-interval"))
+	if err != nil {
+		log.Fatalf("invalid --restore-drill-interval: %v", err)
+	}
+
 	server := api.NewServer(hosts, tlsConfig)
 	if c.Bool("replication") {
 		addr := c.String("advertise")
@@ -428,10 +534,20 @@ func manage(c *cli.Context) {
 		// if necessary.
 		defer candidate.Resign()
 
-		setupReplication(c, cl, server, candidate, follower, addr, tlsConfig, ormer)
+		setupReplication(c, cl, server, candidate, follower, addr, tlsConfig, ormer, healthInterval, drillInterval)
 	} else {
 		server.SetHandler(api.NewPrimary(cl, tlsConfig, &statusHandler{cl, nil, nil}, c.GlobalBool("debug"), c.Bool("cors")))
 		cluster.NewWatchdog(cl)
+
+		if stop := startRegistryReporter(cl); stop != nil {
+			defer stop()
+		}
+		if stop := startHealthMonitor(cl, healthInterval); stop != nil {
+			defer stop()
+		}
+		if stop := startRestoreDrills(cl, drillInterval); stop != nil {
+			defer stop()
+		}
 	}
 	defer cl.CloseWatchQueues()
 
@@ -327,3 +327,60 @@ func holdHijackedConnection(ctx context.Context, tty bool, inputStream io.Reader
 
 	return nil
 }
+
+// CreateExecSession creates an interactive exec in the running container,
+// the exec is started by AttachExecSession.
+func (c Container) CreateExecSession(ctx context.Context, config types.ExecConfig) (string, error) {
+	if c.Engine == nil {
+		return "", errors.Errorf("Engine of Container:%s is required", c.Names)
+	}
+
+	config.AttachStdin = true
+	config.AttachStdout = true
+	config.AttachStderr = true
+	config.Detach = false
+
+	exec, err := c.Engine.apiClient.ContainerExecCreate(ctx, c.ID, config)
+	c.Engine.CheckConnectionErr(err)
+	if err != nil {
+		return "", errors.Wrapf(err, "Container %s exec create", c.ID)
+	}
+
+	// add execID to the container, so the later exec/start will work
+	if container := c.Engine.Containers().Get(c.ID); container != nil {
+		container.Info.ExecIDs = append(container.Info.ExecIDs, exec.ID)
+	}
+
+	return exec.ID, nil
+}
+
+// AttachExecSession starts the exec,returns the hijacked connection of the exec stdin and output.
+func (e *Engine) AttachExecSession(ctx context.Context, execID string, tty bool) (types.HijackedResponse, error) {
+	resp, err := e.apiClient.ContainerExecAttach(ctx, execID, types.ExecConfig{Tty: tty})
+	e.CheckConnectionErr(err)
+	if err != nil {
+		return resp, errors.Wrapf(err, "exec %s attach", execID)
+	}
+
+	return resp, nil
+}
+
+// ResizeExecSession resizes the tty of the exec.
+func (e *Engine) ResizeExecSession(ctx context.Context, execID string, height, width uint) error {
+	err := e.apiClient.ContainerExecResize(ctx, execID, types.ResizeOptions{Height: height, Width: width})
+	e.CheckConnectionErr(err)
+
+	return errors.Wrapf(err, "exec %s resize", execID)
+}
+
+// ContainerLogs returns the logs stream of the container,
+// stdout and stderr are multiplexed unless the container is tty.
+func (e *Engine) ContainerLogs(ctx context.Context, name string, options types.ContainerLogsOptions) (io.ReadCloser, error) {
+	rc, err := e.apiClient.ContainerLogs(ctx, name, options)
+	e.CheckConnectionErr(err)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Container %s logs", name)
+	}
+
+	return rc, nil
+}
@@ -0,0 +1,189 @@
+package garden
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/docker/swarm/garden/database"
+	"github.com/docker/swarm/garden/structs"
+	"github.com/docker/swarm/scheduler/node"
+	"github.com/pkg/errors"
+	"golang.org/x/net/context"
+)
+
+// affinityHosts is the affinity rule with the engines of the units of the other service
+type affinityHosts struct {
+	rule    structs.AffinityRule
+	engines map[string]bool
+}
+
+func affinityString(r structs.AffinityRule) string {
+	op := "=="
+	if r.Anti {
+		op = "!="
+	}
+
+	if r.Master {
+		return fmt.Sprintf("service%s%s(master)", op, r.Service)
+	}
+
+	return fmt.Sprintf("service%s%s", op, r.Service)
+}
+
+func affinitiesString(list []affinityHosts) string {
+	out := make([]string, len(list))
+	for i := range list {
+		out[i] = affinityString(list[i].rule)
+	}
+
+	return strings.Join(out, ",")
+}
+
+// affinityRules returns the affinity rules of opts,
+// and the anti-affinity rules declared by other services against the service.
+func (gd *Garden) affinityRules(opts scheduleOption) ([]structs.AffinityRule, error) {
+	rules := make([]structs.AffinityRule, 0, len(opts.Affinities)+1)
+	rules = append(rules, opts.Affinities...)
+
+	if opts.serviceID == "" {
+		return rules, nil
+	}
+
+	services, err := gd.ormer.ListServices()
+	if err != nil {
+		return nil, err
+	}
+
+	name := ""
+	for i := range services {
+		if services[i].ID == opts.serviceID {
+			name = services[i].Name
+			break
+		}
+	}
+
+	for i := range services {
+		if services[i].ID == opts.serviceID ||
+			services[i].Desc == nil || services[i].Desc.ScheduleOptions == "" {
+			continue
+		}
+
+		var so scheduleOption
+
+		err := json.Unmarshal([]byte(services[i].Desc.ScheduleOptions), &so)
+		if err != nil {
+			continue
+		}
+
+		for _, r := range so.Affinities {
+			if r.Anti && !r.Master &&
+				(r.Service == opts.serviceID || (name != "" && r.Service == name)) {
+
+				rules = append(rules, structs.AffinityRule{Service: services[i].ID, Anti: true})
+			}
+		}
+	}
+
+	return rules, nil
+}
+
+// serviceEngines returns the engines of the units of service,
+// the engines of the master units if master is true.
+func (gd *Garden) serviceEngines(ctx context.Context, nameOrID string, master bool) (map[string]bool, error) {
+	svc, err := gd.Service(nameOrID)
+	if err != nil {
+		return nil, err
+	}
+
+	units, err := svc.so.ListUnitByServiceID(svc.ID())
+	if err != nil {
+		return nil, err
+	}
+
+	engines := make(map[string]bool, len(units))
+
+	if !master {
+		for i := range units {
+			if units[i].EngineID != "" {
+				engines[units[i].EngineID] = true
+			}
+		}
+
+		return engines, nil
+	}
+
+	topology, err := svc.Topology(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, t := range topology.Units {
+		if t.Role != "master" {
+			continue
+		}
+
+		for i := range units {
+			if units[i].ID == t.ID && units[i].EngineID != "" {
+				engines[units[i].EngineID] = true
+			}
+		}
+	}
+
+	return engines, nil
+}
+
+// resolveAffinity returns the affinity rules with the engines of the other services
+func (gd *Garden) resolveAffinity(ctx context.Context, opts scheduleOption) ([]affinityHosts, error) {
+	rules, err := gd.affinityRules(opts)
+	if err != nil || len(rules) == 0 {
+		return nil, err
+	}
+
+	out := make([]affinityHosts, 0, len(rules))
+
+	for _, r := range rules {
+		engines, err := gd.serviceEngines(ctx, r.Service, r.Master)
+		if database.IsNotFound(err) {
+			if r.Anti {
+				// the service is removed,no units to keep away from
+				continue
+			}
+
+			return nil, errors.Errorf("affinity rule %s cannot be satisfied,service '%s' is not found", affinityString(r), r.Service)
+		}
+		if err != nil {
+			return nil, errors.WithMessage(err, "affinity rule "+affinityString(r))
+		}
+
+		if !r.Anti && len(engines) == 0 {
+			return nil, errors.Errorf("affinity rule %s cannot be satisfied,no unit of service '%s' is placed", affinityString(r), r.Service)
+		}
+
+		out = append(out, affinityHosts{rule: r, engines: engines})
+	}
+
+	return out, nil
+}
+
+// filterNodesByAffinity returns the nodes satisfy all of the affinity rules
+func filterNodesByAffinity(nodes []*node.Node, list []affinityHosts) []*node.Node {
+	if len(list) == 0 {
+		return nodes
+	}
+
+	out := make([]*node.Node, 0, len(nodes))
+
+loop:
+	for i := range nodes {
+		for _, a := range list {
+			if a.engines[nodes[i].ID] == a.rule.Anti {
+				continue loop
+			}
+		}
+
+		out = append(out, nodes[i])
+	}
+
+	return out
+}
@@ -0,0 +1,91 @@
+package garden
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/docker/swarm/garden/database"
+	"github.com/docker/swarm/garden/structs"
+	"github.com/docker/swarm/scheduler/node"
+	"github.com/pkg/errors"
+	"golang.org/x/net/context"
+)
+
+type affinityOrmer struct {
+	database.Ormer
+	services []database.Service
+}
+
+func (o affinityOrmer) ListServices() ([]database.Service, error) {
+	return o.services, nil
+}
+
+func (o affinityOrmer) GetServiceInfo(nameOrID string) (database.ServiceInfo, error) {
+	return database.ServiceInfo{}, errors.Wrap(sql.ErrNoRows, "get Service by nameOrID")
+}
+
+func TestFilterNodesByAffinity(t *testing.T) {
+	nodes := []*node.Node{{ID: "e1"}, {ID: "e2"}, {ID: "e3"}}
+
+	list := []affinityHosts{
+		{
+			rule:    structs.AffinityRule{Service: "db", Master: true},
+			engines: map[string]bool{"e1": true, "e2": true},
+		},
+		{
+			rule:    structs.AffinityRule{Service: "cache", Anti: true},
+			engines: map[string]bool{"e2": true},
+		},
+	}
+
+	out := filterNodesByAffinity(nodes, list)
+	if len(out) != 1 || out[0].ID != "e1" {
+		t.Errorf("expected [e1] but got %v", out)
+	}
+
+	if out := filterNodesByAffinity(nodes, nil); len(out) != len(nodes) {
+		t.Errorf("expected all nodes without rules but got %d", len(out))
+	}
+
+	if got := affinitiesString(list); got != "service==db(master),service!=cache" {
+		t.Errorf("unexpected rules string %q", got)
+	}
+}
+
+func TestAffinityRules(t *testing.T) {
+	gd := &Garden{
+		ormer: affinityOrmer{
+			services: []database.Service{
+				{ID: "id001", Name: "db"},
+				{ID: "id002", Name: "cache", Desc: &database.ServiceDesc{ScheduleOptions: `{"Affinities":[{"service":"db","anti":true}]}`}},
+				{ID: "id003", Name: "proxy", Desc: &database.ServiceDesc{ScheduleOptions: `{"Affinities":[{"service":"id001"}]}`}},
+				{ID: "id004", Name: "backup", Desc: &database.ServiceDesc{ScheduleOptions: `{"Affinities":[{"service":"db","anti":true,"master":true}]}`}},
+			},
+		},
+	}
+
+	rules, err := gd.affinityRules(scheduleOption{serviceID: "id001"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// only the anti-affinity of cache against db is reversed
+	if len(rules) != 1 || rules[0] != (structs.AffinityRule{Service: "id002", Anti: true}) {
+		t.Errorf("expected the reversed anti-affinity of cache but got %v", rules)
+	}
+
+	// the service of anti-affinity is removed
+	out, err := gd.resolveAffinity(context.Background(), scheduleOption{
+		Affinities: []structs.AffinityRule{{Service: "removed", Anti: true}},
+	})
+	if err != nil || len(out) != 0 {
+		t.Errorf("expected no rules of the removed service but got %v,%v", out, err)
+	}
+
+	_, err = gd.resolveAffinity(context.Background(), scheduleOption{
+		Affinities: []structs.AffinityRule{{Service: "removed"}},
+	})
+	if err == nil {
+		t.Error("expected error of the affinity to the removed service")
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/docker/docker/api/types/network"
 	"github.com/docker/swarm/cluster"
 	"github.com/docker/swarm/garden/database"
+	"github.com/docker/swarm/garden/metrics"
 	"github.com/docker/swarm/garden/resource/alloc"
 	"github.com/docker/swarm/garden/resource/storage"
 	"github.com/docker/swarm/garden/structs"
@@ -53,12 +54,21 @@ func getImage(orm database.SysConfigOrmer, version string) (string, error) {
 // BuildService build a pointer of Service,
 // 根据 ServiceSpec 生成 Service、scheduleOption、[]unit、Task，并记录到数据库。
 func (gd *Garden) BuildService(spec structs.ServiceSpec) (*Service, *database.Task, error) {
+	return gd.buildService(spec, false)
+}
+
+// buildService builds the Service,the deprecated image is rejected unless deprecated is true,
+// the restore drill builds the service of the current image,which may be deprecated after deployed.
+func (gd *Garden) buildService(spec structs.ServiceSpec, deprecated bool) (*Service, *database.Task, error) {
 	options := newScheduleOption(spec)
 
 	im, err := gd.ormer.GetImageVersion(spec.Image.ID)
 	if err != nil {
 		return nil, nil, err
 	}
+	if im.Deprecated && !deprecated {
+		return nil, nil, errors.Errorf("Image %s is deprecated,%s", im.Image(), im.DeprecatedReason)
+	}
 	spec.Image = structs.ImageVersion{
 		ID:    im.ID,
 		Name:  im.Name,
@@ -129,6 +139,15 @@ type scheduleOption struct {
 		Strategy string   `json:"Strategy,omitempty"`
 		Filters  []string `json:"Filters,omitempty"`
 	} `json:"Scheduler,omitempty"`
+
+	Spread []structs.SpreadRule `json:"Spread,omitempty"`
+
+	Affinities []structs.AffinityRule `json:"Affinities,omitempty"`
+
+	Exec *structs.ExecPolicy `json:"Exec,omitempty"`
+
+	serviceID string // ID of the service to schedule,not persisted
+	placement placement
 }
 
 func newScheduleOption(spec structs.ServiceSpec) scheduleOption {
@@ -140,11 +159,51 @@ func newScheduleOption(spec structs.ServiceSpec) scheduleOption {
 	opts.Nodes.Constraints = spec.Constraints
 	opts.Nodes.Networkings = spec.Networkings
 	opts.Nodes.Clusters = spec.Clusters
+	opts.Spread = spec.Spread
+	opts.Affinities = spec.Affinities
+	opts.Exec = spec.Exec
 
 	return opts
 }
 
+// failureCountFilter counts the failures of the filter in metrics.SchedulingFailures.
+type failureCountFilter struct {
+	f filter.Filter
+}
+
+func (fc failureCountFilter) Name() string {
+	return fc.f.Name()
+}
+
+func (fc failureCountFilter) GetFilters(config *cluster.ContainerConfig) ([]string, error) {
+	return fc.f.GetFilters(config)
+}
+
+func (fc failureCountFilter) Filter(config *cluster.ContainerConfig, nodes []*node.Node, soft bool) ([]*node.Node, error) {
+	out, err := fc.f.Filter(config, nodes, soft)
+	if err != nil {
+		metrics.SchedulingFailures.Inc(fc.f.Name())
+	}
+
+	return out, err
+}
+
+// CountFilterFailures wraps the filters to count the scheduling failures by filter name.
+func CountFilterFailures(filters []filter.Filter) []filter.Filter {
+	out := make([]filter.Filter, len(filters))
+	for i := range filters {
+		out[i] = failureCountFilter{filters[i]}
+	}
+
+	return out
+}
+
 func (gd *Garden) schedule(ctx context.Context, actor alloc.Allocator, config *cluster.ContainerConfig, opts scheduleOption) ([]*node.Node, error) {
+	start := time.Now()
+	defer func() {
+		metrics.SchedulingDuration.Observe(time.Since(start).Seconds())
+	}()
+
 	_scheduler := gd.scheduler
 
 	if opts.Scheduler.Strategy != "" && len(opts.Scheduler.Filters) > 0 {
@@ -152,7 +211,7 @@ func (gd *Garden) schedule(ctx context.Context, actor alloc.Allocator, config *c
 		filters, _ := filter.New(opts.Scheduler.Filters)
 
 		if strategy != nil && len(filters) > 0 {
-			_scheduler = scheduler.New(strategy, filters)
+			_scheduler = scheduler.New(strategy, CountFilterFailures(filters))
 		}
 	}
 
@@ -162,11 +221,18 @@ func (gd *Garden) schedule(ctx context.Context, actor alloc.Allocator, config *c
 		return nil, errors.WithStack(ctx.Err())
 	}
 
+	affinity, err := gd.resolveAffinity(ctx, opts)
+	if err != nil {
+		metrics.SchedulingFailures.Inc("affinity")
+		return nil, err
+	}
+
 	gd.scheduler.Lock()
 	defer gd.scheduler.Unlock()
 
 	out, err := actor.ListCandidates(opts.Nodes.Clusters, opts.Nodes.Filters, opts.Nodes.Networkings, opts.Require.Volumes)
 	if err != nil && len(out) == 0 {
+		metrics.SchedulingFailures.Inc("candidates")
 		return nil, err
 	}
 	if err != nil {
@@ -185,6 +251,14 @@ func (gd *Garden) schedule(ctx context.Context, actor alloc.Allocator, config *c
 		return nil, errors.New("no one node that satisfies")
 	}
 
+	if len(affinity) > 0 {
+		nodes = filterNodesByAffinity(nodes, affinity)
+		if len(nodes) == 0 {
+			metrics.SchedulingFailures.Inc("affinity")
+			return nil, errors.Errorf("no one node that satisfies affinity rules:%s", affinitiesString(affinity))
+		}
+	}
+
 	select {
 	default:
 	case <-ctx.Done():
@@ -243,6 +317,10 @@ func setNodesWithLable(out []database.Node, cl cluster.Cluster, iface database.C
 		n.Labels[roomLabel] = nt.Room
 		n.Labels[seatLabel] = nt.Seat
 		n.Labels[networkPartitionLable] = clusterMap[nt.ClusterID]
+
+		for k, v := range nt.TopologyLabels() {
+			n.Labels[topologyLabelPrefix+k] = v
+		}
 		//	n.Labels[maxContainerLable] = strconv.Itoa(nt.MaxContainer)
 
 		nodes = append(nodes, n)
@@ -294,6 +372,7 @@ func buildServiceContainerConfig(svc *Service) (*cluster.ContainerConfig, error)
 		Resources: container.Resources{
 			CpusetCpus: strconv.Itoa(svc.options.Require.Require.CPU),
 			Memory:     svc.options.Require.Require.Memory,
+			MemorySwap: svc.options.Require.Policy.MemorySwap(svc.options.Require.Require.Memory),
 		},
 	}, network.NetworkingConfig{})
 
@@ -368,7 +447,7 @@ func pendingAlloc(actor alloc.Allocator, unit database.Unit,
 		volumes:     make([]database.Volume, 0, 3),
 	}
 
-	_, err := actor.AlloctCPUMemory(pu.config, node, int64(opts.Require.Require.CPU), config.HostConfig.Memory, nil)
+	_, err := actor.AlloctCPUMemory(pu.config, node, int64(opts.Require.Require.CPU), config.HostConfig.Memory, nil, opts.Require.Policy)
 	if err != nil {
 		return pu, err
 	}
@@ -576,6 +655,7 @@ type nodeSelectStrategy struct {
 	nodes         []*node.Node
 	attemptNodes  []*node.Node
 	category      map[string][]*node.Node
+	spread        *spreadPolicy
 }
 
 func newNodeSelectStrategy(isSAN, highAvailable bool, num int, nodes []*node.Node) *nodeSelectStrategy {
@@ -620,6 +700,10 @@ func (cs *nodeSelectStrategy) selectNode(used []*node.Node) *node.Node {
 					continue
 				}
 
+				if !cs.spread.match(nodes[i], used) {
+					continue
+				}
+
 				cs.attemptNodes = append(cs.attemptNodes, nodes[i])
 
 				return nodes[i]
@@ -636,6 +720,10 @@ func (cs *nodeSelectStrategy) selectNode(used []*node.Node) *node.Node {
 			continue
 		}
 
+		if !cs.spread.match(cs.nodes[i], used) {
+			continue
+		}
+
 		cs.attemptNodes = append(cs.attemptNodes, cs.nodes[i])
 
 		return cs.nodes[i]
@@ -653,6 +741,7 @@ func (gd *Garden) allocation(ctx context.Context, actor alloc.Allocator, svc *Se
 	}
 
 	opts := svc.options
+	opts.serviceID = svc.ID()
 
 	gd.Lock()
 	defer gd.Unlock()
@@ -667,6 +756,10 @@ func (gd *Garden) allocation(ctx context.Context, actor alloc.Allocator, svc *Se
 		if err != nil {
 			return nil, err
 		}
+
+		// masters are elected by compose after deployed,unknown in allocation,
+		// so master rules apply only to the migrated masters,see setPlacement
+		opts.placement = placement{}
 	}
 
 	replicas := len(units)
@@ -675,6 +768,16 @@ func (gd *Garden) allocation(ctx context.Context, actor alloc.Allocator, svc *Se
 		return nil, errors.Errorf("not enough nodes for allocation,%d<%d", len(candidates), replicas)
 	}
 
+	spread, err := newSpreadPolicy(gd.ormer, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	err = spread.precheck(candidates, replicas)
+	if err != nil {
+		return nil, err
+	}
+
 	var (
 		bad   = make([]pendingUnit, 0, replicas)
 		field = logrus.WithField("Service", svc.Name())
@@ -720,6 +823,7 @@ func (gd *Garden) allocation(ctx context.Context, actor alloc.Allocator, svc *Se
 	usedNodes := make([]*node.Node, 0, count)
 	isSan := isSANStorage(opts.Require.Volumes)
 	ns := newNodeSelectStrategy(isSan, opts.HighAvailable, replicas, candidates)
+	ns.spread = spread
 
 alloc:
 	for retry := 3; retry > 0; {
@@ -732,7 +836,11 @@ alloc:
 
 		candidate := ns.selectNode(usedNodes)
 		if candidate == nil {
-			err = fmt.Errorf("no more candidate for allocation,%d<%d,tried candidate:%d\n%+v", len(used), replicas, len(usedNodes), err)
+			if spread != nil {
+				err = fmt.Errorf("no more candidate for allocation,%d<%d,tried candidate:%d,spread rules:%s\n%+v", len(used), replicas, len(usedNodes), spread, err)
+			} else {
+				err = fmt.Errorf("no more candidate for allocation,%d<%d,tried candidate:%d\n%+v", len(used), replicas, len(usedNodes), err)
+			}
 			break alloc
 		}
 
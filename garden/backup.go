@@ -4,6 +4,7 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/docker/swarm/garden/backup"
 	"github.com/docker/swarm/garden/database"
 	"github.com/docker/swarm/garden/structs"
 	"github.com/docker/swarm/garden/tasklock"
@@ -14,7 +15,7 @@ import (
 // Backup is exported
 // 对服务进行备份，如果指定则对指定的容器进行备份，执行ContainerExec进行备份任务。
 func (svc *Service) Backup(ctx context.Context, local string, config structs.ServiceBackupConfig, async bool, task *database.Task) error {
-	backup := func() error {
+	do := func() error {
 		//		err := svc.checkBackupFiles(ctx, config.MaxSizeByte)
 		//		if err != nil {
 		//			return err
@@ -44,18 +45,62 @@ func (svc *Service) Backup(ctx context.Context, local string, config structs.Ser
 			}
 		}
 
+		token, callback, err := backup.NewCallback(task.ID, u.u.ID, task.Timeout)
+		if err != nil {
+			return err
+		}
+
+		err = svc.so.InsertBackupCallback(callback)
+		if err != nil {
+			return err
+		}
+
 		cmd = append(cmd, local+"/v1.0/tasks/backup/callback", task.ID, u.u.ID, config.Type, config.BackupDir,
-			strconv.Itoa(config.FilesRetention), config.Remark, config.Tag, config.Tables)
+			strconv.Itoa(config.FilesRetention), config.Remark, config.Tag, config.Tables, token)
 
 		_, err = u.containerExec(ctx, cmd, config.Detach)
+		if err != nil {
+			return err
+		}
 
-		return err
+		return svc.waitBackupCallback(ctx, task.ID, callback.ExpiresAt)
 	}
 
 	sl := tasklock.NewServiceTask(database.ServiceBackupTask, svc.ID(), svc.so, task,
 		statusServiceBackuping, statusServiceBackupDone, statusServiceBackupFailed)
 
-	return sl.Run(isnotInProgress, backup, async)
+	return sl.Run(isnotInProgress, do, async)
+}
+
+var backupCallbackInterval = 3 * time.Second
+
+// waitBackupCallback waits until the task is finished by the backup callback,
+// returns context.DeadlineExceeded if the callback isnot arrived before expires.
+func (svc *Service) waitBackupCallback(ctx context.Context, task string, expires time.Time) error {
+	timer := time.NewTimer(time.Until(expires))
+	defer timer.Stop()
+
+	ticker := time.NewTicker(backupCallbackInterval)
+	defer ticker.Stop()
+
+	for {
+		t, err := svc.so.GetTask(task)
+		if err == nil && t.Status > database.TaskRunningStatus {
+			if t.Status == database.TaskDoneStatus {
+				return nil
+			}
+
+			return errors.Errorf("backup task %s failed,status=%d,%s", task, t.Status, t.Errors)
+		}
+
+		select {
+		case <-ctx.Done():
+			return errors.WithStack(ctx.Err())
+		case <-timer.C:
+			return errors.Wrapf(context.DeadlineExceeded, "wait callback of backup task %s", task)
+		case <-ticker.C:
+		}
+	}
 }
 
 func (svc *Service) checkBackupFiles(ctx context.Context, maxSize int) error {
@@ -0,0 +1,87 @@
+package backup
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/swarm/garden/database"
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrTokenInvalid the callback token isnot signed for the task and unit
+	ErrTokenInvalid = errors.New("invalid backup callback token")
+	// ErrTokenExpired the callback token is expired,the task is timeout
+	ErrTokenExpired = errors.New("backup callback token is expired")
+	// ErrTokenReplayed the callback token has been used for another backup file
+	ErrTokenReplayed = errors.New("backup callback token has been used")
+)
+
+// NewCallback returns the callback token passed to the backup task and
+// the BackupCallback to verify it,the token expires after ttl.
+func NewCallback(task, unit string, ttl time.Duration) (string, database.BackupCallback, error) {
+	secret := make([]byte, 32)
+
+	_, err := rand.Read(secret)
+	if err != nil {
+		return "", database.BackupCallback{}, errors.Wrap(err, "generate backup callback secret")
+	}
+
+	now := time.Now()
+	c := database.BackupCallback{
+		TaskID:    task,
+		UnitID:    unit,
+		Secret:    hex.EncodeToString(secret),
+		ExpiresAt: now.Add(ttl),
+		CreatedAt: now,
+	}
+
+	return sign(c, c.ExpiresAt.Unix()), c, nil
+}
+
+// sign returns token "<expires>.<signature>",signature is HMAC-SHA256 of task,unit and expires.
+func sign(c database.BackupCallback, expires int64) string {
+	exp := strconv.FormatInt(expires, 10)
+
+	mac := hmac.New(sha256.New, []byte(c.Secret))
+	mac.Write([]byte(c.TaskID + "\n" + c.UnitID + "\n" + exp))
+
+	return exp + "." + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyCallback verifies the token of the callback from unit against BackupCallback,
+// returns true if the callback is a repeat of the used token for the same backup file.
+func VerifyCallback(c database.BackupCallback, token, unit, path string, now time.Time) (bool, error) {
+	parts := strings.SplitN(token, ".", 2)
+	if len(parts) != 2 || c.Secret == "" || c.UnitID != unit {
+		return false, errors.WithStack(ErrTokenInvalid)
+	}
+
+	expires, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil || expires != c.ExpiresAt.Unix() {
+		return false, errors.WithStack(ErrTokenInvalid)
+	}
+
+	if !hmac.Equal([]byte(token), []byte(sign(c, expires))) {
+		return false, errors.WithStack(ErrTokenInvalid)
+	}
+
+	if !c.UsedAt.IsZero() {
+		if c.Path != path {
+			return false, errors.WithStack(ErrTokenReplayed)
+		}
+
+		return true, nil
+	}
+
+	if now.After(c.ExpiresAt) {
+		return false, errors.WithStack(ErrTokenExpired)
+	}
+
+	return false, nil
+}
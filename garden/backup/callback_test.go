@@ -0,0 +1,61 @@
+package backup
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestVerifyCallback(t *testing.T) {
+	token, c, err := NewCallback("task01", "unit01", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	path := "/backup/unit01_201802101607"
+
+	var tests = []struct {
+		token, unit string
+		now         time.Time
+		want        error
+	}{
+		{token: token, unit: "unit01", now: now},
+		{token: token, unit: "unit02", now: now, want: ErrTokenInvalid},
+		{token: token + "0", unit: "unit01", now: now, want: ErrTokenInvalid},
+		{token: "0." + token[len(token)-64:], unit: "unit01", now: now, want: ErrTokenInvalid},
+		{token: "", unit: "unit01", now: now, want: ErrTokenInvalid},
+		{token: token, unit: "unit01", now: now.Add(2 * time.Minute), want: ErrTokenExpired},
+	}
+
+	for i, tt := range tests {
+		repeat, err := VerifyCallback(c, tt.token, tt.unit, path, tt.now)
+		if errors.Cause(err) != tt.want || repeat {
+			t.Errorf("%d:expected %v but got %v,%t", i, tt.want, err, repeat)
+		}
+	}
+
+	// token of another task
+	other, _, err := NewCallback("task02", "unit01", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := VerifyCallback(c, other, "unit01", path, now); errors.Cause(err) != ErrTokenInvalid {
+		t.Errorf("expected %v but got %v", ErrTokenInvalid, err)
+	}
+
+	// used by the first callback
+	c.Path = path
+	c.UsedAt = now
+
+	repeat, err := VerifyCallback(c, token, "unit01", path, now.Add(2*time.Minute))
+	if err != nil || !repeat {
+		t.Errorf("expected repeat callback but got %v,%t", err, repeat)
+	}
+
+	_, err = VerifyCallback(c, token, "unit01", "/backup/forged", now)
+	if errors.Cause(err) != ErrTokenReplayed {
+		t.Errorf("expected %v but got %v", ErrTokenReplayed, err)
+	}
+}
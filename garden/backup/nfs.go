@@ -0,0 +1,54 @@
+package backup
+
+import (
+	"github.com/docker/swarm/garden/database"
+	"github.com/docker/swarm/garden/resource/alloc/driver"
+	"github.com/docker/swarm/garden/utils"
+	"github.com/pkg/errors"
+	"golang.org/x/net/context"
+)
+
+// nfsTarget keeps the backup files in the NFS backup dir
+type nfsTarget struct {
+	driver driver.Driver
+}
+
+func (nfsTarget) Type() string { return database.BackupTargetNFS }
+
+func (nfsTarget) Upload(ctx context.Context, local, key string) (string, error) {
+	return "", nil
+}
+
+func (nfsTarget) Download(ctx context.Context, location, local string) error {
+	if location == local {
+		return nil
+	}
+
+	return errors.Errorf("NFS backup target cannot download %s", location)
+}
+
+func (nfsTarget) Remove(ctx context.Context, location string) error {
+	out, err := utils.ExecContextTimeout(ctx, 0, "sudo rm -rf", location)
+	if err != nil {
+		return errors.Errorf("fail to delete backup file:%s,%s,%s", location, out, err)
+	}
+
+	return nil
+}
+
+func (nt nfsTarget) Space(ctx context.Context) (Space, error) {
+	if nt.driver == nil {
+		return Space{}, errors.New("NFS backup target without NFS settings")
+	}
+
+	space, err := nt.driver.Space()
+	if err != nil {
+		return Space{}, err
+	}
+
+	return Space{
+		Total: space.Total,
+		Free:  space.Free,
+		Used:  space.Total - space.Free,
+	}, nil
+}
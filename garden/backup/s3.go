@@ -0,0 +1,639 @@
+package backup
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/swarm/garden/database"
+	"github.com/pkg/errors"
+	"golang.org/x/net/context"
+	"golang.org/x/net/context/ctxhttp"
+)
+
+const (
+	s3Scheme = "s3://"
+
+	emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	unsignedPayload  = "UNSIGNED-PAYLOAD"
+
+	s3MaxParts = 10000
+)
+
+var (
+	// s3MaxPutSize is the max object size of a single PUT,
+	// larger files are uploaded by multipart upload.
+	s3MaxPutSize int64 = 5 << 30
+	// s3PartSize is the min part size of multipart upload.
+	s3PartSize int64 = 512 << 20
+)
+
+// s3Client is a minimal S3 client with path-style requests and Signature Version 4,
+// works with AWS S3 and S3-compatible storages like MinIO.
+type s3Client struct {
+	endpoint  *url.URL
+	region    string
+	accessKey string
+	secretKey string
+	client    *http.Client
+	now       func() time.Time
+}
+
+type s3Object struct {
+	Key  string `xml:"Key"`
+	Size int64  `xml:"Size"`
+}
+
+type listBucketResult struct {
+	Contents              []s3Object `xml:"Contents"`
+	IsTruncated           bool       `xml:"IsTruncated"`
+	NextContinuationToken string     `xml:"NextContinuationToken"`
+}
+
+type initiateMultipartUploadResult struct {
+	UploadID string `xml:"UploadId"`
+}
+
+type completedPart struct {
+	PartNumber int    `xml:"PartNumber"`
+	ETag       string `xml:"ETag"`
+}
+
+type completeMultipartUpload struct {
+	XMLName xml.Name        `xml:"CompleteMultipartUpload"`
+	Parts   []completedPart `xml:"Part"`
+}
+
+type s3Error struct {
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+func newS3Client(endpoint, region, accessKey, secretKey string) (*s3Client, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, errors.Wrap(err, "parse S3 endpoint")
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return nil, errors.Errorf("invalid S3 endpoint '%s'", endpoint)
+	}
+
+	if region == "" {
+		region = "us-east-1"
+	}
+
+	return &s3Client{
+		endpoint:  u,
+		region:    region,
+		accessKey: accessKey,
+		secretKey: secretKey,
+		client:    http.DefaultClient,
+		now:       time.Now,
+	}, nil
+}
+
+func (c *s3Client) objectURL(bucket, key string, query url.Values) *url.URL {
+	u := *c.endpoint
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + bucket
+	if key != "" {
+		u.Path += "/" + key
+	}
+	// send the path as the canonical URI signed
+	u.RawPath = uriEncode(u.Path, false)
+	u.RawQuery = query.Encode()
+
+	return &u
+}
+
+func (c *s3Client) do(ctx context.Context, method string, u *url.URL, body io.Reader, size int64) (*http.Response, error) {
+	req, err := http.NewRequest(method, u.String(), body)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	hash := emptyPayloadHash
+	if body != nil {
+		req.ContentLength = size
+		hash = unsignedPayload
+	}
+
+	c.sign(req, hash)
+
+	resp, err := ctxhttp.Do(ctx, c.client, req)
+	if err != nil {
+		return nil, errors.Wrapf(err, "S3 %s %s", method, u.Path)
+	}
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp, nil
+	}
+
+	defer resp.Body.Close()
+
+	out, _ := ioutil.ReadAll(resp.Body)
+	e := s3Error{}
+	if xml.Unmarshal(out, &e) == nil && e.Code != "" {
+		return nil, errors.Errorf("S3 %s %s,%d %s:%s", method, u.Path, resp.StatusCode, e.Code, e.Message)
+	}
+
+	return nil, errors.Errorf("S3 %s %s,%d %s", method, u.Path, resp.StatusCode, out)
+}
+
+// sign signs the request with AWS Signature Version 4,
+// host and all the headers of the request are signed.
+func (c *s3Client) sign(req *http.Request, payloadHash string) {
+	now := c.now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := amzDate[:8]
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		headers[strings.ToLower(k)] = strings.TrimSpace(strings.Join(v, ","))
+	}
+
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	canonical := make([]string, len(names))
+	for i := range names {
+		canonical[i] = names[i] + ":" + headers[names[i]] + "\n"
+	}
+
+	signed := strings.Join(names, ";")
+
+	request := strings.Join([]string{
+		req.Method,
+		uriEncode(req.URL.Path, false),
+		canonicalQuery(req.URL.Query()),
+		strings.Join(canonical, ""),
+		signed,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + c.region + "/s3/aws4_request"
+	sum := sha256.Sum256([]byte(request))
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(sum[:])
+
+	key := hmacSHA256([]byte("AWS4"+c.secretKey), date)
+	key = hmacSHA256(key, c.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+
+	signature := hex.EncodeToString(hmacSHA256(key, toSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s,SignedHeaders=%s,Signature=%s",
+		c.accessKey, scope, signed, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+
+	return h.Sum(nil)
+}
+
+// uriEncode encodes as the S3 canonical URI,'/' is kept unless encodeSlash
+func uriEncode(s string, encodeSlash bool) string {
+	buf := make([]byte, 0, len(s))
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			buf = append(buf, c)
+		case c == '/' && !encodeSlash:
+			buf = append(buf, c)
+		default:
+			buf = append(buf, fmt.Sprintf("%%%02X", c)...)
+		}
+	}
+
+	return string(buf)
+}
+
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	out := make([]string, 0, len(keys))
+	for _, k := range keys {
+		vals := query[k]
+		sort.Strings(vals)
+
+		for _, v := range vals {
+			out = append(out, uriEncode(k, true)+"="+uriEncode(v, true))
+		}
+	}
+
+	return strings.Join(out, "&")
+}
+
+func (c *s3Client) putObject(ctx context.Context, bucket, key, file string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	if info.Size() > s3MaxPutSize {
+		return c.multipartUpload(ctx, bucket, key, f, info.Size())
+	}
+
+	resp, err := c.do(ctx, http.MethodPut, c.objectURL(bucket, key, nil), f, info.Size())
+	if err != nil {
+		return err
+	}
+
+	return resp.Body.Close()
+}
+
+// multipartUpload uploads the file in parts of s3PartSize at least,
+// the part size grows to keep no more than s3MaxParts parts,
+// the upload is aborted if any part failed.
+func (c *s3Client) multipartUpload(ctx context.Context, bucket, key string, f io.ReaderAt, size int64) error {
+	query := url.Values{}
+	query.Set("uploads", "")
+
+	resp, err := c.do(ctx, http.MethodPost, c.objectURL(bucket, key, query), nil, 0)
+	if err != nil {
+		return err
+	}
+
+	result := initiateMultipartUploadResult{}
+	err = xml.NewDecoder(resp.Body).Decode(&result)
+	resp.Body.Close()
+	if err != nil {
+		return errors.Wrap(err, "decode InitiateMultipartUpload result")
+	}
+	if result.UploadID == "" {
+		return errors.Errorf("S3 multipart upload %s/%s without UploadId", bucket, key)
+	}
+
+	err = c.uploadParts(ctx, bucket, key, result.UploadID, f, size)
+	if err != nil {
+		query = url.Values{}
+		query.Set("uploadId", result.UploadID)
+
+		if resp, _err := c.do(ctx, http.MethodDelete, c.objectURL(bucket, key, query), nil, 0); _err == nil {
+			resp.Body.Close()
+		}
+	}
+
+	return err
+}
+
+func (c *s3Client) uploadParts(ctx context.Context, bucket, key, uploadID string, f io.ReaderAt, size int64) error {
+	partSize := s3PartSize
+	if n := (size + s3MaxParts - 1) / s3MaxParts; n > partSize {
+		partSize = n
+	}
+
+	complete := completeMultipartUpload{}
+
+	for off, n := int64(0), 1; off < size; off, n = off+partSize, n+1 {
+		length := partSize
+		if off+length > size {
+			length = size - off
+		}
+
+		query := url.Values{}
+		query.Set("partNumber", strconv.Itoa(n))
+		query.Set("uploadId", uploadID)
+
+		resp, err := c.do(ctx, http.MethodPut, c.objectURL(bucket, key, query), io.NewSectionReader(f, off, length), length)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+
+		etag := resp.Header.Get("ETag")
+		if etag == "" {
+			return errors.Errorf("S3 upload part %d of %s/%s without ETag", n, bucket, key)
+		}
+
+		complete.Parts = append(complete.Parts, completedPart{PartNumber: n, ETag: etag})
+	}
+
+	body, err := xml.Marshal(complete)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	query := url.Values{}
+	query.Set("uploadId", uploadID)
+
+	resp, err := c.do(ctx, http.MethodPost, c.objectURL(bucket, key, query), bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// CompleteMultipartUpload may fail with 200 OK and an error body
+	out, _ := ioutil.ReadAll(resp.Body)
+	e := s3Error{}
+	if xml.Unmarshal(out, &e) == nil && e.Code != "" {
+		return errors.Errorf("S3 complete multipart upload %s/%s:%s %s", bucket, key, e.Code, e.Message)
+	}
+
+	return nil
+}
+
+func (c *s3Client) getObject(ctx context.Context, bucket, key, file string) error {
+	resp, err := c.do(ctx, http.MethodGet, c.objectURL(bucket, key, nil), nil, 0)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	err = os.MkdirAll(filepath.Dir(file), 0755)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	f, err := os.Create(file)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	_, err = io.Copy(f, resp.Body)
+	if err != nil {
+		f.Close()
+		return errors.Wrapf(err, "download %s/%s", bucket, key)
+	}
+
+	return errors.WithStack(f.Close())
+}
+
+func (c *s3Client) deleteObject(ctx context.Context, bucket, key string) error {
+	resp, err := c.do(ctx, http.MethodDelete, c.objectURL(bucket, key, nil), nil, 0)
+	if err != nil {
+		return err
+	}
+
+	return resp.Body.Close()
+}
+
+// listObjects returns all objects with the prefix,calls ListObjectsV2 until not truncated.
+func (c *s3Client) listObjects(ctx context.Context, bucket, prefix string) ([]s3Object, error) {
+	var (
+		out   []s3Object
+		token string
+	)
+
+	for {
+		query := url.Values{}
+		query.Set("list-type", "2")
+		if prefix != "" {
+			query.Set("prefix", prefix)
+		}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+
+		resp, err := c.do(ctx, http.MethodGet, c.objectURL(bucket, "", query), nil, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		result := listBucketResult{}
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, errors.Wrap(err, "decode ListObjectsV2 result")
+		}
+
+		out = append(out, result.Contents...)
+
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return out, nil
+		}
+
+		token = result.NextContinuationToken
+	}
+}
+
+// SecretKeyFunc returns the S3 secret key of the backup target kept out of the system config,
+// used if the backup target is without SecretKey.
+type SecretKeyFunc func(t database.BackupTarget) (string, error)
+
+// s3Target uploads backup files into the bucket,
+// a backup directory is uploaded as objects with the prefix "<key>/".
+type s3Target struct {
+	client *s3Client
+	bucket string
+	quota  int64
+}
+
+func newS3Target(t database.BackupTarget, secret SecretKeyFunc) (Target, error) {
+	if t.Bucket == "" {
+		return nil, errors.New("S3 backup target without bucket")
+	}
+
+	if t.SecretKey == "" && secret != nil {
+		key, err := secret(t)
+		if err != nil {
+			return nil, err
+		}
+
+		t.SecretKey = key
+	}
+
+	c, err := newS3Client(t.Endpoint, t.Region, t.AccessKey, t.SecretKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return s3Target{
+		client: c,
+		bucket: t.Bucket,
+		quota:  t.Quota,
+	}, nil
+}
+
+func (s3Target) Type() string { return database.BackupTargetS3 }
+
+// Reachable returns error if the object key isnot found in the bucket at endpoint by the S3 backup target of sys,
+// the backup files exported from other managers are restored by the backup target.
+func Reachable(ctx context.Context, sys database.SysConfig, secret SecretKeyFunc, endpoint, bucket, key string) error {
+	t := sys.BackupTarget
+	if t.Type != database.BackupTargetS3 {
+		return errors.Errorf("backup target is '%s',the backup file in S3 bucket %s is unreachable", t.Type, bucket)
+	}
+
+	if strings.TrimSuffix(t.Endpoint, "/") != strings.TrimSuffix(endpoint, "/") || t.Bucket != bucket {
+		return errors.Errorf("the backup file in %s bucket %s is unreachable by the backup target %s bucket %s", endpoint, bucket, t.Endpoint, t.Bucket)
+	}
+
+	target, err := newS3Target(t, secret)
+	if err != nil {
+		return err
+	}
+
+	_, err = target.(s3Target).objects(ctx, key)
+
+	return err
+}
+
+func (st s3Target) location(key string) string {
+	return s3Scheme + st.bucket + "/" + key
+}
+
+func (st s3Target) parseLocation(location string) (string, error) {
+	prefix := s3Scheme + st.bucket + "/"
+	if !strings.HasPrefix(location, prefix) || len(location) == len(prefix) {
+		return "", errors.Errorf("'%s' isnot in bucket %s", location, st.bucket)
+	}
+
+	return location[len(prefix):], nil
+}
+
+func (st s3Target) Upload(ctx context.Context, local, key string) (string, error) {
+	info, err := os.Stat(local)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	if !info.IsDir() {
+		return st.location(key), st.client.putObject(ctx, st.bucket, key, local)
+	}
+
+	walk := func(path string, fi os.FileInfo, err error) error {
+		if err != nil || !fi.Mode().IsRegular() {
+			return err
+		}
+
+		rel, err := filepath.Rel(local, path)
+		if err != nil {
+			return err
+		}
+
+		return st.client.putObject(ctx, st.bucket, key+"/"+filepath.ToSlash(rel), path)
+	}
+
+	err = filepath.Walk(local, walk)
+	if err != nil {
+		return "", errors.Wrapf(err, "upload %s", local)
+	}
+
+	return st.location(key), nil
+}
+
+// objects returns the objects of the backup file,the object of key or the objects with prefix "<key>/"
+func (st s3Target) objects(ctx context.Context, key string) ([]s3Object, error) {
+	list, err := st.client.listObjects(ctx, st.bucket, key)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]s3Object, 0, len(list))
+	for i := range list {
+		if list[i].Key == key || strings.HasPrefix(list[i].Key, key+"/") {
+			out = append(out, list[i])
+		}
+	}
+
+	if len(out) == 0 {
+		return nil, errors.Errorf("not found backup file %s", st.location(key))
+	}
+
+	return out, nil
+}
+
+func (st s3Target) Download(ctx context.Context, location, local string) error {
+	key, err := st.parseLocation(location)
+	if err != nil {
+		return err
+	}
+
+	objects, err := st.objects(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	for _, obj := range objects {
+		file := local
+		if obj.Key != key {
+			file = filepath.Join(local, filepath.FromSlash(strings.TrimPrefix(obj.Key, key+"/")))
+		}
+
+		err := st.client.getObject(ctx, st.bucket, obj.Key, file)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (st s3Target) Remove(ctx context.Context, location string) error {
+	key, err := st.parseLocation(location)
+	if err != nil {
+		return err
+	}
+
+	objects, err := st.objects(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	for _, obj := range objects {
+		err := st.client.deleteObject(ctx, st.bucket, obj.Key)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (st s3Target) Space(ctx context.Context) (Space, error) {
+	objects, err := st.client.listObjects(ctx, st.bucket, "")
+	if err != nil {
+		return Space{}, err
+	}
+
+	used := int64(0)
+	for i := range objects {
+		used += objects[i].Size
+	}
+
+	if st.quota <= 0 {
+		return Space{Total: -1, Free: -1, Used: used}, nil
+	}
+
+	free := st.quota - used
+	if free < 0 {
+		free = 0
+	}
+
+	return Space{Total: st.quota, Free: free, Used: used}, nil
+}
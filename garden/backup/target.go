@@ -0,0 +1,149 @@
+package backup
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/docker/swarm/garden/database"
+	"github.com/docker/swarm/garden/resource/alloc/driver"
+	"github.com/docker/swarm/garden/utils"
+	"github.com/pkg/errors"
+	"golang.org/x/net/context"
+)
+
+// Space is the capacity of Target in byte,Total is -1 if unlimited.
+type Space struct {
+	Total int64 `json:"total_space"`
+	Free  int64 `json:"free_space"`
+	Used  int64 `json:"used_space"`
+}
+
+// Target is where the backup files are kept after the backup task callback.
+// Backup files are written into BackupDir by the containers,
+// the manager reads them through the NFS mount,see LocalPath.
+type Target interface {
+	Type() string
+
+	// Upload keeps the local backup file or directory as key,
+	// returns the location recorded in BackupFile.Path,"" if the file is kept in place.
+	Upload(ctx context.Context, local, key string) (string, error)
+
+	// Download fetches the backup file at location into local.
+	Download(ctx context.Context, location, local string) error
+
+	// Remove deletes the backup file at location.
+	Remove(ctx context.Context, location string) error
+
+	Space(ctx context.Context) (Space, error)
+}
+
+// NewTarget returns the Target configured in SysConfig,
+// nfs is the NFS driver for the space of the NFS target,could be nil if space is not required,
+// secret resolves the S3 secret key kept out of the system config,could be nil.
+func NewTarget(sys database.SysConfig, nfs driver.Driver, secret SecretKeyFunc) (Target, error) {
+	switch sys.BackupTarget.Type {
+	case "", database.BackupTargetNFS:
+		return nfsTarget{driver: nfs}, nil
+
+	case database.BackupTargetS3:
+		return newS3Target(sys.BackupTarget, secret)
+
+	default:
+		return nil, errors.Errorf("unsupported backup target '%s'", sys.BackupTarget.Type)
+	}
+}
+
+// Verify downloads the backup file at location into a temporary directory,
+// returns error if its size or checksum differs,size isnot checked if zero and checksum isnot checked if empty.
+func Verify(ctx context.Context, t Target, location string, size int, checksum string) error {
+	dir, err := ioutil.TempDir("", "backup-verify")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer os.RemoveAll(dir)
+
+	local := filepath.Join(dir, filepath.Base(location))
+
+	err = t.Download(ctx, location, local)
+	if err != nil {
+		return err
+	}
+
+	if size > 0 {
+		var n int64
+		err = filepath.Walk(local, func(file string, info os.FileInfo, err error) error {
+			if err == nil && info.Mode().IsRegular() {
+				n += info.Size()
+			}
+
+			return err
+		})
+		if err != nil {
+			return errors.WithStack(err)
+		}
+
+		if n != int64(size) {
+			return errors.Errorf("backup file %s size %d,expected %d", location, n, size)
+		}
+	}
+
+	if checksum != "" {
+		sum, err := utils.ChecksumPath(local)
+		if err != nil {
+			return errors.Wrap(err, "checksum backup file "+location)
+		}
+
+		if sum != checksum {
+			return errors.Errorf("backup file %s checksum %s,expected %s", location, sum, checksum)
+		}
+	}
+
+	return nil
+}
+
+// IsRemote returns true if the backup file is uploaded to the object storage
+func IsRemote(location string) bool {
+	return strings.HasPrefix(location, s3Scheme)
+}
+
+// Location returns the location of the object key in the bucket
+func Location(bucket, key string) string {
+	return s3Scheme + bucket + "/" + key
+}
+
+// SplitLocation returns the bucket and the object key of the location uploaded to the object storage
+func SplitLocation(location string) (string, string, error) {
+	parts := strings.SplitN(strings.TrimPrefix(location, s3Scheme), "/", 2)
+	if !IsRemote(location) || len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", errors.Errorf("'%s' isnot uploaded to the object storage", location)
+	}
+
+	return parts[0], parts[1], nil
+}
+
+// Key returns the object key of the backup file of unit
+func Key(unitID, path string) string {
+	return unitID + "/" + filepath.Base(path)
+}
+
+// LocalPath returns the path of the backup file on the manager,
+// file is the path in the container under backup dir,
+// mount is the NFS source "addr:dir",mounts is the content of /proc/mounts.
+func LocalPath(file, backup, mount, mounts string) string {
+	index := strings.Index(mounts, mount)
+	if index != -1 {
+		parts := strings.SplitN(mounts[index:], "\n", 2)
+		mounts = parts[0]
+	}
+
+	parts := strings.Split(mounts, " ")
+	if len(parts) > 1 {
+		mount = parts[1]
+	}
+
+	file = strings.Replace(file, backup, mount, 1)
+
+	return filepath.Clean(file)
+}
@@ -0,0 +1,333 @@
+package garden
+
+import (
+	stderr "errors"
+	"time"
+
+	"github.com/docker/swarm/garden/database"
+	"github.com/docker/swarm/garden/structs"
+	"github.com/docker/swarm/garden/utils"
+	"github.com/docker/swarm/garden/vault"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ssh"
+)
+
+// backupTargetCredential is the credential name of the S3 backup target secret key
+const backupTargetCredential = "backup_target_s3"
+
+var errVaultNotConfigured = stderr.New("credential vault is not configured")
+
+// SetVault set the vault used to encrypt and decrypt host credentials and the S3 backup target secret key.
+func (gd *Garden) SetVault(v *vault.Vault) {
+	gd.vault = v
+}
+
+// VaultConfigured returns true if the vault is set.
+func (gd *Garden) VaultConfigured() bool {
+	return gd.vault != nil
+}
+
+func convertCredential(c database.Credential) structs.CredentialInfo {
+	return structs.CredentialInfo{
+		ID:        c.ID,
+		Name:      c.Name,
+		Type:      c.Type,
+		Username:  c.Username,
+		Port:      c.Port,
+		Version:   c.Version,
+		CreatedAt: utils.TimeToString(c.CreatedAt),
+		UpdatedAt: utils.TimeToString(c.UpdatedAt),
+	}
+}
+
+// encryptSecret validates the secret by type and returns the encrypted secret.
+func (gd *Garden) encryptSecret(typ, password, privateKey string) (string, error) {
+	if gd.vault == nil {
+		return "", errors.WithStack(errVaultNotConfigured)
+	}
+
+	var secret string
+
+	switch typ {
+	case database.CredentialPassword:
+		if password == "" {
+			return "", errors.New("password is required")
+		}
+
+		secret = password
+
+	case database.CredentialSSHKey:
+		if privateKey == "" {
+			return "", errors.New("private_key is required")
+		}
+
+		if _, err := ssh.ParsePrivateKey([]byte(privateKey)); err != nil {
+			return "", errors.Wrap(err, "parse private key")
+		}
+
+		secret = privateKey
+
+	default:
+		return "", errors.Errorf("unsupported credential type:%s", typ)
+	}
+
+	return gd.vault.Encrypt([]byte(secret))
+}
+
+// CreateCredential stores a new host credential,the secret is encrypted by vault.
+func (gd *Garden) CreateCredential(req structs.PostCredentialRequest) (string, error) {
+	if req.Name == "" || req.Username == "" {
+		return "", errors.New("credential name and username are required")
+	}
+
+	secret, err := gd.encryptSecret(req.Type, req.Password, req.PrivateKey)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	c := database.Credential{
+		ID:        utils.Generate32UUID(),
+		Name:      req.Name,
+		Type:      req.Type,
+		Username:  req.Username,
+		Secret:    secret,
+		Port:      req.Port,
+		Version:   1,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	err = gd.ormer.InsertCredential(c)
+
+	return c.ID, err
+}
+
+// VaultHostCredential stores the SSHConfig of the host as a new credential encrypted by vault,
+// returns the credential ID,the SSHConfig of host isnot kept in plain.
+func (gd *Garden) VaultHostCredential(addr string, config structs.SSHConfig) (string, error) {
+	req := structs.PostCredentialRequest{
+		Name:     "host_" + addr + "_" + utils.Generate8UUID(),
+		Type:     database.CredentialPassword,
+		Username: config.Username,
+		Password: config.Password,
+		Port:     config.Port,
+	}
+
+	if len(config.PrivateKey) > 0 {
+		req.Type = database.CredentialSSHKey
+		req.PrivateKey = string(config.PrivateKey)
+	}
+
+	return gd.CreateCredential(req)
+}
+
+// GetCredential returns the credential without secret.
+func (gd *Garden) GetCredential(nameOrID string) (structs.CredentialInfo, error) {
+	c, err := gd.ormer.GetCredential(nameOrID)
+	if err != nil {
+		return structs.CredentialInfo{}, err
+	}
+
+	return convertCredential(c), nil
+}
+
+// ListCredentials returns all credentials without secret.
+func (gd *Garden) ListCredentials() ([]structs.CredentialInfo, error) {
+	list, err := gd.ormer.ListCredentials()
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]structs.CredentialInfo, 0, len(list))
+	for i := range list {
+		out = append(out, convertCredential(list[i]))
+	}
+
+	return out, nil
+}
+
+// RotateCredential updates the credential in place,
+// hosts referenced the credential login with the new secret.
+func (gd *Garden) RotateCredential(nameOrID string, req structs.PutCredentialRequest) error {
+	c, err := gd.ormer.GetCredential(nameOrID)
+	if err != nil {
+		return err
+	}
+
+	if c.Type == database.CredentialS3Key {
+		return errors.Errorf("credential %s is the backup target secret key,updated by the backup target", c.Name)
+	}
+
+	if req.Username != nil {
+		if *req.Username == "" {
+			return errors.New("credential username is required")
+		}
+
+		c.Username = *req.Username
+	}
+
+	if req.Port != nil {
+		c.Port = *req.Port
+	}
+
+	if req.PrivateKey != "" || req.Password != "" {
+		typ := database.CredentialPassword
+		if req.PrivateKey != "" {
+			typ = database.CredentialSSHKey
+		}
+
+		c.Secret, err = gd.encryptSecret(typ, req.Password, req.PrivateKey)
+		if err != nil {
+			return err
+		}
+
+		c.Type = typ
+	}
+
+	c.UpdatedAt = time.Now()
+
+	return gd.ormer.SetCredential(c)
+}
+
+// RemoveCredential removes the credential,returns error if referenced by any host.
+func (gd *Garden) RemoveCredential(nameOrID string) error {
+	c, err := gd.ormer.GetCredential(nameOrID)
+	if err != nil {
+		if database.IsNotFound(err) {
+			return nil
+		}
+
+		return err
+	}
+
+	if c.Type == database.CredentialS3Key {
+		return errors.Errorf("credential %s is the backup target secret key,updated by the backup target", c.Name)
+	}
+
+	n, err := gd.ormer.CountNodeByCredential(c.ID)
+	if err != nil {
+		return err
+	}
+
+	if n > 0 {
+		return errors.Errorf("credential %s is referenced by %d hosts", c.Name, n)
+	}
+
+	return gd.ormer.DelCredential(c.ID)
+}
+
+// CredentialSSHConfig returns the decrypted SSHConfig of credential.
+func (gd *Garden) CredentialSSHConfig(nameOrID string) (structs.SSHConfig, error) {
+	if gd.vault == nil {
+		return structs.SSHConfig{}, errors.WithStack(errVaultNotConfigured)
+	}
+
+	c, err := gd.ormer.GetCredential(nameOrID)
+	if err != nil {
+		return structs.SSHConfig{}, err
+	}
+
+	if c.Type != database.CredentialPassword && c.Type != database.CredentialSSHKey {
+		return structs.SSHConfig{}, errors.Errorf("credential %s isnot a host credential", c.Name)
+	}
+
+	secret, err := gd.vault.Decrypt(c.Secret)
+	if err != nil {
+		return structs.SSHConfig{}, errors.WithMessage(err, "credential "+c.Name)
+	}
+
+	config := structs.SSHConfig{
+		Username: c.Username,
+		Port:     c.Port,
+	}
+
+	if c.Type == database.CredentialSSHKey {
+		config.PrivateKey = secret
+	} else {
+		config.Password = string(secret)
+	}
+
+	return config, nil
+}
+
+// NodeSSHConfig returns SSHConfig of the node credential.
+func (gd *Garden) NodeSSHConfig(nameOrID string) (structs.SSHConfig, error) {
+	n, err := gd.ormer.GetNode(nameOrID)
+	if err != nil {
+		return structs.SSHConfig{}, err
+	}
+
+	if n.Credential == "" {
+		return structs.SSHConfig{}, errors.Errorf("host %s has no credential", n.Addr)
+	}
+
+	return gd.CredentialSSHConfig(n.Credential)
+}
+
+// SetBackupTarget updates the backup target settings,
+// the S3 secret key is encrypted by vault into the credential backupTargetCredential,
+// the secret key isnot kept in the system config.
+func (gd *Garden) SetBackupTarget(t database.BackupTarget) error {
+	if t.Type == database.BackupTargetS3 && t.SecretKey != "" {
+		if gd.vault == nil {
+			return errors.WithStack(errVaultNotConfigured)
+		}
+
+		secret, err := gd.vault.Encrypt([]byte(t.SecretKey))
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		c, err := gd.ormer.GetCredential(backupTargetCredential)
+		if err == nil {
+			c.Username = t.AccessKey
+			c.Secret = secret
+			c.UpdatedAt = now
+
+			err = gd.ormer.SetCredential(c)
+		} else if database.IsNotFound(err) {
+			err = gd.ormer.InsertCredential(database.Credential{
+				ID:        utils.Generate32UUID(),
+				Name:      backupTargetCredential,
+				Type:      database.CredentialS3Key,
+				Username:  t.AccessKey,
+				Secret:    secret,
+				Version:   1,
+				CreatedAt: now,
+				UpdatedAt: now,
+			})
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	t.SecretKey = ""
+
+	return gd.ormer.SetBackupTarget(t)
+}
+
+// BackupSecretKey returns the decrypted S3 secret key of the backup target,see backup.SecretKeyFunc.
+func (gd *Garden) BackupSecretKey(t database.BackupTarget) (string, error) {
+	if gd.vault == nil {
+		return "", errors.WithStack(errVaultNotConfigured)
+	}
+
+	c, err := gd.ormer.GetCredential(backupTargetCredential)
+	if err != nil {
+		return "", err
+	}
+
+	if c.Type != database.CredentialS3Key || c.Username != t.AccessKey {
+		return "", errors.Errorf("credential %s isnot the secret key of S3 access key %s", c.Name, t.AccessKey)
+	}
+
+	secret, err := gd.vault.Decrypt(c.Secret)
+	if err != nil {
+		return "", errors.WithMessage(err, "credential "+c.Name)
+	}
+
+	return string(secret), nil
+}
@@ -0,0 +1,122 @@
+package database
+
+import (
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+// ErrBackupCallbackUsed the BackupCallback has been used by another callback
+var ErrBackupCallbackUsed = errors.New("backup callback has been used")
+
+// BackupCallback table structure,the callback token issued to the backup task,
+// Secret signs the token,UsedAt is zero until the first valid callback,
+// BackupFileID and Path are recorded by the first valid callback.
+type BackupCallback struct {
+	TaskID       string    `db:"task_id"`
+	UnitID       string    `db:"unit_id"`
+	Secret       string    `db:"secret"`
+	BackupFileID string    `db:"backup_file_id"`
+	Path         string    `db:"path"`
+	ExpiresAt    time.Time `db:"expires_at"`
+	UsedAt       time.Time `db:"used_at"`
+	CreatedAt    time.Time `db:"created_at"`
+}
+
+func (db dbBase) backupCallbackTable() string {
+	return db.prefix + "_backup_callback"
+}
+
+// InsertBackupCallback insert BackupCallback issued to the backup task.
+func (db dbBase) InsertBackupCallback(c BackupCallback) error {
+	query := "INSERT INTO " + db.backupCallbackTable() + " (task_id,unit_id,secret,backup_file_id,path,expires_at,used_at,created_at) VALUES (:task_id,:unit_id,:secret,:backup_file_id,:path,:expires_at,:used_at,:created_at)"
+
+	_, err := db.NamedExec(query, &c)
+
+	return errors.Wrap(err, "insert BackupCallback")
+}
+
+// GetBackupCallback get BackupCallback by task ID.
+func (db dbBase) GetBackupCallback(task string) (BackupCallback, error) {
+	var (
+		c     BackupCallback
+		query = "SELECT task_id,unit_id,secret,backup_file_id,path,expires_at,used_at,created_at FROM " + db.backupCallbackTable() + " WHERE task_id=?"
+	)
+
+	err := db.Get(&c, query, task)
+
+	return c, errors.Wrap(err, "get BackupCallback by task:"+task)
+}
+
+// txSetBackupCallbackUsed marks the BackupCallback used if it is unused,
+// returns ErrBackupCallbackUsed if it has been used by a concurrent callback.
+func (db dbBase) txSetBackupCallbackUsed(tx *sqlx.Tx, c BackupCallback) error {
+	query := "UPDATE " + db.backupCallbackTable() + " SET backup_file_id=?,path=?,used_at=? WHERE task_id=? AND (used_at IS NULL OR used_at=?)"
+
+	r, err := tx.Exec(query, c.BackupFileID, c.Path, c.UsedAt, c.TaskID, time.Time{})
+	if err != nil {
+		return errors.Wrap(err, "Tx update BackupCallback used")
+	}
+
+	n, err := r.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err, "Tx update BackupCallback used")
+	}
+
+	if n != 1 {
+		return errors.Wrap(ErrBackupCallbackUsed, "Tx update BackupCallback used:"+c.TaskID)
+	}
+
+	return nil
+}
+
+// InsertBackupFileWithCallback insert BackupFile,update Task status and mark the BackupCallback used in a Tx.
+func (db dbBase) InsertBackupFileWithCallback(bf BackupFile, t Task, c BackupCallback) error {
+	do := func(tx *sqlx.Tx) error {
+		err := db.txInsertBackupFile(tx, bf)
+		if err != nil {
+			return err
+		}
+
+		err = db.txSetBackupCallbackUsed(tx, c)
+		if err != nil {
+			return err
+		}
+
+		return db.txSetTask(tx, t)
+	}
+
+	return db.txFrame(do)
+}
+
+// SetTaskWithBackupCallback update Task status and mark the BackupCallback used in a Tx,
+// used by the callback of failed backup task.
+func (db dbBase) SetTaskWithBackupCallback(t Task, c BackupCallback) error {
+	do := func(tx *sqlx.Tx) error {
+		err := db.txSetBackupCallbackUsed(tx, c)
+		if err != nil {
+			return err
+		}
+
+		return db.txSetTask(tx, t)
+	}
+
+	return db.txFrame(do)
+}
+
+// SetBackupFileWithTask update the BackupFile reported by the repeat callback and Task status in a Tx.
+func (db dbBase) SetBackupFileWithTask(bf BackupFile, t Task) error {
+	do := func(tx *sqlx.Tx) error {
+		query := "UPDATE " + db.backupFileTable() + " SET size=?,checksum=?,verify_status=?,remark=?,tag=?,retention=?,finished_at=? WHERE id=?"
+
+		_, err := tx.Exec(query, bf.SizeByte, bf.Checksum, bf.Verify, bf.Remark, bf.Tag, bf.Retention, bf.FinishedAt, bf.ID)
+		if err != nil {
+			return errors.Wrap(err, "Tx update BackupFile")
+		}
+
+		return db.txSetTask(tx, t)
+	}
+
+	return db.txFrame(do)
+}
@@ -0,0 +1,76 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	"github.com/docker/swarm/garden/utils"
+	"github.com/pkg/errors"
+)
+
+func TestBackupCallbackReplay(t *testing.T) {
+	if ormer == nil || db == nil {
+		t.Skip("orm:db is required")
+	}
+
+	task := NewTask("unit0001", BackupManualTask, "unit0001", "backup callback replay", nil, 300)
+
+	err := db.InsertTask(task)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	defer db.delTasks([]Task{task})
+
+	c := BackupCallback{
+		TaskID:    task.ID,
+		UnitID:    "unit0001",
+		Secret:    utils.Generate32UUID(),
+		ExpiresAt: time.Now().Add(time.Hour),
+		CreatedAt: time.Now(),
+	}
+
+	err = db.InsertBackupCallback(c)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	// the concurrent first callbacks passed the used check
+	files := make([]BackupFile, 2)
+	errs := make(chan error, len(files))
+
+	for i := range files {
+		files[i] = BackupFile{
+			ID:         utils.Generate32UUID(),
+			TaskID:     task.ID,
+			UnitID:     c.UnitID,
+			Path:       "/backup/" + utils.Generate8UUID(),
+			Retention:  time.Now().Add(time.Hour),
+			CreatedAt:  time.Now(),
+			FinishedAt: time.Now(),
+		}
+
+		go func(bf BackupFile) {
+			c := c
+			c.BackupFileID = bf.ID
+			c.Path = bf.Path
+			c.UsedAt = time.Now()
+
+			errs <- db.InsertBackupFileWithCallback(bf, task, c)
+		}(files[i])
+	}
+	defer db.DelBackupFiles(files)
+
+	used := 0
+	for range files {
+		err := <-errs
+		if errors.Cause(err) == ErrBackupCallbackUsed {
+			used++
+		} else if err != nil {
+			t.Errorf("%+v", err)
+		}
+	}
+
+	if used != 1 {
+		t.Errorf("expected one callback rejected as used but got %d", used)
+	}
+}
@@ -19,9 +19,30 @@ type BackupFileIface interface {
 
 	ListBackupFilesByService(nameOrID string) ([]BackupFile, error)
 
+	SetBackupFileVerified(bf BackupFile) error
+
+	SetBackupFilePath(id, path string) error
+
 	DelBackupFiles(files []BackupFile) error
+
+	InsertBackupCallback(c BackupCallback) error
+
+	GetBackupCallback(task string) (BackupCallback, error)
+
+	InsertBackupFileWithCallback(bf BackupFile, t Task, c BackupCallback) error
+
+	SetBackupFileWithTask(bf BackupFile, t Task) error
+
+	SetTaskWithBackupCallback(t Task, c BackupCallback) error
 }
 
+const (
+	// BackupFile.Verify
+	BackupFileUnverified   = ""
+	BackupFileVerifyPassed = "passed"
+	BackupFileVerifyFailed = "failed"
+)
+
 // BackupFile is table _backup_files structure,correspod with backup files
 type BackupFile struct {
 	ID         string    `db:"id" json:"id"`
@@ -34,6 +55,9 @@ type BackupFile struct {
 	Remark     string    `db:"remark" json:"remark"`
 	Tag        string    `db:"tag" json:"tag"`
 	SizeByte   int       `db:"size" json:"size"`
+	Checksum   string    `db:"checksum" json:"checksum"` // sha256 of the backup files
+	Verify     string    `db:"verify_status" json:"verify_status"`
+	VerifiedAt time.Time `db:"verified_at" json:"verified_at"`
 	Retention  time.Time `db:"retention" json:"retention"`
 	CreatedAt  time.Time `db:"created_at" json:"created_at"`
 	FinishedAt time.Time `db:"finished_at" json:"finished_at"`
@@ -47,7 +71,7 @@ func (db dbBase) backupFileTable() string {
 func (db dbBase) ListBackupFiles() ([]BackupFile, error) {
 	var (
 		out   []BackupFile
-		query = "SELECT id,task_id,unit_id,type,tables,path,nfs_mount_src,remark,tag,size,retention,created_at,finished_at FROM " + db.backupFileTable() + " ORDER BY finished_at DESC"
+		query = "SELECT id,task_id,unit_id,type,tables,path,nfs_mount_src,remark,tag,size,checksum,verify_status,verified_at,retention,created_at,finished_at FROM " + db.backupFileTable() + " ORDER BY finished_at DESC"
 	)
 
 	err := db.Select(&out, query)
@@ -62,7 +86,7 @@ func (db dbBase) ListBackupFiles() ([]BackupFile, error) {
 func (db dbBase) ListBackupFilesByTag(tag string) ([]BackupFile, error) {
 	var (
 		out   []BackupFile
-		query = "SELECT id,task_id,unit_id,type,tables,path,nfs_mount_src,remark,tag,size,retention,created_at,finished_at FROM " + db.backupFileTable() + " WHERE tag=? ORDER BY finished_at DESC"
+		query = "SELECT id,task_id,unit_id,type,tables,path,nfs_mount_src,remark,tag,size,checksum,verify_status,verified_at,retention,created_at,finished_at FROM " + db.backupFileTable() + " WHERE tag=? ORDER BY finished_at DESC"
 	)
 
 	err := db.Select(&out, query, tag)
@@ -90,7 +114,7 @@ func (db dbBase) ListBackupFilesByService(service string) ([]BackupFile, error)
 		return nil, nil
 	}
 
-	query = "SELECT id,task_id,unit_id,type,tables,path,nfs_mount_src,remark,tag,size,retention,created_at,finished_at FROM " + db.backupFileTable() + " WHERE unit_id IN (?);"
+	query = "SELECT id,task_id,unit_id,type,tables,path,nfs_mount_src,remark,tag,size,checksum,verify_status,verified_at,retention,created_at,finished_at FROM " + db.backupFileTable() + " WHERE unit_id IN (?);"
 
 	query, args, err := sqlx.In(query, units)
 	if err != nil {
@@ -109,7 +133,7 @@ func (db dbBase) ListBackupFilesByService(service string) ([]BackupFile, error)
 func (db dbBase) GetBackupFile(id string) (BackupFile, error) {
 	var row BackupFile
 
-	query := "SELECT id,task_id,unit_id,type,tables,path,nfs_mount_src,remark,tag,size,retention,created_at,finished_at FROM " + db.backupFileTable() + " WHERE id=?"
+	query := "SELECT id,task_id,unit_id,type,tables,path,nfs_mount_src,remark,tag,size,checksum,verify_status,verified_at,retention,created_at,finished_at FROM " + db.backupFileTable() + " WHERE id=?"
 
 	err := db.Get(&row, query, id)
 
@@ -117,7 +141,7 @@ func (db dbBase) GetBackupFile(id string) (BackupFile, error) {
 }
 
 func (db dbBase) txInsertBackupFile(tx *sqlx.Tx, bf BackupFile) error {
-	query := "INSERT INTO " + db.backupFileTable() + " (id,task_id,unit_id,type,tables,path,nfs_mount_src,remark,tag,size,retention,created_at,finished_at) VALUES (:id,:task_id,:unit_id,:type,:tables,:path,:nfs_mount_src,:remark,:tag,:size,:retention,:created_at,:finished_at)"
+	query := "INSERT INTO " + db.backupFileTable() + " (id,task_id,unit_id,type,tables,path,nfs_mount_src,remark,tag,size,checksum,verify_status,verified_at,retention,created_at,finished_at) VALUES (:id,:task_id,:unit_id,:type,:tables,:path,:nfs_mount_src,:remark,:tag,:size,:checksum,:verify_status,:verified_at,:retention,:created_at,:finished_at)"
 
 	_, err := tx.NamedExec(query, bf)
 
@@ -137,6 +161,24 @@ func (db dbBase) InsertBackupFileWithTask(bf BackupFile, t Task) error {
 	return db.txFrame(do)
 }
 
+// SetBackupFileVerified update checksum,verify_status and verified_at by ID
+func (db dbBase) SetBackupFileVerified(bf BackupFile) error {
+	query := "UPDATE " + db.backupFileTable() + " SET checksum=?,verify_status=?,verified_at=? WHERE id=?"
+
+	_, err := db.Exec(query, bf.Checksum, bf.Verify, bf.VerifiedAt, bf.ID)
+
+	return errors.Wrap(err, "update BackupFile verified")
+}
+
+// SetBackupFilePath update path by ID,the location of the backup file after uploaded
+func (db dbBase) SetBackupFilePath(id, path string) error {
+	query := "UPDATE " + db.backupFileTable() + " SET path=? WHERE id=?"
+
+	_, err := db.Exec(query, path, id)
+
+	return errors.Wrap(err, "update BackupFile path")
+}
+
 func (db dbBase) DelBackupFiles(files []BackupFile) error {
 	do := func(tx *sqlx.Tx) error {
 		query := "DELETE FROM " + db.backupFileTable() + " WHERE id=?"
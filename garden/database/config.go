@@ -17,6 +17,7 @@ type SysConfigOrmer interface {
 	GetSysConfigIface
 
 	InsertSysConfig(c SysConfig) error
+	SetBackupTarget(t BackupTarget) error
 	GetPorts() (Ports, error)
 	GetRegistry() (Registry, error)
 	GetAuthConfig() (*types.AuthConfig, error)
@@ -31,6 +32,26 @@ type SysConfig struct {
 	ConsulConfig
 	Registry
 	SSHDeliver
+	BackupTarget
+}
+
+const (
+	// BackupTarget.Type
+	BackupTargetNFS = "nfs"
+	BackupTargetS3  = "s3"
+)
+
+// BackupTarget is where the backup files are kept,
+// backup files are uploaded from BackupDir to the S3-compatible object storage if Type is s3,
+// Quota is the space of the bucket in byte,0 is unlimited.
+type BackupTarget struct {
+	Type      string `db:"backup_target" json:"backup_target"`
+	Endpoint  string `db:"s3_endpoint" json:"s3_endpoint"`
+	Region    string `db:"s3_region" json:"s3_region"`
+	Bucket    string `db:"s3_bucket" json:"s3_bucket"`
+	AccessKey string `db:"s3_access_key" json:"-"`
+	SecretKey string `db:"s3_secret_key" json:"-"`
+	Quota     int64  `db:"s3_quota" json:"s3_quota"`
 }
 
 type Ports struct {
@@ -98,7 +119,7 @@ func (db dbBase) sysConfigTable() string {
 // InsertSysConfig insert a new SysConfig
 func (db dbBase) InsertSysConfig(c SysConfig) error {
 
-	query := "INSERT INTO " + db.sysConfigTable() + " (dc_id,consul_ip,consul_port,consul_dc,consul_token,consul_wait_time,swarm_agent_port,registry_os_username,registry_domain,registry_ip,registry_port,registry_ssh_port,registry_username,registry_password,registry_email,registry_token,registry_ca_crt,source_dir,clean_script_name,init_script_name,ca_crt_name,destination_dir,docker_port,retry,backup_dir) VALUES (:dc_id,:consul_ip,:consul_port,:consul_dc,:consul_token,:consul_wait_time,:swarm_agent_port,:registry_os_username,:registry_domain,:registry_ip,:registry_port,:registry_ssh_port,:registry_username,:registry_password,:registry_email,:registry_token,:registry_ca_crt,:source_dir,:clean_script_name,:init_script_name,:ca_crt_name,:destination_dir,:docker_port,:retry,:backup_dir)"
+	query := "INSERT INTO " + db.sysConfigTable() + " (dc_id,consul_ip,consul_port,consul_dc,consul_token,consul_wait_time,swarm_agent_port,registry_os_username,registry_domain,registry_ip,registry_port,registry_ssh_port,registry_username,registry_password,registry_email,registry_token,registry_ca_crt,source_dir,clean_script_name,init_script_name,ca_crt_name,destination_dir,docker_port,retry,backup_dir,backup_target,s3_endpoint,s3_region,s3_bucket,s3_access_key,s3_secret_key,s3_quota) VALUES (:dc_id,:consul_ip,:consul_port,:consul_dc,:consul_token,:consul_wait_time,:swarm_agent_port,:registry_os_username,:registry_domain,:registry_ip,:registry_port,:registry_ssh_port,:registry_username,:registry_password,:registry_email,:registry_token,:registry_ca_crt,:source_dir,:clean_script_name,:init_script_name,:ca_crt_name,:destination_dir,:docker_port,:retry,:backup_dir,:backup_target,:s3_endpoint,:s3_region,:s3_bucket,:s3_access_key,:s3_secret_key,:s3_quota)"
 
 	_, err := db.NamedExec(query, &c)
 
@@ -127,13 +148,22 @@ func (c SysConfig) GetConsulAddrs() []string {
 func (db dbBase) GetSysConfig() (SysConfig, error) {
 	var c = SysConfig{}
 
-	query := "SELECT dc_id,consul_ip,consul_port,consul_dc,consul_token,consul_wait_time,swarm_agent_port,registry_domain,registry_ip,registry_port,registry_ssh_port,registry_username,registry_password,registry_email,registry_token,registry_ca_crt,source_dir,clean_script_name,init_script_name,ca_crt_name,destination_dir,docker_port,retry,registry_os_username,backup_dir FROM " + db.sysConfigTable() + " LIMIT 1"
+	query := "SELECT dc_id,consul_ip,consul_port,consul_dc,consul_token,consul_wait_time,swarm_agent_port,registry_domain,registry_ip,registry_port,registry_ssh_port,registry_username,registry_password,registry_email,registry_token,registry_ca_crt,source_dir,clean_script_name,init_script_name,ca_crt_name,destination_dir,docker_port,retry,registry_os_username,backup_dir,backup_target,s3_endpoint,s3_region,s3_bucket,s3_access_key,s3_secret_key,s3_quota FROM " + db.sysConfigTable() + " LIMIT 1"
 
 	err := db.Get(&c, query)
 
 	return c, errors.Wrap(err, "get SystemConfig")
 }
 
+// SetBackupTarget update the backup target settings
+func (db dbBase) SetBackupTarget(t BackupTarget) error {
+	query := "UPDATE " + db.sysConfigTable() + " SET backup_target=?,s3_endpoint=?,s3_region=?,s3_bucket=?,s3_access_key=?,s3_secret_key=?,s3_quota=?"
+
+	_, err := db.Exec(query, t.Type, t.Endpoint, t.Region, t.Bucket, t.AccessKey, t.SecretKey, t.Quota)
+
+	return errors.Wrap(err, "update SysConfig BackupTarget")
+}
+
 func (db dbBase) GetAuthConfig() (*types.AuthConfig, error) {
 	r, err := db.GetRegistry()
 	if err != nil {
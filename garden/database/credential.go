@@ -0,0 +1,114 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// Credential.Type
+	CredentialPassword = "password"
+	CredentialSSHKey   = "ssh_key"
+	// CredentialS3Key is the secret key of the S3 backup target,not a host credential
+	CredentialS3Key = "s3_key"
+)
+
+type CredentialOrmer interface {
+	InsertCredential(c Credential) error
+
+	GetCredential(nameOrID string) (Credential, error)
+
+	ListCredentials() ([]Credential, error)
+
+	SetCredential(c Credential) error
+
+	CountNodeByCredential(ID string) (int, error)
+
+	DelCredential(ID string) error
+}
+
+// Credential table structure,SSH login credential of hosts,
+// Secret is the encrypted password or private key.
+type Credential struct {
+	ID        string    `db:"id"`
+	Name      string    `db:"name"`
+	Type      string    `db:"type"`
+	Username  string    `db:"username"`
+	Secret    string    `db:"secret"`
+	Port      int       `db:"port"`
+	Version   int       `db:"version"`
+	CreatedAt time.Time `db:"created_at"`
+	UpdatedAt time.Time `db:"updated_at"`
+}
+
+func (db dbBase) credentialTable() string {
+	return db.prefix + "_credential"
+}
+
+// InsertCredential insert a new record.
+func (db dbBase) InsertCredential(c Credential) error {
+	query := "INSERT INTO " + db.credentialTable() + " (id,name,type,username,secret,port,version,created_at,updated_at) VALUES (:id,:name,:type,:username,:secret,:port,:version,:created_at,:updated_at)"
+
+	_, err := db.NamedExec(query, &c)
+
+	return errors.Wrap(err, "insert Credential")
+}
+
+// GetCredential get Credential by nameOrID.
+func (db dbBase) GetCredential(nameOrID string) (Credential, error) {
+	var (
+		c     Credential
+		query = "SELECT id,name,type,username,secret,port,version,created_at,updated_at FROM " + db.credentialTable() + " WHERE id=? OR name=?"
+	)
+
+	err := db.Get(&c, query, nameOrID, nameOrID)
+
+	return c, errors.Wrap(err, "get Credential by:"+nameOrID)
+}
+
+// ListCredentials returns all credentials.
+func (db dbBase) ListCredentials() ([]Credential, error) {
+	var (
+		out   []Credential
+		query = "SELECT id,name,type,username,secret,port,version,created_at,updated_at FROM " + db.credentialTable()
+	)
+
+	err := db.Select(&out, query)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	return out, errors.Wrap(err, "list Credentials")
+}
+
+// SetCredential update type,username,secret and port,the version is increased.
+func (db dbBase) SetCredential(c Credential) error {
+	query := "UPDATE " + db.credentialTable() + " SET type=?,username=?,secret=?,port=?,version=version+1,updated_at=? WHERE id=?"
+
+	_, err := db.Exec(query, c.Type, c.Username, c.Secret, c.Port, c.UpdatedAt, c.ID)
+
+	return errors.Wrap(err, "update Credential by ID")
+}
+
+// CountNodeByCredential returns the number of nodes reference the Credential.
+func (db dbBase) CountNodeByCredential(ID string) (int, error) {
+	var (
+		n     int
+		query = "SELECT COUNT(id) FROM " + db.nodeTable() + " WHERE credential_id=?"
+	)
+
+	err := db.Get(&n, query, ID)
+
+	return n, errors.Wrap(err, "count Node by Credential")
+}
+
+// DelCredential delete Credential by ID.
+func (db dbBase) DelCredential(ID string) error {
+	query := "DELETE FROM " + db.credentialTable() + " WHERE id=?"
+
+	_, err := db.Exec(query, ID)
+
+	return errors.Wrap(err, "delete Credential by ID")
+}
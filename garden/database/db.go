@@ -24,6 +24,8 @@ type Ormer interface {
 	BackupFileIface
 
 	SysConfigOrmer
+	CredentialOrmer
+	RestoreDrillOrmer
 	NetworkingOrmer
 	TaskOrmer
 	VolumeOrmer
@@ -25,6 +25,8 @@ type ImageIface interface {
 	InsertImageWithTask(img Image, t Task) error
 	SetImageAndTask(img Image, t Task) error
 
+	SetImageDeprecated(ID string, deprecated bool, reason string) error
+
 	DelImage(ID string) error
 }
 
@@ -40,6 +42,9 @@ type Image struct {
 	Size     int       `db:"size"`
 	Labels   string    `db:"label"`
 	UploadAt time.Time `db:"upload_at"`
+	// deprecated image is unavailable for new services and image updates
+	Deprecated       bool   `db:"deprecated"`
+	DeprecatedReason string `db:"deprecated_reason"`
 }
 
 func (db dbBase) imageTable() string {
@@ -53,7 +58,7 @@ func (im Image) Image() string {
 // InsertImage insert Image
 func (db dbBase) InsertImageWithTask(img Image, t Task) error {
 	do := func(tx *sqlx.Tx) error {
-		query := "INSERT INTO " + db.imageTable() + " (id,software_name,docker_image_id,major_version,minor_version,patch_version,build_version,size,label,upload_at) VALUES (:id,:software_name,:docker_image_id,:major_version,:minor_version,:patch_version,:build_version,:size,:label,:upload_at)"
+		query := "INSERT INTO " + db.imageTable() + " (id,software_name,docker_image_id,major_version,minor_version,patch_version,build_version,size,label,deprecated,deprecated_reason,upload_at) VALUES (:id,:software_name,:docker_image_id,:major_version,:minor_version,:patch_version,:build_version,:size,:label,:deprecated,:deprecated_reason,:upload_at)"
 
 		_, err := tx.NamedExec(query, &img)
 		if err != nil {
@@ -70,7 +75,7 @@ func (db dbBase) InsertImageWithTask(img Image, t Task) error {
 func (db dbBase) ListImages() ([]Image, error) {
 	var (
 		images []Image
-		query  = "SELECT id,software_name,docker_image_id,major_version,minor_version,patch_version,build_version,size,label,upload_at FROM " + db.imageTable()
+		query  = "SELECT id,software_name,docker_image_id,major_version,minor_version,patch_version,build_version,size,label,deprecated,deprecated_reason,upload_at FROM " + db.imageTable()
 	)
 
 	err := db.Select(&images, query)
@@ -83,7 +88,7 @@ func (db dbBase) ListImages() ([]Image, error) {
 
 func (db dbBase) GetImageVersion(nameOrID string) (Image, error) {
 	image := Image{}
-	query := "SELECT id,software_name,docker_image_id,major_version,minor_version,patch_version,build_version,size,label,upload_at FROM " + db.imageTable() + " WHERE id=? OR docker_image_id=?"
+	query := "SELECT id,software_name,docker_image_id,major_version,minor_version,patch_version,build_version,size,label,deprecated,deprecated_reason,upload_at FROM " + db.imageTable() + " WHERE id=? OR docker_image_id=?"
 	err := db.Get(&image, query, nameOrID, nameOrID)
 	if err == nil {
 		return image, nil
@@ -100,7 +105,7 @@ func (db dbBase) GetImageVersion(nameOrID string) (Image, error) {
 // GetImage returns Image select by name and version.
 func (db dbBase) GetImage(name string, major, minor, patch, build int) (Image, error) {
 	image := Image{}
-	query := "SELECT id,software_name,docker_image_id,major_version,minor_version,patch_version,build_version,size,label,upload_at FROM " + db.imageTable() + " WHERE software_name=? AND major_version=? AND minor_version=? AND patch_version=? AND build_version=?"
+	query := "SELECT id,software_name,docker_image_id,major_version,minor_version,patch_version,build_version,size,label,deprecated,deprecated_reason,upload_at FROM " + db.imageTable() + " WHERE software_name=? AND major_version=? AND minor_version=? AND patch_version=? AND build_version=?"
 
 	err := db.Get(&image, query, name, major, minor, patch, build)
 
@@ -123,6 +128,15 @@ func (db dbBase) SetImageAndTask(img Image, t Task) error {
 	return db.txFrame(do)
 }
 
+// SetImageDeprecated update Image.Deprecated&DeprecatedReason by ID.
+func (db dbBase) SetImageDeprecated(ID string, deprecated bool, reason string) error {
+	query := "UPDATE " + db.imageTable() + " SET deprecated=?,deprecated_reason=? WHERE id=?"
+
+	_, err := db.Exec(query, deprecated, reason, ID)
+
+	return errors.Wrap(err, "update Image deprecated by ID")
+}
+
 // DelImage delete Image by ID in Tx.
 func (db dbBase) DelImage(ID string) error {
 	do := func(tx *sqlx.Tx) error {
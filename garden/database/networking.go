@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"fmt"
 
+	"github.com/docker/swarm/garden/utils"
 	"github.com/jmoiron/sqlx"
 	"github.com/pkg/errors"
 )
@@ -21,6 +22,7 @@ type NetworkingOrmer interface {
 	ListIPByUnitID(unit string) ([]IP, error)
 	ListIPByNetworking(networkingID string) ([]IP, error)
 	CountIPWithCondition(networking string, allocated bool) (int, error)
+	CountFreeIP(networking string) (int, error)
 
 	AllocNetworking(unit, engine string, req []NetworkingRequire) ([]IP, error)
 
@@ -29,6 +31,7 @@ type NetworkingOrmer interface {
 
 	SetNetworkingEnable(ID string, enable bool) error
 	SetIPEnable([]uint32, string, bool) error
+	SetIPReserved([]uint32, string, string) error
 	SetIPs(ips []IP) error
 	ResetIPs(ips []IP) error
 }
@@ -45,9 +48,10 @@ type IP struct {
 	Engine     string `db:"engine_id"`
 	Bond       string `db:"net_dev"`
 	Bandwidth  int    `db:"bandwidth"`
+	Reserved   string `db:"reserved"` // service name the IP is reserved for
 }
 
-// ip_addr,prefix,networking_id,unit_id,gateway,vlan_id,enabled
+// ip_addr,prefix,networking_id,unit_id,gateway,vlan_id,enabled,reserved
 func (db dbBase) ipTable() string {
 	return db.prefix + "_ip"
 }
@@ -56,7 +60,7 @@ func (db dbBase) ipTable() string {
 func (db dbBase) ListIPs() ([]IP, error) {
 	var (
 		list  []IP
-		query = "SELECT ip_addr,prefix,networking_id,unit_id,gateway,vlan_id,enabled,engine_id,net_dev,bandwidth FROM " + db.ipTable()
+		query = "SELECT ip_addr,prefix,networking_id,unit_id,gateway,vlan_id,enabled,engine_id,net_dev,bandwidth,reserved FROM " + db.ipTable()
 	)
 
 	err := db.Select(&list, query)
@@ -71,7 +75,7 @@ func (db dbBase) ListIPs() ([]IP, error) {
 func (db dbBase) ListIPByNetworking(networking string) ([]IP, error) {
 	var list []IP
 
-	query := "SELECT ip_addr,prefix,networking_id,unit_id,gateway,vlan_id,enabled,engine_id,net_dev,bandwidth FROM " + db.ipTable() + " WHERE networking_id=?"
+	query := "SELECT ip_addr,prefix,networking_id,unit_id,gateway,vlan_id,enabled,engine_id,net_dev,bandwidth,reserved FROM " + db.ipTable() + " WHERE networking_id=?"
 
 	err := db.Select(&list, query, networking)
 	if err == sql.ErrNoRows {
@@ -94,9 +98,9 @@ func (db dbBase) listIPsByAllocated(allocated bool, num int) ([]IP, error) {
 	}
 
 	if num > 0 {
-		query = fmt.Sprintf("SELECT ip_addr,prefix,networking_id,unit_id,gateway,vlan_id,enabled,engine_id,net_dev,bandwidth FROM %s WHERE unit_id%s? LIMIT %d", db.ipTable(), opt, num)
+		query = fmt.Sprintf("SELECT ip_addr,prefix,networking_id,unit_id,gateway,vlan_id,enabled,engine_id,net_dev,bandwidth,reserved FROM %s WHERE unit_id%s? LIMIT %d", db.ipTable(), opt, num)
 	} else {
-		query = fmt.Sprintf("SELECT ip_addr,prefix,networking_id,unit_id,gateway,vlan_id,enabled,engine_id,net_dev,bandwidth FROM %s WHERE unit_id%s?", db.ipTable(), opt)
+		query = fmt.Sprintf("SELECT ip_addr,prefix,networking_id,unit_id,gateway,vlan_id,enabled,engine_id,net_dev,bandwidth,reserved FROM %s WHERE unit_id%s?", db.ipTable(), opt)
 	}
 
 	err := db.Select(&out, query, "")
@@ -111,7 +115,7 @@ func (db dbBase) listIPsByAllocated(allocated bool, num int) ([]IP, error) {
 func (db dbBase) ListIPByUnitID(unit string) ([]IP, error) {
 	var out []IP
 
-	query := "SELECT ip_addr,prefix,networking_id,unit_id,gateway,vlan_id,enabled,engine_id,net_dev,bandwidth FROM " + db.ipTable() + " WHERE unit_id=?"
+	query := "SELECT ip_addr,prefix,networking_id,unit_id,gateway,vlan_id,enabled,engine_id,net_dev,bandwidth,reserved FROM " + db.ipTable() + " WHERE unit_id=?"
 
 	err := db.Select(&out, query, unit)
 	if err == sql.ErrNoRows {
@@ -125,7 +129,7 @@ func (db dbBase) ListIPByUnitID(unit string) ([]IP, error) {
 func (db dbBase) ListIPByEngine(ID string) ([]IP, error) {
 	var out []IP
 
-	query := "SELECT ip_addr,prefix,networking_id,unit_id,gateway,vlan_id,enabled,engine_id,net_dev,bandwidth FROM " + db.ipTable() + " WHERE engine_id=?"
+	query := "SELECT ip_addr,prefix,networking_id,unit_id,gateway,vlan_id,enabled,engine_id,net_dev,bandwidth,reserved FROM " + db.ipTable() + " WHERE engine_id=?"
 
 	err := db.Select(&out, query, ID)
 	if err == sql.ErrNoRows {
@@ -154,6 +158,20 @@ func (db dbBase) CountIPWithCondition(networking string, allocated bool) (int, e
 	return n, errors.Wrap(err, "list []IP with condition")
 }
 
+// CountFreeIP returns num of the enabled IPs of networking,neither allocated nor reserved
+func (db dbBase) CountFreeIP(networking string) (int, error) {
+	n := 0
+
+	query := "SELECT COUNT(ip_addr) FROM " + db.ipTable() + " WHERE networking_id=? AND enabled=? AND unit_id=? AND reserved=?"
+
+	err := db.Get(&n, query, networking, true, "", "")
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+
+	return n, errors.Wrap(err, "count free IP by networking")
+}
+
 func combin(in []NetworkingRequire) [][]NetworkingRequire {
 	if len(in) == 0 {
 		return nil
@@ -193,6 +211,11 @@ func (db dbBase) AllocNetworking(unit, engine string, requires []NetworkingRequi
 
 	do := func(tx *sqlx.Tx) error {
 
+		service, err := db.txGetServiceNameByUnit(tx, unit)
+		if err != nil {
+			return err
+		}
+
 		in := combin(requires)
 
 		for _, list := range in {
@@ -203,11 +226,13 @@ func (db dbBase) AllocNetworking(unit, engine string, requires []NetworkingRequi
 
 			key := list[0].Networking
 
-			query := fmt.Sprintf("SELECT ip_addr,prefix,gateway,vlan_id,networking_id FROM %s WHERE networking_id=? AND enabled=? AND unit_id=? AND ip_addr <> ? LIMIT %d FOR UPDATE;", db.ipTable(), num)
+			// IPs reserved for the unit's service come first,
+			// IPs reserved for other services are never allocated.
+			query := fmt.Sprintf("SELECT ip_addr,prefix,gateway,vlan_id,networking_id,reserved FROM %s WHERE networking_id=? AND enabled=? AND unit_id=? AND ip_addr <> ? AND (reserved=? OR reserved=?) ORDER BY reserved DESC LIMIT %d FOR UPDATE;", db.ipTable(), num)
 			query = tx.Rebind(query)
 
 			var ips []IP
-			err := tx.Select(&ips, query, key, true, "", 0)
+			err := tx.Select(&ips, query, key, true, "", 0, service, "")
 			if err != nil {
 				return errors.Wrap(err, "Tx get available IP")
 			}
@@ -234,6 +259,20 @@ func (db dbBase) AllocNetworking(unit, engine string, requires []NetworkingRequi
 	return out, err
 }
 
+// txGetServiceNameByUnit returns the name of the service the unit belongs to,
+// returns "" if the unit is not found.
+func (db dbBase) txGetServiceNameByUnit(tx *sqlx.Tx, unit string) (string, error) {
+	name := ""
+	query := "SELECT S.name FROM " + db.serviceTable() + " S," + db.unitTable() + " U WHERE U.id=? AND U.service_id=S.id"
+
+	err := tx.Get(&name, query, unit)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+
+	return name, errors.Wrap(err, "Tx get service name by unit")
+}
+
 // txSetIPs update []IP in Tx
 func (db dbBase) txSetIPs(tx *sqlx.Tx, val []IP) error {
 	query := "UPDATE " + db.ipTable() + " SET unit_id=:unit_id,engine_id=:engine_id,net_dev=:net_dev,bandwidth=:bandwidth WHERE ip_addr=:ip_addr"
@@ -295,7 +334,7 @@ func (db dbBase) txResetIPByUnit(tx *sqlx.Tx, unit string) error {
 func (db dbBase) InsertNetworking(ips []IP) error {
 	do := func(tx *sqlx.Tx) error {
 
-		query := "INSERT INTO " + db.ipTable() + " ( ip_addr,prefix,networking_id,unit_id,gateway,vlan_id,enabled,engine_id,net_dev,bandwidth ) VALUES ( :ip_addr,:prefix,:networking_id,:unit_id,:gateway,:vlan_id,:enabled,:engine_id,:net_dev,:bandwidth )"
+		query := "INSERT INTO " + db.ipTable() + " ( ip_addr,prefix,networking_id,unit_id,gateway,vlan_id,enabled,engine_id,net_dev,bandwidth,reserved ) VALUES ( :ip_addr,:prefix,:networking_id,:unit_id,:gateway,:vlan_id,:enabled,:engine_id,:net_dev,:bandwidth,:reserved )"
 
 		stmt, err := tx.PrepareNamed(query)
 		if err != nil {
@@ -378,3 +417,40 @@ func (db dbBase) SetIPEnable(in []uint32, networking string, enable bool) error
 
 	return db.txFrame(do)
 }
+
+// SetIPReserved reserves []IP for the service,release reservations if service is "".
+// It returns error if any IP has been reserved for another service.
+func (db dbBase) SetIPReserved(in []uint32, networking, service string) error {
+	do := func(tx *sqlx.Tx) error {
+
+		query := tx.Rebind("SELECT reserved FROM " + db.ipTable() + " WHERE ip_addr=? AND networking_id=? FOR UPDATE")
+
+		stmt, err := tx.Prepare("UPDATE " + db.ipTable() + " SET reserved=? WHERE ip_addr=? AND networking_id=?")
+		if err != nil {
+			return errors.Wrap(err, "tx prepare update []IP")
+		}
+		defer stmt.Close()
+
+		for i := range in {
+			reserved := ""
+
+			err = tx.Get(&reserved, query, in[i], networking)
+			if err != nil {
+				return errors.Wrapf(err, "tx get IP %d in networking %s", in[i], networking)
+			}
+
+			if service != "" && reserved != "" && reserved != service {
+				return errors.Errorf("IP %s has been reserved for service %s", utils.Uint32ToIP(in[i]), reserved)
+			}
+
+			_, err = stmt.Exec(service, in[i], networking)
+			if err != nil {
+				return errors.Wrap(err, "tx update IP reserved")
+			}
+		}
+
+		return nil
+	}
+
+	return db.txFrame(do)
+}
@@ -137,3 +137,65 @@ func TestNetworking(t *testing.T) {
 		t.Errorf("%+v", err)
 	}
 }
+
+func TestSetIPReserved(t *testing.T) {
+	if ormer == nil {
+		t.Skip("orm:db is required")
+	}
+
+	networking := utils.Generate64UUID()
+	ips := make([]IP, 4)
+	in := make([]uint32, len(ips))
+
+	for i := range ips {
+		ips[i].IPAddr = 4232238000 + uint32(i)
+		ips[i].Prefix = 24
+		ips[i].Networking = networking
+		ips[i].Gateway = "192.168.1.1"
+		ips[i].Enabled = true
+		in[i] = ips[i].IPAddr
+	}
+
+	err := ormer.InsertNetworking(ips)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	defer func() {
+		err := ormer.DelNetworking(networking)
+		if err != nil {
+			t.Errorf("%+v", err)
+		}
+	}()
+
+	err = ormer.SetIPReserved(in[:2], networking, "service_a")
+	if err != nil {
+		t.Errorf("%+v", err)
+	}
+
+	err = ormer.SetIPReserved(in[1:3], networking, "service_b")
+	if err == nil {
+		t.Error("expected error,IP has been reserved for another service")
+	}
+
+	out, err := ormer.AllocNetworking(utils.Generate32UUID(), utils.Generate32UUID(), []NetworkingRequire{{Networking: networking}})
+	if err != nil {
+		t.Errorf("%+v", err)
+	}
+
+	for i := range out {
+		if out[i].Reserved != "" {
+			t.Errorf("unexpected IP %d reserved for %s", out[i].IPAddr, out[i].Reserved)
+		}
+	}
+
+	err = ormer.ResetIPs(out)
+	if err != nil {
+		t.Errorf("%+v", err)
+	}
+
+	err = ormer.SetIPReserved(in, networking, "")
+	if err != nil {
+		t.Errorf("%+v", err)
+	}
+}
@@ -2,6 +2,7 @@ package database
 
 import (
 	"database/sql"
+	"encoding/json"
 	"net"
 	"strings"
 	"time"
@@ -33,6 +34,10 @@ type NodeIface interface {
 	CountUnitByEngine(id string) (int, error)
 
 	SetNodeEnable(string, bool) error
+	SetNodeStatus(ID string, status int, enabled bool) error
+	SwapNodeStatus(ID string, old, status int) (bool, error)
+	SetNodeCredential(ID, credential string) error
+	SetNodeLabels(ID string, labels map[string]string) error
 	SetNodeParam(string, int) error
 
 	RegisterNode(n *Node, t *Task) error
@@ -40,6 +45,10 @@ type NodeIface interface {
 	DelNode(nameOrID string) error
 }
 
+// NodeStatusQuarantined is the status of host quarantined by the health monitor,
+// quarantined host isnot scheduled,Node.Enabled is kept as set by users.
+const NodeStatusQuarantined = 14
+
 // Node table structure,correspod with mainframe computer.
 type Node struct {
 	ID           string `db:"id"`
@@ -48,10 +57,12 @@ type Node struct {
 	EngineID     string `db:"engine_id"`
 	Room         string `db:"room"`
 	Seat         string `db:"seat"`
+	Labels       string `db:"labels"` // topology labels in JSON,such as rack,room,power and zone
 	Storage      string `db:"storage"`
 	MaxContainer int    `db:"max_container"`
 	Status       int    `db:"status"`
 	Enabled      bool   `db:"enabled"`
+	Credential   string `db:"credential_id"`
 
 	NFS
 
@@ -74,7 +85,7 @@ func (db dbBase) nodeTable() string {
 func (db dbBase) InsertNodesAndTask(nodes []Node, tasks []Task) error {
 	do := func(tx *sqlx.Tx) error {
 
-		query := "INSERT INTO " + db.nodeTable() + " (id,cluster_id,admin_ip,engine_id,room,seat,storage,max_container,status,enabled,register_at,nfs_ip,nfs_dir,nfs_mount_dir,nfs_mount_opts) VALUES (:id,:cluster_id,:admin_ip,:engine_id,:room,:seat,:storage,:max_container,:status,:enabled,:register_at,:nfs_ip,:nfs_dir,:nfs_mount_dir,:nfs_mount_opts)"
+		query := "INSERT INTO " + db.nodeTable() + " (id,cluster_id,admin_ip,engine_id,room,seat,labels,storage,max_container,status,enabled,credential_id,register_at,nfs_ip,nfs_dir,nfs_mount_dir,nfs_mount_opts) VALUES (:id,:cluster_id,:admin_ip,:engine_id,:room,:seat,:labels,:storage,:max_container,:status,:enabled,:credential_id,:register_at,:nfs_ip,:nfs_dir,:nfs_mount_dir,:nfs_mount_opts)"
 
 		if len(nodes) == 1 {
 			_, err := tx.NamedExec(query, &nodes[0])
@@ -127,6 +138,78 @@ func (db dbBase) SetNodeEnable(ID string, enabled bool) error {
 	return errors.Wrap(err, "update Node.Enabled by ID")
 }
 
+// SetNodeStatus returns error when Node update status and enabled.
+func (db dbBase) SetNodeStatus(ID string, status int, enabled bool) error {
+
+	query := "UPDATE " + db.nodeTable() + " SET status=?,enabled=? WHERE id=?"
+
+	_, err := db.Exec(query, status, enabled, ID)
+
+	return errors.Wrap(err, "update Node.Status&Enabled by ID")
+}
+
+// SwapNodeStatus updates Node.Status to status only if the current status is old,
+// returns false if the status has been changed by others.
+func (db dbBase) SwapNodeStatus(ID string, old, status int) (bool, error) {
+
+	query := "UPDATE " + db.nodeTable() + " SET status=? WHERE id=? AND status=?"
+
+	r, err := db.Exec(query, status, ID, old)
+	if err != nil {
+		return false, errors.Wrap(err, "swap Node.Status by ID")
+	}
+
+	n, err := r.RowsAffected()
+
+	return n > 0, errors.Wrap(err, "swap Node.Status by ID")
+}
+
+// SetNodeCredential returns error when Node update credential_id.
+func (db dbBase) SetNodeCredential(ID, credential string) error {
+
+	query := "UPDATE " + db.nodeTable() + " SET credential_id=? WHERE id=?"
+
+	_, err := db.Exec(query, credential, ID)
+
+	return errors.Wrap(err, "update Node.Credential by ID")
+}
+
+// SetNodeLabels returns error when Node update labels.
+func (db dbBase) SetNodeLabels(ID string, labels map[string]string) error {
+	dat, err := json.Marshal(labels)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	query := "UPDATE " + db.nodeTable() + " SET labels=? WHERE id=?"
+
+	_, err = db.Exec(query, string(dat), ID)
+
+	return errors.Wrap(err, "update Node.Labels by ID")
+}
+
+// TopologyLabels returns the topology labels of Node,
+// room and rack are set by Room and Seat if not labeled.
+func (n Node) TopologyLabels() map[string]string {
+	labels := make(map[string]string)
+
+	if n.Labels != "" {
+		err := json.Unmarshal([]byte(n.Labels), &labels)
+		if err != nil {
+			labels = make(map[string]string)
+		}
+	}
+
+	if _, ok := labels["room"]; !ok && n.Room != "" {
+		labels["room"] = n.Room
+	}
+	if _, ok := labels["rack"]; !ok && n.Seat != "" {
+		labels["rack"] = n.Seat
+	}
+
+	return labels
+}
+
 // RegisterNode returns error when Node UPDATE infomation.
 func (db dbBase) RegisterNode(n *Node, t *Task) error {
 	do := func(tx *sqlx.Tx) (err error) {
@@ -152,7 +235,7 @@ func (db dbBase) RegisterNode(n *Node, t *Task) error {
 // GetNode get Node by nameOrID.
 func (db dbBase) GetNode(nameOrID string) (Node, error) {
 	var node Node
-	query := "SELECT id,cluster_id,admin_ip,engine_id,room,seat,storage,max_container,status,enabled,register_at, nfs_ip,nfs_dir,nfs_mount_dir,nfs_mount_opts FROM " + db.nodeTable() + " WHERE id=? OR engine_id=?"
+	query := "SELECT id,cluster_id,admin_ip,engine_id,room,seat,labels,storage,max_container,status,enabled,credential_id,register_at,nfs_ip,nfs_dir,nfs_mount_dir,nfs_mount_opts FROM " + db.nodeTable() + " WHERE id=? OR engine_id=?"
 
 	err := db.Get(&node, query, nameOrID, nameOrID)
 
@@ -163,7 +246,7 @@ func (db dbBase) GetNode(nameOrID string) (Node, error) {
 func (db dbBase) GetNodeByAddr(addr string) (Node, error) {
 	var (
 		node  Node
-		query = "SELECT id,cluster_id,admin_ip,engine_id,room,seat,storage,max_container,status,enabled,register_at,nfs_ip,nfs_dir,nfs_mount_dir,nfs_mount_opts FROM " + db.nodeTable() + " WHERE admin_ip=?"
+		query = "SELECT id,cluster_id,admin_ip,engine_id,room,seat,labels,storage,max_container,status,enabled,credential_id,register_at,nfs_ip,nfs_dir,nfs_mount_dir,nfs_mount_opts FROM " + db.nodeTable() + " WHERE admin_ip=?"
 	)
 
 	addr, _, err := net.SplitHostPort(addr)
@@ -180,7 +263,7 @@ func (db dbBase) GetNodeByAddr(addr string) (Node, error) {
 func (db dbBase) ListNodes() ([]Node, error) {
 	var (
 		nodes []Node
-		query = "SELECT id,cluster_id,admin_ip,engine_id,room,seat,storage,max_container,status,enabled,register_at,nfs_ip,nfs_dir,nfs_mount_dir,nfs_mount_opts FROM " + db.nodeTable()
+		query = "SELECT id,cluster_id,admin_ip,engine_id,room,seat,labels,storage,max_container,status,enabled,credential_id,register_at,nfs_ip,nfs_dir,nfs_mount_dir,nfs_mount_opts FROM " + db.nodeTable()
 	)
 
 	err := db.Select(&nodes, query)
@@ -195,7 +278,7 @@ func (db dbBase) ListNodes() ([]Node, error) {
 func (db dbBase) ListNodesByCluster(cluster string) ([]Node, error) {
 	var (
 		nodes []Node
-		query = "SELECT id,cluster_id,admin_ip,engine_id,room,seat,storage,max_container,status,enabled,register_at,nfs_ip,nfs_dir,nfs_mount_dir,nfs_mount_opts FROM " + db.nodeTable() + " WHERE cluster_id=?"
+		query = "SELECT id,cluster_id,admin_ip,engine_id,room,seat,labels,storage,max_container,status,enabled,credential_id,register_at,nfs_ip,nfs_dir,nfs_mount_dir,nfs_mount_opts FROM " + db.nodeTable() + " WHERE cluster_id=?"
 	)
 
 	err := db.Select(&nodes, query, cluster)
@@ -224,7 +307,7 @@ func (db dbBase) ListNodesByEngines(names []string) ([]Node, error) {
 
 	var (
 		nodes []Node
-		query = "SELECT id,cluster_id,admin_ip,engine_id,room,seat,storage,max_container,status,enabled,register_at,nfs_ip,nfs_dir,nfs_mount_dir,nfs_mount_opts FROM " + db.nodeTable() + " WHERE engine_id IN (?);"
+		query = "SELECT id,cluster_id,admin_ip,engine_id,room,seat,labels,storage,max_container,status,enabled,credential_id,register_at,nfs_ip,nfs_dir,nfs_mount_dir,nfs_mount_opts FROM " + db.nodeTable() + " WHERE engine_id IN (?);"
 	)
 
 	query, args, err := sqlx.In(query, names)
@@ -248,7 +331,7 @@ func (db dbBase) ListNodesByIDs(in []string, cluster string) ([]Node, error) {
 
 	var (
 		nodes []Node
-		query = "SELECT id,cluster_id,admin_ip,engine_id,room,seat,storage,max_container,status,enabled,register_at,nfs_ip,nfs_dir,nfs_mount_dir,nfs_mount_opts FROM " + db.nodeTable() + " WHERE id IN (?);"
+		query = "SELECT id,cluster_id,admin_ip,engine_id,room,seat,labels,storage,max_container,status,enabled,credential_id,register_at,nfs_ip,nfs_dir,nfs_mount_dir,nfs_mount_opts FROM " + db.nodeTable() + " WHERE id IN (?);"
 	)
 
 	query, args, err := sqlx.In(query, in)
@@ -281,7 +364,7 @@ func (db dbBase) ListNodesByClusters(clusters []string, enable bool) ([]Node, er
 		return []Node{}, errors.New("clusters is required")
 	}
 
-	query := "SELECT id,cluster_id,admin_ip,engine_id,room,seat,storage,max_container,status,enabled,register_at,nfs_ip,nfs_dir,nfs_mount_dir,nfs_mount_opts FROM " + db.nodeTable() + " WHERE cluster_id IN (?) AND enabled=?;"
+	query := "SELECT id,cluster_id,admin_ip,engine_id,room,seat,labels,storage,max_container,status,enabled,credential_id,register_at,nfs_ip,nfs_dir,nfs_mount_dir,nfs_mount_opts FROM " + db.nodeTable() + " WHERE cluster_id IN (?) AND enabled=?;"
 	query, args, err := sqlx.In(query, clusters, enable)
 	if err != nil {
 		return nil, errors.Wrap(err, "select []Node IN clusterIDs")
@@ -0,0 +1,98 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// RestoreDrill.LastResult
+	RestoreDrillPassed = "passed"
+	RestoreDrillFailed = "failed"
+)
+
+type RestoreDrillOrmer interface {
+	SetRestoreDrill(d RestoreDrill) error
+
+	GetRestoreDrill(service string) (RestoreDrill, error)
+
+	ListRestoreDrills() ([]RestoreDrill, error)
+
+	SetRestoreDrillResult(d RestoreDrill) error
+
+	DelRestoreDrill(service string) error
+}
+
+// RestoreDrill table structure,the scheduled restore drill of service,
+// BackupFileID is empty means the latest backup file of the service,
+// Cmd is the validation command joined by '\n',empty means the command of the image.
+type RestoreDrill struct {
+	ServiceID    string    `db:"service_id"`
+	BackupFileID string    `db:"backup_file_id"`
+	Cmd          string    `db:"cmd"`
+	Interval     int       `db:"interval"` // seconds
+	LastTaskID   string    `db:"last_task_id"`
+	LastResult   string    `db:"last_result"`
+	LastRunAt    time.Time `db:"last_run_at"`
+	CreatedAt    time.Time `db:"created_at"`
+}
+
+func (db dbBase) restoreDrillTable() string {
+	return db.prefix + "_restore_drill"
+}
+
+// SetRestoreDrill insert or update the restore drill schedule of service.
+func (db dbBase) SetRestoreDrill(d RestoreDrill) error {
+	query := "INSERT INTO " + db.restoreDrillTable() + " (service_id,backup_file_id,cmd,`interval`,last_task_id,last_result,last_run_at,created_at) VALUES (:service_id,:backup_file_id,:cmd,:interval,:last_task_id,:last_result,:last_run_at,:created_at) ON DUPLICATE KEY UPDATE backup_file_id=VALUES(backup_file_id),cmd=VALUES(cmd),`interval`=VALUES(`interval`)"
+
+	_, err := db.NamedExec(query, &d)
+
+	return errors.Wrap(err, "set RestoreDrill")
+}
+
+// GetRestoreDrill get RestoreDrill by service ID.
+func (db dbBase) GetRestoreDrill(service string) (RestoreDrill, error) {
+	var (
+		d     RestoreDrill
+		query = "SELECT service_id,backup_file_id,cmd,`interval`,last_task_id,last_result,last_run_at,created_at FROM " + db.restoreDrillTable() + " WHERE service_id=?"
+	)
+
+	err := db.Get(&d, query, service)
+
+	return d, errors.Wrap(err, "get RestoreDrill by service:"+service)
+}
+
+// ListRestoreDrills returns all restore drills.
+func (db dbBase) ListRestoreDrills() ([]RestoreDrill, error) {
+	var (
+		out   []RestoreDrill
+		query = "SELECT service_id,backup_file_id,cmd,`interval`,last_task_id,last_result,last_run_at,created_at FROM " + db.restoreDrillTable()
+	)
+
+	err := db.Select(&out, query)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	return out, errors.Wrap(err, "list RestoreDrills")
+}
+
+// SetRestoreDrillResult update last_task_id,last_result and last_run_at by service ID.
+func (db dbBase) SetRestoreDrillResult(d RestoreDrill) error {
+	query := "UPDATE " + db.restoreDrillTable() + " SET last_task_id=?,last_result=?,last_run_at=? WHERE service_id=?"
+
+	_, err := db.Exec(query, d.LastTaskID, d.LastResult, d.LastRunAt, d.ServiceID)
+
+	return errors.Wrap(err, "update RestoreDrill result")
+}
+
+// DelRestoreDrill delete RestoreDrill by service ID.
+func (db dbBase) DelRestoreDrill(service string) error {
+	query := "DELETE FROM " + db.restoreDrillTable() + " WHERE service_id=?"
+
+	_, err := db.Exec(query, service)
+
+	return errors.Wrap(err, "delete RestoreDrill by service")
+}
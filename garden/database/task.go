@@ -37,6 +37,8 @@ const (
 
 	// node distribution task
 	NodeInstall = "host_install"
+	// node drain task,migrate all units off the node
+	NodeDrainTask = "host_drain"
 
 	// load image task
 	ImageLoadTask = "image_load"
@@ -55,9 +57,12 @@ const (
 	ServiceUpdateTask          = "service_update"
 	ServiceExecTask            = "service_exec"
 	ServiceBackupTask          = "service_backup"
+	ServiceSwitchoverTask      = "service_switchover"
 
 	ServiceUpdateConfigTask = "service_update_config"
 	ServiceUpdateImageTask  = "service_update_image"
+	ServiceImportTask       = "service_import"
+	ServiceManifestTask     = "service_manifest"
 
 	// unit tasks
 	UnitMigrateTask = "unit_migrate"
@@ -67,6 +72,9 @@ const (
 	// backup tasks
 	BackupAutoTask   = "backup_auto"
 	BackupManualTask = "backup_manual"
+	BackupVerifyTask = "backup_verify"
+	BackupUploadTask = "backup_upload"
+	RestoreDrillTask = "restore_drill"
 )
 
 // TaskOrmer Task db table operators
@@ -81,7 +89,18 @@ type TaskOrmer interface {
 
 	SetTask(t Task) error
 
+	SetTaskLabels(t Task) error
+
 	SetTaskFail(id string) error
+
+	TaskStepOrmer
+}
+
+// TaskStepOrmer task steps db table operators
+type TaskStepOrmer interface {
+	SetTaskStep(s TaskStep) error
+
+	ListTaskSteps(task string) ([]TaskStep, error)
 }
 
 // NewTask new a Task
@@ -264,6 +283,17 @@ func (db dbBase) SetTask(t Task) error {
 	return errors.Wrap(err, "update Task status & errors")
 }
 
+// SetTaskLabels update Task.Labels,used to report task progress
+func (db dbBase) SetTaskLabels(t Task) error {
+	tk := t.toTask()
+
+	query := "UPDATE " + db.taskTable() + " SET labels=? WHERE id=?"
+
+	_, err := db.Exec(query, tk.Labels, tk.ID)
+
+	return errors.Wrap(err, "update Task labels")
+}
+
 func (db dbBase) GetTask(ID string) (Task, error) {
 	tk := task{}
 	query := "SELECT id,name,related,link_to,link_table,description,labels,errors,timeout,status,created_at,timestamp,finished_at FROM " + db.taskTable() + " WHERE id=?"
@@ -455,3 +485,55 @@ func (db dbBase) SetTaskFail(id string) error {
 
 	return db.txFrame(do)
 }
+
+const (
+	// TaskStep.Status
+	TaskStepRunning = "running"
+	TaskStepDone    = "done"
+	TaskStepFailed  = "failed"
+	TaskStepSkipped = "skipped"
+)
+
+// TaskStep is table structure,records a step of task and the step output
+type TaskStep struct {
+	Task       string    `db:"task_id" json:"task_id"`
+	Name       string    `db:"name" json:"name"`
+	Status     string    `db:"status" json:"status"`
+	Output     string    `db:"output" json:"output"`
+	StartedAt  time.Time `db:"started_at" json:"started_at"`
+	FinishedAt time.Time `db:"finished_at" json:"finished_at"`
+}
+
+// Succeeded returns true if the step done or skipped
+func (s TaskStep) Succeeded() bool {
+	return s.Status == TaskStepDone || s.Status == TaskStepSkipped
+}
+
+func (db dbBase) taskStepTable() string {
+	return db.prefix + "_task_step"
+}
+
+// SetTaskStep insert TaskStep,update status,output and finished_at if exist
+func (db dbBase) SetTaskStep(s TaskStep) error {
+
+	query := "INSERT INTO " + db.taskStepTable() + " (task_id,name,status,output,started_at,finished_at) VALUES (:task_id,:name,:status,:output,:started_at,:finished_at) ON DUPLICATE KEY UPDATE status=VALUES(status),output=VALUES(output),finished_at=VALUES(finished_at)"
+
+	_, err := db.NamedExec(query, s)
+
+	return errors.Wrap(err, "set Task step")
+}
+
+// ListTaskSteps returns steps of Task,order by started_at
+func (db dbBase) ListTaskSteps(task string) ([]TaskStep, error) {
+	var (
+		out   []TaskStep
+		query = "SELECT task_id,name,status,output,started_at,finished_at FROM " + db.taskStepTable() + " WHERE task_id=? ORDER BY started_at"
+	)
+
+	err := db.Select(&out, query, task)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	return out, errors.Wrap(err, "list Task steps by task:"+task)
+}
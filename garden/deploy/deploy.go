@@ -160,6 +160,9 @@ func (d *Deployment) deploy(ctx context.Context, svc *garden.Service, compose bo
 
 	if compose {
 		err = svc.Compose(ctx)
+		if err == nil {
+			svc.SyncMonitorTargets(ctx, d.gd.KVClient())
+		}
 	}
 
 	return err
@@ -187,140 +190,161 @@ func (d *Deployment) Link(ctx context.Context, links structs.ServicesLink) (stri
 		return "", err
 	}
 
-	runLink := func() error {
-		// generate new units config and commands,and sorted
-		resp, err := d.gd.PluginClient().ServicesLink(ctx, links)
-		if err != nil {
-			return err
-		}
-
-		var (
-			svc       *garden.Service
-			serviceID string
-		)
+	go func() (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = errors.Errorf("deploy link,panic:%v", r)
+			}
 
-		// update units config file.
-		for _, ul := range resp.Links {
-			if ul.ServiceID == "" || ul.NameOrID == "" || ul.ConfigFile == "" {
-				continue
+			if err == nil {
+				task.Status = database.TaskDoneStatus
+			} else {
+				task.Status = database.TaskFailedStatus
 			}
 
-			if ul.ServiceID != serviceID {
-				s := d.serviceFromLinks(links, ul.ServiceID)
-				if s == nil {
-					return errors.Errorf("not found Service '%s' from ServicesLink", ul.ServiceID)
-				}
+			task.SetErrors(err)
 
-				svc = s
-				serviceID = ul.ServiceID
-			}
+			_err := d.gd.Ormer().SetTask(task)
 
-			err := svc.UpdateUnitConfig(ctx, ul.NameOrID, ul.ConfigFile, ul.ConfigContent)
-			if err != nil {
-				return err
+			logrus.Infof("deploy link %s,since=%s,%+v %+v", links.Mode, time.Since(start), _err, err)
+		}()
+
+		err = d.runLink(ctx, links)
+		if err == nil {
+			// link definitions are exported with the services
+			if _err := garden.SaveServicesLink(ctx, d.gd.KVClient(), links); _err != nil {
+				logrus.Warnf("save services link %s,%+v", links.Mode, _err)
 			}
 		}
 
-		// start units service
-		for _, ul := range resp.Links {
-			if ul.ServiceID == "" || ul.NameOrID == "" || len(ul.Commands) == 0 {
-				continue
-			}
+		return err
+	}()
 
-			if ul.ServiceID != serviceID {
-				s := d.serviceFromLinks(links, ul.ServiceID)
-				if s == nil {
-					return errors.Errorf("not found Service '%s' from ServicesLink", ul.ServiceID)
-				}
+	return task.ID, nil
+}
 
-				svc = s
-				serviceID = ul.ServiceID
-			}
+// RecordLink stores the definitions of the links deployed before the definitions were stored,
+// the links aren't run again,all services of the links must exist.
+func (d *Deployment) RecordLink(ctx context.Context, links structs.ServicesLink) error {
+	links, err := d.freshServicesLink(links)
+	if err != nil {
+		return err
+	}
 
-			err := svc.Exec(ctx, structs.ServiceExecConfig{
-				Container: ul.NameOrID,
-				Cmd:       ul.Commands,
-			}, false, nil)
+	return garden.SaveServicesLink(ctx, d.gd.KVClient(), links)
+}
 
-			if err != nil {
-				return err
-			}
+// runLink generates the units config and commands by plugin,
+// then updates configs,executes commands,composes and reloads the linked services.
+func (d *Deployment) runLink(ctx context.Context, links structs.ServicesLink) error {
+	// generate new units config and commands,and sorted
+	resp, err := d.gd.PluginClient().ServicesLink(ctx, links)
+	if err != nil {
+		return err
+	}
+
+	var (
+		svc       *garden.Service
+		serviceID string
+	)
+
+	// update units config file.
+	for _, ul := range resp.Links {
+		if ul.ServiceID == "" || ul.NameOrID == "" || ul.ConfigFile == "" {
+			continue
 		}
 
-		// service compose
-		for _, name := range resp.Compose {
-			svc := d.serviceFromLinks(links, name)
-			if svc == nil {
-				return errors.Errorf("not found Service '%s' from ServicesLink", name)
+		if ul.ServiceID != serviceID {
+			s := d.serviceFromLinks(links, ul.ServiceID)
+			if s == nil {
+				return errors.Errorf("not found Service '%s' from ServicesLink", ul.ServiceID)
 			}
 
-			err := svc.Compose(ctx)
-			if err != nil {
-				return err
-			}
+			svc = s
+			serviceID = ul.ServiceID
 		}
 
-		// service interval requests
-		for _, ul := range resp.Links {
-			if ul.Request == nil {
-				continue
-			}
+		err := svc.UpdateUnitConfig(ctx, ul.NameOrID, ul.ConfigFile, ul.ConfigContent)
+		if err != nil {
+			return err
+		}
+	}
 
-			logrus.Debugf("LINK:%s %s\nBody:%s", ul.Request.Method, ul.Request.URL, ul.Request.Body)
-		retry:
-			for i := 3; i > 0; i-- {
-				err = ul.Request.Send(ctx)
-				if err == nil {
-					break retry
-				}
-				time.Sleep(time.Second)
-			}
-			if err != nil {
-				return err
-			}
+	// start units service
+	for _, ul := range resp.Links {
+		if ul.ServiceID == "" || ul.NameOrID == "" || len(ul.Commands) == 0 {
+			continue
 		}
 
-		// reload service config
-		for _, name := range resp.ReloadServicesConfig {
-			svc := d.serviceFromLinks(links, name)
-			if svc == nil {
-				return errors.Errorf("not found Service '%s' from ServicesLink", name)
+		if ul.ServiceID != serviceID {
+			s := d.serviceFromLinks(links, ul.ServiceID)
+			if s == nil {
+				return errors.Errorf("not found Service '%s' from ServicesLink", ul.ServiceID)
 			}
 
-			_, err := svc.ReloadServiceConfig(ctx, "")
-			if err != nil {
-				return err
-			}
+			svc = s
+			serviceID = ul.ServiceID
 		}
 
-		return nil
+		err := svc.Exec(ctx, structs.ServiceExecConfig{
+			Container: ul.NameOrID,
+			Cmd:       ul.Commands,
+		}, false, nil)
+
+		if err != nil {
+			return err
+		}
 	}
 
-	go func() (err error) {
-		defer func() {
-			if r := recover(); r != nil {
-				err = errors.Errorf("deploy link,panic:%v", r)
-			}
+	// service compose
+	for _, name := range resp.Compose {
+		svc := d.serviceFromLinks(links, name)
+		if svc == nil {
+			return errors.Errorf("not found Service '%s' from ServicesLink", name)
+		}
 
-			if err == nil {
-				task.Status = database.TaskDoneStatus
-			} else {
-				task.Status = database.TaskFailedStatus
-			}
+		err := svc.Compose(ctx)
+		if err != nil {
+			return err
+		}
 
-			task.SetErrors(err)
+		svc.SyncMonitorTargets(ctx, d.gd.KVClient())
+	}
 
-			_err := d.gd.Ormer().SetTask(task)
+	// service interval requests
+	for _, ul := range resp.Links {
+		if ul.Request == nil {
+			continue
+		}
 
-			logrus.Infof("deploy link %s,since=%s,%+v %+v", links.Mode, time.Since(start), _err, err)
-		}()
+		logrus.Debugf("LINK:%s %s\nBody:%s", ul.Request.Method, ul.Request.URL, ul.Request.Body)
+	retry:
+		for i := 3; i > 0; i-- {
+			err = ul.Request.Send(ctx)
+			if err == nil {
+				break retry
+			}
+			time.Sleep(time.Second)
+		}
+		if err != nil {
+			return err
+		}
+	}
 
-		err = runLink()
+	// reload service config
+	for _, name := range resp.ReloadServicesConfig {
+		svc := d.serviceFromLinks(links, name)
+		if svc == nil {
+			return errors.Errorf("not found Service '%s' from ServicesLink", name)
+		}
 
-		return err
-	}()
+		_, err := svc.ReloadServiceConfig(ctx, "")
+		if err != nil {
+			return err
+		}
+	}
 
-	return task.ID, nil
+	return nil
 }
 
 func (d *Deployment) serviceFromLinks(links structs.ServicesLink, nameOrID string) *garden.Service {
@@ -414,6 +438,79 @@ func (d *Deployment) ServiceScale(ctx context.Context, nameOrID string, scale st
 	return d.gd.Scale(ctx, svc, actor, scale, true)
 }
 
+// Switchover switches the master of the replicated service,
+// re-generates links to repoint the linked proxies to the new master,
+// the stored links of the service are used if req.Links is not set.
+func (d *Deployment) Switchover(ctx context.Context, nameOrID string, req structs.ServiceSwitchoverRequest) (string, error) {
+	svc, err := d.gd.Service(nameOrID)
+	if err != nil {
+		return "", err
+	}
+
+	after, err := d.repointLinks(ctx, svc, req.Links)
+	if err != nil {
+		return "", err
+	}
+
+	return d.gd.Switchover(ctx, svc, req, after, true)
+}
+
+// repointLinks returns the func to repoint the links to the new master after switchover,
+// links are the stored links of the service if nil.
+func (d *Deployment) repointLinks(ctx context.Context, svc *garden.Service, links *structs.ServicesLink) (func(ctx context.Context) error, error) {
+	var (
+		list []structs.ServicesLink
+		err  error
+	)
+
+	if links != nil {
+		list = []structs.ServicesLink{*links}
+	} else {
+		list, err = garden.ListServiceLinks(ctx, d.gd.KVClient(), svc.ID())
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// refuse the switchover that would leave the linked proxies on the old master
+	for i := range list {
+		list[i], err = d.freshServicesLink(list[i])
+		if err != nil {
+			return nil, errors.WithMessage(err, "repoint services link "+list[i].Mode)
+		}
+	}
+
+	after := func(ctx context.Context) error {
+		for i := range list {
+			err := d.runLink(ctx, list[i])
+			if err != nil {
+				return errors.WithMessage(err, "repoint services link "+list[i].Mode)
+			}
+		}
+
+		return nil
+	}
+
+	return after, nil
+}
+
+// DrainNode migrates all units off the node,the masters of replication are switched over first,
+// the linked services are repointed to the new masters.
+func (d *Deployment) DrainNode(ctx context.Context, nameOrID string, candidates []string, async bool) (string, error) {
+	switchover := func(ctx context.Context, svc *garden.Service, target string) error {
+		after, err := d.repointLinks(ctx, svc, nil)
+		if err != nil {
+			return err
+		}
+
+		_, err = d.gd.Switchover(ctx, svc, structs.ServiceSwitchoverRequest{Target: target}, after, false)
+
+		return err
+	}
+
+	return d.gd.DrainNode(ctx, nameOrID, candidates, switchover, async)
+}
+
 // ServiceUpdateImage update Service image version
 func (d *Deployment) ServiceUpdateImage(ctx context.Context, name, version string, async bool) (string, error) {
 	orm := d.gd.Ormer()
@@ -433,8 +530,22 @@ func (d *Deployment) ServiceUpdateImage(ctx context.Context, name, version strin
 		return "", err
 	}
 
-	if im1.Name != im.Name || im1.Major != im.Major {
-		return "", errors.Errorf("Service:%s unsupported image update from %s to %s", name, im1.Image(), im.Image())
+	ct, err := d.gd.PluginClient().GetImage(ctx, im.Image())
+	if err != nil {
+		return "", err
+	}
+
+	err = structs.UpgradeCheck(
+		structs.NewImageVersion(im1.Name, im1.Major, im1.Minor, im1.Patch, im1.Dev),
+		structs.NewImageVersion(im.Name, im.Major, im.Minor, im.Patch, im.Dev),
+		ct.Upgrade)
+	if err != nil {
+		return "", errors.WithMessage(err, "Service:"+name)
+	}
+
+	// allow to update away from the deprecated image,but not to
+	if im.Deprecated && im.ID != im1.ID {
+		return "", errors.Errorf("Service:%s unsupported image update to the deprecated %s,%s", name, im.Image(), im.DeprecatedReason)
 	}
 
 	authConfig, err := d.gd.AuthConfig()
@@ -517,7 +628,7 @@ func (d *Deployment) ServiceUpdate(ctx context.Context, name string, config stru
 				d.gd.Lock()
 				defer d.gd.Unlock()
 
-				return svc.VolumeExpansion(actor, config.Volumes)
+				return svc.VolumeExpansion(ctx, actor, config.Volumes)
 			}()
 		}
 		if err != nil {
@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"github.com/docker/swarm/garden/structs"
+	"github.com/pkg/errors"
 )
 
 func TestServicesLink(t *testing.T) {
@@ -43,3 +44,131 @@ func TestServicesLink(t *testing.T) {
 	}
 
 }
+
+func TestChangedKeysets(t *testing.T) {
+	configs := structs.ServiceConfigs{
+		{
+			ID: "unit001",
+			ConfigTemplate: structs.ConfigTemplate{
+				Keysets: []structs.Keyset{
+					{CanSet: true, Key: "max_connections", Value: "3000", Default: "1000"},
+					{CanSet: true, Key: "server_id", Value: "1", Default: "0"},
+					{CanSet: false, Key: "datadir", Value: "/DATA", Default: "/data"},
+					{CanSet: true, Key: "wait_timeout", Value: "600", Default: "600"},
+				},
+			},
+		},
+		{
+			ID: "unit002",
+			ConfigTemplate: structs.ConfigTemplate{
+				Keysets: []structs.Keyset{
+					{CanSet: true, Key: "max_connections", Value: "3000", Default: "1000"},
+					{CanSet: true, Key: "server_id", Value: "2", Default: "0"},
+					{CanSet: true, Key: "wait_timeout", Value: "600", Default: "600"},
+				},
+			},
+		},
+		{
+			ID: "unit003",
+			ConfigTemplate: structs.ConfigTemplate{
+				Keysets: []structs.Keyset{
+					{CanSet: true, Key: "server_id", Value: "1", Default: "0"},
+				},
+			},
+		},
+	}
+
+	out := changedKeysets(configs)
+	if len(out) != 1 || out[0].Key != "max_connections" || out[0].Value != "3000" {
+		t.Errorf("expected max_connections only but got %+v", out)
+	}
+}
+
+func TestPlanManifest(t *testing.T) {
+	spec := structs.ServiceSpec{
+		Service: structs.Service{
+			Image: structs.ImageVersion{Name: "mysql", Major: 5, Minor: 7, Patch: 19},
+		},
+		Arch: structs.Arch{Mode: "replication", Code: "M:1#S:2", Replicas: 3},
+		Require: &structs.UnitRequire{
+			Volumes:  []structs.VolumeRequire{{Name: "DAT", Type: "local:HDD", Size: 10 << 30}, {Name: "LOG", Type: "local:HDD", Size: 1 << 30}},
+			Networks: []structs.NetDeviceRequire{{Bandwidth: 10}},
+		},
+		Units: make([]structs.UnitSpec, 3),
+	}
+	spec.Require.Require.CPU = 2
+	spec.Require.Require.Memory = 4 << 30
+
+	configs := structs.ServiceConfigs{
+		{ConfigTemplate: structs.ConfigTemplate{Keysets: []structs.Keyset{{Key: "max_connections", Value: "1000"}, {Key: "wait_timeout", Value: "600"}}}},
+		{ConfigTemplate: structs.ConfigTemplate{Keysets: []structs.Keyset{{Key: "max_connections", Value: "1000"}, {Key: "wait_timeout", Value: "300"}}}},
+	}
+	keysets := []structs.Keyset{
+		{Key: "max_connections", CanSet: true},
+		{Key: "wait_timeout", CanSet: true},
+		{Key: "datadir", CanSet: false},
+	}
+
+	cpu, memory := int64(4), int64(4<<30)
+
+	m := structs.ServiceManifest{
+		Image:    "mysql:5.7.21.0",
+		Arch:     &structs.Arch{Replicas: 2},
+		CPU:      &cpu,
+		Memory:   &memory,
+		Volumes:  []structs.VolumeRequire{{Name: "DAT", Size: 20 << 30}, {Name: "LOG", Size: 1 << 30}},
+		Networks: []structs.NetDeviceRequire{{Bandwidth: 100}},
+		Keysets:  map[string]string{"max_connections": "1000", "wait_timeout": "600"},
+	}
+
+	changes, err := planManifest(spec, m, configs, keysets)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	want := []string{
+		structs.ManifestScaleDown,
+		structs.ManifestResource,
+		structs.ManifestVolume,
+		structs.ManifestNetworking,
+		structs.ManifestImage,
+		structs.ManifestConfig,
+	}
+	if len(changes) != len(want) {
+		t.Fatalf("expected %d changes but got %+v", len(want), changes)
+	}
+	for i := range want {
+		if changes[i].Action != want[i] {
+			t.Errorf("change %d expected %s but got %+v", i, want[i], changes[i])
+		}
+	}
+	if c := changes[5]; c.Field != "wait_timeout" || c.To != "600" {
+		t.Errorf("unexpected config change %+v", c)
+	}
+
+	unsupported := []structs.ServiceManifest{
+		{Arch: &structs.Arch{Mode: "sharding", Replicas: 3}},
+		{Volumes: []structs.VolumeRequire{{Name: "DAT", Size: 1 << 30}}},
+		{Networks: []structs.NetDeviceRequire{{Bandwidth: 10}, {Bandwidth: 10}}},
+		{Keysets: map[string]string{"datadir": "/data"}},
+		{Keysets: map[string]string{"unknown": "1"}},
+	}
+	for i := range unsupported {
+		_, err := planManifest(spec, unsupported[i], configs, keysets)
+		if errors.Cause(err) != ErrUnsupportedManifest {
+			t.Errorf("%d expected unsupported manifest but got %v", i, err)
+		}
+	}
+
+	spec.Require.Networks = []structs.NetDeviceRequire{{Bandwidth: 10}, {Bandwidth: 100}}
+	changes, err = planManifest(spec, structs.ServiceManifest{Networks: []structs.NetDeviceRequire{{Bandwidth: 100}, {Bandwidth: 10}}}, configs, keysets)
+	if err != nil || len(changes) != 2 || changes[0].Field != "networks.0.bandwidth" || changes[1].To != "10" {
+		t.Errorf("expected bandwidth changes of both devices but got %+v,%v", changes, err)
+	}
+
+	cpu = 2
+	changes, err = planManifest(spec, structs.ServiceManifest{Arch: &structs.Arch{Replicas: 3}, CPU: &cpu}, configs, keysets)
+	if err != nil || len(changes) != 0 {
+		t.Errorf("expected no changes but got %+v,%v", changes, err)
+	}
+}
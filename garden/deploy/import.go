@@ -0,0 +1,309 @@
+package deploy
+
+import (
+	"sort"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/swarm/garden"
+	"github.com/docker/swarm/garden/backup"
+	"github.com/docker/swarm/garden/database"
+	"github.com/docker/swarm/garden/structs"
+	"github.com/pkg/errors"
+	"golang.org/x/net/context"
+)
+
+const importWaitInterval = 5 * time.Second
+
+// Import recreates the exported service by Deploy with the mapping,
+// the data,configs and links of the bundle are restored by the import task after the service deployed,
+// the deployed service is removed if the import task failed.
+// The backup of the bundle must be reachable by the S3 backup target of the manager unless SkipRestore.
+func (d *Deployment) Import(ctx context.Context, req structs.ServiceImportRequest) (structs.ServiceImportResponse, error) {
+	if b := req.Bundle.Backup; !req.SkipRestore && b != nil {
+		sys, err := d.gd.Ormer().GetSysConfig()
+		if err != nil {
+			return structs.ServiceImportResponse{}, err
+		}
+
+		err = backup.Reachable(ctx, sys, d.gd.BackupSecretKey, b.Endpoint, b.Bucket, b.Key)
+		if err != nil {
+			return structs.ServiceImportResponse{}, err
+		}
+	}
+
+	spec := req.Bundle.ImportSpec(req.Mapping, req.Users)
+
+	out, err := d.Deploy(ctx, spec, req.Compose)
+	if err != nil {
+		return structs.ServiceImportResponse{}, err
+	}
+
+	resp := structs.ServiceImportResponse{PostServiceResponse: out}
+
+	task := database.NewTask(out.Name, database.ServiceImportTask, out.ID, "import from "+req.Bundle.Spec.ID, nil, 300)
+	err = d.gd.Ormer().InsertTask(task)
+	if err != nil {
+		return resp, err
+	}
+
+	resp.ImportTask = task.ID
+
+	go func() (err error) {
+		// the import task outlives the request
+		ctx := context.Background()
+		start := time.Now()
+
+		defer func() {
+			if r := recover(); r != nil {
+				err = errors.Errorf("import service,panic:%v", r)
+			}
+
+			if err == nil {
+				task.Status = database.TaskDoneStatus
+			} else {
+				task.Status = database.TaskFailedStatus
+			}
+
+			task.SetErrors(err)
+
+			_err := d.gd.Ormer().SetTask(task)
+
+			logrus.WithField("Service", out.ID).Infof("import service,since=%s,%+v %+v", time.Since(start), _err, err)
+
+			if err != nil {
+				d.removeImported(ctx, out.ID)
+			}
+		}()
+
+		return d.runImport(ctx, req, out)
+	}()
+
+	return resp, nil
+}
+
+// runImport waits the deploy task done,then restores the data,configs and links of the bundle.
+func (d *Deployment) runImport(ctx context.Context, req structs.ServiceImportRequest, out structs.PostServiceResponse) error {
+	err := d.waitTask(ctx, out.TaskID)
+	if err != nil {
+		return err
+	}
+
+	svc, err := d.gd.Service(out.ID)
+	if err != nil {
+		return err
+	}
+
+	if !req.SkipRestore && req.Bundle.Backup != nil {
+		units := make([]string, len(out.Units))
+		for i := range out.Units {
+			units[i] = out.Units[i].ID
+		}
+
+		location := backup.Location(req.Bundle.Backup.Bucket, req.Bundle.Backup.Key)
+
+		err = d.verifyBackup(ctx, *req.Bundle.Backup, location)
+		if err != nil {
+			return err
+		}
+
+		_, err = svc.UnitRestore(ctx, units, location, false)
+		if err != nil {
+			return errors.WithMessage(err, "restore from "+location)
+		}
+	}
+
+	err = setKeysets(ctx, svc, changedKeysets(req.Bundle.Configs), true)
+	if err != nil {
+		return err
+	}
+
+	for _, links := range req.Bundle.ImportLinks(req.Mapping, out.ID) {
+		ok, err := d.linkable(links)
+		if err != nil {
+			return err
+		}
+
+		if !ok {
+			logrus.WithField("Service", out.ID).Warnf("skip services link %s,linked services are not on the manager", links.Mode)
+			continue
+		}
+
+		links, err = d.freshServicesLink(links)
+		if err != nil {
+			return err
+		}
+
+		err = d.runLink(ctx, links)
+		if err != nil {
+			return err
+		}
+
+		err = garden.SaveServicesLink(ctx, d.gd.KVClient(), links)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// verifyBackup checks the size and the checksum of the bundle backup at location before restored.
+func (d *Deployment) verifyBackup(ctx context.Context, b structs.ExportBackup, location string) error {
+	sys, err := d.gd.Ormer().GetSysConfig()
+	if err != nil {
+		return err
+	}
+
+	target, err := backup.NewTarget(sys, nil, d.gd.BackupSecretKey)
+	if err != nil {
+		return err
+	}
+
+	return backup.Verify(ctx, target, location, b.SizeByte, b.Checksum)
+}
+
+// removeImported removes the service deployed by the failed import,
+// the service is left in the delete failed status if it couldn't be removed.
+func (d *Deployment) removeImported(ctx context.Context, ID string) {
+	entry := logrus.WithField("Service", ID)
+
+	svc, err := d.gd.Service(ID)
+	if err == nil {
+		err = svc.Remove(ctx, d.gd.KVClient(), true)
+	}
+	if err != nil && !database.IsNotFound(err) {
+		entry.Errorf("remove the service of the failed import,%+v", err)
+		return
+	}
+
+	entry.Info("removed the service of the failed import")
+}
+
+// linkable returns false if any service of the links isnot exist.
+func (d *Deployment) linkable(links structs.ServicesLink) (bool, error) {
+	for _, id := range links.LinkIDs() {
+		_, err := d.gd.Service(id)
+		if database.IsNotFound(err) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+func (d *Deployment) waitTask(ctx context.Context, ID string) error {
+	ticker := time.NewTicker(importWaitInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return errors.WithStack(ctx.Err())
+		case <-ticker.C:
+		}
+
+		t, err := d.gd.Ormer().GetTask(ID)
+		if err != nil {
+			return err
+		}
+
+		if !t.Done {
+			continue
+		}
+
+		if !t.Success {
+			return errors.Errorf("Task %s is failed,status=%d,%s", ID, t.Status, t.Errors)
+		}
+
+		return nil
+	}
+}
+
+// changedKeysets returns the keysets could be set and modified from the defaults,
+// the values differ between units are generated by units,so they are not carried.
+func changedKeysets(configs structs.ServiceConfigs) []structs.Keyset {
+	values := make(map[string]structs.Keyset)
+	differ := make(map[string]bool)
+
+	for i := range configs {
+		for _, ks := range configs[i].Keysets {
+			if !ks.CanSet || differ[ks.Key] {
+				continue
+			}
+
+			if val, ok := values[ks.Key]; ok && val.Value != ks.Value {
+				differ[ks.Key] = true
+				delete(values, ks.Key)
+				continue
+			}
+
+			values[ks.Key] = ks
+		}
+	}
+
+	out := make([]structs.Keyset, 0, len(values))
+	for _, ks := range values {
+		if ks.Value != ks.Default {
+			out = append(out, ks)
+		}
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Key < out[j].Key
+	})
+
+	return out
+}
+
+// setKeysets sets the keysets into the units configs of the service,restarts the units if restart,
+// the keysets not supported by the service are ignored.
+func setKeysets(ctx context.Context, svc *garden.Service, keysets []structs.Keyset, restart bool) error {
+	if len(keysets) == 0 {
+		return nil
+	}
+
+	current, err := svc.GetUnitsConfigs(ctx)
+	if err != nil {
+		return err
+	}
+
+	configs := make(structs.ServiceConfigs, 0, len(current))
+	applied := make([]structs.Keyset, 0, len(keysets))
+
+	for _, uc := range current {
+		m := make(map[string]structs.Keyset, len(uc.Keysets))
+		for _, ks := range uc.Keysets {
+			m[ks.Key] = ks
+		}
+
+		ks := make([]structs.Keyset, 0, len(keysets))
+		for _, k := range keysets {
+			val, ok := m[k.Key]
+			if !ok || !val.CanSet {
+				continue
+			}
+
+			val.Value = k.Value
+			ks = append(ks, val)
+		}
+
+		if len(applied) == 0 {
+			applied = append(applied, ks...)
+		}
+
+		uc.Keysets = ks
+		configs = append(configs, uc)
+	}
+
+	if len(applied) == 0 {
+		return nil
+	}
+
+	task := database.NewTask(svc.Name(), database.ServiceUpdateConfigTask, svc.ID(), "", nil, 300)
+
+	return svc.UpdateUnitsConfigs(ctx, configs, applied, &task, restart, false)
+}
@@ -0,0 +1,406 @@
+package deploy
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/swarm/garden/database"
+	"github.com/docker/swarm/garden/resource/alloc"
+	"github.com/docker/swarm/garden/structs"
+	"github.com/pkg/errors"
+	"golang.org/x/net/context"
+)
+
+// ErrUnsupportedManifest the manifest change cannot be applied to the service
+var ErrUnsupportedManifest = errors.New("unsupported manifest change")
+
+// planManifest returns the changes between the service spec and the manifest,
+// configs are the current units configs,keysets are the template keysets of the manifest image.
+func planManifest(spec structs.ServiceSpec, m structs.ServiceManifest,
+	configs structs.ServiceConfigs, keysets []structs.Keyset) ([]structs.ManifestChange, error) {
+
+	var (
+		changes []structs.ManifestChange
+		require structs.UnitRequire
+	)
+
+	if spec.Require != nil {
+		require = *spec.Require
+	}
+
+	add := func(action, field, from, to string) {
+		changes = append(changes, structs.ManifestChange{
+			Action: action,
+			Field:  field,
+			From:   from,
+			To:     to,
+		})
+	}
+
+	if m.Arch != nil {
+		if (m.Arch.Mode != "" && m.Arch.Mode != spec.Arch.Mode) ||
+			(m.Arch.Code != "" && m.Arch.Code != spec.Arch.Code) {
+			return nil, errors.Wrapf(ErrUnsupportedManifest, "architecture %s:%s to %s:%s", spec.Arch.Mode, spec.Arch.Code, m.Arch.Mode, m.Arch.Code)
+		}
+
+		if m.Arch.Replicas <= 0 {
+			return nil, errors.Wrapf(ErrUnsupportedManifest, "replicas %d", m.Arch.Replicas)
+		}
+
+		current := len(spec.Units)
+		from, to := strconv.Itoa(current), strconv.Itoa(m.Arch.Replicas)
+
+		if m.Arch.Replicas < current {
+			add(structs.ManifestScaleDown, "replicas", from, to)
+		} else if m.Arch.Replicas > current {
+			add(structs.ManifestScaleUp, "replicas", from, to)
+		}
+	}
+
+	if m.CPU != nil && *m.CPU != int64(require.Require.CPU) {
+		add(structs.ManifestResource, "ncpu", strconv.Itoa(require.Require.CPU), strconv.FormatInt(*m.CPU, 10))
+	}
+
+	if m.Memory != nil && *m.Memory != require.Require.Memory {
+		add(structs.ManifestResource, "memory", strconv.FormatInt(require.Require.Memory, 10), strconv.FormatInt(*m.Memory, 10))
+	}
+
+	for _, v := range manifestVolumes(require.Volumes, m.Volumes) {
+		from := ""
+
+		for i := range require.Volumes {
+			if require.Volumes[i].Name == v.Name {
+				if v.Size < require.Volumes[i].Size {
+					return nil, errors.Wrapf(ErrUnsupportedManifest, "shrink volume %s from %d to %d", v.Name, require.Volumes[i].Size, v.Size)
+				}
+
+				from = strconv.FormatInt(require.Volumes[i].Size, 10)
+				break
+			}
+		}
+
+		if from == "" && (v.Name == "" || v.Type == "") {
+			return nil, errors.Wrapf(ErrUnsupportedManifest, "invalid volume require,%v", v)
+		}
+
+		add(structs.ManifestVolume, "volumes."+v.Name, from, strconv.FormatInt(v.Size, 10))
+	}
+
+	if len(m.Networks) > 0 {
+		if len(m.Networks) != len(require.Networks) {
+			return nil, errors.Wrapf(ErrUnsupportedManifest, "networks devices from %d to %d", len(require.Networks), len(m.Networks))
+		}
+
+		for i := range m.Networks {
+			from, to := require.Networks[i].Bandwidth, m.Networks[i].Bandwidth
+			if from != to {
+				add(structs.ManifestNetworking, fmt.Sprintf("networks.%d.bandwidth", i), strconv.Itoa(from), strconv.Itoa(to))
+			}
+		}
+	}
+
+	if m.Image != "" && m.Image != spec.Image.Image() {
+		add(structs.ManifestImage, "image", spec.Image.Image(), m.Image)
+	}
+
+	if len(m.Keysets) > 0 {
+		template := make(map[string]structs.Keyset, len(keysets))
+		for _, ks := range keysets {
+			template[ks.Key] = ks
+		}
+
+		keys := make([]string, 0, len(m.Keysets))
+		for key := range m.Keysets {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			ks, ok := template[key]
+			if !ok {
+				return nil, errors.Wrapf(ErrUnsupportedManifest, "config key %s not exist", key)
+			}
+
+			if !ks.CanSet {
+				return nil, errors.Wrapf(ErrUnsupportedManifest, "config key %s forbit to reset", key)
+			}
+
+			from, same := keysetValue(configs, key)
+			if !same || from != m.Keysets[key] {
+				add(structs.ManifestConfig, key, from, m.Keysets[key])
+			}
+		}
+	}
+
+	return changes, nil
+}
+
+// manifestVolumes returns the volumes of the manifest to expand or add.
+func manifestVolumes(current, desired []structs.VolumeRequire) []structs.VolumeRequire {
+	out := make([]structs.VolumeRequire, 0, len(desired))
+
+loop:
+	for _, v := range desired {
+		for i := range current {
+			if current[i].Name == v.Name {
+				if current[i].Size != v.Size {
+					out = append(out, v)
+				}
+
+				continue loop
+			}
+		}
+
+		out = append(out, v)
+	}
+
+	return out
+}
+
+// keysetValue returns the value of the key in the units configs,
+// same is false if the values differ between units.
+func keysetValue(configs structs.ServiceConfigs, key string) (string, bool) {
+	var (
+		value string
+		found bool
+	)
+
+	for i := range configs {
+		for _, ks := range configs[i].Keysets {
+			if ks.Key != key {
+				continue
+			}
+
+			if found && ks.Value != value {
+				return value, false
+			}
+
+			value, found = ks.Value, true
+		}
+	}
+
+	return value, found
+}
+
+// PlanManifest returns the changes to reconcile the service to the manifest.
+func (d *Deployment) PlanManifest(ctx context.Context, nameOrID string, m structs.ServiceManifest) (structs.ManifestPlan, error) {
+	svc, err := d.gd.Service(nameOrID)
+	if err != nil {
+		return structs.ManifestPlan{}, err
+	}
+
+	spec, err := svc.Spec()
+	if err != nil {
+		return structs.ManifestPlan{}, err
+	}
+
+	plan := structs.ManifestPlan{Service: spec.ID}
+
+	var (
+		configs structs.ServiceConfigs
+		keysets []structs.Keyset
+	)
+
+	image := spec.Image.Image()
+	if m.Image != "" {
+		image = m.Image
+	}
+
+	if len(m.Keysets) > 0 || image != spec.Image.Image() {
+		ct, err := d.gd.PluginClient().GetImage(ctx, image)
+		if err != nil {
+			return plan, err
+		}
+
+		keysets = ct.Keysets
+
+		if image != spec.Image.Image() {
+			to, err := structs.ParseImage(image)
+			if err != nil {
+				return plan, errors.Wrapf(ErrUnsupportedManifest, "image %s,%s", image, err)
+			}
+
+			err = structs.UpgradeCheck(spec.Image, to, ct.Upgrade)
+			if err != nil {
+				return plan, errors.Wrap(ErrUnsupportedManifest, err.Error())
+			}
+		}
+	}
+
+	if len(m.Keysets) > 0 {
+		configs, err = svc.GetUnitsConfigs(ctx)
+		if err != nil {
+			return plan, err
+		}
+	}
+
+	plan.Changes, err = planManifest(*spec, m, configs, keysets)
+
+	return plan, err
+}
+
+// ApplyManifest reconciles the service to the manifest by the plan,
+// the changes are applied in the order of structs.ManifestActions under the service task lock in a goroutine,
+// the plan is recomputed under the lock and the finished actions are recorded in the task labels,
+// the plan without changes is returned without task.
+func (d *Deployment) ApplyManifest(ctx context.Context, nameOrID string, m structs.ServiceManifest) (structs.ManifestPlan, error) {
+	plan, err := d.PlanManifest(ctx, nameOrID, m)
+	if err != nil || len(plan.Changes) == 0 {
+		return plan, err
+	}
+
+	svc, err := d.gd.Service(plan.Service)
+	if err != nil {
+		return plan, err
+	}
+
+	task := database.NewTask(svc.Name(), database.ServiceManifestTask, plan.Service, fmt.Sprintf("%d changes", len(plan.Changes)), nil, 300)
+
+	apply := func(ctx context.Context) error {
+		// the service could be changed before locked
+		current, err := d.PlanManifest(ctx, plan.Service, m)
+		if err != nil {
+			return err
+		}
+
+		finished := make([]string, 0, len(structs.ManifestActions))
+
+		for _, action := range structs.ManifestActions {
+			if !current.Has(action) {
+				continue
+			}
+
+			select {
+			default:
+			case <-ctx.Done():
+				return errors.WithStack(ctx.Err())
+			}
+
+			err = d.applyManifest(ctx, current, m, action)
+			if err != nil {
+				return errors.WithMessage(err, "apply manifest "+action)
+			}
+
+			finished = append(finished, action)
+			task.Labels = "finished:" + strings.Join(finished, ",") + "\n"
+
+			err = d.gd.Ormer().SetTaskLabels(task)
+			if err != nil {
+				logrus.WithField("Service", plan.Service).Warnf("update task %s finished actions,%+v", task.ID, err)
+			}
+		}
+
+		return nil
+	}
+
+	err = d.gd.ApplyManifest(ctx, svc, &task, apply, true)
+	if err != nil {
+		return plan, err
+	}
+
+	plan.TaskID = task.ID
+
+	return plan, nil
+}
+
+func (d *Deployment) applyManifest(ctx context.Context, plan structs.ManifestPlan, m structs.ServiceManifest, action string) error {
+	svc, err := d.gd.Service(plan.Service)
+	if err != nil {
+		return err
+	}
+
+	actor := alloc.NewAllocator(d.gd.Ormer(), d.gd.Cluster)
+
+	switch action {
+	case structs.ManifestScaleDown, structs.ManifestScaleUp:
+		spec, err := svc.Spec()
+		if err != nil {
+			return err
+		}
+
+		arch := spec.Arch
+		arch.Replicas = m.Arch.Replicas
+
+		_, err = d.gd.Scale(ctx, svc, actor, structs.ServiceScaleRequest{Arch: arch}, false)
+
+		return err
+
+	case structs.ManifestResource:
+		d.gd.Lock()
+		defer d.gd.Unlock()
+
+		return svc.UpdateResource(ctx, actor, m.CPU, m.Memory)
+
+	case structs.ManifestVolume:
+		spec, err := svc.Spec()
+		if err != nil {
+			return err
+		}
+
+		var current []structs.VolumeRequire
+		if spec.Require != nil {
+			current = spec.Require.Volumes
+		}
+
+		d.gd.Lock()
+		defer d.gd.Unlock()
+
+		return svc.VolumeExpansion(ctx, actor, manifestVolumes(current, m.Volumes))
+
+	case structs.ManifestNetworking:
+		d.gd.Lock()
+		defer d.gd.Unlock()
+
+		return svc.UpdateNetworking(ctx, actor, m.Networks)
+
+	case structs.ManifestImage:
+		_, err := d.ServiceUpdateImage(ctx, plan.Service, m.Image, false)
+
+		return err
+
+	case structs.ManifestConfig:
+		image := m.Image
+		if image == "" {
+			spec, err := svc.Spec()
+			if err != nil {
+				return err
+			}
+
+			image = spec.Image.Image()
+		}
+
+		ct, err := d.gd.PluginClient().GetImage(ctx, image)
+		if err != nil {
+			return err
+		}
+
+		changed := make(map[string]bool, len(plan.Changes))
+		for _, c := range plan.Changes {
+			if c.Action == structs.ManifestConfig {
+				changed[c.Field] = true
+			}
+		}
+
+		restart := false
+		keysets := make([]structs.Keyset, 0, len(changed))
+
+		for _, ks := range ct.Keysets {
+			if !changed[ks.Key] {
+				continue
+			}
+
+			if ks.MustRestart {
+				restart = true
+			}
+
+			keysets = append(keysets, structs.Keyset{Key: ks.Key, Value: m.Keysets[ks.Key]})
+		}
+
+		return setKeysets(ctx, svc, keysets, restart)
+	}
+
+	return errors.Errorf("unknown manifest action %s", action)
+}
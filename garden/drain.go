@@ -0,0 +1,329 @@
+package garden
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/swarm/garden/database"
+	"github.com/docker/swarm/garden/structs"
+	"github.com/docker/swarm/garden/tasklock"
+	"github.com/pkg/errors"
+	"golang.org/x/net/context"
+)
+
+const (
+	drainWaiting   = "waiting"
+	drainMigrating = "migrating"
+	drainDone      = "done"
+	drainFailed    = "failed"
+)
+
+type drainUnit struct {
+	unit   database.Unit
+	master bool
+	target string // the unit to switch the master over to,empty if fails over by compose
+	status string
+	taskID string
+}
+
+// drainProgress records per unit migrate progress of a drain task,
+// progress is saved into Task.Labels.
+type drainProgress []drainUnit
+
+func (dp drainProgress) String() string {
+	buf := bytes.NewBuffer(nil)
+
+	for i := range dp {
+		buf.WriteString(dp[i].unit.Name)
+		buf.WriteByte(':')
+		buf.WriteString(dp[i].status)
+
+		if dp[i].taskID != "" {
+			buf.WriteByte(':')
+			buf.WriteString(dp[i].taskID)
+		}
+
+		buf.WriteByte('\n')
+	}
+
+	return buf.String()
+}
+
+// sortDrainUnits returns units order by slaves first,masters at last.
+func sortDrainUnits(units []drainUnit) drainProgress {
+	out := make(drainProgress, 0, len(units))
+
+	for i := range units {
+		if !units[i].master {
+			out = append(out, units[i])
+		}
+	}
+
+	for i := range units {
+		if units[i].master {
+			out = append(out, units[i])
+		}
+	}
+
+	return out
+}
+
+// SwitchoverFunc switches the master of the service over to the target unit,
+// the linked services are repointed to the new master,see deploy.Deployment.DrainNode.
+type SwitchoverFunc func(ctx context.Context, svc *Service, target string) error
+
+// hasRoles returns true if the units of the arch have master and slave roles.
+func hasRoles(arch structs.Arch) bool {
+	switch arch.Mode {
+	case "replication", "group_replication", "sharding_replication":
+		return true
+	}
+
+	return false
+}
+
+// unitRole returns the role of the unit in the topology,returns error if the role is unknown.
+func unitRole(topology []structs.UnitTopology, id string) (string, error) {
+	for i := range topology {
+		if topology[i].ID != id {
+			continue
+		}
+
+		if topology[i].Role == "" {
+			return "", errors.Errorf("role of unit %s is unknown,%s", topology[i].Name, topology[i].Error)
+		}
+
+		return topology[i].Role, nil
+	}
+
+	return "", errors.Errorf("role of unit %s is unknown,not in topology", id)
+}
+
+// drainUnits returns the units of the service on the engine with the roles from the topology,
+// returns error if the role of any unit on the engine is unknown.
+// The master is switched over to the slave off the engine with the lowest lag if switchover,
+// returns error if there is no such slave.
+func drainUnits(units []database.Unit, topology []structs.UnitTopology, engine string, switchover bool) ([]drainUnit, error) {
+	roles := make(map[string]structs.UnitTopology, len(topology))
+	for _, t := range topology {
+		roles[t.ID] = t
+	}
+
+	target, lag := "", -1
+	if switchover {
+		for _, u := range units {
+			t := roles[u.ID]
+			if u.EngineID == engine || t.Role != "slave" || t.Error != "" {
+				continue
+			}
+
+			// lag -1 is unknown
+			if target == "" || (t.Lag >= 0 && (lag < 0 || t.Lag < lag)) {
+				target, lag = u.ID, t.Lag
+			}
+		}
+	}
+
+	out := make([]drainUnit, 0, len(units))
+
+	for _, u := range units {
+		if u.EngineID != engine {
+			continue
+		}
+
+		role, err := unitRole(topology, u.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		du := drainUnit{
+			unit:   u,
+			master: role == "master",
+			status: drainWaiting,
+		}
+
+		if du.master && switchover {
+			if target == "" {
+				return nil, errors.Errorf("no healthy slave off the host to switch master unit %s over", u.Name)
+			}
+
+			du.target = target
+		}
+
+		out = append(out, du)
+	}
+
+	return out, nil
+}
+
+// DrainNode stops scheduling on the node,then migrates all units off the node,
+// slave units are migrated first,master units at last,the roles are from the service topology.
+// The master of replication is switched over before migrated,others fail over by compose.
+// Progress of each unit is saved in the task labels.
+func (gd *Garden) DrainNode(ctx context.Context, nameOrID string, candidates []string, switchover SwitchoverFunc, async bool) (string, error) {
+	n, err := gd.ormer.GetNode(nameOrID)
+	if err != nil {
+		return "", err
+	}
+
+	err = gd.ormer.SetNodeEnable(n.ID, false)
+	if err != nil {
+		return "", err
+	}
+
+	var units []database.Unit
+	if n.EngineID != "" {
+		units, err = gd.ormer.ListUnitByEngine(n.EngineID)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	task := database.NewTask(n.Addr, database.NodeDrainTask, n.ID, "drain host", nil, 300*(len(units)+1))
+
+	before := func(key string, new int, t *database.Task, f func(val int) bool) (bool, int, error) {
+		err := gd.ormer.InsertTask(*t)
+		if err != nil {
+			return false, 0, err
+		}
+
+		return true, 0, nil
+	}
+
+	after := func(key string, val int, t *database.Task, now time.Time) error {
+		if t == nil {
+			return nil
+		}
+
+		return gd.ormer.SetTask(*t)
+	}
+
+	tl := tasklock.NewGoTask(database.NodeDrainTask, n.ID, &task, before, after)
+
+	err = tl.Run(func(int) bool { return true }, func() error {
+		return gd.drainNode(ctx, &task, n.EngineID, units, candidates, switchover)
+	}, async)
+
+	return task.ID, err
+}
+
+func (gd *Garden) drainNode(ctx context.Context, task *database.Task, engine string,
+	units []database.Unit, candidates []string, switchover SwitchoverFunc) error {
+
+	list := make([]drainUnit, 0, len(units))
+	services := make(map[string]*Service, len(units))
+
+	// roles of all units are decided before migrating any unit
+	for i := range units {
+		if _, ok := services[units[i].ServiceID]; ok {
+			continue
+		}
+
+		svc, err := gd.Service(units[i].ServiceID)
+		if err != nil {
+			return err
+		}
+
+		services[units[i].ServiceID] = svc
+
+		spec, err := svc.Spec()
+		if err != nil {
+			return err
+		}
+
+		all, err := gd.ormer.ListUnitByServiceID(svc.ID())
+		if err != nil {
+			return err
+		}
+
+		var topology structs.ServiceTopology
+
+		if hasRoles(spec.Arch) {
+			topology, err = svc.Topology(ctx)
+			if err != nil {
+				return errors.WithMessage(err, "service "+svc.Name()+" topology")
+			}
+		} else {
+			for _, u := range all {
+				topology.Units = append(topology.Units, structs.UnitTopology{ID: u.ID, Role: "standalone"})
+			}
+		}
+
+		out, err := drainUnits(all, topology.Units, engine, spec.Arch.Mode == "replication")
+		if err != nil {
+			return errors.WithMessage(err, "service "+svc.Name())
+		}
+
+		list = append(list, out...)
+	}
+
+	progress := sortDrainUnits(list)
+
+	report := func() {
+		task.Labels = progress.String()
+
+		err := gd.ormer.SetTaskLabels(*task)
+		if err != nil {
+			logrus.Warnf("update task %s progress,%+v", task.ID, err)
+		}
+	}
+
+	report()
+
+	errs := make([]string, 0, len(progress))
+
+	for i := range progress {
+		svc := services[progress[i].unit.ServiceID]
+
+		progress[i].status = drainMigrating
+		report()
+
+		if progress[i].target != "" {
+			err := switchover(ctx, svc, progress[i].target)
+			if err != nil {
+				progress[i].status = drainFailed
+				errs = append(errs, fmt.Sprintf("switchover master unit %s,%+v", progress[i].unit.Name, err))
+				report()
+				continue
+			}
+		}
+
+		req := structs.PostUnitMigrate{
+			// compose the service after master unit migrated,fail over to the new master
+			Compose:    progress[i].master && progress[i].target == "",
+			NameOrID:   progress[i].unit.ID,
+			Candidates: candidates,
+		}
+
+		id, err := gd.ServiceMigrate(ctx, svc, req, false)
+		progress[i].taskID = id
+
+		if err != nil {
+			progress[i].status = drainFailed
+			errs = append(errs, fmt.Sprintf("migrate unit %s,%+v", progress[i].unit.Name, err))
+		} else {
+			progress[i].status = drainDone
+		}
+
+		report()
+	}
+
+	if len(errs) > 0 {
+		return errors.Errorf("drain host,%d units migrate failed\n%s", len(errs), strings.Join(errs, "\n"))
+	}
+
+	return nil
+}
+
+// UndrainNode returns the node to service,enable scheduling on the node.
+func (gd *Garden) UndrainNode(nameOrID string) error {
+	n, err := gd.ormer.GetNode(nameOrID)
+	if err != nil {
+		return err
+	}
+
+	return gd.ormer.SetNodeEnable(n.ID, true)
+}
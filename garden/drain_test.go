@@ -0,0 +1,88 @@
+package garden
+
+import (
+	"testing"
+
+	"github.com/docker/swarm/garden/database"
+	"github.com/docker/swarm/garden/structs"
+)
+
+func TestSortDrainUnits(t *testing.T) {
+	units := []drainUnit{
+		{unit: database.Unit{Name: "unit001"}, master: true, status: drainWaiting},
+		{unit: database.Unit{Name: "unit002"}, status: drainWaiting},
+		{unit: database.Unit{Name: "unit003"}, status: drainWaiting},
+	}
+
+	out := sortDrainUnits(units)
+	if len(out) != len(units) {
+		t.Fatalf("expected %d units but got %d", len(units), len(out))
+	}
+
+	if out[len(out)-1].unit.Name != "unit001" {
+		t.Errorf("expected master unit at last but got %s", out[len(out)-1].unit.Name)
+	}
+
+	out[0].status = drainDone
+	out[0].taskID = "task001"
+
+	want := "unit002:done:task001\nunit003:waiting\nunit001:waiting\n"
+	if got := out.String(); got != want {
+		t.Errorf("expected %q but got %q", want, got)
+	}
+}
+
+func TestDrainUnits(t *testing.T) {
+	units := []database.Unit{
+		{ID: "u1", Name: "unit001", EngineID: "e1"},
+		{ID: "u2", Name: "unit002", EngineID: "e1"},
+		{ID: "u3", Name: "unit003", EngineID: "e2"},
+		{ID: "u4", Name: "unit004", EngineID: "e3"},
+	}
+
+	topology := []structs.UnitTopology{
+		{ID: "u1", Name: "unit001", Role: "master"},
+		{ID: "u2", Name: "unit002", Role: "slave", Lag: 0},
+		{ID: "u3", Name: "unit003", Role: "slave", Lag: 5},
+		{ID: "u4", Name: "unit004", Role: "slave", Lag: 1},
+	}
+
+	out, err := drainUnits(units, topology, "e1", true)
+	if err != nil {
+		t.Fatalf("unexpected error,%+v", err)
+	}
+
+	if len(out) != 2 {
+		t.Fatalf("expected 2 units but got %d", len(out))
+	}
+
+	if !out[0].master || out[0].target != "u4" {
+		t.Errorf("expected master switched over to u4 but got %v %s", out[0].master, out[0].target)
+	}
+
+	if out[1].master || out[1].target != "" {
+		t.Errorf("expected slave without target but got %v %s", out[1].master, out[1].target)
+	}
+
+	out, err = drainUnits(units, topology, "e1", false)
+	if err != nil || out[0].target != "" {
+		t.Errorf("expected master fails over by compose but got %q,%v", out[0].target, err)
+	}
+
+	topology[1].Role, topology[1].Error = "", "probe timeout"
+
+	if _, err := drainUnits(units, topology, "e1", true); err == nil {
+		t.Error("expected error for unknown role")
+	}
+
+	topology[1].Role = "slave"
+	topology[2].Role, topology[3].Role = "", ""
+
+	if _, err := drainUnits(units, topology, "e2", true); err == nil {
+		t.Error("expected error for unknown role of unit on the host")
+	}
+
+	if _, err := drainUnits(units, topology, "e1", true); err == nil {
+		t.Error("expected error without slave off the host")
+	}
+}
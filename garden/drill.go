@@ -0,0 +1,373 @@
+package garden
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/swarm/garden/database"
+	"github.com/docker/swarm/garden/resource/alloc"
+	"github.com/docker/swarm/garden/structs"
+	"github.com/docker/swarm/garden/tasklock"
+	"github.com/docker/swarm/garden/utils"
+	"github.com/pkg/errors"
+	"golang.org/x/net/context"
+)
+
+// latestBackupFile returns the latest full backup file which isnot failed in verification
+func latestBackupFile(files []database.BackupFile) (database.BackupFile, bool) {
+	var (
+		latest database.BackupFile
+		found  bool
+	)
+
+	for i := range files {
+		if files[i].Verify == database.BackupFileVerifyFailed ||
+			(files[i].Type != "" && files[i].Type != "full") {
+			continue
+		}
+
+		if !found || files[i].FinishedAt.After(latest.FinishedAt) {
+			latest = files[i]
+			found = true
+		}
+	}
+
+	return latest, found
+}
+
+// drillBackupFile returns the backup file of the service by ID,the latest one if ID is empty.
+func (svc *Service) drillBackupFile(ID string) (database.BackupFile, error) {
+	files, err := svc.so.ListBackupFilesByService(svc.ID())
+	if err != nil {
+		return database.BackupFile{}, err
+	}
+
+	if ID == "" {
+		bf, ok := latestBackupFile(files)
+		if !ok {
+			return bf, errors.Errorf("Service %s has no full backup file for restore drill", svc.Name())
+		}
+
+		return bf, nil
+	}
+
+	for i := range files {
+		if files[i].ID == ID {
+			return files[i], nil
+		}
+	}
+
+	return database.BackupFile{}, errors.Errorf("BackupFile %s isnot belongs to Service %s", ID, svc.Name())
+}
+
+// RestoreDrill restores the backup file into the unit of a temporary service,
+// runs the validation command in the unit and removes the temporary service at last.
+// The result is recorded in the task,and in the restore drill schedule of the service if exists.
+func (gd *Garden) RestoreDrill(ctx context.Context, svc *Service, req structs.ServiceRestoreDrillRequest, async bool) (string, error) {
+	bf, err := svc.drillBackupFile(req.File)
+	if err != nil {
+		return "", err
+	}
+
+	task := database.NewTask(svc.Name(), database.RestoreDrillTask, svc.ID(), "backup_file="+bf.ID, nil, 600)
+
+	drill := func() error {
+		err := gd.restoreDrill(ctx, svc, bf, req.Cmd)
+
+		result := database.RestoreDrill{
+			ServiceID:  svc.ID(),
+			LastTaskID: task.ID,
+			LastResult: database.RestoreDrillPassed,
+			LastRunAt:  time.Now(),
+		}
+		if err != nil {
+			result.LastResult = database.RestoreDrillFailed
+		}
+
+		_err := gd.ormer.SetRestoreDrillResult(result)
+		if _err != nil {
+			logrus.WithField("Service", svc.Name()).Warnf("record restore drill result:%+v", _err)
+		}
+
+		return err
+	}
+
+	tl := tasklock.NewServiceTask(database.RestoreDrillTask, svc.ID(), svc.so, &task,
+		statusServiceRestoreDrilling, statusServiceRestoreDrilled, statusServiceRestoreDrillFailed)
+
+	err = tl.Run(isnotInProgress, drill, async)
+
+	return task.ID, err
+}
+
+// drillSpec returns the spec of the temporary service for the restore drill,
+// a standalone unit of the same image and require,isnot a member of the service.
+func drillSpec(spec structs.ServiceSpec) structs.ServiceSpec {
+	out := spec
+
+	out.ID = utils.Generate32UUID()
+	out.Name = fmt.Sprintf("%s_drill_%s", spec.Name, out.ID[:8])
+	out.Arch = structs.Arch{Replicas: 1, Mode: "clone", Code: "M:1"}
+	out.Status = 0
+	out.CreatedAt = ""
+	out.FinishedAt = ""
+	out.AutoHealing = false
+	out.AutoScaling = false
+	out.HighAvailable = false
+	out.Spread = nil
+	out.Affinities = nil
+	out.Units = nil
+
+	if spec.Options != nil {
+		out.Options = make(map[string]interface{}, len(spec.Options))
+		for k, v := range spec.Options {
+			out.Options[k] = v
+		}
+	}
+
+	return out
+}
+
+// restoreDrill deploys a temporary service of one unit,the unit isnot registered to the kv store
+// and the monitors,and isnot in the configs of the service.
+func (gd *Garden) restoreDrill(ctx context.Context, svc *Service, bf database.BackupFile, cmd []string) (err error) {
+	spec, err := svc.RefreshSpec()
+	if err != nil {
+		return err
+	}
+
+	// the drill runs the image of the service,even if deprecated
+	drill, task, err := gd.buildService(drillSpec(*spec), true)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		_err := gd.removeDrillService(ctx, drill)
+
+		task.Status = database.TaskDoneStatus
+		if err != nil || _err != nil {
+			task.Status = database.TaskFailedStatus
+		}
+		task.SetErrors(err)
+
+		if e := gd.ormer.SetTask(*task); e != nil {
+			logrus.WithField("Service", drill.Name()).Warnf("record restore drill service task:%+v", e)
+		}
+
+		if _err == nil {
+			return
+		}
+
+		if err != nil {
+			err = errors.Errorf("%+v\nremove restore drill service:%+v", err, _err)
+		} else {
+			err = _err
+		}
+	}()
+
+	actor := alloc.NewAllocator(gd.ormer, gd.Cluster)
+
+	pendings, err := gd.allocation(ctx, actor, drill, nil, true, true)
+	if err != nil {
+		return err
+	}
+
+	units := make([]*unit, len(pendings))
+	for i := range pendings {
+		units[i] = newUnit(pendings[i].Unit, drill.so, drill.cluster)
+	}
+
+	auth, err := gd.AuthConfig()
+	if err != nil {
+		return err
+	}
+
+	err = drill.createContainer(ctx, pendings, auth)
+	if err != nil {
+		return err
+	}
+
+	err = drill.initStart(ctx, units, nil, nil, nil)
+	if err != nil {
+		return err
+	}
+
+	u := units[0]
+
+	err = drill.restoreUnits(ctx, []string{u.u.ID}, bf.Path)
+	if err != nil {
+		return err
+	}
+
+	if len(cmd) == 0 {
+		cmds, err := drill.generateUnitsCmd(ctx)
+		if err != nil {
+			return err
+		}
+
+		cmd = cmds.GetCmd(u.u.ID, structs.RestoreCheckCmd)
+		if len(cmd) == 0 {
+			return errors.Errorf("image %s has no %s,set it in check_cmds of the image template or the drill cmd", spec.Image.Image(), structs.RestoreCheckCmd)
+		}
+	}
+
+	buf := bytes.NewBuffer(nil)
+
+	inspect, err := u.ContainerExec(ctx, cmd, false, buf)
+	if err != nil {
+		return err
+	}
+
+	if inspect.ExitCode != 0 {
+		return errors.Errorf("restore drill of %s failed,unit %s exec %s,exit code %d,%s",
+			bf.ID, u.u.Name, cmd, inspect.ExitCode, buf.String())
+	}
+
+	return nil
+}
+
+// removeDrillService removes the containers,volumes,configs and records of the temporary service
+func (gd *Garden) removeDrillService(ctx context.Context, drill *Service) error {
+	units, err := drill.getUnits()
+	if err != nil {
+		return err
+	}
+
+	err = drill.removeUnits(ctx, units, nil)
+	if err != nil {
+		return err
+	}
+
+	err = drill.removeUnitsConfigs(ctx, gd.kvClient)
+	if err != nil {
+		return err
+	}
+
+	return drill.so.DelServiceRelation(drill.ID(), true)
+}
+
+// SetRestoreDrill schedules the restore drill of the service every req.Interval seconds.
+func (gd *Garden) SetRestoreDrill(svc *Service, req structs.ServiceRestoreDrillRequest) error {
+	if req.File != "" {
+		_, err := svc.drillBackupFile(req.File)
+		if err != nil {
+			return err
+		}
+	}
+
+	cmd := ""
+	if len(req.Cmd) > 0 {
+		out, err := json.Marshal(req.Cmd)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+
+		cmd = string(out)
+	}
+
+	return gd.ormer.SetRestoreDrill(database.RestoreDrill{
+		ServiceID:    svc.ID(),
+		BackupFileID: req.File,
+		Cmd:          cmd,
+		Interval:     req.Interval,
+		CreatedAt:    time.Now(),
+	})
+}
+
+// ConvertRestoreDrill converts database.RestoreDrill to structs.RestoreDrillInfo
+func ConvertRestoreDrill(d database.RestoreDrill) structs.RestoreDrillInfo {
+	info := structs.RestoreDrillInfo{
+		Service:    d.ServiceID,
+		File:       d.BackupFileID,
+		Interval:   d.Interval,
+		LastTask:   d.LastTaskID,
+		LastResult: d.LastResult,
+		LastRunAt:  utils.TimeToString(d.LastRunAt),
+		CreatedAt:  utils.TimeToString(d.CreatedAt),
+	}
+
+	if d.Cmd != "" {
+		_ = json.Unmarshal([]byte(d.Cmd), &info.Cmd)
+	}
+
+	return info
+}
+
+// dueRestoreDrills returns the scheduled drills should be run at now
+func dueRestoreDrills(drills []database.RestoreDrill, now time.Time) []database.RestoreDrill {
+	out := make([]database.RestoreDrill, 0, len(drills))
+
+	for i := range drills {
+		if drills[i].Interval <= 0 {
+			continue
+		}
+
+		if now.Sub(drills[i].LastRunAt) >= time.Duration(drills[i].Interval)*time.Second {
+			out = append(out, drills[i])
+		}
+	}
+
+	return out
+}
+
+// RunRestoreDrills checks the restore drill schedules every interval until ctx is done,
+// the drills due are run one by one.
+func (gd *Garden) RunRestoreDrills(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		drills, err := gd.ormer.ListRestoreDrills()
+		if err != nil {
+			logrus.Warnf("list restore drills:%+v", err)
+			continue
+		}
+
+		for _, d := range dueRestoreDrills(drills, time.Now()) {
+			select {
+			case <-ctx.Done():
+				return
+			default:
+			}
+
+			info := ConvertRestoreDrill(d)
+			entry := logrus.WithField("Service", d.ServiceID)
+
+			svc, err := gd.Service(d.ServiceID)
+			if err != nil {
+				entry.Warnf("restore drill:%+v", err)
+				continue
+			}
+
+			id, err := gd.RestoreDrill(ctx, svc, structs.ServiceRestoreDrillRequest{
+				File: info.File,
+				Cmd:  info.Cmd,
+			}, false)
+			if err != nil {
+				entry.WithField("Task", id).Warnf("restore drill failed:%+v", err)
+
+				if id == "" {
+					// not started,record the failure to wait for the next interval
+					d.LastResult = database.RestoreDrillFailed
+					d.LastRunAt = time.Now()
+
+					if err := gd.ormer.SetRestoreDrillResult(d); err != nil {
+						entry.Warnf("record restore drill result:%+v", err)
+					}
+				}
+			} else {
+				entry.WithField("Task", id).Info("restore drill passed")
+			}
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package garden
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/swarm/garden/database"
+	"github.com/docker/swarm/garden/structs"
+)
+
+func TestLatestBackupFile(t *testing.T) {
+	now := time.Now()
+
+	files := []database.BackupFile{
+		{ID: "f1", Type: "full", FinishedAt: now.Add(-3 * time.Hour)},
+		{ID: "f2", Type: "full", FinishedAt: now.Add(-2 * time.Hour)},
+		{ID: "f3", Type: "full", FinishedAt: now.Add(-time.Hour), Verify: database.BackupFileVerifyFailed},
+		{ID: "f4", Type: "incremental", FinishedAt: now},
+	}
+
+	bf, ok := latestBackupFile(files)
+	if !ok || bf.ID != "f2" {
+		t.Errorf("expected f2 but got %s,%t", bf.ID, ok)
+	}
+
+	if _, ok := latestBackupFile(files[2:]); ok {
+		t.Error("expected no backup file for restore drill")
+	}
+}
+
+func TestDueRestoreDrills(t *testing.T) {
+	now := time.Now()
+
+	drills := []database.RestoreDrill{
+		{ServiceID: "s1", Interval: 3600, LastRunAt: now.Add(-2 * time.Hour)},
+		{ServiceID: "s2", Interval: 3600, LastRunAt: now.Add(-time.Minute)},
+		{ServiceID: "s3", Interval: 0},
+		{ServiceID: "s4", Interval: 60},
+	}
+
+	due := dueRestoreDrills(drills, now)
+	if len(due) != 2 || due[0].ServiceID != "s1" || due[1].ServiceID != "s4" {
+		t.Errorf("expected [s1 s4] but got %v", due)
+	}
+}
+
+func TestConvertRestoreDrill(t *testing.T) {
+	info := ConvertRestoreDrill(database.RestoreDrill{
+		ServiceID: "s1",
+		Cmd:       `["/root/serv","check"]`,
+		Interval:  60,
+	})
+
+	if len(info.Cmd) != 2 || info.Cmd[1] != "check" || info.Interval != 60 {
+		t.Errorf("unexpected %+v", info)
+	}
+}
+
+func TestDrillSpec(t *testing.T) {
+	spec := structs.ServiceSpec{
+		Service: structs.Service{ID: "s1", Name: "svc1", Tag: "tag1", HighAvailable: true, Status: 2},
+		Arch:    structs.Arch{Replicas: 3, Mode: "group_replication", Code: "M:3"},
+		Units:   []structs.UnitSpec{{}, {}, {}},
+		Spread:  []structs.SpreadRule{{Label: "rack"}},
+		Options: map[string]interface{}{"mysqld::port": 3306},
+	}
+
+	out := drillSpec(spec)
+
+	if out.ID == "" || out.ID == spec.ID || !strings.HasPrefix(out.Name, "svc1_drill_") {
+		t.Errorf("expected a new service,%s %s", out.ID, out.Name)
+	}
+
+	if out.Arch.Replicas != 1 || out.Arch.Mode != "clone" || len(out.Units) != 0 || len(out.Spread) != 0 || out.HighAvailable || out.Status != 0 {
+		t.Errorf("expected a standalone unit,%+v", out)
+	}
+
+	out.Options["mysqld::port"] = 3307
+	if spec.Options["mysqld::port"] != 3306 {
+		t.Error("options of the service should not be changed")
+	}
+}
@@ -0,0 +1,357 @@
+package garden
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/swarm/cluster"
+	"github.com/docker/swarm/garden/structs"
+	"github.com/docker/swarm/garden/utils"
+	"github.com/pkg/errors"
+	"golang.org/x/net/context"
+)
+
+const execSessionTTL = time.Minute
+
+var (
+	// ErrExecSessionNotFound the exec session isnot exist or belongs to other service
+	ErrExecSessionNotFound = errors.New("exec session is not found")
+	// ErrExecSessionToken the token of the exec session is invalid or expired
+	ErrExecSessionToken = errors.New("invalid exec session token")
+	// ErrExecSessionStarted the exec session has been started
+	ErrExecSessionStarted = errors.New("exec session has been started")
+	// ErrExecNotAllowed the exec isnot allowed by the ExecPolicy of the service
+	ErrExecNotAllowed = errors.New("exec is not allowed by the service exec policy")
+)
+
+type execSession struct {
+	structs.ExecSession
+	service string
+	engine  *cluster.Engine
+	expires time.Time
+	started bool
+}
+
+// execSessions are the interactive exec sessions created by the master,
+// a session is bound to the service and started once with the token.
+type execSessions struct {
+	sync.Mutex
+	sessions map[string]*execSession
+}
+
+func newExecSessions() *execSessions {
+	return &execSessions{
+		sessions: make(map[string]*execSession),
+	}
+}
+
+func (es *execSessions) add(s *execSession) {
+	es.Lock()
+	es.sessions[s.ID] = s
+	es.Unlock()
+}
+
+// get returns the session of the service if the token is valid,
+// the sessions not started before expired are removed.
+func (es *execSessions) get(service, id, token string, now time.Time) (*execSession, error) {
+	es.Lock()
+	defer es.Unlock()
+
+	for key, s := range es.sessions {
+		if !s.started && now.After(s.expires) {
+			delete(es.sessions, key)
+		}
+	}
+
+	s, ok := es.sessions[id]
+	if !ok || s.service != service {
+		return nil, errors.WithStack(ErrExecSessionNotFound)
+	}
+
+	if !hmac.Equal([]byte(token), []byte(s.Token)) {
+		return nil, errors.WithStack(ErrExecSessionToken)
+	}
+
+	return s, nil
+}
+
+func (es *execSessions) start(service, id, token string, now time.Time) (*execSession, error) {
+	s, err := es.get(service, id, token, now)
+	if err != nil {
+		return nil, err
+	}
+
+	es.Lock()
+	defer es.Unlock()
+
+	if s.started {
+		return nil, errors.WithStack(ErrExecSessionStarted)
+	}
+
+	s.started = true
+
+	return s, nil
+}
+
+func (es *execSessions) remove(id string) {
+	es.Lock()
+	delete(es.sessions, id)
+	es.Unlock()
+}
+
+// checkExecPolicy returns ErrExecNotAllowed if the command,the user or the env of config isnot allowed by policy,
+// the command is matched by the argv prefix,and the empty user is the default user of the container.
+func checkExecPolicy(policy *structs.ExecPolicy, config structs.UnitExecConfig) error {
+	if policy == nil {
+		return errors.Wrap(ErrExecNotAllowed, "no exec policy")
+	}
+
+	if config.User != "" {
+		allowed := false
+		for _, user := range policy.Users {
+			if user == config.User {
+				allowed = true
+				break
+			}
+		}
+
+		if !allowed {
+			return errors.Wrapf(ErrExecNotAllowed, "user '%s'", config.User)
+		}
+	}
+
+	for _, env := range config.Env {
+		name := strings.SplitN(env, "=", 2)[0]
+
+		allowed := false
+		for _, e := range policy.Env {
+			if e == name {
+				allowed = true
+				break
+			}
+		}
+
+		if !allowed {
+			return errors.Wrapf(ErrExecNotAllowed, "env '%s'", name)
+		}
+	}
+
+	if len(config.Cmd) == 0 {
+		return errors.Wrap(ErrExecNotAllowed, "empty command")
+	}
+
+	for _, cmd := range policy.Cmds {
+		if cmd == "*" || hasArgsPrefix(config.Cmd, strings.Fields(cmd)) {
+			return nil
+		}
+	}
+
+	return errors.Wrapf(ErrExecNotAllowed, "command '%s'", strings.Join(config.Cmd, " "))
+}
+
+// hasArgsPrefix returns true if args begins with prefix.
+func hasArgsPrefix(args, prefix []string) bool {
+	if len(prefix) == 0 || len(args) < len(prefix) {
+		return false
+	}
+
+	for i := range prefix {
+		if args[i] != prefix[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// SetExecPolicy updates the ExecPolicy of the service,nil policy denies all exec sessions,
+// the policy is kept in the schedule options of a new service description.
+func (gd *Garden) SetExecPolicy(nameOrID string, policy *structs.ExecPolicy) error {
+	table, err := gd.ormer.GetService(nameOrID)
+	if err != nil {
+		return err
+	}
+
+	if table.Desc == nil {
+		return errors.Errorf("service %s without description", nameOrID)
+	}
+
+	desc := *table.Desc
+	desc.ID = utils.Generate32UUID()
+
+	opts := scheduleOption{}
+	if desc.ScheduleOptions != "" {
+		err = json.Unmarshal([]byte(desc.ScheduleOptions), &opts)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+	}
+
+	opts.Exec = policy
+
+	dat, err := json.Marshal(opts)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	desc.ScheduleOptions = string(dat)
+	desc.Previous = table.DescID
+
+	table.DescID = desc.ID
+	table.Desc = &desc
+
+	return gd.ormer.SetServiceDesc(table)
+}
+
+// CreateExecSession creates an interactive exec session on the unit of the service if allowed by the ExecPolicy,
+// the session is started once with the returned token before expired.
+func (gd *Garden) CreateExecSession(ctx context.Context, service, nameOrID string, config structs.UnitExecConfig) (structs.ExecSession, error) {
+	svc, err := gd.Service(service)
+	if err != nil {
+		return structs.ExecSession{}, err
+	}
+
+	if svc.svc == nil || svc.svc.Desc == nil {
+		return structs.ExecSession{}, errors.Errorf("service %s without description", service)
+	}
+
+	opts, err := svc.getScheduleOption()
+	if err != nil {
+		return structs.ExecSession{}, errors.WithStack(err)
+	}
+
+	err = checkExecPolicy(opts.Exec, config)
+	if err != nil {
+		return structs.ExecSession{}, err
+	}
+
+	u, err := svc.GetUnit(nameOrID)
+	if err != nil {
+		return structs.ExecSession{}, err
+	}
+
+	c := u.getContainer()
+	if c == nil || c.Engine == nil {
+		return structs.ExecSession{}, errors.WithStack(newContainerError(u.u.Name, notFound))
+	}
+
+	if !c.Info.State.Running {
+		return structs.ExecSession{}, errors.WithStack(newContainerError(u.u.Name, notRunning))
+	}
+
+	secret := make([]byte, 32)
+
+	_, err = io.ReadFull(rand.Reader, secret)
+	if err != nil {
+		return structs.ExecSession{}, errors.Wrap(err, "generate exec session token")
+	}
+
+	id, err := c.CreateExecSession(ctx, types.ExecConfig{
+		Cmd:  config.Cmd,
+		Tty:  config.Tty,
+		Env:  config.Env,
+		User: config.User,
+	})
+	if err != nil {
+		return structs.ExecSession{}, err
+	}
+
+	expires := time.Now().Add(execSessionTTL)
+
+	s := &execSession{
+		ExecSession: structs.ExecSession{
+			ID:        id,
+			Service:   svc.Name(),
+			Unit:      u.u.Name,
+			Tty:       config.Tty,
+			Token:     hex.EncodeToString(secret),
+			ExpiresAt: utils.TimeToString(expires),
+		},
+		service: svc.ID(),
+		engine:  c.Engine,
+		expires: expires,
+	}
+
+	gd.execSessions.add(s)
+
+	return s.ExecSession, nil
+}
+
+// StartExecSession starts the exec session of the service,
+// returns the hijacked connection of the exec and whether it's tty,
+// the session should be closed by CloseExecSession after the connection is done.
+func (gd *Garden) StartExecSession(ctx context.Context, service, id, token string) (types.HijackedResponse, bool, error) {
+	svc, err := gd.Service(service)
+	if err != nil {
+		return types.HijackedResponse{}, false, err
+	}
+
+	s, err := gd.execSessions.start(svc.ID(), id, token, time.Now())
+	if err != nil {
+		return types.HijackedResponse{}, false, err
+	}
+
+	resp, err := s.engine.AttachExecSession(ctx, id, s.Tty)
+	if err != nil {
+		gd.execSessions.remove(id)
+	}
+
+	return resp, s.Tty, err
+}
+
+// ResizeExecSession resizes the tty of the exec session of the service.
+func (gd *Garden) ResizeExecSession(ctx context.Context, service, id, token string, height, width uint) error {
+	svc, err := gd.Service(service)
+	if err != nil {
+		return err
+	}
+
+	s, err := gd.execSessions.get(svc.ID(), id, token, time.Now())
+	if err != nil {
+		return err
+	}
+
+	return s.engine.ResizeExecSession(ctx, id, height, width)
+}
+
+// CloseExecSession removes the exec session.
+func (gd *Garden) CloseExecSession(id string) {
+	gd.execSessions.remove(id)
+}
+
+// UnitLogs returns the logs stream of the unit container and whether the container is tty,
+// stdout and stderr are multiplexed in the stream unless tty,
+// returns ErrExecNotAllowed if logs isnot allowed by the ExecPolicy of the service.
+func (svc *Service) UnitLogs(ctx context.Context, nameOrID string, options types.ContainerLogsOptions) (io.ReadCloser, bool, error) {
+	opts, err := svc.getScheduleOption()
+	if err != nil {
+		return nil, false, errors.WithStack(err)
+	}
+
+	if opts.Exec == nil || !opts.Exec.Logs {
+		return nil, false, errors.Wrap(ErrExecNotAllowed, "logs")
+	}
+
+	u, err := svc.GetUnit(nameOrID)
+	if err != nil {
+		return nil, false, err
+	}
+
+	c := u.getContainer()
+	if c == nil || c.Engine == nil {
+		return nil, false, errors.WithStack(newContainerError(u.u.Name, notFound))
+	}
+
+	tty := c.Config != nil && c.Config.Tty
+
+	rc, err := c.Engine.ContainerLogs(ctx, c.ID, options)
+
+	return rc, tty, err
+}
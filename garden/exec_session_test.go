@@ -0,0 +1,106 @@
+package garden
+
+import (
+	"testing"
+	"time"
+
+	"github.com/docker/swarm/garden/structs"
+	"github.com/pkg/errors"
+)
+
+func TestExecSessions(t *testing.T) {
+	now := time.Now()
+	es := newExecSessions()
+
+	es.add(&execSession{
+		ExecSession: structs.ExecSession{ID: "exec001", Token: "token001"},
+		service:     "service001",
+		expires:     now.Add(time.Minute),
+	})
+	es.add(&execSession{
+		ExecSession: structs.ExecSession{ID: "exec002", Token: "token002"},
+		service:     "service001",
+		expires:     now.Add(-time.Second),
+	})
+
+	if _, err := es.get("service002", "exec001", "token001", now); errors.Cause(err) != ErrExecSessionNotFound {
+		t.Errorf("expected not found with other service but got %v", err)
+	}
+
+	if _, err := es.get("service001", "exec001", "token002", now); errors.Cause(err) != ErrExecSessionToken {
+		t.Errorf("expected invalid token but got %v", err)
+	}
+
+	if _, err := es.start("service001", "exec002", "token002", now); errors.Cause(err) != ErrExecSessionNotFound {
+		t.Errorf("expected expired session removed but got %v", err)
+	}
+
+	if _, err := es.start("service001", "exec001", "token001", now); err != nil {
+		t.Error(err)
+	}
+
+	if _, err := es.start("service001", "exec001", "token001", now); errors.Cause(err) != ErrExecSessionStarted {
+		t.Errorf("expected started session but got %v", err)
+	}
+
+	// started session is kept after expired,resize is allowed
+	if _, err := es.get("service001", "exec001", "token001", now.Add(time.Hour)); err != nil {
+		t.Error(err)
+	}
+
+	es.remove("exec001")
+
+	if _, err := es.get("service001", "exec001", "token001", now); errors.Cause(err) != ErrExecSessionNotFound {
+		t.Errorf("expected removed session but got %v", err)
+	}
+}
+
+func TestCheckExecPolicy(t *testing.T) {
+	bash := structs.UnitExecConfig{Cmd: []string{"/bin/bash"}}
+
+	if err := checkExecPolicy(nil, bash); errors.Cause(err) != ErrExecNotAllowed {
+		t.Errorf("expected denied without policy but got %v", err)
+	}
+
+	policy := &structs.ExecPolicy{Cmds: []string{"/bin/bash"}, Users: []string{"mysql"}}
+
+	if err := checkExecPolicy(policy, bash); err != nil {
+		t.Error(err)
+	}
+
+	if err := checkExecPolicy(policy, structs.UnitExecConfig{Cmd: []string{"/bin/sh"}}); errors.Cause(err) != ErrExecNotAllowed {
+		t.Errorf("expected denied command but got %v", err)
+	}
+
+	bash.User = "root"
+	if err := checkExecPolicy(policy, bash); errors.Cause(err) != ErrExecNotAllowed {
+		t.Errorf("expected denied user but got %v", err)
+	}
+
+	bash.User = "mysql"
+	policy.Cmds = []string{"*"}
+	if err := checkExecPolicy(policy, bash); err != nil {
+		t.Error(err)
+	}
+
+	bash.Env = []string{"LANG=C"}
+	if err := checkExecPolicy(policy, bash); errors.Cause(err) != ErrExecNotAllowed {
+		t.Errorf("expected denied env but got %v", err)
+	}
+
+	policy.Env = []string{"LANG"}
+	if err := checkExecPolicy(policy, bash); err != nil {
+		t.Error(err)
+	}
+
+	policy.Cmds = []string{"mysql -uroot"}
+	for _, cmd := range [][]string{{"mysql"}, {"mysql", "-uadmin"}, {"/bin/sh", "-c", "mysql -uroot"}} {
+		if err := checkExecPolicy(policy, structs.UnitExecConfig{Cmd: cmd}); errors.Cause(err) != ErrExecNotAllowed {
+			t.Errorf("expected denied command %q but got %v", cmd, err)
+		}
+	}
+
+	if err := checkExecPolicy(policy, structs.UnitExecConfig{Cmd: []string{"mysql", "-uroot", "-e", "show databases"}}); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,197 @@
+package garden
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/docker/swarm/garden/backup"
+	"github.com/docker/swarm/garden/database"
+	"github.com/docker/swarm/garden/kvstore"
+	"github.com/docker/swarm/garden/structs"
+	"github.com/docker/swarm/garden/utils"
+	"github.com/pkg/errors"
+	"golang.org/x/net/context"
+)
+
+// serviceLinkKV stores the link definitions of service,/links/<service_id>/<mode>
+const serviceLinkKV = "/links/"
+
+// SaveServicesLink stores the link definition for each service of the links,
+// the definitions are exported with the services.
+func SaveServicesLink(ctx context.Context, kvc kvstore.Store, links structs.ServicesLink) error {
+	if kvc == nil {
+		return nil
+	}
+
+	def := structs.ServicesLink{
+		Mode:     links.Mode,
+		NameOrID: links.NameOrID,
+		Links:    make([]*structs.ServiceLink, 0, len(links.Links)),
+	}
+
+	for _, l := range links.Links {
+		if l == nil {
+			continue
+		}
+
+		def.Links = append(def.Links, &structs.ServiceLink{
+			Arch: l.Arch,
+			ID:   l.ID,
+			Deps: l.Deps,
+		})
+	}
+
+	val, err := json.Marshal(def)
+	if err != nil {
+		return errors.Wrapf(err, "JSON marshal ServicesLink %s", links.Mode)
+	}
+
+	// the links refer services by name or ID,stored by ID
+	alias := make(map[string]string, len(links.Links))
+	for _, l := range links.Links {
+		if l != nil && l.Spec != nil && l.Spec.ID != "" {
+			alias[l.Spec.Name] = l.Spec.ID
+			alias[l.ID] = l.Spec.ID
+		}
+	}
+
+	for _, id := range links.LinkIDs() {
+		if v, ok := alias[id]; ok {
+			id = v
+		}
+
+		err := kvc.PutKV(ctx, serviceLinkKV+id+"/"+links.Mode, val)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ListServiceLinks returns the stored link definitions of the service.
+func ListServiceLinks(ctx context.Context, kvc kvstore.Store, service string) ([]structs.ServicesLink, error) {
+	if kvc == nil {
+		return nil, nil
+	}
+
+	pairs, err := kvc.ListKV(ctx, serviceLinkKV+service+"/")
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]structs.ServicesLink, 0, len(pairs))
+
+	for _, pair := range pairs {
+		if pair == nil || len(pair.Value) == 0 {
+			continue
+		}
+
+		var sl structs.ServicesLink
+
+		err := json.Unmarshal(pair.Value, &sl)
+		if err != nil {
+			return nil, errors.Wrapf(err, "JSON unmarshal ServicesLink %s", pair.Key)
+		}
+
+		out = append(out, sl)
+	}
+
+	return out, nil
+}
+
+// ExportService returns the portable bundle of the service,
+// backup is the BackupFile ID of the service uploaded to the S3 backup target,
+// the latest full backup file uploaded is used if backup is empty,
+// the bundle has no backup if the service has no full backup file uploaded and backup is empty.
+// The users of the service aren't persisted,so they aren't exported.
+func (gd *Garden) ExportService(ctx context.Context, nameOrID, backupID string) (structs.ServiceExport, error) {
+	svc, err := gd.Service(nameOrID)
+	if err != nil {
+		return structs.ServiceExport{}, err
+	}
+
+	spec, err := svc.Spec()
+	if err != nil {
+		return structs.ServiceExport{}, err
+	}
+
+	configs, err := svc.GetUnitsConfigs(ctx)
+	if err != nil {
+		return structs.ServiceExport{}, err
+	}
+
+	links, err := ListServiceLinks(ctx, gd.kvClient, spec.ID)
+	if err != nil {
+		return structs.ServiceExport{}, err
+	}
+
+	out := structs.ServiceExport{
+		Spec:       *spec,
+		Image:      spec.Image.Image(),
+		Configs:    configs,
+		Links:      links,
+		ExportedAt: utils.TimeToString(time.Now()),
+	}
+	out.Spec.Users = nil
+
+	files, err := svc.so.ListBackupFilesByService(svc.ID())
+	if err != nil {
+		return out, err
+	}
+
+	remote := make([]database.BackupFile, 0, len(files))
+	for i := range files {
+		if backup.IsRemote(files[i].Path) {
+			remote = append(remote, files[i])
+		}
+	}
+
+	bf, ok := latestBackupFile(remote)
+
+	if backupID != "" {
+		bf, ok = database.BackupFile{}, false
+
+		for i := range files {
+			if files[i].ID == backupID {
+				bf, ok = files[i], true
+				break
+			}
+		}
+
+		if !ok {
+			return out, errors.Errorf("BackupFile %s isnot belongs to Service %s", backupID, svc.Name())
+		}
+
+		if !backup.IsRemote(bf.Path) {
+			return out, errors.Errorf("BackupFile %s isnot uploaded to the S3 backup target,%s isnot portable", backupID, bf.Path)
+		}
+	}
+
+	if !ok {
+		return out, nil
+	}
+
+	sys, err := gd.ormer.GetSysConfig()
+	if err != nil {
+		return out, err
+	}
+
+	bucket, key, err := backup.SplitLocation(bf.Path)
+	if err != nil {
+		return out, err
+	}
+
+	out.Backup = &structs.ExportBackup{
+		ID:         bf.ID,
+		Type:       bf.Type,
+		Endpoint:   sys.BackupTarget.Endpoint,
+		Bucket:     bucket,
+		Key:        key,
+		SizeByte:   bf.SizeByte,
+		Checksum:   bf.Checksum,
+		FinishedAt: utils.TimeToString(bf.FinishedAt),
+	}
+
+	return out, nil
+}
@@ -0,0 +1,46 @@
+package garden
+
+import (
+	"testing"
+
+	"github.com/docker/swarm/garden/kvstore"
+	"github.com/docker/swarm/garden/structs"
+	"golang.org/x/net/context"
+)
+
+func TestSaveServicesLink(t *testing.T) {
+	ctx := context.Background()
+	kvc := kvstore.NewMockClient()
+
+	links := structs.ServicesLink{
+		Mode:     "upsql-upproxy",
+		NameOrID: "service001",
+		Links: []*structs.ServiceLink{
+			{ID: "service001", Deps: []string{"proxy001"}, Spec: &structs.ServiceSpec{}},
+			{ID: "proxy001", Spec: &structs.ServiceSpec{}},
+		},
+	}
+
+	if err := SaveServicesLink(ctx, kvc, links); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, id := range []string{"service001", "proxy001"} {
+		out, err := ListServiceLinks(ctx, kvc, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(out) != 1 || out[0].Mode != "upsql-upproxy" || len(out[0].Links) != 2 {
+			t.Fatalf("%s unexpected links %+v", id, out)
+		}
+
+		if l := out[0].Links[0]; l.ID != "service001" || len(l.Deps) != 1 || l.Spec != nil {
+			t.Errorf("%s unexpected link %+v", id, l)
+		}
+	}
+
+	if out, err := ListServiceLinks(ctx, kvc, "service002"); err != nil || len(out) != 0 {
+		t.Errorf("expected no links but got %v,%v", out, err)
+	}
+}
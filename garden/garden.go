@@ -15,9 +15,11 @@ import (
 	"github.com/docker/swarm/cluster"
 	"github.com/docker/swarm/garden/database"
 	"github.com/docker/swarm/garden/kvstore"
+	"github.com/docker/swarm/garden/resource"
 	"github.com/docker/swarm/garden/resource/alloc"
 	"github.com/docker/swarm/garden/structs"
 	"github.com/docker/swarm/garden/tasklock"
+	"github.com/docker/swarm/garden/vault"
 	pluginapi "github.com/docker/swarm/plugin/parser/api"
 	"github.com/docker/swarm/scheduler"
 	consulapi "github.com/hashicorp/consul/api"
@@ -56,12 +58,18 @@ type Garden struct {
 	scheduler  *scheduler.Scheduler
 	tlsConfig  *tls.Config
 	authConfig *types.AuthConfig
+	health     *resource.HealthMonitor
+	vault      *vault.Vault
+
+	execSessions *execSessions
 }
 
 // NewGarden is exported.
 func NewGarden(kvc kvstore.Client, cl cluster.Cluster,
 	scheduler *scheduler.Scheduler, ormer database.Ormer,
 	pClient pluginapi.PluginAPI, tlsConfig *tls.Config) *Garden {
+	hc, _ := kvc.(kvstore.HealthChecker)
+
 	return &Garden{
 		Mutex:        new(sync.Mutex),
 		kvClient:     kvc,
@@ -70,9 +78,22 @@ func NewGarden(kvc kvstore.Client, cl cluster.Cluster,
 		pluginClient: pClient,
 		scheduler:    scheduler,
 		tlsConfig:    tlsConfig,
+		health:       resource.NewHealthMonitor(ormer, cl, hc, tlsConfig),
+		execSessions: newExecSessions(),
 	}
 }
 
+// RunHealthMonitor checks hosts health every interval until ctx is done,
+// unhealthy hosts would be quarantined from scheduling.
+func (gd *Garden) RunHealthMonitor(ctx context.Context, interval time.Duration) {
+	gd.health.Run(ctx, interval)
+}
+
+// NodeHealth returns the latest health score of the node.
+func (gd *Garden) NodeHealth(ID string) (structs.NodeHealth, bool) {
+	return gd.health.Health(ID)
+}
+
 // KVClient returns kv store Client
 func (gd *Garden) KVClient() kvstore.Client {
 	return gd.kvClient
@@ -104,7 +125,10 @@ func (gd *Garden) NewService(spec *structs.ServiceSpec, svc *database.Service) *
 		return nil
 	}
 
-	return newService(spec, svc, gd.ormer, gd.Cluster, gd.pluginClient)
+	s := newService(spec, svc, gd.ormer, gd.Cluster, gd.pluginClient)
+	s.secret = gd.BackupSecretKey
+
+	return s
 }
 
 // Service get ServiceInfo from db,convert to ServiceSpec
@@ -176,6 +200,9 @@ func (gd *Garden) DeployService(ctx context.Context,
 
 		if compose {
 			err = svc.Compose(ctx)
+			if err == nil {
+				svc.SyncMonitorTargets(ctx, gd.kvClient)
+			}
 		}
 
 		return err
@@ -238,6 +265,7 @@ func (gd *Garden) Register(req structs.RegisterDC) error {
 			InitScriptName:  req.SSHDeliver.InitScriptName,
 			CleanScriptName: req.SSHDeliver.CleanScriptName,
 		},
+		BackupTarget: database.BackupTarget(req.BackupTarget),
 		//		Users: database.Users{
 		//			MonitorUsername:     req.Users.MonitorUsername,
 		//			MonitorPassword:     req.Users.MonitorPassword,
@@ -284,14 +312,18 @@ func (gd *Garden) Register(req structs.RegisterDC) error {
 	//		return err
 	//	}
 
+	if config.BackupTarget.Type == "" {
+		config.BackupTarget.Type = database.BackupTargetNFS
+	}
+
 	err = gd.ormer.InsertSysConfig(config)
 
 	return err
 }
 
 func pingHorus(ctx context.Context, kvc kvstore.Client) error {
-	addr, err := kvc.GetHorusAddr(ctx)
-	if err != nil {
+	addr, err := kvstore.GetHorusAddr(ctx, kvc)
+	if err != nil || addr == "" {
 		return err
 	}
 
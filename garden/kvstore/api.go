@@ -10,8 +10,6 @@ import (
 type Client interface {
 	Store
 	Register
-
-	GetHorusAddr(ctx context.Context) (string, error)
 }
 
 type Store interface {
@@ -28,6 +26,12 @@ const (
 	agentType     = "agent/install"
 )
 
+// HealthChecker is a client for checking consul agent on hosts,
+// optional for the Client,implemented by the consul and etcd clients.
+type HealthChecker interface {
+	AgentChecks(host string) (map[string]api.AgentCheck, error)
+}
+
 // Register is a client for register service
 type Register interface {
 	//	HealthChecks(ctx context.Context, state string) (map[string]api.HealthCheck, error)
@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/docker/go-connections/tlsconfig"
+	"github.com/docker/swarm/garden/metrics"
 	"github.com/hashicorp/consul/api"
 	"github.com/pkg/errors"
 )
@@ -18,17 +19,18 @@ const (
 	defaultTimeout    = 2 * time.Minute
 )
 
-// NewClient returns a consul Client
+// NewClient returns a Client selected by the URI scheme,
+// etcd://node1:port,node2:port/prefix returns an etcd Client,others return a consul Client.
 func NewClient(uri string, options map[string]string) (Client, error) {
 	if uri == "" {
 		uri = defaultConsulAddr
 	}
 
 	var (
-		prefix  = ""
-		_, uris = parse(uri)
-		parts   = strings.SplitN(uris, "/", 2)
-		addrs   = strings.Split(parts[0], ",")
+		prefix       = ""
+		scheme, uris = parse(uri)
+		parts        = strings.SplitN(uris, "/", 2)
+		addrs        = strings.Split(parts[0], ",")
 	)
 
 	// A custom prefix to the path can be optionally used.
@@ -41,11 +43,6 @@ func NewClient(uri string, options map[string]string) (Client, error) {
 		return nil, errors.Wrap(err, "split host port:"+addrs[0])
 	}
 
-	config := &api.Config{
-		Address: addrs[0],
-		//	WaitTime: defaultTimeout,
-	}
-
 	var tlsConfig *tls.Config
 	if options["kv.cacertfile"] != "" && options["kv.certfile"] != "" && options["kv.keyfile"] != "" {
 		tlsConfig, err = tlsconfig.Client(tlsconfig.Options{
@@ -58,10 +55,29 @@ func NewClient(uri string, options map[string]string) (Client, error) {
 		}
 	}
 
-	return MakeClient(config, prefix, port, tlsConfig)
+	if scheme == "etcd" {
+		return NewEtcdClient(addrs, prefix, tlsConfig, options)
+	}
+
+	config := &api.Config{
+		Address: addrs[0],
+		//	WaitTime: defaultTimeout,
+	}
+
+	c, err := MakeClient(config, prefix, port, tlsConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mon, err = NewMonitor(c, options)
+	if err != nil {
+		return nil, err
+	}
+
+	return c, nil
 }
 
-// MakeClient returns a consul kv client
+// MakeClient returns a consul kv client,registers to Horus
 func MakeClient(config *api.Config, prefix, port string, tlsConfig *tls.Config) (*kvClient, error) {
 	if tlsConfig != nil {
 		config.HttpClient.Transport = &http.Transport{
@@ -92,6 +108,7 @@ func MakeClient(config *api.Config, prefix, port string, tlsConfig *tls.Config)
 
 	kvc.agents[config.Address] = c
 	kvc.config.Address = ""
+	kvc.mon = NewHorusMonitor(kvc)
 
 	return kvc, nil
 }
@@ -114,6 +131,11 @@ type kvClient struct {
 	peers  []string
 	agents map[string]*api.Client
 	config api.Config
+	mon    Monitor
+}
+
+func (c *kvClient) monitor() Monitor {
+	return c.mon
 }
 
 func (c kvClient) key(key string) string {
@@ -232,6 +254,8 @@ func (c *kvClient) checkConnectError(addr string, err error) {
 		return
 	}
 
+	metrics.ClientErrors.Inc("consul")
+
 	c.lock.Lock()
 
 	if addr == c.leader {
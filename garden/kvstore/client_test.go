@@ -1,3 +1,4 @@
 package kvstore
 
 var _ Client = &kvClient{}
+var _ Client = &etcdClient{}
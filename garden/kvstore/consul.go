@@ -72,6 +72,30 @@ func (c *kvClient) HealthChecks(ctx context.Context, state string) (map[string]a
 	return m, nil
 }
 
+// AgentChecks returns the checks registered on the consul agent of host,
+// returns error if the agent is unreachable.
+func (c *kvClient) AgentChecks(host string) (map[string]api.AgentCheck, error) {
+	addr, client, err := c.getClient(host)
+	if err != nil {
+		return nil, err
+	}
+
+	checks, err := client.Agent().Checks()
+	c.checkConnectError(addr, err)
+	if err != nil {
+		return nil, errors.Wrap(err, "consul agent checks:"+addr)
+	}
+
+	m := make(map[string]api.AgentCheck, len(checks))
+	for id, val := range checks {
+		if val != nil {
+			m[id] = *val
+		}
+	}
+
+	return m, nil
+}
+
 func (c *kvClient) registerHealthCheck(host string, config api.AgentServiceRegistration) error {
 	addr, client, err := c.getClient(host)
 	if err != nil {
@@ -0,0 +1,390 @@
+package kvstore
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/docker/libkv"
+	"github.com/docker/libkv/store"
+	"github.com/docker/swarm/garden/structs"
+	"github.com/hashicorp/consul/api"
+	"github.com/pkg/errors"
+	"golang.org/x/net/context"
+)
+
+// etcd registry layout,relative to the client prefix:
+//
+//	registry/services/<host>/<ID>  service registration,TTL key refreshed by the passing check
+//	registry/checks/<host>/<ID>    check status,TTL key
+//	registry/hosts/<host>          host heartbeat,TTL key
+//	registry/horus/<addr>          Horus heartbeat,TTL key
+//
+// The TTL keys are reported by the manager through Reporter,see garden.RunRegistryReporter.
+const (
+	etcdServices = "registry/services"
+	etcdChecks   = "registry/checks"
+	etcdHosts    = "registry/hosts"
+	etcdHorus    = "registry/horus"
+
+	// DefaultCheckTTL is the TTL of check status and heartbeat keys
+	DefaultCheckTTL = 30 * time.Second
+
+	// defaultRegistrationTTL is the TTL of service registration without DeregisterCriticalServiceAfter,
+	// the registration is removed if the check is not passing in the TTL,as consul does.
+	defaultRegistrationTTL = 30 * time.Minute
+)
+
+// Reporter reports the liveness of hosts,services and Horus to the registry,
+// the etcd registry has no agents on hosts,so the manager reports for them.
+type Reporter interface {
+	// Heartbeat refreshes the heartbeat of host
+	Heartbeat(host string, ttl time.Duration) error
+
+	// Registrations returns the services registered on host
+	Registrations(host string) ([]api.AgentServiceRegistration, error)
+
+	// ReportCheck refreshes the check status of the service registered on host
+	ReportCheck(host, id string, status CheckStatus, ttl time.Duration) error
+
+	// PublishHorus publishes the Horus address if Horus is reachable
+	PublishHorus(ttl time.Duration) error
+}
+
+// CheckStatus is the value of check status key
+type CheckStatus struct {
+	Status string `json:"status"`
+	Output string `json:"output"`
+}
+
+type etcdClient struct {
+	prefix string
+	horus  string
+	kv     store.Store
+	mon    Monitor
+}
+
+func (c *etcdClient) monitor() Monitor {
+	return c.mon
+}
+
+// NewEtcdClient returns a Client backed by etcd,
+// the liveness of hosts,checks and Horus are TTL keys instead of consul agent services,
+// "kv.horus.addr" is the Horus address published by the manager.
+// The etcd backend of libkv must be registered,see cli.
+func NewEtcdClient(addrs []string, prefix string, tlsConfig *tls.Config, options map[string]string) (Client, error) {
+	kv, err := libkv.NewStore(store.ETCD, addrs, &store.Config{
+		TLS:               tlsConfig,
+		ConnectionTimeout: 10 * time.Second,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "new etcd client")
+	}
+
+	c := &etcdClient{
+		prefix: prefix,
+		horus:  options["kv.horus.addr"],
+		kv:     kv,
+	}
+
+	c.mon, err = NewMonitor(c, options)
+	if err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+func (c etcdClient) key(parts ...string) string {
+	key := strings.Join(parts, "/")
+	if len(key) > 0 && key[0] == '/' {
+		key = key[1:]
+	}
+
+	if c.prefix == "" {
+		return key
+	}
+
+	return strings.Trim(c.prefix, "/") + "/" + key
+}
+
+func toKVPair(pair *store.KVPair) *api.KVPair {
+	return &api.KVPair{
+		Key:         strings.TrimPrefix(pair.Key, "/"),
+		Value:       pair.Value,
+		ModifyIndex: pair.LastIndex,
+	}
+}
+
+// GetKV lookup a single key of KV store,returns nil if the key is not found
+func (c *etcdClient) GetKV(ctx context.Context, key string) (*api.KVPair, error) {
+	key = c.key(key)
+
+	pair, err := c.kv.Get(key)
+	if err == store.ErrKeyNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "get KVPair:"+key)
+	}
+
+	return toKVPair(pair), nil
+}
+
+// ListKV lists the keys under the prefix recursively,as consul does.
+func (c *etcdClient) ListKV(ctx context.Context, key string) (api.KVPairs, error) {
+	key = c.key(key)
+
+	pairs, err := c.list(key)
+	if err != nil {
+		return nil, errors.Wrap(err, "list KVPairs:"+key)
+	}
+
+	out := make(api.KVPairs, len(pairs))
+	for i := range pairs {
+		out[i] = toKVPair(pairs[i])
+	}
+
+	return out, nil
+}
+
+// list flattens the directories,libkv lists the direct children only.
+func (c *etcdClient) list(key string) ([]*store.KVPair, error) {
+	pairs, err := c.kv.List(key)
+	if err == store.ErrKeyNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]*store.KVPair, 0, len(pairs))
+
+	for _, pair := range pairs {
+		if len(pair.Value) > 0 {
+			out = append(out, pair)
+			continue
+		}
+
+		children, err := c.list(pair.Key)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(children) == 0 {
+			out = append(out, pair)
+		} else {
+			out = append(out, children...)
+		}
+	}
+
+	return out, nil
+}
+
+func (c *etcdClient) PutKV(ctx context.Context, key string, val []byte) error {
+	err := c.kv.Put(c.key(key), val, nil)
+
+	return errors.WithStack(err)
+}
+
+func (c *etcdClient) DeleteKVTree(ctx context.Context, key string) error {
+	key = c.key(key)
+
+	err := c.kv.DeleteTree(key)
+	if err == store.ErrKeyNotFound {
+		return nil
+	}
+
+	return errors.Wrap(err, "delete KV Tree:"+key)
+}
+
+// RegisterService stores the service registration and registers to the monitor,
+// the check status is reported through ReportCheck,which refreshes the registration if passing.
+func (c *etcdClient) RegisterService(ctx context.Context, host string, config structs.ServiceRegistration) error {
+	if config.Consul != nil {
+		err := c.putRegistration(host, *config.Consul)
+		if err != nil {
+			return errors.Wrap(err, "register service "+config.Consul.ID)
+		}
+	}
+
+	return c.mon.RegisterMonitor(ctx, config)
+}
+
+// putRegistration stores the registration,expires after DeregisterCriticalServiceAfter.
+func (c *etcdClient) putRegistration(host string, reg api.AgentServiceRegistration) error {
+	val, err := json.Marshal(reg)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	ttl := defaultRegistrationTTL
+	if reg.Check != nil && reg.Check.DeregisterCriticalServiceAfter != "" {
+		if d, err := time.ParseDuration(reg.Check.DeregisterCriticalServiceAfter); err == nil && d > 0 {
+			ttl = d
+		}
+	}
+
+	return c.kv.Put(c.key(etcdServices, host, reg.ID), val, &store.WriteOptions{TTL: ttl})
+}
+
+// Registrations returns the services registered on host
+func (c *etcdClient) Registrations(host string) ([]api.AgentServiceRegistration, error) {
+	pairs, err := c.list(c.key(etcdServices, host))
+	if err != nil {
+		return nil, errors.Wrap(err, "list services of host "+host)
+	}
+
+	out := make([]api.AgentServiceRegistration, 0, len(pairs))
+
+	for _, pair := range pairs {
+		reg := api.AgentServiceRegistration{}
+
+		if err := json.Unmarshal(pair.Value, &reg); err != nil {
+			return nil, errors.Wrapf(err, "decode service %s", pair.Key)
+		}
+
+		out = append(out, reg)
+	}
+
+	return out, nil
+}
+
+// DeregisterService deregisters to the monitor and removes the service registration
+func (c *etcdClient) DeregisterService(ctx context.Context, config structs.ServiceDeregistration, force bool) error {
+	err := c.mon.DeregisterMonitor(ctx, config, force)
+
+	if config.Type == unitType {
+		c.kv.Delete(c.key(etcdServices, config.Addr, config.Key))
+		c.kv.Delete(c.key(etcdChecks, config.Addr, config.Key))
+	}
+
+	return err
+}
+
+// Heartbeat refreshes the heartbeat key of host,expires after ttl.
+func (c *etcdClient) Heartbeat(host string, ttl time.Duration) error {
+	err := c.kv.Put(c.key(etcdHosts, host), []byte(time.Now().Format(time.RFC3339)), &store.WriteOptions{TTL: ttl})
+
+	return errors.Wrap(err, "heartbeat "+host)
+}
+
+// ReportCheck refreshes the check status of the service registered on host,
+// the check turns critical if not reported in ttl,the registration is refreshed if passing.
+func (c *etcdClient) ReportCheck(host, id string, status CheckStatus, ttl time.Duration) error {
+	val, err := json.Marshal(status)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	err = c.kv.Put(c.key(etcdChecks, host, id), val, &store.WriteOptions{TTL: ttl})
+	if err != nil {
+		return errors.Wrap(err, "report check "+id)
+	}
+
+	if status.Status != api.HealthPassing {
+		return nil
+	}
+
+	pair, err := c.kv.Get(c.key(etcdServices, host, id))
+	if err == store.ErrKeyNotFound {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrap(err, "get service "+id)
+	}
+
+	reg := api.AgentServiceRegistration{}
+
+	if err := json.Unmarshal(pair.Value, &reg); err != nil {
+		return errors.Wrapf(err, "decode service %s", pair.Key)
+	}
+
+	err = c.putRegistration(host, reg)
+
+	return errors.Wrap(err, "refresh service "+id)
+}
+
+// PublishHorus publishes the Horus address of "kv.horus.addr" if it's reachable,
+// the address expires after ttl.
+func (c *etcdClient) PublishHorus(ttl time.Duration) error {
+	if c.horus == "" {
+		return nil
+	}
+
+	conn, err := net.DialTimeout("tcp", c.horus, 5*time.Second)
+	if err != nil {
+		return errors.Wrap(err, "Horus is unreachable")
+	}
+	conn.Close()
+
+	err = c.kv.Put(c.key(etcdHorus, c.horus), []byte(c.horus), &store.WriteOptions{TTL: ttl})
+
+	return errors.Wrap(err, "publish Horus "+c.horus)
+}
+
+// AgentChecks returns the checks of services registered on host,
+// returns error if the heartbeat of host expired.
+func (c *etcdClient) AgentChecks(host string) (map[string]api.AgentCheck, error) {
+	if _, err := c.kv.Get(c.key(etcdHosts, host)); err != nil {
+		if err == store.ErrKeyNotFound {
+			return nil, errors.Errorf("heartbeat of host %s expired", host)
+		}
+
+		return nil, errors.Wrap(err, "host heartbeat:"+host)
+	}
+
+	services, err := c.Registrations(host)
+	if err != nil {
+		return nil, err
+	}
+
+	checks := make(map[string]api.AgentCheck, len(services))
+
+	for _, reg := range services {
+		check := api.AgentCheck{
+			Node:        host,
+			CheckID:     "service:" + reg.ID,
+			Name:        "Service '" + reg.Name + "' check",
+			Status:      api.HealthCritical,
+			Output:      "check TTL expired",
+			ServiceID:   reg.ID,
+			ServiceName: reg.Name,
+		}
+
+		status, err := c.kv.Get(c.key(etcdChecks, host, reg.ID))
+		if err == nil {
+			cs := CheckStatus{}
+
+			if e := json.Unmarshal(status.Value, &cs); e == nil {
+				check.Status, check.Output = cs.Status, cs.Output
+			}
+		} else if err != store.ErrKeyNotFound {
+			return nil, errors.Wrap(err, "get check status:"+reg.ID)
+		}
+
+		checks[check.CheckID] = check
+	}
+
+	return checks, nil
+}
+
+// GetHorusAddr returns the Horus which heartbeat not expired.
+func (c *etcdClient) GetHorusAddr(ctx context.Context) (string, error) {
+	pairs, err := c.list(c.key(etcdHorus))
+	if err != nil {
+		return "", errors.Wrap(err, "list Horus")
+	}
+
+	for _, pair := range pairs {
+		addr := pair.Key[strings.LastIndex(pair.Key, "/")+1:]
+
+		if addr = parseIPFromHealthCheck("HS-"+addr, addr); addr != "" {
+			return addr, nil
+		}
+	}
+
+	return "", errors.New("non-available Horus query from KV store")
+}
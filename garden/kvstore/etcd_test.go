@@ -0,0 +1,328 @@
+package kvstore
+
+import (
+	"net"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/libkv/store"
+	"github.com/docker/swarm/garden/structs"
+	"github.com/hashicorp/consul/api"
+	"golang.org/x/net/context"
+)
+
+// fakeStore is an in-memory store.Store,keys are expired by TTL.
+type fakeStore struct {
+	store.Store // unused methods panic
+
+	now    time.Time
+	kv     map[string][]byte
+	expire map[string]time.Time
+}
+
+func newFakeStore() *fakeStore {
+	return &fakeStore{
+		now:    time.Now(),
+		kv:     make(map[string][]byte),
+		expire: make(map[string]time.Time),
+	}
+}
+
+// normalize collapses the slashes as etcd server does
+func normalize(key string) string {
+	return store.Normalize(strings.Trim(key, "/"))
+}
+
+func (s *fakeStore) alive(key string) bool {
+	if _, ok := s.kv[key]; !ok {
+		return false
+	}
+
+	if t, ok := s.expire[key]; ok && !s.now.Before(t) {
+		return false
+	}
+
+	return true
+}
+
+func (s *fakeStore) Put(key string, value []byte, opts *store.WriteOptions) error {
+	key = normalize(key)
+	s.kv[key] = value
+
+	delete(s.expire, key)
+	if opts != nil && opts.TTL > 0 {
+		s.expire[key] = s.now.Add(opts.TTL)
+	}
+
+	return nil
+}
+
+func (s *fakeStore) Get(key string) (*store.KVPair, error) {
+	key = normalize(key)
+	if !s.alive(key) {
+		return nil, store.ErrKeyNotFound
+	}
+
+	return &store.KVPair{Key: key, Value: s.kv[key]}, nil
+}
+
+func (s *fakeStore) Delete(key string) error {
+	key = normalize(key)
+	if !s.alive(key) {
+		return store.ErrKeyNotFound
+	}
+
+	delete(s.kv, key)
+
+	return nil
+}
+
+// List returns the direct children,directories have empty value as etcd does.
+func (s *fakeStore) List(dir string) ([]*store.KVPair, error) {
+	dir = normalize(dir) + "/"
+	children := make(map[string][]byte)
+
+	for key := range s.kv {
+		if !strings.HasPrefix(key, dir) || !s.alive(key) {
+			continue
+		}
+
+		rest := key[len(dir):]
+		if i := strings.Index(rest, "/"); i >= 0 {
+			children[dir+rest[:i]] = nil
+		} else {
+			children[key] = s.kv[key]
+		}
+	}
+
+	if len(children) == 0 {
+		return nil, store.ErrKeyNotFound
+	}
+
+	keys := make([]string, 0, len(children))
+	for key := range children {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	out := make([]*store.KVPair, len(keys))
+	for i, key := range keys {
+		out[i] = &store.KVPair{Key: key, Value: children[key]}
+	}
+
+	return out, nil
+}
+
+func (s *fakeStore) DeleteTree(dir string) error {
+	dir = normalize(dir)
+
+	for key := range s.kv {
+		if key == dir || strings.HasPrefix(key, dir+"/") {
+			delete(s.kv, key)
+		}
+	}
+
+	return nil
+}
+
+func TestEtcdClientKV(t *testing.T) {
+	fs := newFakeStore()
+	c := &etcdClient{prefix: "/swarm", kv: fs}
+	ctx := context.Background()
+
+	pair, err := c.GetKV(ctx, "/configs/svc1/unit1")
+	if err != nil || pair != nil {
+		t.Errorf("expected nil pair but got %v,%v", pair, err)
+	}
+
+	for _, key := range []string{"/configs/svc1/unit1", "/configs/svc1/unit2", "/configs/svc1/sub/unit3", "/configs/svc2/unit1"} {
+		if err := c.PutKV(ctx, key, []byte(key)); err != nil {
+			t.Fatalf("%+v", err)
+		}
+	}
+
+	pair, err = c.GetKV(ctx, "configs/svc1/unit1")
+	if err != nil || pair == nil || pair.Key != "swarm/configs/svc1/unit1" || string(pair.Value) != "/configs/svc1/unit1" {
+		t.Errorf("unexpected pair %v,%v", pair, err)
+	}
+
+	pairs, err := c.ListKV(ctx, "/configs/svc1")
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	keys := make([]string, len(pairs))
+	for i := range pairs {
+		keys[i] = pairs[i].Key
+	}
+
+	if got := strings.Join(keys, ","); got != "swarm/configs/svc1/sub/unit3,swarm/configs/svc1/unit1,swarm/configs/svc1/unit2" {
+		t.Errorf("unexpected keys %s", got)
+	}
+
+	if err := c.DeleteKVTree(ctx, "/configs/svc1"); err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	if pairs, err := c.ListKV(ctx, "/configs/svc1"); err != nil || len(pairs) != 0 {
+		t.Errorf("expected empty but got %v,%v", pairs, err)
+	}
+
+	if pairs, err := c.ListKV(ctx, "/configs"); err != nil || len(pairs) != 1 {
+		t.Errorf("unexpected %v,%v", pairs, err)
+	}
+}
+
+func TestEtcdClientChecks(t *testing.T) {
+	fs := newFakeStore()
+	c := &etcdClient{kv: fs}
+	c.mon = NewHorusMonitor(c)
+
+	const host = "192.168.1.10"
+
+	err := c.RegisterService(context.Background(), host, structs.ServiceRegistration{
+		Consul: &api.AgentServiceRegistration{ID: "unit1", Name: "unit1"},
+	})
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	if _, err := c.AgentChecks(host); err == nil {
+		t.Error("expected error without host heartbeat")
+	}
+
+	if err := c.Heartbeat(host, DefaultCheckTTL); err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	checks, err := c.AgentChecks(host)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	if check := checks["service:unit1"]; check.ServiceID != "unit1" || check.Status != api.HealthCritical {
+		t.Errorf("expected critical check without report,%+v", check)
+	}
+
+	err = c.ReportCheck(host, "unit1", CheckStatus{Status: api.HealthPassing, Output: "ok"}, DefaultCheckTTL)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	checks, _ = c.AgentChecks(host)
+	if check := checks["service:unit1"]; check.Status != api.HealthPassing || check.Output != "ok" {
+		t.Errorf("expected passing check,%+v", check)
+	}
+
+	// check status expired
+	fs.now = fs.now.Add(DefaultCheckTTL / 2)
+	c.Heartbeat(host, DefaultCheckTTL)
+	fs.now = fs.now.Add(DefaultCheckTTL/2 + time.Second)
+
+	checks, err = c.AgentChecks(host)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	if check := checks["service:unit1"]; check.Status != api.HealthCritical {
+		t.Errorf("expected critical check after TTL,%+v", check)
+	}
+
+	// host heartbeat expired
+	fs.now = fs.now.Add(DefaultCheckTTL)
+	if _, err := c.AgentChecks(host); err == nil {
+		t.Error("expected error after heartbeat expired")
+	}
+}
+
+func TestEtcdClientHorusAddr(t *testing.T) {
+	fs := newFakeStore()
+	c := &etcdClient{kv: fs}
+
+	if _, err := c.GetHorusAddr(context.Background()); err == nil {
+		t.Error("expected error without Horus")
+	}
+
+	fs.Put("registry/horus/bad-addr", []byte("x"), &store.WriteOptions{TTL: DefaultCheckTTL})
+	fs.Put("registry/horus/192.168.1.5:8483", []byte("x"), &store.WriteOptions{TTL: DefaultCheckTTL})
+
+	addr, err := c.GetHorusAddr(context.Background())
+	if err != nil || addr != "192.168.1.5:8483" {
+		t.Errorf("unexpected Horus %s,%v", addr, err)
+	}
+}
+
+func TestEtcdClientRegistrationTTL(t *testing.T) {
+	fs := newFakeStore()
+	c := &etcdClient{kv: fs}
+	c.mon = NewHorusMonitor(c)
+
+	const host = "192.168.1.10"
+
+	err := c.RegisterService(context.Background(), host, structs.ServiceRegistration{
+		Consul: &api.AgentServiceRegistration{ID: "unit1", Name: "unit1",
+			Check: &api.AgentServiceCheck{DeregisterCriticalServiceAfter: "10m"}},
+	})
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	// refreshed by the passing check
+	fs.now = fs.now.Add(9 * time.Minute)
+	if err := c.ReportCheck(host, "unit1", CheckStatus{Status: api.HealthPassing}, DefaultCheckTTL); err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	fs.now = fs.now.Add(9 * time.Minute)
+	if regs, err := c.Registrations(host); err != nil || len(regs) != 1 || regs[0].ID != "unit1" {
+		t.Errorf("expected registration refreshed,%v %v", regs, err)
+	}
+
+	// not refreshed by the critical check
+	if err := c.ReportCheck(host, "unit1", CheckStatus{Status: api.HealthCritical}, DefaultCheckTTL); err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	fs.now = fs.now.Add(2 * time.Minute)
+	if regs, err := c.Registrations(host); err != nil || len(regs) != 0 {
+		t.Errorf("expected registration expired,%v %v", regs, err)
+	}
+}
+
+func TestEtcdClientPublishHorus(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	fs := newFakeStore()
+	c := &etcdClient{kv: fs}
+
+	if err := c.PublishHorus(DefaultCheckTTL); err != nil {
+		t.Errorf("expected nil without Horus,%+v", err)
+	}
+
+	c.horus = l.Addr().String()
+
+	if err := c.PublishHorus(DefaultCheckTTL); err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	addr, err := c.GetHorusAddr(context.Background())
+	if err != nil || addr != c.horus {
+		t.Errorf("unexpected Horus %s,%v", addr, err)
+	}
+
+	fs.now = fs.now.Add(DefaultCheckTTL)
+	if _, err := c.GetHorusAddr(context.Background()); err == nil {
+		t.Error("expected error after Horus expired")
+	}
+
+	l.Close()
+	if err := c.PublishHorus(DefaultCheckTTL); err == nil {
+		t.Error("expected error if Horus is unreachable")
+	}
+}
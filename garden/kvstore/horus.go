@@ -25,7 +25,7 @@ type result struct {
 	err error
 }
 
-func (c *kvClient) registerToHorus(ctx context.Context, obj structs.HorusRegistration) error {
+func registerToHorus(ctx context.Context, c HorusGetter, obj structs.HorusRegistration) error {
 	if ctx == nil {
 		var cancel context.CancelFunc
 		ctx, cancel = context.WithTimeout(context.Background(), defaultTimeout)
@@ -146,7 +146,7 @@ func postRegister(ctx context.Context, uri string, obj interface{}) error {
 }
 
 // typ : hosts / containers / units
-func (c *kvClient) deregisterToHorus(ctx context.Context, config structs.ServiceDeregistration, force bool) error {
+func deregisterToHorus(ctx context.Context, c HorusGetter, config structs.ServiceDeregistration, force bool) error {
 	var (
 		addr string
 		ch   = make(chan result, 1)
@@ -262,7 +262,7 @@ func delHostAgent(ctx context.Context, addr string, config structs.ServiceDeregi
 	return nil
 }
 
-// RegisterService register service to consul and Horus
+// RegisterService register service to consul and the monitor
 func (c *kvClient) RegisterService(ctx context.Context, host string, config structs.ServiceRegistration) error {
 	if config.Consul != nil {
 		err := c.registerHealthCheck(host, *config.Consul)
@@ -271,16 +271,12 @@ func (c *kvClient) RegisterService(ctx context.Context, host string, config stru
 		}
 	}
 
-	if config.Horus != nil {
-		return c.registerToHorus(ctx, *config.Horus)
-	}
-
-	return nil
+	return c.mon.RegisterMonitor(ctx, config)
 }
 
-// DeregisterService service to consul and Horus
+// DeregisterService service to consul and the monitor
 func (c *kvClient) DeregisterService(ctx context.Context, config structs.ServiceDeregistration, force bool) error {
-	err := c.deregisterToHorus(ctx, config, force)
+	err := c.mon.DeregisterMonitor(ctx, config, force)
 
 	if config.Type == "units" {
 		c.deregisterHealthCheck(config.Addr, config.Key)
@@ -69,6 +69,10 @@ func (c mockClient) HealthChecks(ctx context.Context, state string) (map[string]
 	return nil, nil
 }
 
+func (c mockClient) AgentChecks(host string) (map[string]api.AgentCheck, error) {
+	return nil, nil
+}
+
 func (c *mockClient) RegisterService(ctx context.Context, host string, config structs.ServiceRegistration) error {
 	return nil
 }
@@ -0,0 +1,229 @@
+package kvstore
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/docker/swarm/garden/structs"
+	"github.com/pkg/errors"
+	"golang.org/x/net/context"
+)
+
+const (
+	horusMonitor      = "horus"
+	prometheusMonitor = "prometheus"
+
+	defaultPrometheusDir = "/etc/prometheus/targets"
+)
+
+// Monitor is the monitoring system which hosts and units are registered to
+type Monitor interface {
+	RegisterMonitor(ctx context.Context, config structs.ServiceRegistration) error
+
+	DeregisterMonitor(ctx context.Context, config structs.ServiceDeregistration, force bool) error
+}
+
+// HorusGetter looks up the Horus server address
+type HorusGetter interface {
+	GetHorusAddr(ctx context.Context) (string, error)
+}
+
+// NewMonitor returns the Monitor selected by options,
+// "kv.monitor" is a comma separated list of horus and prometheus,default horus,
+// "kv.prometheus.dir" is the directory of Prometheus file_sd target files.
+func NewMonitor(horus HorusGetter, options map[string]string) (Monitor, error) {
+	drivers := options["kv.monitor"]
+	if drivers == "" {
+		drivers = horusMonitor
+	}
+
+	var out monitors
+
+	for _, driver := range strings.Split(drivers, ",") {
+		switch strings.TrimSpace(driver) {
+		case horusMonitor:
+			out = append(out, NewHorusMonitor(horus))
+
+		case prometheusMonitor:
+			dir := options["kv.prometheus.dir"]
+			if dir == "" {
+				dir = defaultPrometheusDir
+			}
+
+			m, err := NewPrometheusMonitor(dir)
+			if err != nil {
+				return nil, err
+			}
+
+			out = append(out, m)
+
+		default:
+			return nil, errors.Errorf("unsupported monitor '%s'", driver)
+		}
+	}
+
+	if len(out) == 1 {
+		return out[0], nil
+	}
+
+	return out, nil
+}
+
+// GetHorusAddr returns the Horus address if the Client registers to Horus,
+// returns empty if Horus is not in use.
+func GetHorusAddr(ctx context.Context, c Client) (string, error) {
+	if m, ok := c.(interface {
+		monitor() Monitor
+	}); ok {
+		h := horusOf(m.monitor())
+		if h == nil {
+			return "", nil
+		}
+
+		return h.horus.GetHorusAddr(ctx)
+	}
+
+	if h, ok := c.(HorusGetter); ok {
+		return h.GetHorusAddr(ctx)
+	}
+
+	return "", nil
+}
+
+func horusOf(m Monitor) *horusClient {
+	switch m := m.(type) {
+	case *horusClient:
+		return m
+	case monitors:
+		for i := range m {
+			if h := horusOf(m[i]); h != nil {
+				return h
+			}
+		}
+	}
+
+	return nil
+}
+
+// monitors registers to all of the monitors
+type monitors []Monitor
+
+func (m monitors) RegisterMonitor(ctx context.Context, config structs.ServiceRegistration) error {
+	for i := range m {
+		if err := m[i].RegisterMonitor(ctx, config); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// DeregisterMonitor deregisters from all of the monitors,returns the first error.
+func (m monitors) DeregisterMonitor(ctx context.Context, config structs.ServiceDeregistration, force bool) error {
+	var first error
+
+	for i := range m {
+		if err := m[i].DeregisterMonitor(ctx, config, force); err != nil && first == nil {
+			first = err
+		}
+	}
+
+	return first
+}
+
+type horusClient struct {
+	horus HorusGetter
+}
+
+// NewHorusMonitor returns a Monitor posting HorusRegistration to Horus agent
+func NewHorusMonitor(horus HorusGetter) Monitor {
+	return &horusClient{horus: horus}
+}
+
+func (h *horusClient) RegisterMonitor(ctx context.Context, config structs.ServiceRegistration) error {
+	if config.Horus == nil {
+		return nil
+	}
+
+	return registerToHorus(ctx, h.horus, *config.Horus)
+}
+
+func (h *horusClient) DeregisterMonitor(ctx context.Context, config structs.ServiceDeregistration, force bool) error {
+	return deregisterToHorus(ctx, h.horus, config, force)
+}
+
+type prometheusClient struct {
+	lock *sync.Mutex
+	dir  string
+}
+
+// NewPrometheusMonitor returns a Monitor writing a target file per unit into dir,
+// only file_sd is supported,Prometheus reads the files by file_sd_configs,HTTP-SD isnot served.
+func NewPrometheusMonitor(dir string) (Monitor, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, errors.Wrap(err, "prometheus targets dir")
+	}
+
+	return &prometheusClient{
+		lock: new(sync.Mutex),
+		dir:  dir,
+	}, nil
+}
+
+func (p *prometheusClient) file(key string) string {
+	return filepath.Join(p.dir, key+".json")
+}
+
+// RegisterMonitor writes the target group,the file is replaced atomically.
+func (p *prometheusClient) RegisterMonitor(ctx context.Context, config structs.ServiceRegistration) error {
+	reg := config.Prometheus
+	if reg == nil || reg.Key == "" {
+		return nil
+	}
+
+	groups := []struct {
+		Targets []string          `json:"targets"`
+		Labels  map[string]string `json:"labels,omitempty"`
+	}{
+		{Targets: reg.Targets, Labels: reg.Labels},
+	}
+
+	dat, err := json.MarshalIndent(groups, "", "  ")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	tmp := p.file("." + reg.Key)
+
+	if err := ioutil.WriteFile(tmp, dat, 0644); err != nil {
+		return errors.Wrap(err, "write prometheus targets")
+	}
+
+	err = os.Rename(tmp, p.file(reg.Key))
+
+	return errors.Wrap(err, "write prometheus targets")
+}
+
+// DeregisterMonitor removes the target file of unit
+func (p *prometheusClient) DeregisterMonitor(ctx context.Context, config structs.ServiceDeregistration, force bool) error {
+	if config.Type != unitType || config.Key == "" {
+		return nil
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	err := os.Remove(p.file(config.Key))
+	if err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err, "remove prometheus targets")
+	}
+
+	return nil
+}
@@ -0,0 +1,117 @@
+package kvstore
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/swarm/garden/structs"
+	"golang.org/x/net/context"
+)
+
+func TestNewMonitor(t *testing.T) {
+	dir, err := ioutil.TempDir("", "prometheus")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	c := &etcdClient{kv: newFakeStore()}
+
+	m, err := NewMonitor(c, nil)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	if _, ok := m.(*horusClient); !ok {
+		t.Errorf("expected horus monitor by default,%T", m)
+	}
+
+	m, err = NewMonitor(c, map[string]string{"kv.monitor": "prometheus", "kv.prometheus.dir": dir})
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	if _, ok := m.(*prometheusClient); !ok {
+		t.Errorf("expected prometheus monitor,%T", m)
+	}
+
+	c.mon = m
+	if addr, err := GetHorusAddr(context.Background(), c); err != nil || addr != "" {
+		t.Errorf("expected no Horus but got %s,%v", addr, err)
+	}
+
+	m, err = NewMonitor(c, map[string]string{"kv.monitor": "horus, prometheus", "kv.prometheus.dir": dir})
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	if ms, ok := m.(monitors); !ok || len(ms) != 2 {
+		t.Errorf("expected both monitors,%v", m)
+	}
+
+	c.mon = m
+	if _, err := GetHorusAddr(context.Background(), c); err == nil {
+		t.Error("expected error without Horus registered")
+	}
+
+	if _, err := NewMonitor(c, map[string]string{"kv.monitor": "zabbix"}); err == nil {
+		t.Error("expected error with unsupported monitor")
+	}
+}
+
+func TestPrometheusMonitor(t *testing.T) {
+	dir, err := ioutil.TempDir("", "prometheus")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	m, err := NewPrometheusMonitor(dir)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	reg := structs.ServiceRegistration{
+		Horus: &structs.HorusRegistration{},
+		Prometheus: &structs.PrometheusRegistration{
+			Key:     "unit1",
+			Targets: []string{"192.168.1.10:3306"},
+			Labels:  map[string]string{"service": "svc1", "unit": "unit1", "role": "master", "tag": "biz"},
+		},
+	}
+
+	if err := m.RegisterMonitor(context.Background(), reg); err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	dat, err := ioutil.ReadFile(filepath.Join(dir, "unit1.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var groups []structs.PrometheusRegistration
+	if err := json.Unmarshal(dat, &groups); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(groups) != 1 || groups[0].Targets[0] != "192.168.1.10:3306" || groups[0].Labels["role"] != "master" || groups[0].Key != "" {
+		t.Errorf("unexpected target file %s", dat)
+	}
+
+	// hosts are not Prometheus targets
+	err = m.DeregisterMonitor(context.Background(), structs.ServiceDeregistration{Type: hostType, Key: "unit1"}, true)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		err = m.DeregisterMonitor(context.Background(), structs.ServiceDeregistration{Type: unitType, Key: "unit1"}, true)
+		if err != nil {
+			t.Fatalf("%+v", err)
+		}
+	}
+
+	if files, _ := ioutil.ReadDir(dir); len(files) != 0 {
+		t.Errorf("expected target files removed,%d left", len(files))
+	}
+}
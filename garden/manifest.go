@@ -0,0 +1,56 @@
+package garden
+
+import (
+	"time"
+
+	"github.com/docker/swarm/garden/database"
+	"github.com/docker/swarm/garden/tasklock"
+	"golang.org/x/net/context"
+)
+
+type serviceLockKey struct{}
+
+// ApplyManifest runs apply under the service task lock of the manifest task,
+// the task locks of the service taken with the ctx passed to apply are held by the manifest lock,
+// so the service isnot changed by others between the actions of the manifest.
+func (gd *Garden) ApplyManifest(ctx context.Context, svc *Service, task *database.Task,
+	apply func(ctx context.Context) error, async bool) error {
+
+	sl := tasklock.NewServiceTask(database.ServiceManifestTask, svc.ID(), svc.so, task,
+		statusServiceManifestApplying, statusServiceManifestApplied, statusServiceManifestApplyFailed)
+
+	return sl.Run(isnotInProgress, func() error {
+		return apply(context.WithValue(ctx, serviceLockKey{}, svc.ID()))
+	}, async)
+}
+
+// serviceTask returns the service task lock of the action,
+// the lock doesn't change the service status if ctx holds the service lock already,see ApplyManifest.
+func (svc *Service) serviceTask(ctx context.Context, action string, task *database.Task, current, expect, fail int) tasklock.GoTaskLock {
+	sl := tasklock.NewServiceTask(action, svc.ID(), svc.so, task, current, expect, fail)
+
+	if id, _ := ctx.Value(serviceLockKey{}).(string); id != svc.ID() {
+		return sl
+	}
+
+	sl.Before = func(key string, val int, t *database.Task, f func(val int) bool) (bool, int, error) {
+		if t != nil {
+			err := svc.so.InsertTask(*t)
+			if err != nil {
+				return false, 0, err
+			}
+		}
+
+		return true, val, nil
+	}
+
+	sl.After = func(key string, val int, t *database.Task, finish time.Time) error {
+		if t != nil {
+			return svc.so.SetTask(*t)
+		}
+
+		return nil
+	}
+
+	return sl
+}
@@ -0,0 +1,366 @@
+// Package metrics collects the manager operational metrics,
+// exposed in Prometheus text format.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefBuckets are the default histogram buckets in seconds,for requests and scheduling
+var DefBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// TaskBuckets are the histogram buckets in seconds for tasks
+var TaskBuckets = []float64{1, 5, 15, 30, 60, 120, 300, 600, 1800, 3600}
+
+var (
+	// APIRequests counts the master API requests
+	APIRequests = NewCounter("swarm_api_requests_total",
+		"Total of master API requests.", "route", "method", "status")
+
+	// APIRequestDuration observes the master API request latency
+	APIRequestDuration = NewHistogram("swarm_api_request_duration_seconds",
+		"Latency of master API requests.", DefBuckets, "route", "method")
+
+	// Tasks counts the finished tasks by Task.Related and outcome
+	Tasks = NewCounter("swarm_tasks_total",
+		"Total of finished tasks.", "related", "status")
+
+	// TaskDuration observes the task duration by Task.Related
+	TaskDuration = NewHistogram("swarm_task_duration_seconds",
+		"Duration of tasks.", TaskBuckets, "related")
+
+	// SchedulingDuration observes the latency of scheduling a container
+	SchedulingDuration = NewHistogram("swarm_scheduling_duration_seconds",
+		"Latency of scheduling.", DefBuckets)
+
+	// SchedulingFailures counts the scheduling failures by the filter which no node passed
+	SchedulingFailures = NewCounter("swarm_scheduling_failures_total",
+		"Total of scheduling failures.", "filter")
+
+	// Allocations counts the allocator outcomes,
+	// resource is ip_pool,volume_driver or raid_group.
+	Allocations = NewCounter("swarm_allocations_total",
+		"Total of resource allocations.", "resource", "name", "result")
+
+	// Engines is the number of engines by status
+	Engines = NewGauge("swarm_engines",
+		"Number of engines.", "status")
+
+	// ClientErrors counts the errors of clients,consul or plugin.
+	ClientErrors = NewCounter("swarm_client_errors_total",
+		"Total of client errors.", "client")
+)
+
+// Result returns the result label value
+func Result(err error) string {
+	if err != nil {
+		return "failed"
+	}
+
+	return "ok"
+}
+
+type collector interface {
+	write(w io.Writer) error
+}
+
+var registry = struct {
+	lock       sync.Mutex
+	collectors map[string]collector
+}{
+	collectors: make(map[string]collector),
+}
+
+func register(name string, c collector) {
+	registry.lock.Lock()
+	defer registry.lock.Unlock()
+
+	if _, ok := registry.collectors[name]; ok {
+		panic("duplicate metric " + name)
+	}
+
+	registry.collectors[name] = c
+}
+
+// WriteTo writes all of the metrics in Prometheus text format,sorted by name.
+func WriteTo(w io.Writer) error {
+	registry.lock.Lock()
+
+	names := make([]string, 0, len(registry.collectors))
+	for name := range registry.collectors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	list := make([]collector, len(names))
+	for i := range names {
+		list[i] = registry.collectors[names[i]]
+	}
+
+	registry.lock.Unlock()
+
+	for i := range list {
+		if err := list[i].write(w); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type desc struct {
+	name   string
+	help   string
+	typ    string
+	labels []string
+}
+
+func (d desc) header(w io.Writer) error {
+	_, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", d.name, d.help, d.name, d.typ)
+
+	return err
+}
+
+// key joins the label values,values must be in the same order as the labels.
+func (d desc) key(values []string) string {
+	if len(values) != len(d.labels) {
+		panic(fmt.Sprintf("metric %s expects %d label values,got %d", d.name, len(d.labels), len(values)))
+	}
+
+	return strings.Join(values, "\xff")
+}
+
+// pairs formats the labels,extra is appended as the last label.
+func (d desc) pairs(key string, extra ...string) string {
+	var values []string
+	if len(d.labels) > 0 {
+		values = strings.Split(key, "\xff")
+	}
+
+	parts := make([]string, 0, len(d.labels)+1)
+	for i := range d.labels {
+		parts = append(parts, d.labels[i]+"="+quote(values[i]))
+	}
+	if len(extra) == 2 {
+		parts = append(parts, extra[0]+"="+quote(extra[1]))
+	}
+
+	if len(parts) == 0 {
+		return ""
+	}
+
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// quote escapes the label value as Prometheus text format
+func quote(v string) string {
+	return `"` + labelEscaper.Replace(v) + `"`
+}
+
+func formatFloat(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func sortedKeys(m map[string]float64) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// Counter is a counter with labels
+type Counter struct {
+	desc
+	lock   sync.Mutex
+	values map[string]float64
+}
+
+// NewCounter returns a registered Counter
+func NewCounter(name, help string, labels ...string) *Counter {
+	c := &Counter{
+		desc:   desc{name: name, help: help, typ: "counter", labels: labels},
+		values: make(map[string]float64),
+	}
+
+	register(name, c)
+
+	return c
+}
+
+// Inc increases the counter of the label values by 1
+func (c *Counter) Inc(values ...string) {
+	c.Add(1, values...)
+}
+
+// Add increases the counter of the label values by v
+func (c *Counter) Add(v float64, values ...string) {
+	key := c.key(values)
+
+	c.lock.Lock()
+	c.values[key] += v
+	c.lock.Unlock()
+}
+
+// Value returns the counter of the label values
+func (c *Counter) Value(values ...string) float64 {
+	key := c.key(values)
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.values[key]
+}
+
+func (c *Counter) write(w io.Writer) error {
+	if err := c.header(w); err != nil {
+		return err
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for _, key := range sortedKeys(c.values) {
+		_, err := fmt.Fprintf(w, "%s%s %s\n", c.name, c.pairs(key), formatFloat(c.values[key]))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Gauge is a gauge with labels
+type Gauge struct {
+	Counter
+}
+
+// NewGauge returns a registered Gauge
+func NewGauge(name, help string, labels ...string) *Gauge {
+	g := &Gauge{
+		Counter: Counter{
+			desc:   desc{name: name, help: help, typ: "gauge", labels: labels},
+			values: make(map[string]float64),
+		},
+	}
+
+	register(name, g)
+
+	return g
+}
+
+// Set sets the gauge of the label values
+func (g *Gauge) Set(v float64, values ...string) {
+	key := g.key(values)
+
+	g.lock.Lock()
+	g.values[key] = v
+	g.lock.Unlock()
+}
+
+// Reset removes all of the label values
+func (g *Gauge) Reset() {
+	g.lock.Lock()
+	g.values = make(map[string]float64)
+	g.lock.Unlock()
+}
+
+type histogramValue struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// Histogram is a histogram with labels
+type Histogram struct {
+	desc
+	buckets []float64
+	lock    sync.Mutex
+	values  map[string]*histogramValue
+}
+
+// NewHistogram returns a registered Histogram,buckets are the sorted upper bounds.
+func NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	h := &Histogram{
+		desc:    desc{name: name, help: help, typ: "histogram", labels: labels},
+		buckets: buckets,
+		values:  make(map[string]*histogramValue),
+	}
+
+	register(name, h)
+
+	return h
+}
+
+// Observe adds an observation of the label values
+func (h *Histogram) Observe(v float64, values ...string) {
+	key := h.key(values)
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	hv, ok := h.values[key]
+	if !ok {
+		hv = &histogramValue{counts: make([]uint64, len(h.buckets))}
+		h.values[key] = hv
+	}
+
+	for i := range h.buckets {
+		if v <= h.buckets[i] {
+			hv.counts[i]++
+		}
+	}
+
+	hv.sum += v
+	hv.count++
+}
+
+func (h *Histogram) write(w io.Writer) error {
+	if err := h.header(w); err != nil {
+		return err
+	}
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	keys := make([]string, 0, len(h.values))
+	for k := range h.values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		hv := h.values[key]
+
+		for i := range h.buckets {
+			_, err := fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.pairs(key, "le", formatFloat(h.buckets[i])), hv.counts[i])
+			if err != nil {
+				return err
+			}
+		}
+
+		_, err := fmt.Fprintf(w, "%s_bucket%s %d\n%s_sum%s %s\n%s_count%s %d\n",
+			h.name, h.pairs(key, "le", "+Inf"), hv.count,
+			h.name, h.pairs(key), formatFloat(hv.sum),
+			h.name, h.pairs(key), hv.count)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,94 @@
+package metrics
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestCounterAndGauge(t *testing.T) {
+	c := NewCounter("test_requests_total", "Test counter.", "route", "status")
+	c.Inc("/services", "200")
+	c.Add(2, "/services", "200")
+	c.Inc("/a\"b\\c", "500")
+
+	if v := c.Value("/services", "200"); v != 3 {
+		t.Errorf("expected 3 but got %v", v)
+	}
+
+	g := NewGauge("test_engines", "Test gauge.", "status")
+	g.Set(5, "healthy")
+	g.Reset()
+	g.Add(1, "unhealthy")
+
+	buf := bytes.NewBuffer(nil)
+	if err := c.write(buf); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.write(buf); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `# HELP test_requests_total Test counter.
+# TYPE test_requests_total counter
+test_requests_total{route="/a\"b\\c",status="500"} 1
+test_requests_total{route="/services",status="200"} 3
+# HELP test_engines Test gauge.
+# TYPE test_engines gauge
+test_engines{status="unhealthy"} 1
+`
+	if got := buf.String(); got != expected {
+		t.Errorf("unexpected output:\n%s", got)
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	h := NewHistogram("test_duration_seconds", "Test histogram.", []float64{1, 5}, "related")
+	h.Observe(0.5, "deploy")
+	h.Observe(3, "deploy")
+	h.Observe(10, "deploy")
+
+	buf := bytes.NewBuffer(nil)
+	if err := h.write(buf); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `# HELP test_duration_seconds Test histogram.
+# TYPE test_duration_seconds histogram
+test_duration_seconds_bucket{related="deploy",le="1"} 1
+test_duration_seconds_bucket{related="deploy",le="5"} 2
+test_duration_seconds_bucket{related="deploy",le="+Inf"} 3
+test_duration_seconds_sum{related="deploy"} 13.5
+test_duration_seconds_count{related="deploy"} 3
+`
+	if got := buf.String(); got != expected {
+		t.Errorf("unexpected output:\n%s", got)
+	}
+}
+
+func TestWriteTo(t *testing.T) {
+	SchedulingDuration.Observe(0.02)
+	Allocations.Inc("ip_pool", "net1", Result(errors.New("no IP")))
+
+	buf := bytes.NewBuffer(nil)
+	if err := WriteTo(buf); err != nil {
+		t.Fatal(err)
+	}
+
+	out := buf.String()
+
+	for _, line := range []string{
+		`swarm_scheduling_duration_seconds_count 1`,
+		`swarm_allocations_total{resource="ip_pool",name="net1",result="failed"} 1`,
+		`# TYPE swarm_api_requests_total counter`,
+	} {
+		if !strings.Contains(out, line+"\n") {
+			t.Errorf("expected line %q", line)
+		}
+	}
+
+	if strings.Index(out, "swarm_allocations_total") > strings.Index(out, "swarm_api_requests_total") {
+		t.Error("expected metrics sorted by name")
+	}
+}
@@ -0,0 +1,50 @@
+package garden
+
+import (
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/swarm/garden/kvstore"
+	"github.com/docker/swarm/garden/structs"
+	"golang.org/x/net/context"
+)
+
+// SyncMonitorTargets re-registers the Prometheus targets of units labeled with the probed roles,
+// call it after the roles changed,such as compose and switchover.
+// Monitoring is not critical to the service,errors are logged only.
+func (svc *Service) SyncMonitorTargets(ctx context.Context, reg kvstore.Register) {
+	if reg == nil {
+		return
+	}
+
+	topology, err := svc.Topology(ctx)
+	if err != nil {
+		logrus.WithField("service", svc.Name()).Warnf("sync monitor targets,%+v", err)
+		return
+	}
+
+	for _, t := range topology.Units {
+		if t.Role == "" {
+			continue
+		}
+
+		cc, err := svc.getUnitConfig(ctx, t.ID)
+		if err != nil {
+			logrus.WithField("unit", t.Name).Warnf("sync monitor targets,%+v", err)
+			continue
+		}
+
+		target := cc.GetServiceRegistration().Prometheus
+		if target == nil || target.Labels["role"] == t.Role {
+			continue
+		}
+
+		if target.Labels == nil {
+			target.Labels = make(map[string]string)
+		}
+		target.Labels["role"] = t.Role
+
+		err = reg.RegisterService(ctx, "", structs.ServiceRegistration{Prometheus: target})
+		if err != nil {
+			logrus.WithField("unit", t.Name).Warnf("sync monitor targets,%+v", err)
+		}
+	}
+}
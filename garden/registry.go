@@ -0,0 +1,135 @@
+package garden
+
+import (
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/swarm/cluster"
+	"github.com/docker/swarm/garden/kvstore"
+	"github.com/hashicorp/consul/api"
+	"github.com/pkg/errors"
+	"golang.org/x/net/context"
+)
+
+const registryCheckTimeout = 5 * time.Second
+
+// RunRegistryReporter reports the heartbeat of healthy engines,the checks of registered services
+// and the Horus address to the registry every ttl/3 until ctx is done,
+// returns immediately if the kv store doesn't need reporting,consul agents report by themselves.
+func (gd *Garden) RunRegistryReporter(ctx context.Context, ttl time.Duration) {
+	r, ok := gd.kvClient.(kvstore.Reporter)
+	if !ok {
+		return
+	}
+
+	ticker := time.NewTicker(ttl / 3)
+	defer ticker.Stop()
+
+	for {
+		if err := r.PublishHorus(ttl); err != nil {
+			logrus.Warnf("publish Horus,%+v", err)
+		}
+
+		for _, eng := range gd.Cluster.ListEngines() {
+			err := reportEngine(ctx, r, eng, ttl)
+			if err != nil {
+				logrus.WithField("host", eng.IP).Warnf("report registry,%+v", err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// reportEngine refreshes the heartbeat of the healthy engine,and reports the checks of services on it,
+// the heartbeat of the unhealthy engine expires after ttl.
+func reportEngine(ctx context.Context, r kvstore.Reporter, eng *cluster.Engine, ttl time.Duration) error {
+	if eng == nil || !eng.IsHealthy() {
+		return nil
+	}
+
+	err := r.Heartbeat(eng.IP, ttl)
+	if err != nil {
+		return err
+	}
+
+	regs, err := r.Registrations(eng.IP)
+	if err != nil {
+		return err
+	}
+
+	for _, reg := range regs {
+		status := registrationCheck(ctx, eng, reg)
+
+		err := r.ReportCheck(eng.IP, reg.ID, status, ttl)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// registrationCheck runs the check of the registration from the manager,
+// TCP and HTTP checks are dialed,the other checks run by consul agents,such as script checks,
+// are replaced by the state of the container named by the registration ID.
+func registrationCheck(ctx context.Context, eng *cluster.Engine, reg api.AgentServiceRegistration) kvstore.CheckStatus {
+	var err error
+
+	switch chk := reg.Check; {
+	case chk == nil:
+	case chk.TCP != "":
+		var conn net.Conn
+
+		conn, err = net.DialTimeout("tcp", chk.TCP, registryCheckTimeout)
+		if err == nil {
+			conn.Close()
+		}
+
+	case chk.HTTP != "":
+		err = httpCheck(ctx, chk.HTTP)
+
+	default:
+		c := eng.Containers().Get(reg.ID)
+
+		if c == nil {
+			err = errors.Errorf("container %s not found on %s", reg.ID, eng.IP)
+		} else if c.Info.State == nil || !c.Info.State.Running {
+			err = errors.Errorf("container %s is not running", reg.ID)
+		}
+	}
+
+	if err != nil {
+		return kvstore.CheckStatus{Status: api.HealthCritical, Output: err.Error()}
+	}
+
+	return kvstore.CheckStatus{Status: api.HealthPassing, Output: "reported by manager"}
+}
+
+func httpCheck(ctx context.Context, url string) error {
+	ctx, cancel := context.WithTimeout(ctx, registryCheckTimeout)
+	defer cancel()
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return errors.Errorf("GET %s,status %s", url, resp.Status)
+	}
+
+	return nil
+}
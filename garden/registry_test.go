@@ -0,0 +1,34 @@
+package garden
+
+import (
+	"net"
+	"testing"
+
+	"github.com/hashicorp/consul/api"
+	"golang.org/x/net/context"
+)
+
+func TestRegistrationCheck(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	ctx := context.Background()
+
+	reg := api.AgentServiceRegistration{ID: "unit1"}
+	if status := registrationCheck(ctx, nil, reg); status.Status != api.HealthPassing {
+		t.Errorf("expected passing without check,%+v", status)
+	}
+
+	reg.Check = &api.AgentServiceCheck{TCP: l.Addr().String()}
+	if status := registrationCheck(ctx, nil, reg); status.Status != api.HealthPassing {
+		t.Errorf("expected passing,%+v", status)
+	}
+
+	l.Close()
+	if status := registrationCheck(ctx, nil, reg); status.Status != api.HealthCritical {
+		t.Errorf("expected critical,%+v", status)
+	}
+}
@@ -115,6 +115,11 @@ loop:
 			continue
 		}
 
+		if nodes[i].Status == database.NodeStatusQuarantined {
+			errs = append(errs, fmt.Sprintf("node %s is quarantined", nodes[i].Addr))
+			continue
+		}
+
 		if _, ok := filterMap[nodes[i].ID]; ok {
 			errs = append(errs, fmt.Sprintf("node %s is one of filters %s", nodes[i].ID, filters))
 			continue
@@ -159,7 +164,7 @@ loop:
 	return out, err
 }
 
-func (at allocator) AlloctCPUMemory(config *cluster.ContainerConfig, node *node.Node, ncpu, memory int64, reserved []string) (string, error) {
+func (at allocator) AlloctCPUMemory(config *cluster.ContainerConfig, node *node.Node, ncpu, memory int64, reserved []string, policy structs.ResourcePolicy) (string, error) {
 	if free := node.TotalCpus - node.UsedCpus; free < ncpu {
 		return "", errors.Errorf("Node:%s CPU is unavailable,%d<%d", node.Addr, free, ncpu)
 	}
@@ -181,7 +186,11 @@ func (at allocator) AlloctCPUMemory(config *cluster.ContainerConfig, node *node.
 			used = append(used, containers[i].Config.HostConfig.CpusetCpus)
 		}
 
-		cpuset, err = findIdleCPUs(used, int(node.TotalCpus), int(ncpu))
+		if policy.CPU == "" {
+			cpuset, err = findIdleCPUs(used, int(node.TotalCpus), int(ncpu))
+		} else {
+			cpuset, err = findIdleCPUsByPolicy(config, node, used, int(ncpu), policy.CPU)
+		}
 		if err != nil {
 			return "", err
 		}
@@ -189,11 +198,45 @@ func (at allocator) AlloctCPUMemory(config *cluster.ContainerConfig, node *node.
 
 	config.HostConfig.Resources.CpusetCpus = cpuset
 	config.HostConfig.Resources.Memory = memory
-	config.HostConfig.Resources.MemorySwap = int64(float64(memory) * 1.5)
+	config.HostConfig.Resources.MemorySwap = policy.MemorySwap(memory)
 
 	return cpuset, nil
 }
 
+// findIdleCPUsByPolicy returns the CPUs packed within one NUMA node of the host CPU topology,
+// sets CpusetMems with the NUMA node if it's empty,
+// the NUMA nodes in CpusetMems are required,the CPUs of a scaled up unit stay in its NUMA node.
+func findIdleCPUsByPolicy(config *cluster.ContainerConfig, node *node.Node, used []string, ncpu int, policy string) (string, error) {
+	topology, err := structs.ParseCPUTopology(node.Labels[structs.CPUTopologyLabel])
+	if err != nil {
+		return "", err
+	}
+	if len(topology) == 0 {
+		return "", errors.Errorf("Node:%s CPU topology is unknown,required by cpu policy '%s'", node.Addr, policy)
+	}
+
+	list, err := parseUintList(used)
+	if err != nil {
+		return "", err
+	}
+
+	require, err := utils.ParseUintList(config.HostConfig.CpusetMems)
+	if err != nil {
+		return "", errors.Wrap(err, "parse CpusetMems")
+	}
+
+	cpus, mem, err := findIdleCPUsInNUMA(topology, list, ncpu, policy == structs.CPUPolicyExclusive, require)
+	if err != nil {
+		return "", errors.Wrapf(err, "Node:%s", node.Addr)
+	}
+
+	if len(require) == 0 {
+		config.HostConfig.Resources.CpusetMems = strconv.Itoa(mem)
+	}
+
+	return joinInts(cpus), nil
+}
+
 func (at *allocator) RecycleResource(ips []database.IP, lvs []database.Volume) error {
 	for i := range lvs {
 		eng := at.ec.Engine(lvs[i].EngineID)
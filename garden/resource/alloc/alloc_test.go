@@ -5,6 +5,7 @@ import (
 
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/swarm/cluster"
+	"github.com/docker/swarm/garden/structs"
 	"github.com/docker/swarm/scheduler/node"
 )
 
@@ -103,7 +104,7 @@ func TestAlloctCPUMemory(t *testing.T) {
 	actor := NewAllocator(nil, nil)
 
 	{
-		sets, err := actor.AlloctCPUMemory(&config, &nd, 5, 1<<34, nil)
+		sets, err := actor.AlloctCPUMemory(&config, &nd, 5, 1<<34, nil, structs.ResourcePolicy{})
 		if err != nil {
 			t.Error(err, sets)
 		}
@@ -116,7 +117,7 @@ func TestAlloctCPUMemory(t *testing.T) {
 	}
 
 	{
-		sets, err := actor.AlloctCPUMemory(&config, &nd, 2, 1<<34, []string{"0-13"})
+		sets, err := actor.AlloctCPUMemory(&config, &nd, 2, 1<<34, []string{"0-13"}, structs.ResourcePolicy{})
 		if err == nil {
 			t.Errorf("error expected,but got '%s'", sets)
 		} else {
@@ -125,7 +126,7 @@ func TestAlloctCPUMemory(t *testing.T) {
 	}
 
 	{
-		sets, err := actor.AlloctCPUMemory(&config, &nd, 3, 1<<35-8<<30+1, []string{"0-11"})
+		sets, err := actor.AlloctCPUMemory(&config, &nd, 3, 1<<35-8<<30+1, []string{"0-11"}, structs.ResourcePolicy{})
 		if err == nil {
 			t.Errorf("error expected,but got '%s'", sets)
 		} else {
@@ -133,3 +134,98 @@ func TestAlloctCPUMemory(t *testing.T) {
 		}
 	}
 }
+
+// 2 NUMA nodes,4 cores per node,2 threads per core
+const testCPUTopology = "0,0,0;1,0,1;2,0,2;3,0,3;4,1,4;5,1,5;6,1,6;7,1,7;" +
+	"8,0,0;9,0,1;10,0,2;11,0,3;12,1,4;13,1,5;14,1,6;15,1,7"
+
+func TestFindIdleCPUsInNUMA(t *testing.T) {
+	topology, err := structs.ParseCPUTopology(testCPUTopology)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	used, _ := parseUintList([]string{"0-2,8"})
+
+	{
+		// node 0 has 4 free CPUs,fits best,shared cores first
+		cpus, mem, err := findIdleCPUsInNUMA(topology, used, 3, false, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if mem != 0 || joinInts(cpus) != "3,9,10" {
+			t.Errorf("unexpected cpus %v in NUMA node %d", cpus, mem)
+		}
+	}
+
+	{
+		// node 0 has one whole free core
+		cpus, mem, err := findIdleCPUsInNUMA(topology, used, 4, true, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if mem != 1 || joinInts(cpus) != "4,5,12,13" {
+			t.Errorf("unexpected cpus %v in NUMA node %d", cpus, mem)
+		}
+	}
+
+	{
+		// whole cores are allocated
+		cpus, _, err := findIdleCPUsInNUMA(topology, used, 1, true, map[int]bool{1: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if joinInts(cpus) != "4,12" {
+			t.Errorf("unexpected cpus %v", cpus)
+		}
+	}
+
+	// node 0 is required but has one whole free core only
+	if cpus, _, err := findIdleCPUsInNUMA(topology, used, 4, true, map[int]bool{0: true}); err == nil {
+		t.Errorf("error expected with required NUMA node 0,but got %v", cpus)
+	}
+
+	{
+		// node 0 is required though node 1 has more free CPUs
+		cpus, mem, err := findIdleCPUsInNUMA(topology, used, 2, false, map[int]bool{0: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if mem != 0 || joinInts(cpus) != "9,10" {
+			t.Errorf("unexpected cpus %v in NUMA node %d", cpus, mem)
+		}
+	}
+
+	if cpus, _, err := findIdleCPUsInNUMA(topology, used, 9, false, nil); err == nil {
+		t.Errorf("error expected,but got %v", cpus)
+	}
+}
+
+func TestAlloctCPUMemoryByPolicy(t *testing.T) {
+	config := cluster.ContainerConfig{}
+	nd := node.Node{
+		Addr:        "192.168.1.1:2375",
+		Labels:      map[string]string{structs.CPUTopologyLabel: testCPUTopology},
+		TotalMemory: 1 << 35,
+		TotalCpus:   16,
+	}
+
+	actor := NewAllocator(nil, nil)
+
+	policy := structs.ResourcePolicy{CPU: structs.CPUPolicyNUMA, SwapRatio: 1}
+
+	sets, err := actor.AlloctCPUMemory(&config, &nd, 6, 1<<30, []string{"0-3"}, policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// HT siblings are packed together
+	if sets != "4,5,6,12,13,14" || config.HostConfig.CpusetMems != "1" || config.HostConfig.MemorySwap != 1<<30 {
+		t.Error(sets, config.HostConfig.CpusetMems, config.HostConfig.MemorySwap)
+	}
+
+	nd.Labels = map[string]string{}
+	if sets, err := actor.AlloctCPUMemory(&config, &nd, 2, 1<<30, nil, policy); err == nil {
+		t.Errorf("error expected without CPU topology,but got '%s'", sets)
+	}
+}
@@ -17,7 +17,8 @@ type Allocator interface {
 
 	ListCandidates(clusters, filters []string, networkings map[string][]string, sstores []structs.VolumeRequire) ([]database.Node, error)
 
-	AlloctCPUMemory(config *cluster.ContainerConfig, node *node.Node, ncpu, memory int64, reserved []string) (string, error)
+	// AlloctCPUMemory allocs cpuset and memory,the cpuset is packed within one NUMA node by the policy.
+	AlloctCPUMemory(config *cluster.ContainerConfig, node *node.Node, ncpu, memory int64, reserved []string, policy structs.ResourcePolicy) (string, error)
 
 	RecycleResource(ips []database.IP, lvs []database.Volume) error
 }
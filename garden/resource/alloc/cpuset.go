@@ -0,0 +1,166 @@
+package alloc
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/docker/swarm/garden/structs"
+	"github.com/pkg/errors"
+)
+
+// numaCandidate is the free CPUs of a NUMA node,grouped by physical core.
+type numaCandidate struct {
+	node  int
+	cores [][]int // free CPUs of each core,whole free cores last
+	free  int
+}
+
+// capacity returns the CPUs could be allocated in the NUMA node,
+// only whole free cores are counted if exclusive.
+func (nc numaCandidate) capacity(exclusive bool, threads int) int {
+	if !exclusive {
+		return nc.free
+	}
+
+	n := 0
+	for i := range nc.cores {
+		if len(nc.cores[i]) == threads {
+			n += threads
+		}
+	}
+
+	return n
+}
+
+func newNUMACandidates(topology structs.CPUTopology, used map[int]bool) []numaCandidate {
+	type core struct {
+		node, id int
+		free     []int
+		total    int
+	}
+
+	cores := make(map[int]*core)
+	ids := make([]int, 0, len(topology))
+
+	for _, c := range topology {
+		cc, ok := cores[c.Core]
+		if !ok {
+			cc = &core{node: c.Node, id: c.Core}
+			cores[c.Core] = cc
+			ids = append(ids, c.Core)
+		}
+
+		cc.total++
+		if !used[c.ID] {
+			cc.free = append(cc.free, c.ID)
+		}
+	}
+
+	sort.Ints(ids)
+
+	index := make(map[int]int)
+	out := make([]numaCandidate, 0, 2)
+
+	// cores shared with others first,keep whole free cores for exclusive
+	for _, whole := range []bool{false, true} {
+		for _, id := range ids {
+			cc := cores[id]
+			if len(cc.free) == 0 || (len(cc.free) == cc.total) != whole {
+				continue
+			}
+
+			i, ok := index[cc.node]
+			if !ok {
+				i = len(out)
+				index[cc.node] = i
+				out = append(out, numaCandidate{node: cc.node})
+			}
+
+			out[i].cores = append(out[i].cores, cc.free)
+			out[i].free += len(cc.free)
+		}
+	}
+
+	return out
+}
+
+// findIdleCPUsInNUMA returns ncpu free CPUs packed within one NUMA node and the NUMA node,
+// the node with the least capacity that fits is chosen,only the NUMA nodes in require if not empty.
+// Whole physical cores are allocated if exclusive,so the CPUs may be more than ncpu.
+func findIdleCPUsInNUMA(topology structs.CPUTopology, used map[int]bool, ncpu int, exclusive bool, require map[int]bool) ([]int, int, error) {
+	threads := topology.ThreadsPerCore()
+	candidates := newNUMACandidates(topology, used)
+
+	best := -1
+	for i := range candidates {
+		if len(require) > 0 && !require[candidates[i].node] {
+			continue
+		}
+
+		if candidates[i].capacity(exclusive, threads) < ncpu {
+			continue
+		}
+
+		if best == -1 || candidates[i].capacity(exclusive, threads) < candidates[best].capacity(exclusive, threads) {
+			best = i
+		}
+	}
+
+	if best == -1 {
+		if len(require) > 0 {
+			return nil, 0, errors.Errorf("not enough CPU in NUMA node %s,required=%d,exclusive=%t", joinInts(sortedKeys(require)), ncpu, exclusive)
+		}
+
+		return nil, 0, errors.Errorf("not enough CPU in one NUMA node,required=%d,exclusive=%t", ncpu, exclusive)
+	}
+
+	nc := candidates[best]
+	cpus := make([]int, 0, ncpu+threads)
+
+	for i := range nc.cores {
+		if len(cpus) >= ncpu {
+			break
+		}
+
+		if exclusive {
+			if len(nc.cores[i]) != threads {
+				continue
+			}
+
+			cpus = append(cpus, nc.cores[i]...)
+			continue
+		}
+
+		for _, id := range nc.cores[i] {
+			if len(cpus) < ncpu {
+				cpus = append(cpus, id)
+			}
+		}
+	}
+
+	sort.Ints(cpus)
+
+	return cpus, nc.node, nil
+}
+
+func joinInts(ints []int) string {
+	out := make([]string, len(ints))
+	for i := range ints {
+		out[i] = strconv.Itoa(ints[i])
+	}
+
+	return strings.Join(out, ",")
+}
+
+func sortedKeys(m map[int]bool) []int {
+	keys := make([]int, 0, len(m))
+	for k, v := range m {
+		if v {
+			keys = append(keys, k)
+		}
+	}
+	sort.Ints(keys)
+
+	return keys
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/Sirupsen/logrus"
 	"github.com/docker/swarm/cluster"
 	"github.com/docker/swarm/garden/database"
+	"github.com/docker/swarm/garden/metrics"
 	"github.com/docker/swarm/garden/structs"
 	"github.com/pkg/errors"
 )
@@ -130,6 +131,8 @@ func (vds VolumeDrivers) AllocVolumes(config *cluster.ContainerConfig, uid strin
 		}
 
 		v, err := driver.Alloc(config, uid, stores[i])
+		metrics.Allocations.Inc("volume_driver", stores[i].Type, metrics.Result(err))
+
 		if v != nil {
 			lvs = append(lvs, *v)
 		}
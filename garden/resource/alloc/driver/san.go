@@ -7,6 +7,7 @@ import (
 
 	"github.com/docker/swarm/cluster"
 	"github.com/docker/swarm/garden/database"
+	"github.com/docker/swarm/garden/metrics"
 	"github.com/docker/swarm/garden/resource/storage"
 	"github.com/docker/swarm/garden/structs"
 	"github.com/docker/swarm/seed/sdk"
@@ -68,7 +69,14 @@ func (sv sanVolume) Alloc(config *cluster.ContainerConfig, uid string, req struc
 	vg := uid + "_SAN_VG"
 	name := generateVolumeName(uid, config.Config.Labels["service.tag"], req.Name)
 
-	_, lv, err := sv.san.Alloc(name, uid, vg, sv.engine.ID, req.Size)
+	lun, lv, err := sv.san.Alloc(name, uid, vg, sv.engine.ID, req.Size)
+
+	rg := lun.RaidGroupID
+	if rg == "" {
+		rg = sv.san.ID()
+	}
+	metrics.Allocations.Inc("raid_group", rg, metrics.Result(err))
+
 	if err != nil {
 		return nil, err
 	}
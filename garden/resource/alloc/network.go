@@ -8,6 +8,7 @@ import (
 	"github.com/Sirupsen/logrus"
 	"github.com/docker/swarm/cluster"
 	"github.com/docker/swarm/garden/database"
+	"github.com/docker/swarm/garden/metrics"
 	"github.com/docker/swarm/garden/resource/alloc/nic"
 	"github.com/docker/swarm/garden/structs"
 	"github.com/docker/swarm/garden/utils"
@@ -16,12 +17,16 @@ import (
 	"golang.org/x/net/context"
 )
 
+// FreeIPThreshold alert when free IPs of a networking is less than it
+var FreeIPThreshold = 5
+
 type networkAllocOrmer interface {
 	SetIPs([]database.IP) error
 	ResetIPs(ips []database.IP) error
 
 	ListIPByEngine(ID string) ([]database.IP, error)
 	CountIPWithCondition(networking string, allocated bool) (int, error)
+	CountFreeIP(networking string) (int, error)
 
 	AllocNetworking(unit, engine string, req []database.NetworkingRequire) ([]database.IP, error)
 }
@@ -83,6 +88,12 @@ func (at netAllocator) AlloctNetworking(config *cluster.ContainerConfig, engineI
 			in[l].Networking = networkings[i]
 		}
 		out, err = at.ormer.AllocNetworking(unitID, engineID, in)
+		if err == nil && len(out) < len(in) {
+			metrics.Allocations.Inc("ip_pool", networkings[i], "exhausted")
+		} else {
+			metrics.Allocations.Inc("ip_pool", networkings[i], metrics.Result(err))
+		}
+
 		if err == nil && len(out) >= len(in) {
 			break
 		} else if len(out) > 0 {
@@ -101,6 +112,11 @@ func (at netAllocator) AlloctNetworking(config *cluster.ContainerConfig, engineI
 		out = out[:n]
 	}
 
+	// reserved IPs are only for the reserving services,not counted as free
+	if n, err := at.ormer.CountFreeIP(out[0].Networking); err == nil && n < FreeIPThreshold {
+		logrus.Warnf("networking %s free IPs %d is less than threshold %d", out[0].Networking, n, FreeIPThreshold)
+	}
+
 	for i := range out {
 		ip := utils.Uint32ToIP(out[i].IPAddr)
 		if ip == nil {
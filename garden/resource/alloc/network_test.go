@@ -24,6 +24,18 @@ func (n network) CountIPWithCondition(networking string, allocated bool) (int, e
 	return num, nil
 }
 
+func (n network) CountFreeIP(networking string) (int, error) {
+	num := 0
+
+	for _, ip := range n.ips {
+		if ip.Networking == networking && ip.Enabled && ip.UnitID == "" && ip.Reserved == "" {
+			num++
+		}
+	}
+
+	return num, nil
+}
+
 func (n network) ListIPByEngine(engine string) ([]database.IP, error) {
 	out := make([]database.IP, 0, 5)
 
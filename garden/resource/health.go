@@ -0,0 +1,341 @@
+package resource
+
+import (
+	"crypto/tls"
+	stderr "errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/swarm/cluster"
+	"github.com/docker/swarm/garden/database"
+	"github.com/docker/swarm/garden/kvstore"
+	"github.com/docker/swarm/garden/resource/alloc/driver"
+	"github.com/docker/swarm/garden/structs"
+	"github.com/docker/swarm/garden/utils"
+	"github.com/docker/swarm/seed/sdk"
+	"golang.org/x/net/context"
+)
+
+const (
+	healthCheckEngine  = "engine"
+	healthCheckConsul  = "consul_agent"
+	healthCheckSeed    = "seed_agent"
+	healthCheckStorage = "storage"
+
+	consulCheckCritical = "critical"
+)
+
+var errEngineNotFound = stderr.New("engine not found")
+
+var (
+	// HealthScoreThreshold host is unhealthy if the score lower than it.
+	HealthScoreThreshold = 60
+	// HealthQuarantineTimes host is quarantined after continuous unhealthy times.
+	HealthQuarantineTimes = 3
+	// HealthMinFreeStorage minimum free percent of each host VG.
+	HealthMinFreeStorage = 5
+
+	healthCheckWeights = map[string]int{
+		healthCheckEngine:  40,
+		healthCheckConsul:  20,
+		healthCheckSeed:    20,
+		healthCheckStorage: 20,
+	}
+)
+
+type healthOrmer interface {
+	database.NodeIface
+	database.GetSysConfigIface
+	database.VolumeOrmer
+}
+
+// HealthMonitor checks hosts health periodically,
+// hosts stay unhealthy are quarantined from scheduling,
+// and returned to the status before quarantined after recovered.
+type HealthMonitor struct {
+	lock      *sync.RWMutex
+	ec        engineCluster
+	ormer     healthOrmer
+	kvc       kvstore.HealthChecker
+	tlsConfig *tls.Config
+	nodes     map[string]structs.NodeHealth
+}
+
+// NewHealthMonitor returns a HealthMonitor,the consul agent check passes if kvc is nil.
+func NewHealthMonitor(ormer healthOrmer, ec engineCluster, kvc kvstore.HealthChecker, tlsConfig *tls.Config) *HealthMonitor {
+	return &HealthMonitor{
+		lock:      new(sync.RWMutex),
+		ec:        ec,
+		ormer:     ormer,
+		kvc:       kvc,
+		tlsConfig: tlsConfig,
+		nodes:     make(map[string]structs.NodeHealth),
+	}
+}
+
+// Health returns the latest health of the node.
+func (hm *HealthMonitor) Health(ID string) (structs.NodeHealth, bool) {
+	hm.lock.RLock()
+	h, ok := hm.nodes[ID]
+	hm.lock.RUnlock()
+
+	return h, ok
+}
+
+// Run checks hosts every interval until ctx is done.
+func (hm *HealthMonitor) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		err := hm.CheckNodes(ctx)
+		if err != nil {
+			logrus.Errorf("check hosts health,%+v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// CheckNodes scores all registered hosts,quarantine or release hosts by the score.
+func (hm *HealthMonitor) CheckNodes(ctx context.Context) error {
+	nodes, err := hm.ormer.ListNodes()
+	if err != nil {
+		return err
+	}
+
+	sys, err := hm.ormer.GetSysConfig()
+	if err != nil {
+		return err
+	}
+
+	var wg sync.WaitGroup
+
+	for i := range nodes {
+		if nodes[i].EngineID == "" ||
+			(nodes[i].Status != statusNodeEnable && nodes[i].Status != statusNodeQuarantined) {
+			continue
+		}
+
+		wg.Add(1)
+
+		go func(n database.Node) {
+			defer wg.Done()
+
+			checks := hm.checkNode(ctx, n, sys)
+
+			hm.update(n, checks, time.Now())
+		}(nodes[i])
+	}
+
+	wg.Wait()
+
+	return nil
+}
+
+func (hm *HealthMonitor) checkNode(ctx context.Context, n database.Node, sys database.SysConfig) []structs.NodeHealthCheck {
+	checks := make([]structs.NodeHealthCheck, 0, len(healthCheckWeights))
+
+	eng := hm.ec.Engine(n.EngineID)
+
+	checks = append(checks, checkEngine(eng))
+	checks = append(checks, hm.checkConsulAgent(n))
+	checks = append(checks, hm.checkSeedAgent(ctx, n, sys.SwarmAgent))
+	checks = append(checks, hm.checkStorage(eng, sys.SwarmAgent))
+
+	return checks
+}
+
+func newHealthCheck(name string, err error) structs.NodeHealthCheck {
+	hc := structs.NodeHealthCheck{
+		Name:    name,
+		Passing: err == nil,
+		Weight:  healthCheckWeights[name],
+	}
+
+	if err != nil {
+		hc.Output = err.Error()
+	}
+
+	return hc
+}
+
+func checkEngine(eng *cluster.Engine) structs.NodeHealthCheck {
+	var err error
+
+	if eng == nil {
+		err = errEngineNotFound
+	} else if !eng.IsHealthy() {
+		err = fmt.Errorf("engine %s is %s", eng.Addr, eng.Status())
+	}
+
+	return newHealthCheck(healthCheckEngine, err)
+}
+
+// checkConsulAgent checks consul agent of host is reachable,
+// and the host level checks are not critical.
+func (hm *HealthMonitor) checkConsulAgent(n database.Node) structs.NodeHealthCheck {
+	if hm.kvc == nil {
+		// the kv store client without agent checks
+		return newHealthCheck(healthCheckConsul, nil)
+	}
+
+	checks, err := hm.kvc.AgentChecks(n.Addr)
+	if err == nil {
+		critical := make([]string, 0, 2)
+
+		for id, c := range checks {
+			if c.ServiceID != "" && !strings.HasPrefix(c.ServiceID, n.ID+":") {
+				// unit checks
+				continue
+			}
+
+			if c.Status == consulCheckCritical {
+				critical = append(critical, id)
+			}
+		}
+
+		if len(critical) > 0 {
+			err = fmt.Errorf("critical checks:%s", strings.Join(critical, ","))
+		}
+	}
+
+	return newHealthCheck(healthCheckConsul, err)
+}
+
+func (hm *HealthMonitor) checkSeedAgent(ctx context.Context, n database.Node, port int) structs.NodeHealthCheck {
+	addr := net.JoinHostPort(n.Addr, strconv.Itoa(port))
+
+	cli, err := sdk.NewClient(addr, 10*time.Second, hm.tlsConfig)
+	if err == nil {
+		_, err = cli.Version(ctx)
+	}
+
+	return newHealthCheck(healthCheckSeed, err)
+}
+
+// checkStorage checks free space of host local VGs,
+// failed if anyone lower than HealthMinFreeStorage percent.
+func (hm *HealthMonitor) checkStorage(eng *cluster.Engine, port int) structs.NodeHealthCheck {
+	if eng == nil {
+		return newHealthCheck(healthCheckStorage, errEngineNotFound)
+	}
+
+	drivers, err := driver.FindEngineLocalVolumeDrivers(eng, hm.ormer, port)
+	if err != nil {
+		return newHealthCheck(healthCheckStorage, err)
+	}
+
+	errs := make([]string, 0, len(drivers))
+
+	for _, d := range drivers {
+		if d == nil {
+			continue
+		}
+
+		space, err := d.Space()
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s:%s", d.Name(), err))
+			continue
+		}
+
+		if space.Total > 0 && space.Free*100 < space.Total*int64(HealthMinFreeStorage) {
+			errs = append(errs, fmt.Sprintf("%s free %d/%d lower than %d%%", space.VG, space.Free, space.Total, HealthMinFreeStorage))
+		}
+	}
+
+	if len(errs) > 0 {
+		err = stderr.New(strings.Join(errs, ";"))
+	}
+
+	return newHealthCheck(healthCheckStorage, err)
+}
+
+// healthScore returns sum weight of passing checks.
+func healthScore(checks []structs.NodeHealthCheck) int {
+	score := 0
+
+	for i := range checks {
+		if checks[i].Passing {
+			score += checks[i].Weight
+		}
+	}
+
+	return score
+}
+
+// nextHealth returns the new health of host by the previous health and checks,
+// quarantine is true when the host should be quarantined,release is true when the host should be returned to service.
+func nextHealth(prev structs.NodeHealth, n database.Node, checks []structs.NodeHealthCheck, now time.Time) (h structs.NodeHealth, quarantine, release bool) {
+	h = structs.NodeHealth{
+		Score:       healthScore(checks),
+		Checks:      checks,
+		CheckedAt:   utils.TimeToString(now),
+		Quarantined: n.Status == statusNodeQuarantined,
+	}
+
+	h.Healthy = h.Score >= HealthScoreThreshold
+
+	if h.Healthy {
+		release = h.Quarantined
+		h.Quarantined = false
+
+		return h, false, release
+	}
+
+	h.Failures = prev.Failures + 1
+	h.UnhealthySince = prev.UnhealthySince
+	if h.UnhealthySince == "" {
+		h.UnhealthySince = h.CheckedAt
+	}
+
+	// manually disabled host is not quarantined
+	if !h.Quarantined && n.Enabled && h.Failures >= HealthQuarantineTimes {
+		quarantine = true
+		h.Quarantined = true
+	}
+
+	return h, quarantine, false
+}
+
+func (hm *HealthMonitor) update(n database.Node, checks []structs.NodeHealthCheck, now time.Time) {
+	prev, _ := hm.Health(n.ID)
+
+	h, quarantine, release := nextHealth(prev, n, checks, now)
+
+	entry := logrus.WithField("host", n.Addr)
+
+	// only the status is swapped,Node.Enabled is kept as set by users or drain
+	if quarantine {
+		ok, err := hm.ormer.SwapNodeStatus(n.ID, n.Status, statusNodeQuarantined)
+		if err != nil || !ok {
+			entry.Errorf("quarantine host,status changed:%t,%+v", !ok, err)
+			h.Quarantined = false
+		} else {
+			entry.Warnf("host quarantined,score %d,unhealthy since %s", h.Score, h.UnhealthySince)
+		}
+	}
+
+	if release {
+		ok, err := hm.ormer.SwapNodeStatus(n.ID, statusNodeQuarantined, statusNodeEnable)
+		if err != nil {
+			entry.Errorf("release quarantined host,%+v", err)
+			h.Quarantined = true
+		} else if ok {
+			entry.Infof("host recovered,score %d", h.Score)
+		}
+	}
+
+	hm.lock.Lock()
+	hm.nodes[n.ID] = h
+	hm.lock.Unlock()
+}
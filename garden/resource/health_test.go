@@ -0,0 +1,85 @@
+package resource
+
+import (
+	"testing"
+	"time"
+
+	"github.com/docker/swarm/garden/database"
+	"github.com/docker/swarm/garden/structs"
+)
+
+func TestNextHealth(t *testing.T) {
+	healthy := []structs.NodeHealthCheck{
+		newHealthCheck(healthCheckEngine, nil),
+		newHealthCheck(healthCheckConsul, nil),
+		newHealthCheck(healthCheckSeed, nil),
+		newHealthCheck(healthCheckStorage, nil),
+	}
+
+	unhealthy := []structs.NodeHealthCheck{
+		newHealthCheck(healthCheckEngine, errEngineNotFound),
+		newHealthCheck(healthCheckConsul, nil),
+		newHealthCheck(healthCheckSeed, nil),
+		newHealthCheck(healthCheckStorage, errEngineNotFound),
+	}
+
+	if statusNodeQuarantined != statusNodeDeregisted+1 || statusNodePrecheckFailed != statusNodeQuarantined+1 {
+		t.Errorf("unexpected quarantined status %d", statusNodeQuarantined)
+	}
+
+	if score := healthScore(healthy); score != 100 {
+		t.Errorf("expected score 100 but got %d", score)
+	}
+
+	n := database.Node{
+		ID:      "node0",
+		Status:  statusNodeEnable,
+		Enabled: true,
+	}
+
+	var (
+		h          structs.NodeHealth
+		quarantine bool
+		release    bool
+		now        = time.Now()
+	)
+
+	for i := 1; i <= HealthQuarantineTimes; i++ {
+		h, quarantine, release = nextHealth(h, n, unhealthy, now.Add(time.Duration(i)*time.Minute))
+
+		if h.Healthy || h.Score != 40 || h.Failures != i || release {
+			t.Errorf("unexpected health %d,%+v", i, h)
+		}
+
+		if quarantine != (i == HealthQuarantineTimes) {
+			t.Errorf("round %d,unexpected quarantine %t", i, quarantine)
+		}
+	}
+
+	if !h.Quarantined || h.UnhealthySince == h.CheckedAt {
+		t.Errorf("unexpected health %+v", h)
+	}
+
+	n.Status = statusNodeQuarantined
+	n.Enabled = false
+
+	h, quarantine, _ = nextHealth(h, n, unhealthy, now)
+	if quarantine || !h.Quarantined {
+		t.Errorf("quarantined host should not be quarantined again,%+v", h)
+	}
+
+	h, quarantine, release = nextHealth(h, n, healthy, now)
+	if quarantine || !release || h.Quarantined || !h.Healthy || h.Failures != 0 {
+		t.Errorf("expected host released,%+v", h)
+	}
+
+	// manually disabled host is not quarantined
+	n.Status = statusNodeEnable
+	n.Enabled = false
+	h = structs.NodeHealth{Failures: HealthQuarantineTimes}
+
+	h, quarantine, _ = nextHealth(h, n, unhealthy, now)
+	if quarantine || h.Quarantined {
+		t.Errorf("disabled host should not be quarantined,%+v", h)
+	}
+}
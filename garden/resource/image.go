@@ -11,6 +11,7 @@ import (
 	"time"
 
 	"github.com/Sirupsen/logrus"
+	"github.com/docker/distribution/reference"
 	"github.com/docker/docker/api/types"
 	"github.com/docker/swarm/cluster"
 	"github.com/docker/swarm/garden/database"
@@ -58,7 +59,20 @@ func LoadImage(ctx context.Context,
 		UploadAt: time.Now(),
 	}
 
-	task := database.NewTask(req.Image(), database.ImageLoadTask, image.ID, "load image", nil, int(timeout/time.Second))
+	source := req.Path
+	desc := "load image"
+
+	if req.Reference != "" {
+		ref, err := reference.ParseNormalizedNamed(req.Reference)
+		if err != nil {
+			return "", "", errors.Wrapf(err, "parse image reference:%s", req.Reference)
+		}
+
+		source = reference.TagNameOnly(ref).String()
+		desc = "import image from " + source
+	}
+
+	task := database.NewTask(req.Image(), database.ImageLoadTask, image.ID, desc, nil, int(timeout/time.Second))
 
 	before := func(key string, new int, t *database.Task, f func(val int) bool) (bool, int, error) {
 		err := ormer.InsertImageWithTask(image, *t)
@@ -81,7 +95,7 @@ func LoadImage(ctx context.Context,
 		ch := make(chan error)
 
 		go func(ch chan<- error) {
-			ch <- loadImage(ctx, cluster, ormer, pc, image, task, req.Path, timeout)
+			ch <- loadImage(ctx, cluster, ormer, pc, image, task, source, req.Reference != "", req.Template, timeout)
 		}(ch)
 
 		select {
@@ -113,7 +127,7 @@ func loadImage(ctx context.Context,
 	pc api.PluginAPI,
 	image database.Image,
 	task database.Task,
-	path string, timeout time.Duration) error {
+	path string, remote bool, tmpl *structs.ConfigTemplate, timeout time.Duration) error {
 
 	sys, err := ormer.GetSysConfig()
 	if err != nil {
@@ -123,6 +137,10 @@ func loadImage(ctx context.Context,
 	oldName := image.Image()
 	newName := fmt.Sprintf("%s:%d/%s", sys.Registry.Domain, sys.Registry.Port, oldName)
 	script := fmt.Sprintf("docker load -i %s && docker tag %s %s && docker push %s", path, oldName, newName, newName)
+	if remote {
+		// path is the upstream registry reference
+		script = fmt.Sprintf("docker pull %s && docker tag %s %s && docker push %s", path, path, newName, newName)
+	}
 
 	field := logrus.WithField("Image", oldName)
 	field.Infof("ssh exec:'%s'", script)
@@ -163,7 +181,7 @@ func loadImage(ctx context.Context,
 	}
 	{
 		// post image template to plugin
-		err := postImageTemplate(ctx, scp, pc, oldName, path)
+		err := postImageTemplate(ctx, scp, pc, oldName, path, tmpl)
 		if err != nil {
 			field.Errorf("post image config template,%+v", err)
 		}
@@ -198,14 +216,26 @@ func parsePushImageOutput(in []byte) (string, int, error) {
 	return "", 0, errors.Errorf("parse output error:%s", in)
 }
 
-// post image template to plugin
-func postImageTemplate(ctx context.Context, scp scplib.ScpClient, pc api.PluginAPI, image, path string) error {
-	tmpl, err := readImageTemplateFile(path)
-	if errors.Cause(err) == os.ErrNotExist {
-		tmpl, err = readRemoteImageTemplateFile(scp, path)
-	}
-	if err != nil {
-		return err
+// post image template to plugin,the template is read from the files beside path if tmpl is nil
+func postImageTemplate(ctx context.Context, scp scplib.ScpClient, pc api.PluginAPI, image, path string, in *structs.ConfigTemplate) error {
+	var (
+		tmpl structs.ConfigTemplate
+		err  error
+	)
+
+	if in != nil {
+		tmpl = *in
+		if tmpl.Timestamp == 0 {
+			tmpl.Timestamp = time.Now().Unix()
+		}
+	} else {
+		tmpl, err = readImageTemplateFile(path)
+		if errors.Cause(err) == os.ErrNotExist {
+			tmpl, err = readRemoteImageTemplateFile(scp, path)
+		}
+		if err != nil {
+			return err
+		}
 	}
 
 	if tmpl.Image != image {
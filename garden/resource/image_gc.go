@@ -0,0 +1,159 @@
+package resource
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/swarm/cluster"
+	"github.com/docker/swarm/garden/database"
+	"github.com/docker/swarm/garden/structs"
+)
+
+const noneTag = "<none>:<none>"
+
+type imageLister interface {
+	lister
+	database.GetSysConfigIface
+}
+
+// unusedImages returns the images on the engine could be removed,
+// the image isnot used by any container and isnot the parent of other images,
+// and it's dangling or pushed from the registry but deleted or deprecated,kept are the images in use.
+func unusedImages(images cluster.Images, containers cluster.Containers, registry string, kept map[string]bool) []structs.EngineImage {
+	used := make(map[string]bool, len(containers)+len(images))
+
+	for _, c := range containers {
+		used[c.ImageID] = true
+	}
+
+	for _, im := range images {
+		if im.ParentID != "" {
+			used[im.ParentID] = true
+		}
+	}
+
+	out := make([]structs.EngineImage, 0, len(images))
+
+	for _, im := range images {
+		if used[im.ID] {
+			continue
+		}
+
+		tags := make([]string, 0, len(im.RepoTags))
+		for _, tag := range im.RepoTags {
+			if tag != noneTag {
+				tags = append(tags, tag)
+			}
+		}
+
+		collect := len(tags) > 0
+		for _, tag := range tags {
+			if kept[tag] || !strings.HasPrefix(tag, registry) {
+				collect = false
+				break
+			}
+		}
+
+		if len(tags) > 0 && !collect {
+			continue
+		}
+
+		reclaimable := im.Size
+		if im.SharedSize > 0 && im.SharedSize < im.Size {
+			reclaimable = im.Size - im.SharedSize
+		}
+
+		out = append(out, structs.EngineImage{
+			ID:          im.ID,
+			Tags:        tags,
+			Size:        im.Size,
+			Reclaimable: reclaimable,
+		})
+	}
+
+	return out
+}
+
+func engineImagesGC(eng *cluster.Engine, registry string, kept map[string]bool, dryRun bool) structs.ImageGCReport {
+	report := structs.ImageGCReport{
+		Host:    eng.Name,
+		Engine:  eng.Addr,
+		Removed: !dryRun,
+	}
+
+	err := eng.RefreshImages()
+	if err != nil {
+		report.Errors = append(report.Errors, err.Error())
+	}
+
+	images := unusedImages(eng.Images(), eng.Containers(), registry, kept)
+
+	report.Images = make([]structs.EngineImage, 0, len(images))
+
+	for i := range images {
+		if !dryRun {
+			_, err := eng.RemoveImage(images[i].ID, false)
+			if err != nil {
+				logrus.WithField("Engine", eng.Addr).Warnf("remove unused image %s %s,%s", images[i].ID, images[i].Tags, err)
+
+				report.Errors = append(report.Errors, err.Error())
+				continue
+			}
+		}
+
+		report.Images = append(report.Images, images[i])
+		report.Reclaimable += images[i].Reclaimable
+	}
+
+	return report
+}
+
+// ImagesGC removes the unused images on engines,reports the reclaimable space only if dryRun.
+// Images of other registries are ignored,images of the registry are removed
+// only if deleted or deprecated and no container uses them.
+func ImagesGC(engines map[string]*cluster.Engine, ormer imageLister, dryRun bool) ([]structs.ImageGCReport, error) {
+	sys, err := ormer.GetSysConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	list, err := ormer.ListImages()
+	if err != nil {
+		return nil, err
+	}
+
+	registry := fmt.Sprintf("%s:%d/", sys.Registry.Domain, sys.Registry.Port)
+	kept := make(map[string]bool, len(list))
+
+	for i := range list {
+		if !list[i].Deprecated {
+			kept[registry+list[i].Image()] = true
+		}
+	}
+
+	ch := make(chan structs.ImageGCReport, len(engines))
+	limit := make(chan struct{}, 5)
+
+	for _, e := range engines {
+		limit <- struct{}{}
+
+		go func(eng *cluster.Engine) {
+			ch <- engineImagesGC(eng, registry, kept, dryRun)
+
+			<-limit
+		}(e)
+	}
+
+	out := make([]structs.ImageGCReport, 0, len(engines))
+	for range engines {
+		out = append(out, <-ch)
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Host < out[j].Host
+	})
+
+	return out, nil
+}
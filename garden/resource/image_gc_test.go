@@ -0,0 +1,64 @@
+package resource
+
+import (
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/swarm/cluster"
+)
+
+func TestUnusedImages(t *testing.T) {
+	registry := "registry.dbaas.me:8080/"
+
+	image := func(id, parent string, size, shared int64, tags ...string) *cluster.Image {
+		return &cluster.Image{
+			ImageSummary: types.ImageSummary{
+				ID:         id,
+				ParentID:   parent,
+				RepoTags:   tags,
+				Size:       size,
+				SharedSize: shared,
+			},
+		}
+	}
+
+	images := cluster.Images{
+		image("used", "", 100, -1, registry+"upsql:5.6.19.0"),
+		image("kept", "", 100, -1, registry+"upsql:5.7.17.0"),
+		image("deprecated", "", 100, 40, registry+"upsql:5.6.18.0"),
+		image("deleted", "", 100, -1, registry+"upsql:5.6.17.0"),
+		image("foreign", "", 100, -1, "suse/sles12:latest"),
+		image("mixed", "", 100, -1, registry+"proxy:1.0.0.0", "proxy:1.0.0.0"),
+		image("dangling", "", 10, -1, noneTag),
+		image("parent", "", 10, -1),
+		image("child", "parent", 20, -1),
+	}
+
+	containers := cluster.Containers{
+		{Container: types.Container{ID: "c1", ImageID: "used"}},
+	}
+
+	kept := map[string]bool{
+		registry + "upsql:5.6.19.0": true,
+		registry + "upsql:5.7.17.0": true,
+	}
+
+	out := unusedImages(images, containers, registry, kept)
+
+	want := map[string]int64{
+		"deprecated": 60,
+		"deleted":    100,
+		"dangling":   10,
+		"child":      20,
+	}
+
+	if len(out) != len(want) {
+		t.Fatalf("expected %d images but got %+v", len(want), out)
+	}
+
+	for _, im := range out {
+		if size, ok := want[im.ID]; !ok || size != im.Reclaimable {
+			t.Errorf("unexpected %+v", im)
+		}
+	}
+}
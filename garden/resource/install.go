@@ -0,0 +1,384 @@
+package resource
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/swarm/garden/database"
+	"github.com/docker/swarm/garden/kvstore"
+	"github.com/docker/swarm/garden/structs"
+	"github.com/pkg/errors"
+	"golang.org/x/net/context"
+)
+
+const (
+	installStepPrecheck      = "precheck"
+	installStepUploadPackage = "upload_package"
+	installStepUploadCA      = "upload_ca"
+	installStepExecInit      = "exec_init"
+)
+
+// minimum kernel version required by docker
+const minKernelMajor, minKernelMinor = 3, 10
+
+var supportedOS = []string{"suse", "red hat", "rhel", "centos"}
+
+type installOrmer interface {
+	database.NodeIface
+	database.TaskStepOrmer
+}
+
+// installStep is one step of host installation,
+// failed is the node status if the step failed.
+type installStep struct {
+	name   string
+	failed int
+	retry  bool
+	run    func() (string, error)
+}
+
+func (nt *nodeWithTask) installSteps(script string, config database.SysConfig) []installStep {
+	_, ca, _ := config.DestPath()
+
+	return []installStep{
+		{
+			name:   installStepPrecheck,
+			failed: statusNodePrecheckFailed,
+			run: func() (string, error) {
+				return precheckNode(nt.client.Exec, nt.Node, nt.hdd, nt.ssd)
+			},
+		},
+		{
+			name:   installStepUploadPackage,
+			failed: statusNodeSCPFailed,
+			retry:  true,
+			run: func() (string, error) {
+				err := nt.client.UploadDir(config.Destination, config.SourceDir)
+
+				return fmt.Sprintf("upload %s to %s", config.SourceDir, config.Destination), err
+			},
+		},
+		{
+			name:   installStepUploadCA,
+			failed: statusNodeSCPFailed,
+			retry:  true,
+			run: func() (string, error) {
+				err := nt.client.Upload(config.Registry.CACert, ca, 0644)
+
+				return "upload registry CA to " + ca, err
+			},
+		},
+		{
+			name:   installStepExecInit,
+			failed: statusNodeSSHExecFailed,
+			run: func() (string, error) {
+				out, err := nt.client.Exec(script)
+
+				return string(out), err
+			},
+		},
+	}
+}
+
+// runSteps runs steps in order,steps succeeded in previous installation are skipped.
+// returns the node status of the failed step.
+func (nt *nodeWithTask) runSteps(ctx context.Context, ormer database.TaskStepOrmer, steps []installStep, entry *logrus.Entry) (int, error) {
+	for i := range steps {
+		select {
+		default:
+		case <-ctx.Done():
+			return statusNodeInstallFailed, errors.WithStack(ctx.Err())
+		}
+
+		err := nt.runStep(ormer, steps[i], entry)
+		if err != nil {
+			return steps[i].failed, err
+		}
+	}
+
+	return statusNodeInstalling, nil
+}
+
+func (nt *nodeWithTask) runStep(ormer database.TaskStepOrmer, step installStep, entry *logrus.Entry) error {
+	ts := database.TaskStep{
+		Task:      nt.Task.ID,
+		Name:      step.name,
+		Status:    database.TaskStepRunning,
+		StartedAt: time.Now(),
+	}
+
+	setStep := func() {
+		err := ormer.SetTaskStep(ts)
+		if err != nil {
+			entry.Errorf("save install step %s,%+v", step.name, err)
+		}
+	}
+
+	if nt.succeeded[step.name] {
+		ts.Status = database.TaskStepSkipped
+		ts.Output = "succeeded in previous installation"
+		ts.FinishedAt = ts.StartedAt
+
+		setStep()
+
+		return nil
+	}
+
+	setStep()
+
+	out, err := step.run()
+	if err != nil && step.retry {
+		entry.WithError(err).Warnf("install step %s,retry", step.name)
+
+		out, err = step.run()
+	}
+
+	ts.Output = out
+	ts.FinishedAt = time.Now()
+
+	if err != nil {
+		ts.Status = database.TaskStepFailed
+		ts.Output = fmt.Sprintf("%s\n%+v", out, err)
+
+		entry.WithError(err).Errorf("install step %s,output:%s", step.name, out)
+	} else {
+		ts.Status = database.TaskStepDone
+
+		entry.Infof("install step %s done", step.name)
+	}
+
+	setStep()
+
+	return err
+}
+
+// precheckNode checks OS,kernel,Docker,HBA WWN and free disks of the host,
+// returns the check output.
+func precheckNode(exec func(cmd string) ([]byte, error), n database.Node, hdd, ssd []string) (string, error) {
+	buf := bytes.NewBuffer(nil)
+	errs := make([]string, 0, 3)
+
+	run := func(name, cmd string) (string, error) {
+		out, err := exec(cmd)
+		fmt.Fprintf(buf, "[%s] %s\n%s\n", name, cmd, out)
+
+		return strings.TrimSpace(string(out)), err
+	}
+
+	out, err := run("os", "cat /etc/os-release")
+	if err != nil {
+		errs = append(errs, fmt.Sprintf("read os release,%s", err))
+	} else if !isSupportedOS(out) {
+		errs = append(errs, "unsupported OS")
+	}
+
+	out, err = run("kernel", "uname -r")
+	if err != nil {
+		errs = append(errs, fmt.Sprintf("read kernel version,%s", err))
+	} else if !kernelSatisfied(out) {
+		errs = append(errs, fmt.Sprintf("kernel %s is lower than %d.%d", out, minKernelMajor, minKernelMinor))
+	}
+
+	// Docker is installed by init script,
+	// host with running containers is not managed by us.
+	out, err = run("docker", "docker ps -q 2>/dev/null | wc -l")
+	if err == nil {
+		if num, _ := strconv.Atoi(out); num > 0 {
+			errs = append(errs, fmt.Sprintf("%d containers running on the host", num))
+		}
+	}
+
+	if n.Storage != "" {
+		out, err = run("hba_wwn", "cat /sys/class/fc_host/host*/port_name")
+		if err != nil || out == "" {
+			errs = append(errs, fmt.Sprintf("HBA WWN not found,%v", err))
+		}
+	}
+
+	devs := make([]string, 0, len(hdd)+len(ssd))
+	devs = append(devs, hdd...)
+	devs = append(devs, ssd...)
+
+	for _, dev := range devs {
+		out, err = run("disk", fmt.Sprintf("lsblk -n -o TYPE,MOUNTPOINT /dev/%s", dev))
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("disk %s not found,%s", dev, err))
+			continue
+		}
+
+		if !isFreeDisk(out) {
+			errs = append(errs, fmt.Sprintf("disk %s is in using", dev))
+		}
+	}
+
+	if len(errs) > 0 {
+		return buf.String(), errors.Errorf("precheck host %s failed,%s", n.Addr, strings.Join(errs, ";"))
+	}
+
+	return buf.String(), nil
+}
+
+func isSupportedOS(release string) bool {
+	release = strings.ToLower(release)
+
+	for _, name := range supportedOS {
+		if strings.Contains(release, name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// kernelSatisfied returns true if kernel version not lower than minKernelMajor.minKernelMinor
+func kernelSatisfied(kernel string) bool {
+	parts := strings.SplitN(kernel, ".", 3)
+	if len(parts) < 2 {
+		return false
+	}
+
+	major, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return false
+	}
+
+	minor, err := strconv.Atoi(strings.TrimFunc(parts[1], func(r rune) bool { return r < '0' || r > '9' }))
+	if err != nil {
+		return false
+	}
+
+	return major > minKernelMajor || (major == minKernelMajor && minor >= minKernelMinor)
+}
+
+// isFreeDisk returns true if output of 'lsblk -n -o TYPE,MOUNTPOINT' is a disk without partitions and mountpoint.
+func isFreeDisk(lsblk string) bool {
+	lines := strings.Split(strings.TrimSpace(lsblk), "\n")
+	if len(lines) != 1 {
+		// has partitions or holders
+		return false
+	}
+
+	fields := strings.Fields(lines[0])
+
+	return len(fields) == 1 && fields[0] == "disk"
+}
+
+// lastInstallSteps returns the steps succeeded in the latest installation of node.
+func lastInstallSteps(ormer database.TaskOrmer, node string) (map[string]bool, error) {
+	tasks, err := ormer.ListTasks(node, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var last *database.Task
+
+	for i := range tasks {
+		if tasks[i].Related != database.NodeInstall {
+			continue
+		}
+
+		if last == nil || tasks[i].CreatedAt.After(last.CreatedAt) {
+			last = &tasks[i]
+		}
+	}
+
+	if last == nil {
+		return nil, nil
+	}
+
+	steps, err := ormer.ListTaskSteps(last.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	succeeded := make(map[string]bool, len(steps))
+
+	for i := range steps {
+		if steps[i].Succeeded() {
+			succeeded[steps[i].Name] = true
+		}
+	}
+
+	return succeeded, nil
+}
+
+// canReinstall returns true if the node installation is failed or not completed.
+func canReinstall(status int) bool {
+	switch status {
+	case statusNodeImport,
+		statusNodeInstalled,
+		statusNodeInstallFailed,
+		statusNodePrecheckFailed,
+		statusNodeSSHLoginFailed,
+		statusNodeSCPFailed,
+		statusNodeSSHExecFailed,
+		statusNodeRegisterFailed,
+		statusNodeRegisterTimeout:
+		return true
+	}
+
+	return false
+}
+
+// ReinstallNode reruns installation of the node,
+// steps succeeded in previous installation are skipped unless force is true.
+func (m hostManager) ReinstallNode(ctx context.Context, horus, nameOrID string, req structs.NodeReinstallRequest, reg kvstore.Register) (string, error) {
+	n, err := m.dco.GetNode(nameOrID)
+	if err != nil {
+		return "", err
+	}
+
+	if !canReinstall(n.Status) {
+		return "", errors.Errorf("host %s is %s,cannot reinstall", n.Addr, parseNodeStatus(n.Status))
+	}
+
+	nt := NewNodeWithTask(n, req.HDD, req.SSD, req.SSHConfig)
+
+	if !req.Force {
+		nt.succeeded, err = lastInstallSteps(m.dco, n.ID)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	config, err := m.dco.GetSysConfig()
+	if err != nil {
+		return "", err
+	}
+
+	timeout := 280 * time.Second
+	nt.Task.Timeout = timeout
+	nt.timeout = timeout
+
+	nt.Node.Status = statusNodeInstalling
+	nt.Node.Enabled = false
+
+	err = m.dco.InsertTask(nt.Task)
+	if err != nil {
+		return "", err
+	}
+
+	err = m.dco.RegisterNode(&nt.Node, nil)
+	if err != nil {
+		return "", err
+	}
+
+	m.nodes = []nodeWithTask{nt}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+
+	go func() {
+		err := m.nodes[0].distribute(ctx, horus, m.dco, config)
+		if err != nil {
+			logrus.WithField("host", n.Addr).Errorf("reinstall host,%+v", err)
+		}
+	}()
+
+	go m.registerNodesLoop(ctx, cancel, config, reg)
+
+	return nt.Task.ID, nil
+}
@@ -0,0 +1,153 @@
+package resource
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/swarm/garden/database"
+	"golang.org/x/net/context"
+)
+
+func TestKernelSatisfied(t *testing.T) {
+	tests := map[string]bool{
+		"3.10.0-693.el7.x86_64":  true,
+		"4.4.73-5-default":       true,
+		"2.6.32-754.el6.x86_64":  false,
+		"3.2.0":                  false,
+		"3.10+":                  true,
+		"unknown":                false,
+		"":                       false,
+		"5.x":                    false,
+		"3.12.49-11-default.old": true,
+	}
+
+	for kernel, want := range tests {
+		if got := kernelSatisfied(kernel); got != want {
+			t.Errorf("kernel %q,expected %t but got %t", kernel, want, got)
+		}
+	}
+}
+
+func TestPrecheckNode(t *testing.T) {
+	outputs := map[string]string{
+		"cat /etc/os-release":                          `NAME="SLES"` + "\n" + `PRETTY_NAME="SUSE Linux Enterprise Server 12 SP2"`,
+		"uname -r":                                     "4.4.21-69-default",
+		"docker ps -q 2>/dev/null | wc -l":             "0",
+		"lsblk -n -o TYPE,MOUNTPOINT /dev/sdb":         "disk",
+		"lsblk -n -o TYPE,MOUNTPOINT /dev/sdc":         "disk\npart /data",
+		"cat /sys/class/fc_host/host*/port_name":       "0x21000024ff3d6c4e",
+		"lsblk -n -o TYPE,MOUNTPOINT /dev/sdd":         "disk /mnt",
+		"lsblk -n -o TYPE,MOUNTPOINT /dev/nvme0n1":     "disk",
+		"lsblk -n -o TYPE,MOUNTPOINT /dev/notexist123": "",
+	}
+
+	exec := func(cmd string) ([]byte, error) {
+		out, ok := outputs[cmd]
+		if !ok || out == "" {
+			return nil, errors.New("exit status 32")
+		}
+
+		return []byte(out), nil
+	}
+
+	n := database.Node{Addr: "192.168.1.10", Storage: "san01"}
+
+	_, err := precheckNode(exec, n, []string{"sdb"}, []string{"nvme0n1"})
+	if err != nil {
+		t.Errorf("unexpected error,%+v", err)
+	}
+
+	_, err = precheckNode(exec, n, []string{"sdb", "sdc", "notexist123"}, []string{"sdd"})
+	if err == nil {
+		t.Fatal("expected error")
+	}
+
+	for _, dev := range []string{"sdc", "notexist123", "sdd"} {
+		if !strings.Contains(err.Error(), dev) {
+			t.Errorf("expected %s in error,%s", dev, err)
+		}
+	}
+}
+
+type mockStepOrmer struct {
+	steps map[string]database.TaskStep
+}
+
+func (m *mockStepOrmer) SetTaskStep(s database.TaskStep) error {
+	m.steps[s.Name] = s
+
+	return nil
+}
+
+func (m *mockStepOrmer) ListTaskSteps(task string) ([]database.TaskStep, error) {
+	out := make([]database.TaskStep, 0, len(m.steps))
+	for _, s := range m.steps {
+		out = append(out, s)
+	}
+
+	return out, nil
+}
+
+func TestRunSteps(t *testing.T) {
+	calls := make(map[string]int)
+
+	step := func(name string, failed int, errs ...error) installStep {
+		return installStep{
+			name:   name,
+			failed: failed,
+			retry:  len(errs) > 1,
+			run: func() (string, error) {
+				i := calls[name]
+				calls[name]++
+
+				if i < len(errs) {
+					return name, errs[i]
+				}
+
+				return name, nil
+			},
+		}
+	}
+
+	errFailed := errors.New("failed")
+
+	nt := nodeWithTask{
+		succeeded: map[string]bool{installStepPrecheck: true},
+	}
+	ormer := &mockStepOrmer{steps: make(map[string]database.TaskStep)}
+	entry := logrus.WithField("host", "test")
+
+	steps := []installStep{
+		step(installStepPrecheck, statusNodePrecheckFailed, errFailed),
+		step(installStepUploadPackage, statusNodeSCPFailed, errFailed, nil),
+		step(installStepExecInit, statusNodeSSHExecFailed, errFailed),
+		step(installStepUploadCA, statusNodeSCPFailed),
+	}
+
+	status, err := nt.runSteps(context.Background(), ormer, steps, entry)
+	if err != errFailed || status != statusNodeSSHExecFailed {
+		t.Errorf("unexpected status %d,%v", status, err)
+	}
+
+	if calls[installStepPrecheck] != 0 || calls[installStepUploadPackage] != 2 || calls[installStepUploadCA] != 0 {
+		t.Errorf("unexpected calls,%v", calls)
+	}
+
+	want := map[string]string{
+		installStepPrecheck:      database.TaskStepSkipped,
+		installStepUploadPackage: database.TaskStepDone,
+		installStepExecInit:      database.TaskStepFailed,
+	}
+
+	if len(ormer.steps) != len(want) {
+		t.Errorf("expected %d steps but got %d", len(want), len(ormer.steps))
+	}
+
+	for name, status := range want {
+		if ormer.steps[name].Status != status {
+			t.Errorf("step %s,expected %s but got %s", name, status, ormer.steps[name].Status)
+		}
+	}
+}
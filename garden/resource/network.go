@@ -2,6 +2,8 @@ package resource
 
 import (
 	"github.com/docker/swarm/garden/database"
+	"github.com/docker/swarm/garden/resource/alloc"
+	"github.com/docker/swarm/garden/structs"
 	"github.com/docker/swarm/garden/utils"
 	"github.com/pkg/errors"
 )
@@ -52,3 +54,133 @@ func (nw Networking) AddNetworking(start, end, gateway, networkingID string, vla
 
 	return len(ips), nil
 }
+
+// ReserveIPs reserve IPs of the networking for the service,
+// release the reservations if service is "".
+func (nw Networking) ReserveIPs(networkingID, service string, ips []string) error {
+	list, err := nw.nwo.ListIPByNetworking(networkingID)
+	if err != nil {
+		return err
+	}
+
+	in := make([]uint32, 0, len(ips))
+
+	for i := range ips {
+		n := utils.IPToUint32(ips[i])
+		exist := false
+
+		for l := range list {
+			if list[l].IPAddr == n {
+				exist = true
+				break
+			}
+		}
+
+		if !exist {
+			return errors.Errorf("IP %s is not in networking %s", ips[i], networkingID)
+		}
+
+		in = append(in, n)
+	}
+
+	return nw.nwo.SetIPReserved(in, networkingID, service)
+}
+
+// Usage returns utilization of networkings,
+// threshold is the minimum free IPs before alert,use alloc.FreeIPThreshold if threshold<=0.
+func (nw Networking) Usage(networkingID string, threshold int) ([]structs.NetworkingUsage, error) {
+	var (
+		list []database.IP
+		err  error
+	)
+
+	if networkingID == "" {
+		list, err = nw.nwo.ListIPs()
+	} else {
+		list, err = nw.nwo.ListIPByNetworking(networkingID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return NetworkingsUsage(list, threshold), nil
+}
+
+func countIPUsage(usage *structs.IPUsage, ip database.IP) {
+	usage.Total++
+
+	switch {
+	case ip.UnitID != "":
+		usage.Allocated++
+	case !ip.Enabled:
+		usage.Disabled++
+	case ip.Reserved != "":
+		usage.Reserved++
+	default:
+		usage.Free++
+	}
+}
+
+// NetworkingsUsage counts []IP group by networking,VLAN and gateway.
+func NetworkingsUsage(list []database.IP, threshold int) []structs.NetworkingUsage {
+	if threshold <= 0 {
+		threshold = alloc.FreeIPThreshold
+	}
+
+	out := make([]structs.NetworkingUsage, 0, 5)
+
+	for _, ip := range list {
+		n := -1
+		for i := range out {
+			if out[i].Networking == ip.Networking {
+				n = i
+				break
+			}
+		}
+
+		if n < 0 {
+			out = append(out, structs.NetworkingUsage{
+				Networking: ip.Networking,
+				Threshold:  threshold,
+				VLANs:      make([]structs.VLANUsage, 0, 1),
+				Gateways:   make([]structs.GatewayUsage, 0, 1),
+			})
+			n = len(out) - 1
+		}
+
+		nu := &out[n]
+		countIPUsage(&nu.IPUsage, ip)
+
+		v := -1
+		for i := range nu.VLANs {
+			if nu.VLANs[i].VLAN == ip.VLAN {
+				v = i
+				break
+			}
+		}
+		if v < 0 {
+			nu.VLANs = append(nu.VLANs, structs.VLANUsage{VLAN: ip.VLAN})
+			v = len(nu.VLANs) - 1
+		}
+		countIPUsage(&nu.VLANs[v].IPUsage, ip)
+
+		g := -1
+		for i := range nu.Gateways {
+			if nu.Gateways[i].Gateway == ip.Gateway {
+				g = i
+				break
+			}
+		}
+		if g < 0 {
+			nu.Gateways = append(nu.Gateways, structs.GatewayUsage{Gateway: ip.Gateway})
+			g = len(nu.Gateways) - 1
+		}
+		countIPUsage(&nu.Gateways[g].IPUsage, ip)
+	}
+
+	for i := range out {
+		out[i].Alert = out[i].Free < threshold
+	}
+
+	return out
+}
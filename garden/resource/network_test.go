@@ -0,0 +1,44 @@
+package resource
+
+import (
+	"testing"
+
+	"github.com/docker/swarm/garden/database"
+)
+
+func TestNetworkingsUsage(t *testing.T) {
+	ips := []database.IP{
+		{IPAddr: 1, Networking: "net001", VLAN: 10, Gateway: "192.168.1.1", Enabled: true, UnitID: "unit001"},
+		{IPAddr: 2, Networking: "net001", VLAN: 10, Gateway: "192.168.1.1", Enabled: false},
+		{IPAddr: 3, Networking: "net001", VLAN: 20, Gateway: "192.168.2.1", Enabled: true, Reserved: "service001"},
+		{IPAddr: 4, Networking: "net001", VLAN: 20, Gateway: "192.168.2.1", Enabled: true},
+		{IPAddr: 5, Networking: "net002", VLAN: 30, Gateway: "192.168.3.1", Enabled: true},
+		{IPAddr: 6, Networking: "net002", VLAN: 30, Gateway: "192.168.3.1", Enabled: true},
+	}
+
+	out := NetworkingsUsage(ips, 2)
+	if len(out) != 2 {
+		t.Fatalf("expected 2 networkings but got %d", len(out))
+	}
+
+	nu := out[0]
+	if nu.Total != 4 || nu.Allocated != 1 || nu.Disabled != 1 || nu.Reserved != 1 || nu.Free != 1 {
+		t.Errorf("unexpected usage %+v", nu.IPUsage)
+	}
+
+	if !nu.Alert {
+		t.Error("expected alert,free IPs less than threshold")
+	}
+
+	if len(nu.VLANs) != 2 || nu.VLANs[1].VLAN != 20 || nu.VLANs[1].Free != 1 {
+		t.Errorf("unexpected VLANs usage %+v", nu.VLANs)
+	}
+
+	if len(nu.Gateways) != 2 || nu.Gateways[0].Allocated != 1 {
+		t.Errorf("unexpected Gateways usage %+v", nu.Gateways)
+	}
+
+	if out[1].Free != 2 || out[1].Alert {
+		t.Errorf("unexpected usage %+v", out[1])
+	}
+}
@@ -38,6 +38,8 @@ const (
 	statusNodeRegisterFailed
 	statusNodeRegisterTimeout
 	statusNodeDeregisted
+	statusNodeQuarantined    = database.NodeStatusQuarantined
+	statusNodePrecheckFailed = iota
 )
 
 // parseNodeStatus returns the meaning of the number corresponding
@@ -59,6 +61,10 @@ func parseNodeStatus(status int) string {
 		return "disable"
 	case statusNodeDeregisted:
 		return "deregister"
+	case statusNodeQuarantined:
+		return "quarantined"
+	case statusNodePrecheckFailed:
+		return "precheck failed"
 	default:
 	}
 
@@ -161,6 +167,22 @@ type nodeWithTask struct {
 	Node    database.Node
 	Task    database.Task
 	timeout time.Duration
+	// install steps succeeded in previous installation
+	succeeded map[string]bool
+}
+
+// newSSHClient login host by private key if set,else by password.
+func newSSHClient(host string, config structs.SSHConfig, timeout time.Duration) (scplib.ScpClient, error) {
+	addr := host
+	if config.Port > 0 {
+		addr = net.JoinHostPort(host, strconv.Itoa(config.Port))
+	}
+
+	if len(config.PrivateKey) > 0 {
+		return scplib.NewClientByPrivateKey(addr, config.Username, config.PrivateKey, timeout)
+	}
+
+	return scplib.NewScpClient(addr, config.Username, config.Password, timeout)
 }
 
 // NewNodeWithTask node with task and ssh login config,prepare for install host
@@ -223,7 +245,7 @@ func (m hostManager) InstallNodes(ctx context.Context, horus string, reg kvstore
 	return nil
 }
 
-func (nt *nodeWithTask) distribute(ctx context.Context, horus string, ormer database.NodeIface, config database.SysConfig) (err error) {
+func (nt *nodeWithTask) distribute(ctx context.Context, horus string, ormer installOrmer, config database.SysConfig) (err error) {
 	entry := logrus.WithFields(logrus.Fields{
 		"host": nt.Node.Addr,
 	})
@@ -270,12 +292,7 @@ func (nt *nodeWithTask) distribute(ctx context.Context, horus string, ormer data
 	})
 
 	if nt.client == nil {
-		addr := nt.Node.Addr
-		if nt.config.Port > 0 {
-			addr = net.JoinHostPort(nt.Node.Addr, strconv.Itoa(nt.config.Port))
-		}
-
-		nt.client, err = scplib.NewScpClient(addr, nt.config.Username, nt.config.Password, nt.timeout)
+		nt.client, err = newSSHClient(nt.Node.Addr, nt.config, nt.timeout)
 		if err != nil {
 			entry.WithError(err).Error("ssh dial error")
 
@@ -286,60 +303,19 @@ func (nt *nodeWithTask) distribute(ctx context.Context, horus string, ormer data
 	}
 	defer nt.client.Close()
 
-	select {
-	default:
-	case <-ctx.Done():
-		return ctx.Err()
-	}
-
-	if err := nt.client.UploadDir(config.Destination, config.SourceDir); err != nil {
-		entry.WithError(err).Error("SSH upload dir:" + config.SourceDir)
-
-		if err := nt.client.UploadDir(config.Destination, config.SourceDir); err != nil {
-			entry.WithError(err).Error("SSH upload dir twice:" + config.SourceDir)
-
-			nodeState = statusNodeSCPFailed
-			return err
-		}
-	}
-
-	_, filename, _ := config.DestPath()
-
-	if err := nt.client.Upload(config.Registry.CACert, filename, 0644); err != nil {
-		entry.WithError(err).Error("SSH upload file:" + filename)
-
-		if err := nt.client.Upload(config.Registry.CACert, filename, 0644); err != nil {
-			entry.WithError(err).Error("SSH upload file twice:" + filename)
-
-			nodeState = statusNodeSCPFailed
-			return err
-		}
-	}
-
-	select {
-	default:
-	case <-ctx.Done():
-		return errors.WithStack(ctx.Err())
-	}
-
-	entry.Debug("SSH Exec:", script)
-
-	out, err := nt.client.Exec(script)
+	nodeState, err = nt.runSteps(ctx, ormer, nt.installSteps(script, config), entry)
 	if err != nil {
-		entry.WithError(err).Errorf("exec remote command:'%s',output:%s", script, out)
-
-		nodeState = statusNodeSSHExecFailed
 		return err
 	}
 
-	entry.Infof("SSH remote PKG install successed! output:\n%s", out)
+	entry.Info("SSH remote PKG install successed!")
 
 	return nil
 }
 
 // CA,script,error
 func (nt *nodeWithTask) modifyProfile(horus string, config *database.SysConfig) (string, error) {
-	horusIP, horusPort, err := net.SplitHostPort(horus)
+	horusIP, horusPort, err := splitHorusAddr(horus)
 	if err != nil {
 		return "", errors.Wrap(err, "Horus addr:"+horus)
 	}
@@ -594,7 +570,7 @@ func (m hostManager) removeNode(ID string) error {
 }
 
 // RemoveNode
-func (m hostManager) RemoveNode(ctx context.Context, port, horus, nameOrID, user, password string, force bool, timeout time.Duration, reg kvstore.Register) error {
+func (m hostManager) RemoveNode(ctx context.Context, horus, nameOrID string, ssh structs.SSHConfig, force bool, timeout time.Duration, reg kvstore.Register) error {
 	node, err := m.getNode(nameOrID)
 	if err != nil {
 		if database.IsNotFound(err) {
@@ -621,13 +597,18 @@ func (m hostManager) RemoveNode(ctx context.Context, port, horus, nameOrID, user
 		return m.removeNode(node.node.ID)
 	}
 
+	port := ""
+	if ssh.Port > 0 {
+		port = strconv.Itoa(ssh.Port)
+	}
+
 	err = reg.DeregisterService(ctx, structs.ServiceDeregistration{
 		Type:     "hosts",
 		Key:      node.node.ID,
 		Addr:     node.node.Addr,
 		Port:     port,
-		User:     user,
-		Password: password,
+		User:     ssh.Username,
+		Password: ssh.Password,
 	}, true)
 	if err != nil {
 		return err
@@ -638,11 +619,7 @@ func (m hostManager) RemoveNode(ctx context.Context, port, horus, nameOrID, user
 		return err
 	}
 
-	addr := node.node.Addr
-	if port != "" {
-		addr = net.JoinHostPort(node.node.Addr, port)
-	}
-	client, err := scplib.NewScpClient(addr, user, password, timeout)
+	client, err := newSSHClient(node.node.Addr, ssh, timeout)
 	if err != nil {
 		return err
 	}
@@ -664,8 +641,17 @@ func (m hostManager) RemoveNode(ctx context.Context, port, horus, nameOrID, user
 	return err
 }
 
+// splitHorusAddr returns empty if Horus is not in use
+func splitHorusAddr(horus string) (string, string, error) {
+	if horus == "" {
+		return "", "", nil
+	}
+
+	return net.SplitHostPort(horus)
+}
+
 func (n *Node) nodeClean(ctx context.Context, client scplib.ScpClient, horus string, config database.SysConfig) error {
-	horusIP, horusPort, err := net.SplitHostPort(horus)
+	horusIP, horusPort, err := splitHorusAddr(horus)
 	if err != nil {
 		return errors.Wrap(err, "check Horus Addr:"+horus)
 	}
@@ -14,6 +14,7 @@ type engineCluster interface {
 type nodeOrmer interface {
 	lister
 	database.NodeOrmer
+	database.TaskOrmer
 }
 
 type hostManager struct {
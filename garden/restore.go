@@ -1,11 +1,16 @@
 package garden
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"strings"
 
+	"github.com/docker/swarm/garden/backup"
 	"github.com/docker/swarm/garden/database"
 	"github.com/docker/swarm/garden/structs"
 	"github.com/docker/swarm/garden/tasklock"
+	"github.com/docker/swarm/garden/utils"
 	"github.com/pkg/errors"
 	"golang.org/x/net/context"
 )
@@ -42,63 +47,111 @@ func (u unit) restore(ctx context.Context, path, backupDir string, cmds structs.
 // UnitRestore resotore an unit volume data by the assigned backup file.
 func (svc *Service) UnitRestore(ctx context.Context, assigned []string, path string, async bool) (string, error) {
 	do := func() error {
-		var units []*unit
-
-		switch len(assigned) {
-		case 0:
-			return errors.New("restore unit without assigned")
-		case 1:
-			u, err := svc.getUnit(assigned[0])
-			if err != nil {
-				return err
-			}
-			units = []*unit{u}
+		return svc.restoreUnits(ctx, assigned, path)
+	}
 
-		default:
-			out, err := svc.getUnits()
-			if err != nil {
-				return err
-			}
+	t := database.NewTask(svc.Name(), database.UnitRestoreTask, svc.ID(), strings.Join(assigned, "&&"), nil, 300)
+	tl := tasklock.NewServiceTask(database.UnitRestoreTask, svc.ID(), svc.so, &t,
+		statusServiceRestoring,
+		statusServiceRestored,
+		statusServiceRestoreFailed)
 
-			units = make([]*unit, 0, len(assigned))
+	err := tl.Run(isnotInProgress, do, async)
 
-			for i := range assigned {
-				u := getUnit(out, assigned[i])
-				if u == nil {
-					return errors.Errorf("%s isnot belongs to Service %s", assigned[i], svc.Name())
-				}
+	return t.ID, err
+}
 
-				units = append(units, u)
-			}
-		}
+func (svc *Service) restoreUnits(ctx context.Context, assigned []string, path string) error {
+	var units []*unit
 
-		sys, err := svc.so.GetSysConfig()
+	switch len(assigned) {
+	case 0:
+		return errors.New("restore unit without assigned")
+	case 1:
+		u, err := svc.getUnit(assigned[0])
 		if err != nil {
 			return err
 		}
+		units = []*unit{u}
 
-		cmds, err := svc.generateUnitsCmd(ctx)
+	default:
+		out, err := svc.getUnits()
 		if err != nil {
 			return err
 		}
 
-		for _, u := range units {
-			err := u.restore(ctx, path, sys.BackupDir, cmds)
-			if err != nil {
-				return err
+		units = make([]*unit, 0, len(assigned))
+
+		for i := range assigned {
+			u := getUnit(out, assigned[i])
+			if u == nil {
+				return errors.Errorf("%s isnot belongs to Service %s", assigned[i], svc.Name())
 			}
+
+			units = append(units, u)
 		}
+	}
 
+	sys, err := svc.so.GetSysConfig()
+	if err != nil {
 		return err
 	}
 
-	t := database.NewTask(svc.Name(), database.UnitRestoreTask, svc.ID(), strings.Join(assigned, "&&"), nil, 300)
-	tl := tasklock.NewServiceTask(database.UnitRestoreTask, svc.ID(), svc.so, &t,
-		statusServiceRestoring,
-		statusServiceRestored,
-		statusServiceRestoreFailed)
+	if backup.IsRemote(path) {
+		staged, clean, err := svc.stageBackupFile(ctx, units[0], path, sys)
+		if err != nil {
+			return err
+		}
+		defer clean()
 
-	err := tl.Run(isnotInProgress, do, async)
+		path = staged
+	}
 
-	return t.ID, err
+	cmds, err := svc.generateUnitsCmd(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, u := range units {
+		err := u.restore(ctx, path, sys.BackupDir, cmds)
+		if err != nil {
+			return err
+		}
+	}
+
+	return err
+}
+
+// stageBackupFile downloads the remote backup file into the NFS backup dir of the unit host,
+// returns the path in the container and the func to remove the staged file.
+func (svc *Service) stageBackupFile(ctx context.Context, u *unit, location string, sys database.SysConfig) (string, func(), error) {
+	target, err := backup.NewTarget(sys, nil, svc.secret)
+	if err != nil {
+		return "", nil, err
+	}
+
+	node, err := svc.so.GetNode(u.u.EngineID)
+	if err != nil {
+		return "", nil, err
+	}
+
+	mounts, err := ioutil.ReadFile("/proc/mounts")
+	if err != nil {
+		return "", nil, errors.WithStack(err)
+	}
+
+	path := filepath.Join(sys.BackupDir, ".restore", utils.Generate8UUID(), filepath.Base(location))
+	local := backup.LocalPath(path, sys.BackupDir, node.NFS.Addr+":"+node.NFS.Dir, string(mounts))
+
+	clean := func() {
+		os.RemoveAll(filepath.Dir(local))
+	}
+
+	err = target.Download(ctx, location, local)
+	if err != nil {
+		clean()
+		return "", nil, err
+	}
+
+	return path, clean, nil
 }
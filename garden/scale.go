@@ -13,7 +13,6 @@ import (
 	"github.com/docker/swarm/garden/kvstore"
 	"github.com/docker/swarm/garden/resource/alloc"
 	"github.com/docker/swarm/garden/structs"
-	"github.com/docker/swarm/garden/tasklock"
 	"github.com/docker/swarm/garden/utils"
 	"github.com/pkg/errors"
 	"golang.org/x/net/context"
@@ -76,12 +75,12 @@ func (gd *Garden) Scale(ctx context.Context, svc *Service, actor alloc.Allocator
 			containers := svc.cluster.Containers()
 			out := sortUnitsByContainers(units, containers)
 
-			remove = out[:rn]
+			remove = out[:-n]
 		}
 
 		resp.Remove = make([]structs.UnitNameID, 0, len(remove))
 		for i := range remove {
-			resp.Add = append(resp.Add, structs.UnitNameID{
+			resp.Remove = append(resp.Remove, structs.UnitNameID{
 				ID:   remove[i].u.ID,
 				Name: remove[i].u.Name,
 			})
@@ -94,6 +93,14 @@ func (gd *Garden) Scale(ctx context.Context, svc *Service, actor alloc.Allocator
 		}
 
 		if len(units) > req.Arch.Replicas {
+			if svc.isSharding() {
+				// migrate slots and replicas off the removing units
+				err = svc.composeWithout(ctx, req.Arch, remove)
+				if err != nil {
+					return err
+				}
+			}
+
 			err = svc.scaleDown(ctx, remove, gd.KVClient())
 		} else {
 			_, err = gd.scaleUp(ctx, svc, actor, serviceScaleRequest{
@@ -120,8 +127,11 @@ func (gd *Garden) Scale(ctx context.Context, svc *Service, actor alloc.Allocator
 			}
 		}
 
-		if req.Compose {
+		if req.Compose || svc.isSharding() {
 			err = svc.Compose(ctx)
+			if err == nil {
+				svc.SyncMonitorTargets(ctx, gd.KVClient())
+			}
 		}
 
 		return err
@@ -129,7 +139,7 @@ func (gd *Garden) Scale(ctx context.Context, svc *Service, actor alloc.Allocator
 
 	task := database.NewTask(svc.Name(), database.ServiceScaleTask, svc.ID(), fmt.Sprintf("replicas=%d", req.Arch.Replicas), nil, 300)
 
-	sl := tasklock.NewServiceTask(database.ServiceScaleTask, svc.ID(), svc.so, &task,
+	sl := svc.serviceTask(ctx, database.ServiceScaleTask, &task,
 		statusServiceScaling, statusServiceScaled, statusServiceScaleFailed)
 
 	err = sl.Run(isnotInProgress, scale, async)
@@ -228,6 +238,11 @@ func (gd *Garden) scaleAllocation(ctx context.Context, svc *Service, refer strin
 		return adds, nil, err
 	}
 
+	err = svc.setPlacement(ctx, refer)
+	if err != nil {
+		return adds, nil, err
+	}
+
 	pendings, err := gd.allocation(ctx, actor, svc, add, vr, nr)
 	if err != nil {
 		return adds, nil, err
@@ -113,6 +113,12 @@ func NewClientByPublicKeys(addr, user, rsa string, timeout time.Duration) (ScpCl
 		return nil, errors.Wrap(err, "unable to read private key")
 	}
 
+	return NewClientByPrivateKey(addr, user, key, timeout)
+}
+
+// NewClientByPrivateKey returns ScpClient,ssh client authenticate with
+// the unencrypted PEM-encoded private key.
+func NewClientByPrivateKey(addr, user string, key []byte, timeout time.Duration) (ScpClient, error) {
 	// Create the Signer for this private key.
 	signer, err := ssh.ParsePrivateKey(key)
 	if err != nil {
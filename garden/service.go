@@ -11,6 +11,7 @@ import (
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/volume"
 	"github.com/docker/swarm/cluster"
+	"github.com/docker/swarm/garden/backup"
 	"github.com/docker/swarm/garden/database"
 	"github.com/docker/swarm/garden/kvstore"
 	"github.com/docker/swarm/garden/structs"
@@ -29,6 +30,7 @@ type Service struct {
 	svc     *database.Service
 	spec    *structs.ServiceSpec
 	cluster cluster.Cluster
+	secret  backup.SecretKeyFunc // the S3 secret key of the backup target
 
 	options scheduleOption
 }
@@ -427,8 +429,12 @@ func ConvertServiceInfo(cluster cluster.Cluster, info database.ServiceInfo, cont
 		Arch:    arch,
 		Service: convertService(info.Service),
 		Require: &scheOpts.Require,
+		Spread:  scheOpts.Spread,
 		Units:   units,
 		Options: opts,
+
+		Affinities: scheOpts.Affinities,
+		Exec:       scheOpts.Exec,
 	}
 }
 
@@ -752,7 +758,7 @@ func (svc *Service) UpdateUnitsConfigs(ctx context.Context,
 		return err
 	}
 
-	sl := tasklock.NewServiceTask(database.ServiceUpdateConfigTask, svc.ID(), svc.so, task,
+	sl := svc.serviceTask(ctx, database.ServiceUpdateConfigTask, task,
 		statusServiceConfigUpdating, statusServiceConfigUpdated, statusServiceConfigUpdateFailed)
 
 	return sl.Run(isnotInProgress, update, async)
@@ -1142,7 +1148,12 @@ func (svc Service) removeUnitsConfigs(ctx context.Context, kvc kvstore.Store) er
 		return nil
 	}
 
-	return kvc.DeleteKVTree(ctx, "/configs/"+svc.ID())
+	err := kvc.DeleteKVTree(ctx, "/configs/"+svc.ID())
+	if err != nil {
+		return err
+	}
+
+	return kvc.DeleteKVTree(ctx, serviceLinkKV+svc.ID())
 }
 
 // Compose call plugin compose
@@ -1163,6 +1174,68 @@ func (svc *Service) Compose(ctx context.Context) error {
 	return svc.pc.ServiceCompose(ctx, *spec)
 }
 
+// Topology call plugin to probe the current role of units,
+// returns it next to the desired architecture.
+func (svc *Service) Topology(ctx context.Context) (structs.ServiceTopology, error) {
+	spec, err := svc.RefreshSpec()
+	if err != nil {
+		return structs.ServiceTopology{}, err
+	}
+
+	if svc.spec != nil {
+		spec.Options = svc.spec.Options
+	}
+
+	out := structs.ServiceTopology{
+		ID:   spec.ID,
+		Name: spec.Name,
+		Arch: spec.Arch,
+	}
+
+	out.Units, err = svc.pc.ServiceTopology(ctx, *spec)
+
+	return out, err
+}
+
+// isSharding returns true if the service is a sharding cluster,
+// which is composed online when scaling.
+func (svc *Service) isSharding() bool {
+	return svc.spec != nil && svc.spec.Arch.Mode == "sharding_replication"
+}
+
+// composeWithout call plugin compose with the arch and the units except remove,
+// the sharding cluster moves data off the units before they are removed.
+func (svc *Service) composeWithout(ctx context.Context, arch structs.Arch, remove []*unit) error {
+	var opts map[string]interface{}
+
+	if svc.spec != nil {
+		opts = svc.spec.Options
+	}
+
+	spec, err := svc.RefreshSpec()
+	if err != nil {
+		return err
+	}
+
+	out := *spec
+	out.Options = opts
+	out.Arch = arch
+	out.Units = make([]structs.UnitSpec, 0, len(spec.Units))
+
+loop:
+	for i := range spec.Units {
+		for j := range remove {
+			if remove[j].u.ID == spec.Units[i].ID {
+				continue loop
+			}
+		}
+
+		out.Units = append(out.Units, spec.Units[i])
+	}
+
+	return svc.pc.ServiceCompose(ctx, out)
+}
+
 // Image returns Image,query from db.
 func (svc Service) Image() (database.Image, error) {
 
@@ -0,0 +1,290 @@
+package garden
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/docker/swarm/garden/database"
+	"github.com/docker/swarm/garden/structs"
+	"github.com/docker/swarm/scheduler/node"
+	"github.com/pkg/errors"
+	"golang.org/x/net/context"
+)
+
+// topologyLabelPrefix prefixes the host topology labels in node labels
+const topologyLabelPrefix = "topology."
+
+// placement is the units of service already placed on hosts,
+// set before scaling and migration,not persisted.
+type placement struct {
+	engines       []string // engine ID of the placed units
+	masterEngines []string // engine ID of the placed master units
+	masters       int      // number of master units to place,1 if migrating a master,otherwise 0
+}
+
+func ruleMax(r structs.SpreadRule) int {
+	if r.Max > 0 {
+		return r.Max
+	}
+
+	return 1
+}
+
+func ruleString(r structs.SpreadRule) string {
+	if r.Masters {
+		return fmt.Sprintf("%s:max %d masters per domain", r.Label, ruleMax(r))
+	}
+
+	return fmt.Sprintf("%s:max %d units per domain", r.Label, ruleMax(r))
+}
+
+// spreadPolicy enforces the spread rules in node selection,
+// the units placed first are the masters,as many as masters.
+// Compose elects the masters by weight,so masters are known only when migrating a master unit,
+// the roles are from the service topology,see setPlacement.
+type spreadPolicy struct {
+	rules         []structs.SpreadRule
+	masters       int
+	placed        []map[string]string // topology labels of hosts of the placed units
+	placedMasters []map[string]string // topology labels of hosts of the placed masters
+}
+
+func newSpreadPolicy(iface database.NodeIface, opts scheduleOption) (*spreadPolicy, error) {
+	if len(opts.Spread) == 0 {
+		return nil, nil
+	}
+
+	cache := make(map[string]map[string]string)
+
+	labels := func(engines []string) ([]map[string]string, error) {
+		out := make([]map[string]string, 0, len(engines))
+
+		for _, id := range engines {
+			if l, ok := cache[id]; ok {
+				out = append(out, l)
+				continue
+			}
+
+			n, err := iface.GetNode(id)
+			if err != nil {
+				return nil, err
+			}
+
+			cache[id] = n.TopologyLabels()
+			out = append(out, cache[id])
+		}
+
+		return out, nil
+	}
+
+	sp := &spreadPolicy{
+		rules:   opts.Spread,
+		masters: opts.placement.masters,
+	}
+
+	var err error
+
+	sp.placed, err = labels(opts.placement.engines)
+	if err != nil {
+		return nil, err
+	}
+
+	sp.placedMasters, err = labels(opts.placement.masterEngines)
+	if err != nil {
+		return nil, err
+	}
+
+	return sp, nil
+}
+
+func countDomain(placed []map[string]string, used []*node.Node, label, domain string) int {
+	count := 0
+
+	for i := range placed {
+		if placed[i][label] == domain {
+			count++
+		}
+	}
+
+	for i := range used {
+		if used[i].Labels[topologyLabelPrefix+label] == domain {
+			count++
+		}
+	}
+
+	return count
+}
+
+// match returns true if n satisfies all of the rules,used are the nodes selected in this allocation.
+func (sp *spreadPolicy) match(n *node.Node, used []*node.Node) bool {
+	if sp == nil {
+		return true
+	}
+
+	for _, r := range sp.rules {
+		placed := sp.placed
+
+		if r.Masters {
+			if len(used) >= sp.masters {
+				// masters are placed,n is for a slave
+				continue
+			}
+
+			placed = sp.placedMasters
+		}
+
+		domain := n.Labels[topologyLabelPrefix+r.Label]
+		if domain == "" {
+			return false
+		}
+
+		if countDomain(placed, used, r.Label, domain) >= ruleMax(r) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// precheck returns error if the candidates cannot satisfy the rules for replicas units.
+func (sp *spreadPolicy) precheck(candidates []*node.Node, replicas int) error {
+	if sp == nil {
+		return nil
+	}
+
+	errs := make([]string, 0, len(sp.rules))
+
+	for _, r := range sp.rules {
+		units, placed := replicas, sp.placed
+
+		if r.Masters {
+			units, placed = sp.masters, sp.placedMasters
+			if units > replicas {
+				units = replicas
+			}
+		}
+
+		if units == 0 {
+			continue
+		}
+
+		nodes := make(map[string]int)
+		for i := range candidates {
+			if domain := candidates[i].Labels[topologyLabelPrefix+r.Label]; domain != "" {
+				nodes[domain]++
+			}
+		}
+
+		max, slots := ruleMax(r), 0
+		domains := make([]string, 0, len(nodes))
+
+		for domain, n := range nodes {
+			free := max - countDomain(placed, nil, r.Label, domain)
+			if free > n {
+				free = n
+			}
+			if free > 0 {
+				slots += free
+				domains = append(domains, domain)
+			}
+		}
+
+		if slots < units {
+			sort.Strings(domains)
+
+			errs = append(errs, fmt.Sprintf("spread rule '%s' cannot be satisfied,%d units to place but %d slots available in %d '%s' domains of candidates %s",
+				ruleString(r), units, slots, len(domains), r.Label, domains))
+		}
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+
+	return errors.New(strings.Join(errs, "\n"))
+}
+
+func (sp *spreadPolicy) String() string {
+	if sp == nil {
+		return ""
+	}
+
+	rules := make([]string, len(sp.rules))
+	for i := range sp.rules {
+		rules[i] = ruleString(sp.rules[i])
+	}
+
+	return strings.Join(rules, ",")
+}
+
+// setPlacement sets the placed units of service for spread rules,
+// refer is the unit being migrated,excluded from the placed units,
+// master rules apply to the new unit if refer is a master.
+func (svc *Service) setPlacement(ctx context.Context, refer string) error {
+	if len(svc.options.Spread) == 0 {
+		return nil
+	}
+
+	units, err := svc.getUnits()
+	if err != nil {
+		return err
+	}
+
+	var (
+		old    *unit
+		others = make([]*unit, 0, len(units))
+		p      = placement{}
+	)
+
+	if refer != "" {
+		old = getUnit(units, refer)
+	}
+
+	for i := range units {
+		if units[i] == old || units[i].u.EngineID == "" {
+			continue
+		}
+
+		others = append(others, units[i])
+		p.engines = append(p.engines, units[i].u.EngineID)
+	}
+
+	hasMasters := false
+	for i := range svc.options.Spread {
+		if svc.options.Spread[i].Masters {
+			hasMasters = true
+		}
+	}
+
+	if old != nil && hasMasters && svc.spec != nil && hasRoles(svc.spec.Arch) {
+		topology, err := svc.Topology(ctx)
+		if err != nil {
+			return errors.WithMessage(err, "unit roles for spread rules")
+		}
+
+		role, err := unitRole(topology.Units, old.u.ID)
+		if err != nil {
+			return errors.WithMessage(err, "unit roles for spread rules")
+		}
+
+		if role == "master" {
+			p.masters = 1
+
+			for i := range others {
+				role, err := unitRole(topology.Units, others[i].u.ID)
+				if err != nil {
+					return errors.WithMessage(err, "unit roles for spread rules")
+				}
+
+				if role == "master" {
+					p.masterEngines = append(p.masterEngines, others[i].u.EngineID)
+				}
+			}
+		}
+	}
+
+	svc.options.placement = p
+
+	return nil
+}
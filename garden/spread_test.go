@@ -0,0 +1,116 @@
+package garden
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/docker/swarm/garden/structs"
+	"github.com/docker/swarm/scheduler/node"
+)
+
+func newTopologyNode(id, rack, zone string) *node.Node {
+	return &node.Node{
+		ID: id,
+		Labels: map[string]string{
+			topologyLabelPrefix + "rack": rack,
+			topologyLabelPrefix + "zone": zone,
+		},
+	}
+}
+
+func TestSpreadPolicyMatch(t *testing.T) {
+	sp := &spreadPolicy{
+		rules: []structs.SpreadRule{
+			{Label: "rack"},
+			{Label: "zone", Masters: true},
+		},
+		masters: 2,
+	}
+
+	n1 := newTopologyNode("n1", "r1", "z1")
+	n2 := newTopologyNode("n2", "r1", "z2")
+	n3 := newTopologyNode("n3", "r2", "z1")
+	n4 := newTopologyNode("n4", "r3", "z2")
+
+	if !sp.match(n1, nil) {
+		t.Error("expected n1 matched")
+	}
+
+	used := []*node.Node{n1}
+
+	if sp.match(n2, used) {
+		t.Error("expected n2 not matched,same rack with n1")
+	}
+	if sp.match(n3, used) {
+		t.Error("expected n3 not matched,same zone with master n1")
+	}
+	if !sp.match(n4, used) {
+		t.Error("expected n4 matched")
+	}
+
+	// masters are placed,zone rule doesn't apply to slaves
+	used = append(used, n4)
+	if !sp.match(n3, used) {
+		t.Error("expected n3 matched for slave")
+	}
+
+	if sp.match(&node.Node{ID: "n5", Labels: map[string]string{}}, nil) {
+		t.Error("expected unlabeled node not matched")
+	}
+
+	// placed units of scaling and migration
+	sp.masters = 0
+	sp.placed = []map[string]string{{"rack": "r3", "zone": "z2"}}
+	if sp.match(n4, nil) {
+		t.Error("expected n4 not matched,same rack with placed unit")
+	}
+
+	var nilPolicy *spreadPolicy
+	if !nilPolicy.match(n1, used) {
+		t.Error("expected matched without spread rules")
+	}
+}
+
+func TestSpreadPolicyPrecheck(t *testing.T) {
+	candidates := []*node.Node{
+		newTopologyNode("n1", "r1", "z1"),
+		newTopologyNode("n2", "r1", "z1"),
+		newTopologyNode("n3", "r2", "z1"),
+		newTopologyNode("n4", "r2", "z2"),
+	}
+
+	sp := &spreadPolicy{
+		rules: []structs.SpreadRule{{Label: "rack"}},
+	}
+
+	if err := sp.precheck(candidates, 2); err != nil {
+		t.Errorf("unexpected error,%s", err)
+	}
+
+	err := sp.precheck(candidates, 3)
+	if err == nil {
+		t.Fatal("expected error with 3 units in 2 racks")
+	}
+	if !strings.Contains(err.Error(), "rack:max 1 units per domain") ||
+		!strings.Contains(err.Error(), "[r1 r2]") {
+		t.Errorf("unexpected error,%s", err)
+	}
+
+	sp.rules[0].Max = 2
+	if err := sp.precheck(candidates, 4); err != nil {
+		t.Errorf("unexpected error,%s", err)
+	}
+
+	sp.placed = []map[string]string{{"rack": "r1"}}
+	if err := sp.precheck(candidates, 4); err == nil {
+		t.Error("expected error,r1 is occupied by placed unit")
+	}
+
+	sp = &spreadPolicy{
+		rules:   []structs.SpreadRule{{Label: "zone", Masters: true}},
+		masters: 3,
+	}
+	if err := sp.precheck(candidates, 4); err == nil {
+		t.Error("expected error with 3 masters in 2 zones")
+	}
+}
@@ -24,6 +24,9 @@ const (
 	statusServiceComposing                          // 20
 	statusServiceDeleting                           // 21
 	statusServiceDeploying                          // 22
+	statusServiceSwitching                          // 23
+	statusServiceRestoreDrilling                    // 24
+	statusServiceManifestApplying                   // 25
 
 	_ing    = 0
 	_failed = 1
@@ -90,6 +93,15 @@ const (
 
 	statusServiceDeployed     = statusServiceDeploying + _done
 	statusServiceDeployFailed = statusServiceDeploying + _failed
+
+	statusServiceSwitched     = statusServiceSwitching + _done
+	statusServiceSwitchFailed = statusServiceSwitching + _failed
+
+	statusServiceRestoreDrilled     = statusServiceRestoreDrilling + _done
+	statusServiceRestoreDrillFailed = statusServiceRestoreDrilling + _failed
+
+	statusServiceManifestApplied     = statusServiceManifestApplying + _done
+	statusServiceManifestApplyFailed = statusServiceManifestApplying + _failed
 )
 
 func isInProgress(val int) bool {
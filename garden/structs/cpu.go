@@ -0,0 +1,162 @@
+package structs
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// CPUTopologyLabel is the engine label of host CPU topology,set by node-init.sh,
+// "cpu,node,core" of each logical CPU joined by ';',the output of `lscpu -p=CPU,NODE,CORE`.
+const CPUTopologyLabel = "CPU_TOPOLOGY"
+
+// CPU policies of ResourcePolicy
+const (
+	CPUPolicyNUMA      = "numa"
+	CPUPolicyExclusive = "exclusive"
+)
+
+// ResourcePolicy is the cpuset and memory swap policy of units
+type ResourcePolicy struct {
+	// "" is any free CPUs,
+	// "numa" packs CPUs within one NUMA node,
+	// "exclusive" packs whole physical cores within one NUMA node,HT siblings are not shared.
+	CPU string `json:"cpu,omitempty"`
+
+	// MemorySwap is Memory*SwapRatio,default 1.5,1 disables swap,-1 is unlimited swap.
+	SwapRatio float64 `json:"swap_ratio,omitempty"`
+}
+
+// MemorySwap returns the HostConfig.MemorySwap of memory
+func (p ResourcePolicy) MemorySwap(memory int64) int64 {
+	switch {
+	case p.SwapRatio < 0:
+		return -1
+	case memory == 0:
+		return 0
+	case p.SwapRatio == 0:
+		return int64(float64(memory) * 1.5)
+	default:
+		return int64(float64(memory) * p.SwapRatio)
+	}
+}
+
+// Valid returns error if the policy is invalid
+func (p ResourcePolicy) Valid() error {
+	if p.CPU != "" && p.CPU != CPUPolicyNUMA && p.CPU != CPUPolicyExclusive {
+		return errors.Errorf("unsupported cpu policy '%s'", p.CPU)
+	}
+
+	if p.SwapRatio != 0 && p.SwapRatio != -1 && p.SwapRatio < 1 {
+		return errors.Errorf("swap ratio should be -1 or not less than 1,%v", p.SwapRatio)
+	}
+
+	return nil
+}
+
+// CPU is a logical CPU of host
+type CPU struct {
+	ID   int
+	Node int // NUMA node
+	Core int // physical core,HT siblings share the core
+}
+
+// CPUTopology is the logical CPUs of host,sorted by ID.
+type CPUTopology []CPU
+
+// ParseCPUTopology parses the value of CPUTopologyLabel
+func ParseCPUTopology(val string) (CPUTopology, error) {
+	val = strings.TrimSpace(val)
+	if val == "" {
+		return nil, nil
+	}
+
+	parts := strings.Split(val, ";")
+	out := make(CPUTopology, 0, len(parts))
+
+	for _, p := range parts {
+		fields := strings.Split(strings.TrimSpace(p), ",")
+		if len(fields) != 3 {
+			return nil, errors.Errorf("parse CPU topology '%s',unexpected format", p)
+		}
+
+		ints := make([]int, 3)
+		for i := range fields {
+			if fields[i] == "" {
+				// no NUMA node
+				continue
+			}
+
+			n, err := strconv.Atoi(fields[i])
+			if err != nil {
+				return nil, errors.Wrapf(err, "parse CPU topology '%s'", p)
+			}
+
+			ints[i] = n
+		}
+
+		out = append(out, CPU{ID: ints[0], Node: ints[1], Core: ints[2]})
+	}
+
+	sort.Sort(out)
+
+	return out, nil
+}
+
+func (t CPUTopology) Len() int           { return len(t) }
+func (t CPUTopology) Less(i, j int) bool { return t[i].ID < t[j].ID }
+func (t CPUTopology) Swap(i, j int)      { t[i], t[j] = t[j], t[i] }
+
+// ThreadsPerCore returns the max number of logical CPUs of physical cores
+func (t CPUTopology) ThreadsPerCore() int {
+	cores := make(map[int]int)
+	max := 0
+
+	for _, c := range t {
+		cores[c.Core]++
+		if cores[c.Core] > max {
+			max = cores[c.Core]
+		}
+	}
+
+	return max
+}
+
+// NUMANode is the CPUs of NUMA node
+type NUMANode struct {
+	ID       int   `json:"id"`
+	CPUs     []int `json:"cpus"`
+	FreeCPUs []int `json:"free_cpus"`
+}
+
+// NUMANodes returns the NUMA nodes sorted by ID,used are the CPUs in use.
+func (t CPUTopology) NUMANodes(used map[int]bool) []NUMANode {
+	index := make(map[int]int)
+	out := make([]NUMANode, 0, 2)
+
+	for _, c := range t {
+		i, ok := index[c.Node]
+		if !ok {
+			i = len(out)
+			index[c.Node] = i
+			out = append(out, NUMANode{ID: c.Node, CPUs: []int{}, FreeCPUs: []int{}})
+		}
+
+		out[i].CPUs = append(out[i].CPUs, c.ID)
+		if !used[c.ID] {
+			out[i].FreeCPUs = append(out[i].FreeCPUs, c.ID)
+		}
+	}
+
+	sort.Sort(numaNodes(out))
+
+	return out
+}
+
+type numaNodes []NUMANode
+
+func (n numaNodes) Len() int           { return len(n) }
+func (n numaNodes) Less(i, j int) bool { return n[i].ID < n[j].ID }
+func (n numaNodes) Swap(i, j int)      { n[i], n[j] = n[j], n[i] }
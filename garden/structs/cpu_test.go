@@ -0,0 +1,54 @@
+package structs
+
+import "testing"
+
+func TestParseCPUTopology(t *testing.T) {
+	topology, err := ParseCPUTopology("2,1,1;0,0,0;1,,0;3,1,1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(topology) != 4 || topology[0].ID != 0 || topology[1].Node != 0 || topology[3].Core != 1 {
+		t.Errorf("unexpected topology %v", topology)
+	}
+
+	if n := topology.ThreadsPerCore(); n != 2 {
+		t.Errorf("expected 2 threads per core but got %d", n)
+	}
+
+	nodes := topology.NUMANodes(map[int]bool{2: true})
+	if len(nodes) != 2 || len(nodes[1].CPUs) != 2 || len(nodes[1].FreeCPUs) != 1 || nodes[1].FreeCPUs[0] != 3 {
+		t.Errorf("unexpected NUMA nodes %v", nodes)
+	}
+
+	if _, err := ParseCPUTopology("0,0"); err == nil {
+		t.Error("expected error with bad format")
+	}
+}
+
+func TestResourcePolicy(t *testing.T) {
+	tests := []struct {
+		policy ResourcePolicy
+		memory int64
+		swap   int64
+	}{
+		{ResourcePolicy{}, 1 << 30, 1 << 30 * 3 / 2},
+		{ResourcePolicy{SwapRatio: 1}, 1 << 30, 1 << 30},
+		{ResourcePolicy{SwapRatio: -1}, 1 << 30, -1},
+		{ResourcePolicy{SwapRatio: 2}, 0, 0},
+	}
+
+	for i := range tests {
+		if got := tests[i].policy.MemorySwap(tests[i].memory); got != tests[i].swap {
+			t.Errorf("%d:expected %d but got %d", i, tests[i].swap, got)
+		}
+	}
+
+	if err := (ResourcePolicy{CPU: "spread"}).Valid(); err == nil {
+		t.Error("expected error with unsupported cpu policy")
+	}
+
+	if err := (ResourcePolicy{SwapRatio: 0.5}).Valid(); err == nil {
+		t.Error("expected error with swap ratio 0.5")
+	}
+}
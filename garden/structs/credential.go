@@ -0,0 +1,33 @@
+package structs
+
+// PostCredentialRequest create a host login credential,
+// Password is required if Type is "password",PrivateKey is required if Type is "ssh_key".
+type PostCredentialRequest struct {
+	Name       string `json:"name"`
+	Type       string `json:"type"`
+	Username   string `json:"username"`
+	Password   string `json:"password,omitempty"`
+	PrivateKey string `json:"private_key,omitempty"` // PEM-encoded
+	Port       int    `json:"port,omitempty"`
+}
+
+// PutCredentialRequest rotate the credential,hosts referenced the credential are not changed.
+// Type is changed to "ssh_key" if PrivateKey is set,else "password" if Password is set.
+type PutCredentialRequest struct {
+	Username   *string `json:"username,omitempty"`
+	Password   string  `json:"password,omitempty"`
+	PrivateKey string  `json:"private_key,omitempty"`
+	Port       *int    `json:"port,omitempty"`
+}
+
+// CredentialInfo credential without secret
+type CredentialInfo struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Type      string `json:"type"`
+	Username  string `json:"username"`
+	Port      int    `json:"port"`
+	Version   int    `json:"version"`
+	CreatedAt string `json:"created_at"`
+	UpdatedAt string `json:"updated_at"`
+}
@@ -0,0 +1,185 @@
+package structs
+
+// ServiceExport is the portable bundle of a service,
+// used to move the service between managers.
+// The users of the service aren't persisted by the manager,so they aren't exported,see ServiceImportRequest.
+type ServiceExport struct {
+	Spec       ServiceSpec    `json:"spec"`
+	Image      string         `json:"image"` // image version,<name>:<version>
+	Configs    ServiceConfigs `json:"configs"`
+	Links      []ServicesLink `json:"links,omitempty"`
+	Backup     *ExportBackup  `json:"backup,omitempty"`
+	ExportedAt string         `json:"exported_at"`
+}
+
+// ExportBackup is the backup file of the service export uploaded to the S3 backup target,
+// the data of the imported service is restored from the object Key in Bucket at Endpoint,
+// the backup files kept on NFS aren't portable between managers.
+type ExportBackup struct {
+	ID         string `json:"id"`
+	Type       string `json:"type"`
+	Endpoint   string `json:"endpoint"`
+	Bucket     string `json:"bucket"`
+	Key        string `json:"key"`
+	SizeByte   int    `json:"size"`
+	Checksum   string `json:"checksum"`
+	FinishedAt string `json:"finished_at"`
+}
+
+// ServiceImportMapping remaps the objects of the source manager to the target manager,
+// keys are the IDs on the source manager and values are the IDs on the target manager.
+type ServiceImportMapping struct {
+	// Name of the imported service,default the name of the exported service
+	Name string `json:"name,omitempty"`
+	// IDs maps the service,image and linked services IDs,
+	// the imported service gets a new ID if its ID isnot mapped
+	IDs         map[string]string `json:"ids,omitempty"`
+	Networkings map[string]string `json:"networkings,omitempty"`
+	Clusters    map[string]string `json:"clusters,omitempty"`
+}
+
+// ServiceImportRequest recreates the exported service on the manager,
+// the data is restored from the backup of the bundle unless SkipRestore.
+type ServiceImportRequest struct {
+	Bundle      ServiceExport        `json:"bundle"`
+	Mapping     ServiceImportMapping `json:"mapping"`
+	Compose     bool                 `json:"compose"`
+	SkipRestore bool                 `json:"skip_restore,omitempty"`
+	// Users of the imported service,required,the users aren't exported
+	Users []User `json:"users"`
+}
+
+// ServiceImportResponse is the imported service,
+// TaskID is the deploy task,ImportTask restores the configs,data and links after deployed.
+type ServiceImportResponse struct {
+	PostServiceResponse
+	ImportTask string `json:"import_task_id"`
+}
+
+func (m ServiceImportMapping) id(v string) string {
+	if n, ok := m.IDs[v]; ok && n != "" {
+		return n
+	}
+
+	return v
+}
+
+func (m ServiceImportMapping) cluster(v string) string {
+	if n, ok := m.Clusters[v]; ok && n != "" {
+		return n
+	}
+
+	return v
+}
+
+func (m ServiceImportMapping) networking(v string) string {
+	if n, ok := m.Networkings[v]; ok && n != "" {
+		return n
+	}
+
+	return v
+}
+
+// ImportSpec returns the ServiceSpec with the users to deploy on the target manager,
+// IDs,clusters,networkings and affinity services are remapped,the units are dropped to be scheduled again.
+func (e ServiceExport) ImportSpec(m ServiceImportMapping, users []User) ServiceSpec {
+	spec := e.Spec
+
+	spec.ID = ""
+	if n, ok := m.IDs[e.Spec.ID]; ok {
+		spec.ID = n
+	}
+
+	if m.Name != "" {
+		spec.Name = m.Name
+	}
+
+	spec.Image.ID = e.Image
+	if n, ok := m.IDs[e.Spec.Image.ID]; ok && n != "" {
+		spec.Image.ID = n
+	}
+
+	spec.Status = 0
+	spec.CreatedAt, spec.FinishedAt = "", ""
+	spec.Units = nil
+	spec.Users = users
+
+	if len(e.Spec.Clusters) > 0 {
+		spec.Clusters = make([]string, len(e.Spec.Clusters))
+		for i := range e.Spec.Clusters {
+			spec.Clusters[i] = m.cluster(e.Spec.Clusters[i])
+		}
+	}
+
+	if len(e.Spec.Networkings) > 0 {
+		spec.Networkings = make(map[string][]string, len(e.Spec.Networkings))
+		for cluster, list := range e.Spec.Networkings {
+			nets := make([]string, len(list))
+			for i := range list {
+				nets[i] = m.networking(list[i])
+			}
+
+			spec.Networkings[m.cluster(cluster)] = nets
+		}
+	}
+
+	if len(e.Spec.Affinities) > 0 {
+		spec.Affinities = make([]AffinityRule, len(e.Spec.Affinities))
+		for i, r := range e.Spec.Affinities {
+			r.Service = m.id(r.Service)
+			spec.Affinities[i] = r
+		}
+	}
+
+	if len(e.Spec.Options) > 0 {
+		spec.Options = make(map[string]interface{}, len(e.Spec.Options))
+		for key, val := range e.Spec.Options {
+			spec.Options[key] = val
+		}
+	}
+
+	return spec
+}
+
+// ImportLinks returns the link definitions of the export with the services IDs remapped,
+// service is the ID of the imported service.
+func (e ServiceExport) ImportLinks(m ServiceImportMapping, service string) []ServicesLink {
+	out := make([]ServicesLink, 0, len(e.Links))
+
+	mapping := func(v string) string {
+		if v == e.Spec.ID || v == e.Spec.Name {
+			return service
+		}
+
+		return m.id(v)
+	}
+
+	for _, sl := range e.Links {
+		links := make([]*ServiceLink, 0, len(sl.Links))
+
+		for _, l := range sl.Links {
+			if l == nil {
+				continue
+			}
+
+			deps := make([]string, len(l.Deps))
+			for i := range l.Deps {
+				deps[i] = mapping(l.Deps[i])
+			}
+
+			links = append(links, &ServiceLink{
+				Arch: l.Arch,
+				ID:   mapping(l.ID),
+				Deps: deps,
+			})
+		}
+
+		out = append(out, ServicesLink{
+			Mode:     sl.Mode,
+			NameOrID: mapping(sl.NameOrID),
+			Links:    links,
+		})
+	}
+
+	return out
+}
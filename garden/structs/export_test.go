@@ -0,0 +1,94 @@
+package structs
+
+import "testing"
+
+func TestServiceExportImportSpec(t *testing.T) {
+	e := ServiceExport{
+		Spec: ServiceSpec{
+			Service: Service{
+				ID:     "service001",
+				Name:   "db001",
+				Image:  ImageVersion{ID: "image001", Name: "mysql", Major: 5, Minor: 7, Patch: 19},
+				Status: 111,
+			},
+			Clusters:    []string{"cluster001", "cluster002"},
+			Networkings: map[string][]string{"cluster001": {"net001", "net002"}},
+			Units:       []UnitSpec{{Unit: Unit{ID: "unit001"}}},
+			Options:     map[string]interface{}{"mysqld::port": 3306},
+		},
+		Image: "mysql:5.7.19.0",
+	}
+
+	m := ServiceImportMapping{
+		Name:        "db002",
+		Clusters:    map[string]string{"cluster001": "cluster101"},
+		Networkings: map[string]string{"net001": "net101"},
+	}
+
+	spec := e.ImportSpec(m, []User{{Name: "mon", Role: "mon"}})
+
+	if spec.ID != "" || spec.Name != "db002" || spec.Status != 0 || len(spec.Units) != 0 {
+		t.Errorf("unexpected service %+v", spec.Service)
+	}
+
+	if spec.Image.ID != "mysql:5.7.19.0" {
+		t.Errorf("expected image mysql:5.7.19.0 but got %s", spec.Image.ID)
+	}
+
+	if len(spec.Clusters) != 2 || spec.Clusters[0] != "cluster101" || spec.Clusters[1] != "cluster002" {
+		t.Errorf("unexpected clusters %v", spec.Clusters)
+	}
+
+	nets := spec.Networkings["cluster101"]
+	if len(spec.Networkings) != 1 || len(nets) != 2 || nets[0] != "net101" || nets[1] != "net002" {
+		t.Errorf("unexpected networkings %v", spec.Networkings)
+	}
+
+	if len(spec.Users) != 1 || spec.Users[0].Name != "mon" {
+		t.Errorf("unexpected users %v", spec.Users)
+	}
+
+	if e.Spec.Clusters[0] != "cluster001" || e.Spec.Networkings["cluster001"][0] != "net001" {
+		t.Error("export spec should not be modified")
+	}
+
+	e.Spec.Affinities = []AffinityRule{{Service: "proxy001", Anti: true}}
+	m.IDs = map[string]string{"service001": "service101", "image001": "image101", "proxy001": "proxy101"}
+	spec = e.ImportSpec(m, nil)
+
+	if spec.ID != "service101" || spec.Image.ID != "image101" {
+		t.Errorf("expected mapped IDs but got %s %s", spec.ID, spec.Image.ID)
+	}
+
+	if len(spec.Affinities) != 1 || spec.Affinities[0].Service != "proxy101" || !spec.Affinities[0].Anti {
+		t.Errorf("unexpected affinities %+v", spec.Affinities)
+	}
+}
+
+func TestServiceExportImportLinks(t *testing.T) {
+	e := ServiceExport{
+		Spec: ServiceSpec{Service: Service{ID: "service001", Name: "db001"}},
+		Links: []ServicesLink{
+			{
+				Mode:     "upsql-upproxy",
+				NameOrID: "service001",
+				Links: []*ServiceLink{
+					{ID: "service001", Deps: []string{"proxy001"}},
+					{ID: "proxy001", Spec: &ServiceSpec{}},
+					nil,
+				},
+			},
+		},
+	}
+
+	links := e.ImportLinks(ServiceImportMapping{IDs: map[string]string{"proxy001": "proxy101"}}, "service101")
+
+	if len(links) != 1 || links[0].NameOrID != "service101" || len(links[0].Links) != 2 {
+		t.Fatalf("unexpected links %+v", links)
+	}
+
+	l := links[0].Links
+	if l[0].ID != "service101" || l[0].Deps[0] != "proxy101" || l[1].ID != "proxy101" || l[1].Spec != nil {
+		t.Errorf("unexpected links %+v %+v", l[0], l[1])
+	}
+}
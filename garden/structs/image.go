@@ -101,10 +101,166 @@ func (iv ImageVersion) LessThan(v ImageVersion) (bool, error) {
 	return iv.Dev < v.Dev, nil
 }
 
+// Compare returns -1,0 or 1 if iv is less than,equal to or greater than v,the name isnot compared.
+func (iv ImageVersion) Compare(v ImageVersion) int {
+	a := [...]int{iv.Major, iv.Minor, iv.Patch, iv.Dev}
+	b := [...]int{v.Major, v.Minor, v.Patch, v.Dev}
+
+	for i := range a {
+		if a[i] < b[i] {
+			return -1
+		}
+		if a[i] > b[i] {
+			return 1
+		}
+	}
+
+	return 0
+}
+
+// ErrUnsupportedUpgrade the image couldnot be upgraded from the source version
+var ErrUnsupportedUpgrade = errors.New("unsupported image upgrade")
+
+// ImageUpgrade declares the upgrade path to the image,
+// From are the source version patterns could be upgraded from,e.g. "5.7.*","5.6.30",">=5.6.30,<5.7",
+// RenamedKeys maps the old template keys to the new keys,RemovedKeys are dropped in the new template.
+type ImageUpgrade struct {
+	From        []string          `json:"from"`
+	RenamedKeys map[string]string `json:"renamed_keys,omitempty"`
+	RemovedKeys []string          `json:"removed_keys,omitempty"`
+}
+
+// Validate checks the version patterns and the keys migration.
+func (u ImageUpgrade) Validate() error {
+	for _, p := range u.From {
+		_, err := MatchVersion(p, ImageVersion{})
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, key := range u.RemovedKeys {
+		if _, ok := u.RenamedKeys[key]; ok {
+			return errors.Errorf("key %s is both renamed and removed", key)
+		}
+	}
+
+	return nil
+}
+
+// MatchVersion returns true if v matches the pattern,
+// the pattern is a version prefix with optional "*",e.g. "5.7","5.7.*",
+// or comparisons joined by ',',e.g. ">=5.6.30,<5.7",missing segments of comparisons are 0.
+func MatchVersion(pattern string, v ImageVersion) (bool, error) {
+	match := true
+	vals := [...]int{v.Major, v.Minor, v.Patch, v.Dev}
+
+	for _, cond := range strings.Split(pattern, ",") {
+		cond = strings.TrimSpace(cond)
+
+		op := ""
+		for _, prefix := range []string{">=", "<=", ">", "<", "="} {
+			if strings.HasPrefix(cond, prefix) {
+				op = prefix
+				cond = strings.TrimSpace(cond[len(prefix):])
+				break
+			}
+		}
+
+		segs := strings.Split(cond, ".")
+		if cond == "" || len(segs) > len(vals) {
+			return false, errors.Errorf("invalid version pattern '%s'", pattern)
+		}
+
+		var nums [len(vals)]int
+		n := len(segs)
+
+		for i, s := range segs {
+			if s == "*" && i == len(segs)-1 && op == "" {
+				n = i
+				break
+			}
+
+			num, err := strconv.Atoi(s)
+			if err != nil || num < 0 {
+				return false, errors.Errorf("invalid version pattern '%s'", pattern)
+			}
+
+			nums[i] = num
+		}
+
+		if op == "" || op == "=" {
+			for i := 0; i < n; i++ {
+				if vals[i] != nums[i] {
+					match = false
+				}
+			}
+			continue
+		}
+
+		c := v.Compare(ImageVersion{Major: nums[0], Minor: nums[1], Patch: nums[2], Dev: nums[3]})
+
+		switch op {
+		case ">=":
+			match = match && c >= 0
+		case "<=":
+			match = match && c <= 0
+		case ">":
+			match = match && c > 0
+		case "<":
+			match = match && c < 0
+		}
+	}
+
+	return match, nil
+}
+
+// UpgradeCheck checks the image could be upgraded from the source version,
+// upgrade is declared by the target image template,
+// without the declaration only the upgrade within the same major version is supported.
+func UpgradeCheck(from, to ImageVersion, upgrade *ImageUpgrade) error {
+	if from.Name != to.Name {
+		return errors.Wrapf(ErrUnsupportedUpgrade, "from %s to %s,the image name is different", from.Image(), to.Image())
+	}
+
+	if from.Compare(to) == 0 {
+		return nil
+	}
+
+	if upgrade == nil || len(upgrade.From) == 0 {
+		if from.Major != to.Major {
+			return errors.Wrapf(ErrUnsupportedUpgrade, "from %s to %s,%s declares no upgrade path,only upgrade within major version %d is supported",
+				from.Image(), to.Image(), to.Image(), from.Major)
+		}
+
+		return nil
+	}
+
+	for _, p := range upgrade.From {
+		ok, err := MatchVersion(p, from)
+		if err != nil {
+			return err
+		}
+
+		if ok {
+			return nil
+		}
+	}
+
+	return errors.Wrapf(ErrUnsupportedUpgrade, "from %s to %s,%s supports upgrade from versions '%s' only",
+		from.Image(), to.Image(), to.Image(), strings.Join(upgrade.From, "' '"))
+}
+
 type PostLoadImageRequest struct {
 	ImageVersion
-	Path   string            `json:"image_path"`
-	Labels map[string]string `json:"labels"`
+	Path string `json:"image_path"`
+	// Reference is the image in the upstream registry,e.g. docker.io/library/mysql:5.7.17,
+	// the image is imported from Reference instead of the tarball of Path if set.
+	Reference string            `json:"reference,omitempty"`
+	Labels    map[string]string `json:"labels"`
+	// Template is the config template of the image imported from Reference,required with Reference,
+	// the template of the tarball is read from the files beside Path.
+	Template *ConfigTemplate `json:"template,omitempty"`
 }
 
 type Keyset struct {
@@ -127,6 +283,12 @@ type ConfigTemplate struct {
 
 	Keysets   []Keyset `json:"keysets"`
 	Timestamp int64    `json:"timestamp"`
+
+	Upgrade *ImageUpgrade `json:"upgrade,omitempty"`
+
+	// CheckCmds are the check commands provided by the image,such as RestoreCheckCmd,
+	// the check is unsupported if the command is absent.
+	CheckCmds CmdsMap `json:"check_cmds,omitempty"`
 }
 
 type UnitConfig struct {
@@ -158,6 +320,33 @@ type ImageResponse struct {
 	ImageID  string `json:"docker_image_id"`
 	Labels   string `json:"label"`
 	UploadAt string `json:"upload_at"`
+
+	Deprecated       bool   `json:"deprecated"`
+	DeprecatedReason string `json:"deprecated_reason,omitempty"`
+}
+
+type ImageDeprecateRequest struct {
+	Reason string `json:"reason"`
+}
+
+// EngineImage is the image on the engine could be removed by garbage collection
+type EngineImage struct {
+	ID   string   `json:"id"`
+	Tags []string `json:"tags"`
+	Size int64    `json:"size"`
+	// Reclaimable size,exclude the layers shared with other images if known
+	Reclaimable int64 `json:"reclaimable"`
+}
+
+// ImageGCReport the unused images on the host,
+// Removed is false in dry run.
+type ImageGCReport struct {
+	Host        string        `json:"host"`
+	Engine      string        `json:"engine"`
+	Images      []EngineImage `json:"images"`
+	Reclaimable int64         `json:"reclaimable"`
+	Removed     bool          `json:"removed"`
+	Errors      []string      `json:"errors,omitempty"`
 }
 
 type GetImageResponse struct {
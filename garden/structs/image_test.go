@@ -1,6 +1,11 @@
 package structs
 
-import "testing"
+import (
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+)
 
 func TestParseImage(t *testing.T) {
 	v, err := ParseImage("mysql:5.7.19.2")
@@ -79,3 +84,97 @@ func TestImageVersion(t *testing.T) {
 		t.Error(less, v.Version(), big.Version())
 	}
 }
+
+func TestMatchVersion(t *testing.T) {
+	v := ImageVersion{Name: "mysql", Major: 5, Minor: 7, Patch: 19, Dev: 2}
+
+	tests := []struct {
+		pattern string
+		match   bool
+	}{
+		{"5.7.19.2", true},
+		{"5.7.19", true},
+		{"5.7.*", true},
+		{"5", true},
+		{"*", true},
+		{"5.6.*", false},
+		{"5.7.20", false},
+		{">=5.6.30,<5.7.20", true},
+		{">=5.6.30, <5.7.19", false},
+		{">5.7.19", true},
+		{"<=5.7", false},
+		{"=5.7", true},
+	}
+
+	for _, test := range tests {
+		ok, err := MatchVersion(test.pattern, v)
+		if err != nil {
+			t.Errorf("%s:%v", test.pattern, err)
+		}
+		if ok != test.match {
+			t.Errorf("pattern %s expected %t but got %t", test.pattern, test.match, ok)
+		}
+	}
+
+	for _, pattern := range []string{"", "5.x", ">=5.*", "5.*.1", "1.2.3.4.5", ">=5.6,"} {
+		if _, err := MatchVersion(pattern, v); err == nil {
+			t.Errorf("pattern '%s' expected error", pattern)
+		}
+	}
+}
+
+func TestUpgradeCheck(t *testing.T) {
+	from := ImageVersion{Name: "mysql", Major: 5, Minor: 6, Patch: 30}
+	to := ImageVersion{Name: "mysql", Major: 5, Minor: 7, Patch: 19}
+	next := ImageVersion{Name: "mysql", Major: 8, Minor: 0, Patch: 11}
+
+	if err := UpgradeCheck(from, to, nil); err != nil {
+		t.Error(err)
+	}
+
+	err := UpgradeCheck(to, next, nil)
+	if errors.Cause(err) != ErrUnsupportedUpgrade {
+		t.Errorf("expected unsupported upgrade without declaration,but got %v", err)
+	}
+
+	upgrade := &ImageUpgrade{From: []string{"5.7.*"}}
+
+	if err := UpgradeCheck(to, next, upgrade); err != nil {
+		t.Error(err)
+	}
+
+	err = UpgradeCheck(from, next, upgrade)
+	if errors.Cause(err) != ErrUnsupportedUpgrade || !strings.Contains(err.Error(), "'5.7.*'") {
+		t.Errorf("expected unsupported upgrade from %s,but got %v", from.Image(), err)
+	}
+
+	if err := UpgradeCheck(next, next, upgrade); err != nil {
+		t.Error(err)
+	}
+
+	err = UpgradeCheck(ImageVersion{Name: "redis", Major: 8}, next, nil)
+	if errors.Cause(err) != ErrUnsupportedUpgrade {
+		t.Errorf("expected unsupported upgrade from other image,but got %v", err)
+	}
+}
+
+func TestImageUpgradeValidate(t *testing.T) {
+	u := ImageUpgrade{
+		From:        []string{"5.7.*"},
+		RenamedKeys: map[string]string{"mysqld::query_cache_size": "mysqld::cache_size"},
+		RemovedKeys: []string{"mysqld::query_cache_type"},
+	}
+	if err := u.Validate(); err != nil {
+		t.Error(err)
+	}
+
+	u.RemovedKeys = append(u.RemovedKeys, "mysqld::query_cache_size")
+	if err := u.Validate(); err == nil {
+		t.Error("expected error with key both renamed and removed")
+	}
+
+	u = ImageUpgrade{From: []string{"5.7.x"}}
+	if err := u.Validate(); err == nil {
+		t.Error("expected error with invalid pattern")
+	}
+}
@@ -0,0 +1,63 @@
+package structs
+
+const (
+	// ManifestChange.Action,the changes are applied in the order
+	ManifestScaleDown  = "scale_down"
+	ManifestResource   = "resource"
+	ManifestVolume     = "volume"
+	ManifestNetworking = "networking"
+	ManifestImage      = "image"
+	ManifestConfig     = "config"
+	ManifestScaleUp    = "scale_up"
+)
+
+// ManifestActions are the manifest actions in the applied order,
+// units are removed first and added last,so the new units are created by the final spec.
+var ManifestActions = []string{
+	ManifestScaleDown,
+	ManifestResource,
+	ManifestVolume,
+	ManifestNetworking,
+	ManifestImage,
+	ManifestConfig,
+	ManifestScaleUp,
+}
+
+// ServiceManifest is the desired state of the service,
+// the fields omitted are left unchanged.
+type ServiceManifest struct {
+	Image    string             `json:"image,omitempty"` // <name>:<version>
+	Arch     *Arch              `json:"architecture,omitempty"`
+	CPU      *int64             `json:"ncpu,omitempty"`
+	Memory   *int64             `json:"memory,omitempty"`
+	Volumes  []VolumeRequire    `json:"volumes,omitempty"`
+	Networks []NetDeviceRequire `json:"networks,omitempty"`
+	Keysets  map[string]string  `json:"keysets,omitempty"`
+}
+
+// ManifestChange is a difference between the service and the manifest
+type ManifestChange struct {
+	Action string `json:"action"`
+	Field  string `json:"field"`
+	From   string `json:"from"`
+	To     string `json:"to"`
+}
+
+// ManifestPlan is the changes to reconcile the service to the manifest,
+// TaskID is set if the plan is applied.
+type ManifestPlan struct {
+	Service string           `json:"service_id"`
+	Changes []ManifestChange `json:"changes"`
+	TaskID  string           `json:"task_id,omitempty"`
+}
+
+// Has returns true if the plan has the changes of the action
+func (p ManifestPlan) Has(action string) bool {
+	for i := range p.Changes {
+		if p.Changes[i].Action == action {
+			return true
+		}
+	}
+
+	return false
+}
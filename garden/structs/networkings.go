@@ -24,4 +24,43 @@ type IP struct {
 	Engine    string `json:"engine_id"`
 	Bond      string `json:"net_dev"`
 	Bandwidth int    `json:"bandwidth"`
+	Reserved  string `json:"reserved"`
+}
+
+// ReserveIPRequest reserve IPs of a networking for the named service.
+type ReserveIPRequest struct {
+	Service string   `json:"service"`
+	IPs     []string `json:"IPs"`
+}
+
+// IPUsage counts IPs by state,every IP belongs to only one state:
+// allocated to a unit,disabled,reserved for a service or free.
+type IPUsage struct {
+	Total     int `json:"total"`
+	Allocated int `json:"allocated"`
+	Disabled  int `json:"disabled"`
+	Reserved  int `json:"reserved"`
+	Free      int `json:"free"`
+}
+
+type VLANUsage struct {
+	VLAN int `json:"vlan_id"`
+	IPUsage
+}
+
+type GatewayUsage struct {
+	Gateway string `json:"gateway"`
+	IPUsage
+}
+
+// NetworkingUsage utilization of a networking,
+// Alert is true when free IPs is less than Threshold.
+type NetworkingUsage struct {
+	Networking string `json:"networking_id"`
+	IPUsage
+
+	Threshold int            `json:"threshold"`
+	Alert     bool           `json:"alert"`
+	VLANs     []VLANUsage    `json:"vlans"`
+	Gateways  []GatewayUsage `json:"gateways"`
 }
@@ -1,6 +1,9 @@
 package structs
 
-import "github.com/docker/swarm/cluster"
+import (
+	"github.com/docker/swarm/cluster"
+	"github.com/docker/swarm/garden/utils"
+)
 
 type PostClusterRequest struct {
 	NetworkPartition string  `json:"ha_network_tag"`
@@ -27,6 +30,9 @@ type Node struct {
 	Addr    string `json:"addr"`
 	Storage string `json:"storage"`
 
+	// Credential ID or name,login host by the credential instead of SSHConfig
+	Credential string `json:"credential_id,omitempty"`
+
 	SSHConfig
 	NFS
 
@@ -37,6 +43,10 @@ type Node struct {
 
 	Room string `json:"room,omitempty"`
 	Seat string `json:"seat,omitempty"`
+
+	// topology labels for spreading units,such as rack,room,power and zone,
+	// room and rack are default by Room and Seat.
+	Labels map[string]string `json:"labels,omitempty"`
 }
 
 type NFS struct {
@@ -50,6 +60,9 @@ type SSHConfig struct {
 	Username string `json:"username"`
 	Password string `json:"password"`
 	Port     int    `json:"port,omitempty"` // ssh port
+
+	// PEM-encoded private key,only set by credential
+	PrivateKey []byte `json:"-"`
 }
 
 type PostNodesRequest []Node
@@ -60,14 +73,38 @@ type PostNodeResponse struct {
 	Task string `json:"task_id"`
 }
 
+// NodeReinstallRequest reinstall a host failed in installation,
+// steps succeeded in previous installation are skipped unless Force is true.
+// login host by the host credential if SSHConfig.Username is empty.
+type NodeReinstallRequest struct {
+	SSHConfig
+
+	HDD []string `json:"hdd"`
+	SSD []string `json:"ssd"`
+
+	Force bool `json:"force"`
+}
+
+// NodeLabelsRequest replaces the topology labels of host
+type NodeLabelsRequest struct {
+	Labels map[string]string `json:"labels"`
+}
+
+// NodeDrainRequest migrate units to Candidates,choose by scheduler if Candidates is nil.
+type NodeDrainRequest struct {
+	Candidates []string `json:"candidates,omitempty"` // Node ID
+}
+
 type NodeInfo struct {
-	ID           string `json:"id"`
-	Cluster      string `json:"cluster_id"`
-	Room         string `json:"room"`
-	Seat         string `json:"seat"`
-	MaxContainer int    `json:"max_container"`
-	Enabled      bool   `json:"enabled"`
-	RegisterAt   string `json:"register_at"`
+	ID           string            `json:"id"`
+	Cluster      string            `json:"cluster_id"`
+	Room         string            `json:"room"`
+	Seat         string            `json:"seat"`
+	Labels       map[string]string `json:"labels"`
+	MaxContainer int               `json:"max_container"`
+	Enabled      bool              `json:"enabled"`
+	Credential   string            `json:"credential_id"`
+	RegisterAt   string            `json:"register_at"`
 
 	Engine struct {
 		IsHealthy  bool `json:"is_healthy"`
@@ -88,11 +125,36 @@ type NodeInfo struct {
 		OS           string `json:"os"`
 		OSType       string `json:"os_type"`
 		Architecture string `json:"architecture"`
+
+		// CPU topology,collected at registration
+		ThreadsPerCore int        `json:"threads_per_core,omitempty"`
+		NUMA           []NUMANode `json:"numa,omitempty"`
 	}
 
 	Containers []container `json:"containers"`
 
 	VolumeDrivers []VolumeDriver `json:"volume_drivers"`
+
+	Health *NodeHealth `json:"health,omitempty"`
+}
+
+// NodeHealth is the health score of host,
+// combined by engine,consul agent,seed agent and storage free space.
+type NodeHealth struct {
+	Score          int               `json:"score"` // 0~100
+	Healthy        bool              `json:"healthy"`
+	Quarantined    bool              `json:"quarantined"`
+	Failures       int               `json:"failures"` // continuous unhealthy times
+	CheckedAt      string            `json:"checked_at"`
+	UnhealthySince string            `json:"unhealthy_since,omitempty"`
+	Checks         []NodeHealthCheck `json:"checks"`
+}
+
+type NodeHealthCheck struct {
+	Name    string `json:"name"`
+	Passing bool   `json:"passing"`
+	Weight  int    `json:"weight"`
+	Output  string `json:"output,omitempty"`
 }
 
 type VolumeDriver struct {
@@ -159,6 +221,7 @@ func (n *NodeInfo) SetByEngine(e *cluster.Engine) {
 	var ncpu, memory int64
 	containers := e.Containers()
 	n.Containers = make([]container, len(containers))
+	used := make(map[int]bool)
 
 	for i, c := range containers {
 		n.Containers[i] = convertToContainer(c)
@@ -169,6 +232,13 @@ func (n *NodeInfo) SetByEngine(e *cluster.Engine) {
 				n.Containers[i].NCPU = num
 			}
 
+			cpus, err := utils.ParseUintList(c.Config.HostConfig.CpusetCpus)
+			if err == nil {
+				for k, v := range cpus {
+					used[k] = v
+				}
+			}
+
 			n.Containers[i].Memory = c.Config.HostConfig.Memory
 			memory += c.Config.HostConfig.Memory
 		}
@@ -176,4 +246,10 @@ func (n *NodeInfo) SetByEngine(e *cluster.Engine) {
 
 	n.Engine.FreeMemory = int(e.Memory - memory)
 	n.Engine.FreeCPUs = int(e.Cpus - ncpu)
+
+	topology, err := ParseCPUTopology(e.Labels[CPUTopologyLabel])
+	if err == nil && len(topology) > 0 {
+		n.Engine.ThreadsPerCore = topology.ThreadsPerCore()
+		n.Engine.NUMA = topology.NUMANodes(used)
+	}
 }
@@ -7,6 +7,7 @@ type RegisterDC struct {
 	BackupDir      string       `json:"backup_dir"`
 	Retry          int64        `json:"retry"`
 	NFS            NFSOption    `json:"nfs"`
+	BackupTarget   BackupTarget `json:"backup_target"`
 	Consul         ConsulConfig `json:"consul"`
 	Registry       Registry     `json:"registry"`
 	SSHDeliver     SSHDeliver   `json:"ssh_deliver"`
@@ -19,6 +20,18 @@ type NFSOption struct {
 	MountOptions string `json:"nfs_mount_opts"`
 }
 
+// BackupTarget is where the backup files are kept,"nfs" by default,
+// or "s3" to upload the backup files to the S3-compatible object storage.
+type BackupTarget struct {
+	Type      string `json:"type"`
+	Endpoint  string `json:"s3_endpoint,omitempty"`
+	Region    string `json:"s3_region,omitempty"`
+	Bucket    string `json:"s3_bucket,omitempty"`
+	AccessKey string `json:"s3_access_key,omitempty"`
+	SecretKey string `json:"s3_secret_key,omitempty"`
+	Quota     int64  `json:"s3_quota,omitempty"`
+}
+
 type SSHDeliver struct {
 	SourceDir       string `json:"source_dir"`
 	CACertName      string `json:"ca_crt_name"`
@@ -12,6 +12,7 @@ const (
 	BackupCmd         = "backup_cmd"
 	HealthCheckCmd    = "health_check_cmd"
 	MigrateRebuildCmd = "migrate_rebuild_cmd"
+	RestoreCheckCmd   = "restore_check_cmd"
 )
 
 //type HorusRegistration2 struct {
@@ -56,9 +57,18 @@ type HorusRegistration struct {
 	} `json:"service,omitempty"`
 }
 
+// PrometheusRegistration is a target group of Prometheus file_sd,
+// Targets are the exporter addresses of the unit,Key is the unit ID the group is stored by.
+type PrometheusRegistration struct {
+	Key     string            `json:"key"`
+	Targets []string          `json:"targets"`
+	Labels  map[string]string `json:"labels,omitempty"`
+}
+
 type ServiceRegistration struct {
-	Consul *api.AgentServiceRegistration `json:"consul_server,omitempty"`
-	Horus  *HorusRegistration            `json:"horus_server,omitempty"`
+	Consul     *api.AgentServiceRegistration `json:"consul_server,omitempty"`
+	Horus      *HorusRegistration            `json:"horus_server,omitempty"`
+	Prometheus *PrometheusRegistration       `json:"prometheus,omitempty"`
 }
 
 type ServiceDeregistration struct {
@@ -63,6 +63,8 @@ type UnitIP struct {
 type UnitPort struct {
 	Name string `json:"name,omitempty"`
 	Port int    `json:"port"`
+	// Proto is the proxy protocol of the port,tcp or http
+	Proto string `json:"proto,omitempty"`
 }
 
 type VolumeSpec struct {
@@ -112,13 +114,40 @@ type ServiceSpec struct {
 
 	Constraints []string `json:"constraints,omitempty"`
 
+	Spread []SpreadRule `json:"spread,omitempty"`
+
+	Affinities []AffinityRule `json:"affinities,omitempty"`
+
 	Units []UnitSpec `json:"units"`
 
 	Users []User `json:"users,omitempty"`
 
+	Exec *ExecPolicy `json:"exec_policy,omitempty"`
+
 	Options map[string]interface{} `json:"opts"`
 }
 
+// SpreadRule spreads units across the failure domains of a host topology label,
+// such as rack,room,power and zone,see Node.Labels.
+// "no two units in the same rack" is {"label":"rack","max":1},
+// master rules are rejected on deploy,the masters are elected by compose after deployed,
+// the master rules of services deployed before apply only when migrating a master.
+type SpreadRule struct {
+	Label   string `json:"label"`
+	Max     int    `json:"max,omitempty"`     // max units in one domain,default 1
+	Masters bool   `json:"masters,omitempty"` // spread the migrated master units only,rejected on deploy
+}
+
+// AffinityRule places units on the hosts of the units of another service,
+// "must not share a host with service B" is {"service":"B","anti":true},
+// "sit on the same host as the master of B" is {"service":"B","master":true}.
+// Anti-affinity is kept by both services.
+type AffinityRule struct {
+	Service string `json:"service"`          // name or ID of the other service
+	Anti    bool   `json:"anti,omitempty"`   // anti-affinity
+	Master  bool   `json:"master,omitempty"` // the master units of the other service only
+}
+
 type UnitRequire struct {
 	Require struct {
 		CPU    int   `json:"ncpu"`
@@ -132,6 +161,8 @@ type UnitRequire struct {
 
 	Volumes  []VolumeRequire    `json:"volumes"`
 	Networks []NetDeviceRequire `json:"networks"`
+
+	Policy ResourcePolicy `json:"policy"`
 }
 
 type ServiceResponse struct {
@@ -173,6 +204,47 @@ type ServiceScaleResponse struct {
 	Remove []UnitNameID `json:"remove_units,omitempty"`
 }
 
+type ServiceSwitchoverRequest struct {
+	Target string `json:"target,omitempty"`  // unit ID or name,the healthy slave with the lowest lag if empty
+	MaxLag int    `json:"max_lag,omitempty"` // seconds,the max replication lag of target
+	// Links is re-generated after switchover,repoints the linked proxies to the new master,
+	// the stored links of the service are re-generated if nil
+	Links *ServicesLink `json:"links,omitempty"`
+}
+
+// ServiceSwitchover is the switchover request sent to plugin
+type ServiceSwitchover struct {
+	Spec   ServiceSpec `json:"spec"`
+	Target string      `json:"target,omitempty"` // unit ID
+	MaxLag int         `json:"max_lag"`          // seconds
+}
+
+type ServiceSwitchoverResponse struct {
+	Old string `json:"old_master"` // unit ID
+	New string `json:"new_master"` // unit ID
+}
+
+// UnitTopology is the current role of unit probed from the database
+type UnitTopology struct {
+	ID       string   `json:"id"`
+	Name     string   `json:"name"`
+	Addr     string   `json:"addr"`
+	Role     string   `json:"role"`             // master/slave/sentinel/proxy,empty if probe failed
+	Source   string   `json:"source,omitempty"` // replication source addr
+	Lag      int      `json:"lag"`              // replication lag in seconds,-1 if unknown
+	State    string   `json:"state,omitempty"`
+	Slots    []string `json:"slots,omitempty"`    // slot ranges of redis sharding master
+	Backends []string `json:"backends,omitempty"` // backends of proxy,monitored masters of sentinel
+	Error    string   `json:"error,omitempty"`
+}
+
+type ServiceTopology struct {
+	ID    string         `json:"id"`
+	Name  string         `json:"name"`
+	Arch  Arch           `json:"architecture"` // desired
+	Units []UnitTopology `json:"units"`        // current
+}
+
 type UnitRebuildRequest PostUnitMigrate
 
 type PostServiceResponse struct {
@@ -206,6 +278,39 @@ type ContainerExecOutput struct {
 	Output string `json:"output"`
 }
 
+// UnitExecConfig is the interactive exec session on the unit container
+type UnitExecConfig struct {
+	Cmd  []string `json:"cmd"`
+	Tty  bool     `json:"tty"`
+	Env  []string `json:"env,omitempty"`
+	User string   `json:"user,omitempty"`
+}
+
+// ExecPolicy authorizes the interactive exec sessions and the logs of the service,
+// exec sessions and logs are denied if the service has no ExecPolicy.
+// Cmds are the allowed command lines split by spaces,a command is allowed if it starts with the argv of one,
+// "*" allows any command,
+// Users are the allowed container users besides the default user,
+// Env are the allowed names of environment variables,
+// Logs allows reading the logs of the unit containers.
+type ExecPolicy struct {
+	Cmds  []string `json:"cmds"`
+	Users []string `json:"users,omitempty"`
+	Env   []string `json:"env,omitempty"`
+	Logs  bool     `json:"logs,omitempty"`
+}
+
+// ExecSession is the created interactive exec session,
+// it's started once with Token before ExpiresAt.
+type ExecSession struct {
+	ID        string `json:"id"`
+	Service   string `json:"service"`
+	Unit      string `json:"unit"`
+	Tty       bool   `json:"tty"`
+	Token     string `json:"token"`
+	ExpiresAt string `json:"expires_at"`
+}
+
 type ServiceBackupConfig struct {
 	Container   string `json:"nameOrID"`
 	Type        string `json:"type"`
@@ -217,6 +322,8 @@ type ServiceBackupConfig struct {
 	MaxSizeByte int    `json:"max_backup_space"`
 	// count by Day,used in swarm.BackupTaskCallback(),calculate BackupFile.Retention
 	FilesRetention int `json:"backup_files_retention"`
+	// seconds,the task is timeout if the callback isnot arrived in time
+	Timeout int `json:"timeout,omitempty"`
 
 	Cmd []string `json:"cmd,omitempty"`
 }
@@ -226,6 +333,28 @@ type ServiceRestoreRequest struct {
 	Units []string `json:"units"`
 }
 
+// ServiceRestoreDrillRequest restores the backup file into a temporary unit and runs the validation command,
+// File is BackupFile ID,default the latest full backup file of the service,
+// Cmd default the restore_check_cmd of the image,
+// the drill is scheduled every Interval seconds if Interval>0.
+type ServiceRestoreDrillRequest struct {
+	File     string   `json:"backup_file,omitempty"`
+	Cmd      []string `json:"cmd,omitempty"`
+	Interval int      `json:"interval,omitempty"`
+}
+
+// RestoreDrillInfo is the restore drill schedule and the latest result of service
+type RestoreDrillInfo struct {
+	Service    string   `json:"service_id"`
+	File       string   `json:"backup_file"`
+	Cmd        []string `json:"cmd"`
+	Interval   int      `json:"interval"`
+	LastTask   string   `json:"last_task_id"`
+	LastResult string   `json:"last_result"`
+	LastRunAt  string   `json:"last_run_at"`
+	CreatedAt  string   `json:"created_at"`
+}
+
 type PostUnitMigrate struct {
 	Compose    bool     `json:"compose"`
 	NameOrID   string   `json:"nameOrID"`
@@ -11,6 +11,7 @@ type ResponseHead struct {
 type BackupTaskCallback struct {
 	TaskID    string `json:"task_id"`
 	UnitID    string `json:"unit_id"`
+	Token     string `json:"token"`
 	Type      string `json:"type,omitempty"`
 	Tables    string `json:"tables,omitempty"`
 	Path      string `json:"path,omitempty"`
@@ -24,3 +25,13 @@ type BackupTaskCallback struct {
 	Created   int64  `json:"created_at"`
 	Finished  int64  `json:"finished_at"`
 }
+
+// BackupFileVerifyResponse is the result of re-reading the backup file,
+// Checksum is the recorded checksum,Actual is the checksum re-read.
+type BackupFileVerifyResponse struct {
+	ID       string `json:"id"`
+	Status   string `json:"verify_status"`
+	Checksum string `json:"checksum"`
+	Actual   string `json:"actual"`
+	Error    string `json:"error,omitempty"`
+}
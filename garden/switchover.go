@@ -0,0 +1,76 @@
+package garden
+
+import (
+	"fmt"
+
+	"github.com/docker/swarm/garden/database"
+	"github.com/docker/swarm/garden/structs"
+	"github.com/docker/swarm/garden/tasklock"
+	"github.com/pkg/errors"
+	"golang.org/x/net/context"
+)
+
+const defaultSwitchoverMaxLag = 10 // seconds
+
+// Switchover promotes the target unit to be the master of the replicated service,
+// the old master is fenced and the other units replicate from the new master,
+// after is called when the switchover done,used to repoint the linked services.
+func (gd *Garden) Switchover(ctx context.Context, svc *Service, req structs.ServiceSwitchoverRequest,
+	after func(ctx context.Context) error, async bool) (string, error) {
+
+	target := ""
+	if req.Target != "" {
+		u, err := svc.getUnit(req.Target)
+		if err != nil {
+			return "", err
+		}
+
+		target = u.u.ID
+	}
+
+	if req.MaxLag <= 0 {
+		req.MaxLag = defaultSwitchoverMaxLag
+	}
+
+	task := database.NewTask(svc.Name(), database.ServiceSwitchoverTask, svc.ID(), fmt.Sprintf("target=%s", req.Target), nil, 300)
+
+	sl := tasklock.NewServiceTask(database.ServiceSwitchoverTask, svc.ID(), svc.so, &task,
+		statusServiceSwitching, statusServiceSwitched, statusServiceSwitchFailed)
+
+	err := sl.Run(isnotInProgress, func() error {
+		spec, err := svc.RefreshSpec()
+		if err != nil {
+			return err
+		}
+
+		if svc.spec != nil {
+			spec.Options = svc.spec.Options
+		}
+
+		resp, err := svc.pc.ServiceSwitchover(ctx, structs.ServiceSwitchover{
+			Spec:   *spec,
+			Target: target,
+			MaxLag: req.MaxLag,
+		})
+		if err != nil {
+			return errors.WithMessage(err, "service switchover")
+		}
+
+		task.Labels = fmt.Sprintf("old_master:%s\nnew_master:%s\n", resp.Old, resp.New)
+
+		err = gd.ormer.SetTaskLabels(task)
+		if err != nil {
+			return err
+		}
+
+		svc.SyncMonitorTargets(ctx, gd.KVClient())
+
+		if after != nil {
+			return after(ctx)
+		}
+
+		return nil
+	}, async)
+
+	return task.ID, err
+}
@@ -6,7 +6,9 @@ import (
 
 	"github.com/Sirupsen/logrus"
 	"github.com/docker/swarm/garden/database"
+	"github.com/docker/swarm/garden/metrics"
 	"github.com/pkg/errors"
+	"golang.org/x/net/context"
 )
 
 // GoTaskLock is a workflow do something synchronous or asynchronous
@@ -130,7 +132,14 @@ func (tl GoTaskLock) run(check func(val int) bool, do func() error, async bool)
 
 				if err != nil {
 					tl.task.Status = database.TaskFailedStatus
+
+					if errors.Cause(err) == context.DeadlineExceeded {
+						tl.task.Status = database.TaskTimeoutStatus
+					}
 				}
+
+				metrics.Tasks.Inc(tl.task.Related, metrics.Result(err))
+				metrics.TaskDuration.Observe(time.Since(start).Seconds(), tl.task.Related)
 			}
 
 			val := tl.expect
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/docker/swarm/garden/database"
+	"golang.org/x/net/context"
 )
 
 type statusM struct {
@@ -181,4 +182,20 @@ func TestTasklock(t *testing.T) {
 	} else {
 		t.Logf("%+v", task)
 	}
+
+	// sync,non-nil task,doSomething() timeout
+	task = &database.Task{}
+	sl5 := newStatusLock(key, task, 0, 1, 2, 3)
+	err = sl5.Run(func(val int) bool {
+		return val == 0
+	}, func() error {
+		return context.DeadlineExceeded
+	}, false)
+	if err == nil {
+		t.Error("expect error")
+	}
+
+	if task.Status != database.TaskTimeoutStatus {
+		t.Error(task.Status)
+	}
 }
@@ -0,0 +1,202 @@
+package garden
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+
+	"github.com/docker/swarm/garden/structs"
+	"github.com/pkg/errors"
+	"golang.org/x/net/context"
+)
+
+const (
+	// UnitProxyTCP tunnels the raw TCP stream to the unit port
+	UnitProxyTCP = "tcp"
+	// UnitProxyHTTP proxies the HTTP requests to the unit port
+	UnitProxyHTTP = "http"
+)
+
+var (
+	// ErrProxyPortDenied the port isnot allowed to be proxied by the image type of the unit
+	ErrProxyPortDenied = errors.New("unit port is not allowed to proxy")
+	// ErrProxyNetworking the unit has no IP on the networking
+	ErrProxyNetworking = errors.New("unit has no IP on the networking")
+)
+
+type proxyPort struct {
+	name  string
+	proto string
+	// service option keys of the port number,the first found is used
+	keys []string
+}
+
+// unitProxyPorts are the unit ports allowed to be proxied by image type,
+// ports of tcp are tunnelled only,ports of http are proxied or tunnelled.
+var unitProxyPorts = map[string][]proxyPort{
+	"mysql":          {{name: "mysql", proto: UnitProxyTCP, keys: []string{"mysqld::port"}}},
+	"upsql":          {{name: "mysql", proto: UnitProxyTCP, keys: []string{"mysqld::port"}}},
+	"redis":          {{name: "redis", proto: UnitProxyTCP, keys: []string{"port"}}},
+	"upredis":        {{name: "redis", proto: UnitProxyTCP, keys: []string{"port"}}},
+	"sentinel":       {{name: "sentinel", proto: UnitProxyTCP, keys: []string{"port"}}},
+	"urproxy":        {{name: "proxy", proto: UnitProxyTCP, keys: []string{"port"}}},
+	"switch_manager": {{name: "http", proto: UnitProxyHTTP, keys: []string{"Port"}}},
+	"proxy": {
+		{name: "proxy", proto: UnitProxyTCP, keys: []string{"proxy_data_port", "upsql-proxy::proxy_data_port"}},
+		{name: "admin", proto: UnitProxyTCP, keys: []string{"proxy_admin_port"}},
+	},
+	"upproxy": {
+		{name: "proxy", proto: UnitProxyTCP, keys: []string{"proxy_data_port", "upsql-proxy::proxy_data_port"}},
+		{name: "admin", proto: UnitProxyTCP, keys: []string{"proxy_admin_port"}},
+	},
+}
+
+// unitPorts returns the ports of the units could be proxied,resolved by the image type and the service options.
+func unitPorts(image string, opts map[string]interface{}) []structs.UnitPort {
+	ports := unitProxyPorts[image]
+	out := make([]structs.UnitPort, 0, len(ports))
+
+	for _, p := range ports {
+		for _, key := range p.keys {
+			val, ok := opts[key]
+			if !ok || val == nil {
+				continue
+			}
+
+			n, err := strconv.Atoi(fmt.Sprintf("%v", val))
+			if err != nil || n <= 0 {
+				continue
+			}
+
+			out = append(out, structs.UnitPort{
+				Name:  p.name,
+				Port:  n,
+				Proto: p.proto,
+			})
+			break
+		}
+	}
+
+	return out
+}
+
+// UnitProxyTarget is the unit address proxied to.
+type UnitProxyTarget struct {
+	Service string
+	Unit    string
+	Port    structs.UnitPort
+	Addr    string
+}
+
+// UnitProxyTarget returns the address of the unit port to proxy,port is the name or number of the unit port,
+// networking selects the unit IP by networking ID or IP,the IP of the first networking is used by default,
+// which the unit services are bound to.
+// The unit ports are resolved from the current unit config,updated ports are proxied.
+func (gd *Garden) UnitProxyTarget(ctx context.Context, nameOrID, port, networking, mode string) (UnitProxyTarget, error) {
+	u, err := gd.ormer.GetUnit(nameOrID)
+	if err != nil {
+		return UnitProxyTarget{}, err
+	}
+
+	svc, err := gd.Service(u.ServiceID)
+	if err != nil {
+		return UnitProxyTarget{}, err
+	}
+
+	spec, err := svc.Spec()
+	if err != nil {
+		return UnitProxyTarget{}, err
+	}
+
+	us, err := unitSpecByID(spec.Units, u.ID)
+	if err != nil {
+		return UnitProxyTarget{}, err
+	}
+
+	configs, err := svc.GetUnitsConfigs(ctx)
+	if err != nil {
+		return UnitProxyTarget{}, err
+	}
+
+	opts, err := unitConfigOptions(spec.Options, configs, u.ID)
+	if err != nil {
+		return UnitProxyTarget{}, err
+	}
+
+	us.Ports = unitPorts(spec.Image.Name, opts)
+
+	return resolveProxyTarget(spec.Name, us, port, networking, mode)
+}
+
+// unitConfigOptions returns the service options overridden by the current config values of the unit.
+func unitConfigOptions(opts map[string]interface{}, configs structs.ServiceConfigs, unitID string) (map[string]interface{}, error) {
+	for i := range configs {
+		if configs[i].ID != unitID {
+			continue
+		}
+
+		out := make(map[string]interface{}, len(opts)+len(configs[i].Keysets))
+		for key, val := range opts {
+			out[key] = val
+		}
+
+		for _, ks := range configs[i].Keysets {
+			if ks.Value != "" {
+				out[ks.Key] = ks.Value
+			}
+		}
+
+		return out, nil
+	}
+
+	return nil, errors.Errorf("not found the config of unit %s", unitID)
+}
+
+func unitSpecByID(units []structs.UnitSpec, ID string) (structs.UnitSpec, error) {
+	for i := range units {
+		if units[i].ID == ID {
+			return units[i], nil
+		}
+	}
+
+	return structs.UnitSpec{}, errors.Errorf("not found unit %s in service spec", ID)
+}
+
+func resolveProxyTarget(service string, u structs.UnitSpec, port, networking, mode string) (UnitProxyTarget, error) {
+	var up *structs.UnitPort
+
+	for i := range u.Ports {
+		if u.Ports[i].Name == port || strconv.Itoa(u.Ports[i].Port) == port {
+			up = &u.Ports[i]
+			break
+		}
+	}
+
+	if up == nil {
+		return UnitProxyTarget{}, errors.Wrapf(ErrProxyPortDenied, "unit %s port '%s',allowed ports %v", u.Name, port, u.Ports)
+	}
+
+	if mode == UnitProxyHTTP && up.Proto != UnitProxyHTTP {
+		return UnitProxyTarget{}, errors.Wrapf(ErrProxyPortDenied, "unit %s port '%s' supports %s tunnel only", u.Name, up.Name, up.Proto)
+	}
+
+	var ip string
+
+	for i := range u.Networking {
+		if networking == "" || u.Networking[i].Networking == networking || u.Networking[i].IP == networking {
+			ip = u.Networking[i].IP
+			break
+		}
+	}
+
+	if ip == "" {
+		return UnitProxyTarget{}, errors.Wrapf(ErrProxyNetworking, "unit %s networking '%s'", u.Name, networking)
+	}
+
+	return UnitProxyTarget{
+		Service: service,
+		Unit:    u.Name,
+		Port:    *up,
+		Addr:    net.JoinHostPort(ip, strconv.Itoa(up.Port)),
+	}, nil
+}
@@ -0,0 +1,95 @@
+package garden
+
+import (
+	"testing"
+
+	"github.com/docker/swarm/garden/structs"
+	"github.com/pkg/errors"
+)
+
+func TestUnitPorts(t *testing.T) {
+	ports := unitPorts("upproxy", map[string]interface{}{
+		"upsql-proxy::proxy_data_port": float64(3306),
+		"proxy_admin_port":             "3307",
+	})
+	if len(ports) != 2 ||
+		ports[0] != (structs.UnitPort{Name: "proxy", Port: 3306, Proto: UnitProxyTCP}) ||
+		ports[1] != (structs.UnitPort{Name: "admin", Port: 3307, Proto: UnitProxyTCP}) {
+		t.Errorf("unexpected ports %v", ports)
+	}
+
+	if ports := unitPorts("upsql", map[string]interface{}{"port": 3306}); len(ports) != 0 {
+		t.Errorf("expected no ports without mysqld::port but got %v", ports)
+	}
+
+	if ports := unitPorts("unknown", map[string]interface{}{"port": 3306}); len(ports) != 0 {
+		t.Errorf("expected no ports of unknown image but got %v", ports)
+	}
+}
+
+func TestUnitConfigOptions(t *testing.T) {
+	configs := structs.ServiceConfigs{
+		{ID: "unit001"},
+		{ID: "unit002"},
+	}
+	configs[1].Keysets = []structs.Keyset{
+		{Key: "mysqld::port", Value: "3307"},
+		{Key: "mysqld::socket"},
+	}
+
+	opts := map[string]interface{}{"mysqld::port": float64(3306)}
+
+	out, err := unitConfigOptions(opts, configs, "unit002")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ports := unitPorts("upsql", out)
+	if len(ports) != 1 || ports[0].Port != 3307 || opts["mysqld::port"] != float64(3306) {
+		t.Errorf("expected the updated port 3307 but got %v", ports)
+	}
+
+	if _, err := unitConfigOptions(opts, configs, "unit003"); err == nil {
+		t.Error("expected error without unit config")
+	}
+}
+
+func TestResolveProxyTarget(t *testing.T) {
+	u := structs.UnitSpec{
+		Unit: structs.Unit{ID: "unit001", Name: "unit001_switch"},
+		Networking: []structs.UnitIP{
+			{IP: "192.168.4.141", Networking: "net_business"},
+			{IP: "10.0.0.141", Networking: "net_internal"},
+		},
+		Ports: []structs.UnitPort{
+			{Name: "http", Port: 8080, Proto: UnitProxyHTTP},
+			{Name: "data", Port: 3306, Proto: UnitProxyTCP},
+		},
+	}
+
+	tests := []struct {
+		port, networking, mode string
+		addr                   string
+		err                    error
+	}{
+		{"http", "", UnitProxyHTTP, "192.168.4.141:8080", nil},
+		{"8080", "net_internal", UnitProxyTCP, "10.0.0.141:8080", nil},
+		{"data", "10.0.0.141", UnitProxyTCP, "10.0.0.141:3306", nil},
+		{"data", "", UnitProxyHTTP, "", ErrProxyPortDenied},
+		{"22", "", UnitProxyTCP, "", ErrProxyPortDenied},
+		{"", "", UnitProxyHTTP, "", ErrProxyPortDenied},
+		{"http", "net_other", UnitProxyHTTP, "", ErrProxyNetworking},
+	}
+
+	for _, test := range tests {
+		target, err := resolveProxyTarget("service001", u, test.port, test.networking, test.mode)
+		if errors.Cause(err) != test.err {
+			t.Errorf("%v expected error %v but got %v", test, test.err, err)
+			continue
+		}
+
+		if target.Addr != test.addr {
+			t.Errorf("%v expected %s but got %s", test, test.addr, target.Addr)
+		}
+	}
+}
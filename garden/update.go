@@ -14,7 +14,6 @@ import (
 	"github.com/docker/swarm/garden/kvstore"
 	"github.com/docker/swarm/garden/resource/alloc"
 	"github.com/docker/swarm/garden/structs"
-	"github.com/docker/swarm/garden/tasklock"
 	"github.com/docker/swarm/garden/utils"
 	"github.com/docker/swarm/scheduler/node"
 	"github.com/pkg/errors"
@@ -58,6 +57,19 @@ func (svc *Service) UpdateImage(ctx context.Context, kvc kvstore.Client,
 			return nil
 		}
 
+		{
+			// validate units configs migrated to the new image template before any unit stopped
+			spec, err := svc.RefreshSpec()
+			if err != nil {
+				return err
+			}
+
+			_, err = svc.migrateConfigs(ctx, *spec, im, true)
+			if err != nil {
+				return err
+			}
+		}
+
 		err = svc.stop(ctx, units, true)
 		if err != nil {
 			return err
@@ -127,8 +139,23 @@ func (svc *Service) UpdateImage(ctx context.Context, kvc kvstore.Client,
 			}
 		}
 		{
-			// start units services
-			_, err := svc.RefreshSpec()
+			// migrate units configs to the new image template,start units services
+			spec, err := svc.RefreshSpec()
+			if err != nil {
+				return err
+			}
+
+			cm, err := svc.migrateConfigs(ctx, *spec, im, false)
+			if err != nil {
+				return err
+			}
+
+			units, err := svc.getUnits()
+			if err != nil {
+				return err
+			}
+
+			err = svc.updateConfigs(ctx, units, cm, nil, true)
 			if err != nil {
 				return err
 			}
@@ -166,9 +193,8 @@ func (svc *Service) UpdateImage(ctx context.Context, kvc kvstore.Client,
 		}
 	}
 
-	tl := tasklock.NewServiceTask(
-		database.ServiceUpdateImageTask,
-		svc.ID(), svc.so, task,
+	tl := svc.serviceTask(ctx,
+		database.ServiceUpdateImageTask, task,
 		statusServiceImageUpdating,
 		statusServiceImageUpdated,
 		statusServiceImageUpdateFailed)
@@ -176,6 +202,21 @@ func (svc *Service) UpdateImage(ctx context.Context, kvc kvstore.Client,
 	return tl.Run(isnotInProgress, update, async)
 }
 
+// migrateConfigs migrates the units configs stored by plugin to the template of the image,
+// the migrated configs aren't stored by plugin if dryRun.
+func (svc *Service) migrateConfigs(ctx context.Context, spec structs.ServiceSpec, im database.Image, dryRun bool) (structs.ConfigsMap, error) {
+	spec.Image = structs.ImageVersion{
+		ID:    im.ID,
+		Name:  im.Name,
+		Major: im.Major,
+		Minor: im.Minor,
+		Patch: im.Patch,
+		Dev:   im.Dev,
+	}
+
+	return svc.pc.MigrateConfigs(ctx, spec, dryRun)
+}
+
 func updateDescByImage(table database.Service, im database.Image) database.Service {
 	desc := *table.Desc
 	desc.ID = utils.Generate32UUID()
@@ -217,6 +258,12 @@ func (svc *Service) UpdateResource(ctx context.Context, actor alloc.Allocator, n
 			return err
 		}
 
+		// services created before the policy has no policy
+		policy := structs.ResourcePolicy{}
+		if opts, err := svc.getScheduleOption(); err == nil {
+			policy = opts.Require.Policy
+		}
+
 		pendings := make([]pending, 0, len(units))
 
 		for _, u := range units {
@@ -270,7 +317,7 @@ func (svc *Service) UpdateResource(ctx context.Context, actor alloc.Allocator, n
 			if c.Config.HostConfig.Memory < *memory || nccpu < *ncpu {
 				node := node.NewNode(c.Engine)
 
-				cpuset, err := actor.AlloctCPUMemory(c.Config, node, *ncpu-nccpu, *memory-c.Config.HostConfig.Memory, nil)
+				cpuset, err := actor.AlloctCPUMemory(c.Config, node, *ncpu-nccpu, *memory-c.Config.HostConfig.Memory, nil, policy)
 				if err != nil {
 					return err
 				}
@@ -286,8 +333,9 @@ func (svc *Service) UpdateResource(ctx context.Context, actor alloc.Allocator, n
 			pu.config = container.UpdateConfig{
 				Resources: container.Resources{
 					CpusetCpus: pu.cpuset,
+					CpusetMems: c.Config.HostConfig.CpusetMems,
 					Memory:     pu.memory,
-					MemorySwap: int64(float64(pu.memory) * 1.5),
+					MemorySwap: policy.MemorySwap(pu.memory),
 				},
 			}
 
@@ -332,7 +380,7 @@ func (svc *Service) UpdateResource(ctx context.Context, actor alloc.Allocator, n
 		}
 	}
 
-	sl := tasklock.NewServiceTask(database.ServiceUpdateTask+"_cpu", svc.ID(), svc.so, nil,
+	sl := svc.serviceTask(ctx, database.ServiceUpdateTask+"_cpu", nil,
 		statusServiceResourceUpdating, statusServiceResourceUpdated, statusServiceResourceUpdateFailed)
 
 	return sl.Run(isnotInProgress, update, false)
@@ -488,7 +536,7 @@ func reduceCPUset(cpusetCpus string, need int) (string, error) {
 }
 
 // VolumeExpansion expand container volume size.
-func (svc *Service) VolumeExpansion(actor alloc.Allocator, target []structs.VolumeRequire) error {
+func (svc *Service) VolumeExpansion(ctx context.Context, actor alloc.Allocator, target []structs.VolumeRequire) error {
 	if len(target) == 0 {
 		return nil
 	}
@@ -579,7 +627,7 @@ func (svc *Service) VolumeExpansion(actor alloc.Allocator, target []structs.Volu
 		}
 	}
 
-	sl := tasklock.NewServiceTask(database.ServiceUpdateTask+"_lv", svc.ID(), svc.so, nil,
+	sl := svc.serviceTask(ctx, database.ServiceUpdateTask+"_lv", nil,
 		statusServiceVolumeExpanding, statusServiceVolumeExpanded, statusServiceVolumeExpandFailed)
 
 	return sl.Run(isnotInProgress, expansion, false)
@@ -706,7 +754,7 @@ func (svc *Service) UpdateNetworking(ctx context.Context, actor alloc.Allocator,
 		}
 	}
 
-	sl := tasklock.NewServiceTask(database.ServiceUpdateTask+"_net", svc.ID(), svc.so, nil,
+	sl := svc.serviceTask(ctx, database.ServiceUpdateTask+"_net", nil,
 		statusServiceNetworkUpdating, statusServiceNetworkUpdated, statusServiceNetworkUpdateFailed)
 
 	return sl.Run(isnotInProgress, update, false)
@@ -3,10 +3,12 @@ package utils
 import (
 	"bytes"
 	crand "crypto/rand"
+	"crypto/sha256"
 	"encoding/base64"
 	"encoding/binary"
 	"encoding/json"
 	"fmt"
+	"io"
 	"math/rand"
 	"net"
 	"net/http"
@@ -293,6 +295,46 @@ func GetAbsolutePath(isDir bool, path ...string) (string, error) {
 	return abs, nil
 }
 
+// ChecksumPath returns the sha256 checksum of the file,
+// or of all regular files under the directory in lexical order,including the relative paths.
+func ChecksumPath(path string) (string, error) {
+	h := sha256.New()
+
+	walk := func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(path, file)
+		if err != nil {
+			return err
+		}
+
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		fmt.Fprintf(h, "%s\x00%d\x00", filepath.ToSlash(rel), info.Size())
+
+		_, err = io.Copy(h, f)
+
+		return err
+	}
+
+	err := filepath.Walk(path, walk)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("sha256:%x", h.Sum(nil)), nil
+}
+
 // CountCPU returns CPU num,calls ParseUintList
 func CountCPU(val string) (int64, error) {
 	if val == "" {
@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"reflect"
@@ -330,6 +331,60 @@ func TestParseUintList(t *testing.T) {
 	}
 }
 
+func TestChecksumPath(t *testing.T) {
+	dir, err := ioutil.TempDir("", "checksum")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	err = os.MkdirAll(filepath.Join(dir, "sub"), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := map[string]string{
+		"a.sql":     "create table a;",
+		"sub/b.sql": "create table b;",
+	}
+	for name, data := range files {
+		err := ioutil.WriteFile(filepath.Join(dir, name), []byte(data), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sum, err := ChecksumPath(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(sum, "sha256:") || len(sum) != len("sha256:")+64 {
+		t.Errorf("unexpected checksum %q", sum)
+	}
+
+	if again, _ := ChecksumPath(dir); again != sum {
+		t.Errorf("checksum should be stable,%s!=%s", sum, again)
+	}
+
+	err = ioutil.WriteFile(filepath.Join(dir, "sub/b.sql"), []byte("create table c;"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if changed, _ := ChecksumPath(dir); changed == sum {
+		t.Error("checksum should be changed with the file content")
+	}
+
+	file, err := ChecksumPath(filepath.Join(dir, "a.sql"))
+	if err != nil || file == sum {
+		t.Errorf("unexpected checksum of file %q,%v", file, err)
+	}
+
+	if _, err := ChecksumPath(filepath.Join(dir, "not_exist")); err == nil {
+		t.Error("expected error with not exist path")
+	}
+}
+
 func TestParseTime(t *testing.T) {
 	now := time.Now()
 	timeString := TimeToString(now)
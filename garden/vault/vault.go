@@ -0,0 +1,84 @@
+package vault
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Vault encrypts and decrypts secrets by AES-256-GCM,
+// the encryption key is derived from the master key.
+type Vault struct {
+	aead cipher.AEAD
+}
+
+// New returns a Vault with the master key
+func New(master []byte) (*Vault, error) {
+	if len(master) == 0 {
+		return nil, errors.New("vault master key is required")
+	}
+
+	key := sha256.Sum256(master)
+
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, errors.Wrap(err, "new AES cipher")
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, errors.Wrap(err, "new GCM cipher")
+	}
+
+	return &Vault{aead: aead}, nil
+}
+
+// NewFromFile returns a Vault with the master key read from file
+func NewFromFile(file string) (*Vault, error) {
+	master, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, errors.Wrap(err, "read vault master key file")
+	}
+
+	return New([]byte(strings.TrimSpace(string(master))))
+}
+
+// Encrypt returns base64 encoded nonce and ciphertext of plaintext
+func (v *Vault) Encrypt(plaintext []byte) (string, error) {
+	nonce := make([]byte, v.aead.NonceSize())
+
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", errors.Wrap(err, "generate nonce")
+	}
+
+	out := v.aead.Seal(nonce, nonce, plaintext, nil)
+
+	return base64.StdEncoding.EncodeToString(out), nil
+}
+
+// Decrypt returns plaintext of the secret encrypted by Encrypt
+func (v *Vault) Decrypt(secret string) ([]byte, error) {
+	data, err := base64.StdEncoding.DecodeString(secret)
+	if err != nil {
+		return nil, errors.Wrap(err, "decode secret")
+	}
+
+	size := v.aead.NonceSize()
+	if len(data) < size {
+		return nil, errors.New("secret is too short")
+	}
+
+	out, err := v.aead.Open(nil, data[:size], data[size:], nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "decrypt secret")
+	}
+
+	return out, nil
+}
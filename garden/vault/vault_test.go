@@ -0,0 +1,46 @@
+package vault
+
+import "testing"
+
+func TestVault(t *testing.T) {
+	v, err := New([]byte("master key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	secret, err := v.Encrypt([]byte("password"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	another, err := v.Encrypt([]byte("password"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if secret == another {
+		t.Error("expected different ciphertext with random nonce")
+	}
+
+	out, err := v.Decrypt(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(out) != "password" {
+		t.Errorf("expected %s but got %s", "password", out)
+	}
+
+	v1, err := New([]byte("another key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := v1.Decrypt(secret); err == nil {
+		t.Error("expected error when decrypt with another key")
+	}
+
+	if _, err := New(nil); err == nil {
+		t.Error("expected error with empty master key")
+	}
+}
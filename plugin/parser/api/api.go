@@ -8,6 +8,7 @@ import (
 	"io/ioutil"
 	"net/http"
 
+	"github.com/docker/swarm/garden/metrics"
 	"github.com/docker/swarm/garden/structs"
 	"github.com/pkg/errors"
 	"golang.org/x/net/context"
@@ -36,7 +37,10 @@ type PluginAPI interface {
 	PostImageTemplate(ctx context.Context, ct structs.ConfigTemplate) error
 
 	UpdateConfigs(ctx context.Context, service string, configs structs.ServiceConfigs) (structs.ConfigsMap, error)
+	MigrateConfigs(ctx context.Context, spec structs.ServiceSpec, dryRun bool) (structs.ConfigsMap, error)
 	ServiceCompose(ctx context.Context, spec structs.ServiceSpec) error
+	ServiceSwitchover(ctx context.Context, req structs.ServiceSwitchover) (structs.ServiceSwitchoverResponse, error)
+	ServiceTopology(ctx context.Context, spec structs.ServiceSpec) ([]structs.UnitTopology, error)
 	ServicesLink(ctx context.Context, links structs.ServicesLink) (structs.ServiceLinkResponse, error)
 }
 
@@ -256,6 +260,64 @@ func (p plugin) ServiceCompose(ctx context.Context, spec structs.ServiceSpec) er
 	return nil
 }
 
+func (p plugin) ServiceSwitchover(ctx context.Context, req structs.ServiceSwitchover) (structs.ServiceSwitchoverResponse, error) {
+	uri := fmt.Sprintf("/services/%s/switchover", req.Spec.ID)
+	obj := structs.ServiceSwitchoverResponse{}
+
+	resp, err := requireOK(p.c.Put(ctx, uri, req))
+	if err != nil {
+		return obj, err
+	}
+	defer resp.Body.Close()
+
+	err = decodeBody(resp, &obj)
+	if err != nil {
+		return obj, errors.Errorf("%s %s%s,%v", http.MethodPut, p.host, uri, err)
+	}
+
+	return obj, nil
+}
+
+func (p plugin) ServiceTopology(ctx context.Context, spec structs.ServiceSpec) ([]structs.UnitTopology, error) {
+	uri := fmt.Sprintf("/services/%s/topology", spec.ID)
+
+	resp, err := requireOK(p.c.Post(ctx, uri, spec))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var out []structs.UnitTopology
+
+	err = decodeBody(resp, &out)
+	if err != nil {
+		return nil, errors.Errorf("%s %s%s,%v", http.MethodPost, p.host, uri, err)
+	}
+
+	return out, nil
+}
+
+// MigrateConfigs migrates the service units configs to the template of the spec image,
+// the migrated configs aren't stored if dryRun.
+func (p plugin) MigrateConfigs(ctx context.Context, spec structs.ServiceSpec, dryRun bool) (structs.ConfigsMap, error) {
+	uri := fmt.Sprintf("/services/%s/configs/migrate?dry_run=%t", spec.ID, dryRun)
+
+	resp, err := requireOK(p.c.Post(ctx, uri, spec))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var m structs.ConfigsMap
+
+	err = decodeBody(resp, &m)
+	if err != nil {
+		return nil, errors.Errorf("%s %s%s,%v", http.MethodPost, p.host, uri, err)
+	}
+
+	return m, nil
+}
+
 func (p plugin) ServicesLink(ctx context.Context, links structs.ServicesLink) (structs.ServiceLinkResponse, error) {
 	const uri = "/services/link"
 	obj := structs.ServiceLinkResponse{}
@@ -284,6 +346,8 @@ func decodeBody(resp *http.Response, out interface{}) error {
 // requireOK is used to wrap doRequest and check for a 200
 func requireOK(resp *http.Response, e error) (*http.Response, error) {
 	if e != nil {
+		metrics.ClientErrors.Inc("plugin")
+
 		if resp != nil && resp.Body != nil {
 			resp.Body.Close()
 		}
@@ -291,6 +355,8 @@ func requireOK(resp *http.Response, e error) (*http.Response, error) {
 	}
 
 	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusBadRequest {
+		metrics.ClientErrors.Inc("plugin")
+
 		buf := bytes.NewBuffer(nil)
 
 		io.Copy(buf, resp.Body)
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/Sirupsen/logrus"
 	"github.com/docker/swarm/garden/structs"
@@ -32,6 +33,7 @@ const (
 	groupRole    dbRole = "GROUP"
 	masterRole   dbRole = "MASTER"
 	slaveRole    dbRole = "SLAVE"
+	sentinelRole dbRole = "SENTINEL"
 )
 
 //Composer is exported
@@ -51,14 +53,23 @@ func NewCompserBySpec(req *structs.ServiceSpec, script, mgmip string, mgmport in
 	arch := getDbType(req)
 
 	switch arch {
-	case mysqlRepArch, mysqlGroupArch:
+	case mysqlRepArch:
+		dbs := getMysqls(req)
+		return newMysqlRepManager(dbs), nil
+
+	case mysqlGroupArch:
+		single, err := IsSinglePrimary(req.Options)
+		if err != nil {
+			return nil, err
+		}
+
 		dbs := getMysqls(req)
-		return newMysqlRepManager(dbs, script, mgmip, mgmport), nil
+		return newMysqlGroupManager(dbs, single), nil
 
 	case redisShardingArch, upredisShardingArch:
 		dbs := getRedis(req)
 		master, slave, _ := getmasterAndSlave(req)
-		return newRedisShardingManager(dbs, master, slave, script), nil
+		return newRedisShardingManager(dbs, master, slave), nil
 
 	case upredisRepArch:
 		dbs := getRedis(req)
@@ -77,6 +88,69 @@ func NewCompserBySpec(req *structs.ServiceSpec, script, mgmip string, mgmport in
 	return nil, errors.New(string(arch) + ":the composer do not implement yet")
 }
 
+// Switchover promotes the target unit to be the master of the replicated service,
+// returns the IDs of the old and the new master.
+// The healthy slave with the lowest lag is choosed if target is empty.
+func Switchover(req *structs.ServiceSpec, target string, maxLag time.Duration) (string, string, error) {
+	if err := valicateServiceSpec(req); err != nil {
+		return "", "", err
+	}
+
+	var (
+		port int
+		err  error
+	)
+
+	arch := getDbType(req)
+
+	switch arch {
+	case mysqlRepArch:
+		port, err = getMysqlPortBySpec(req)
+	case redisRepArch, upredisRepArch:
+		port, err = getRedisPortBySpec(req)
+	default:
+		return "", "", errors.Errorf("%s:switchover is not supported", arch)
+	}
+	if err != nil {
+		return "", "", err
+	}
+
+	// unit ID <-> ip:port
+	keys := make(map[string]string, len(req.Units))
+	units := make(map[string]string, len(req.Units))
+
+	for _, u := range req.Units {
+		if len(u.Networking) == 0 {
+			return "", "", errors.Errorf("unit %s has no networking", u.Name)
+		}
+
+		key := u.Networking[0].IP + ":" + strconv.Itoa(port)
+		keys[u.ID] = key
+		units[key] = u.ID
+	}
+
+	key := ""
+	if target != "" {
+		k, ok := keys[target]
+		if !ok {
+			return "", "", errors.Errorf("unit %s is not a member of service %s", target, req.Name)
+		}
+
+		key = k
+	}
+
+	var old string
+
+	if arch == mysqlRepArch {
+		m := newMysqlRepManager(getMysqls(req)).(*MysqlRepManager)
+		old, key, err = m.Switchover(key, maxLag)
+	} else {
+		old, key, err = newRedisSwitcher(getRedis(req)).Switchover(key, maxLag)
+	}
+
+	return units[old], units[key], err
+}
+
 func valicateServiceSpec(req *structs.ServiceSpec) error {
 	if err := valicateCommonSpec(req); err != nil {
 		return err
@@ -294,6 +368,7 @@ func getRedis(req *structs.ServiceSpec) []Redis {
 		redis := Redis{
 			Ip:   ip,
 			Port: port,
+			Host: unit.Engine.Node,
 		}
 
 		redisslice = append(redisslice, redis)
@@ -308,6 +383,11 @@ func getMysqls(req *structs.ServiceSpec) []Mysql {
 		logrus.Warnf("%+v", err)
 	}
 
+	admin := mysqlUser{
+		user:     vars.Root.User,
+		password: vars.Root.Password,
+	}
+
 	intport, err := getMysqlPortBySpec(req)
 	if err != nil {
 		logrus.Warnf("%+v", err)
@@ -322,6 +402,7 @@ func getMysqls(req *structs.ServiceSpec) []Mysql {
 
 		mysql := Mysql{
 			user:     user,
+			admin:    admin,
 			IP:       ip,
 			Port:     intport,
 			Instance: instance,
@@ -130,6 +130,8 @@ func TestOptions(t *testing.T) {
 }
 
 func TestRedis(t *testing.T) {
+	defer fakeDialRedis(newFakeRedisCluster("192.168.30.105:6379", "192.168.30.104:6379", "192.168.30.103:6379"))()
+
 	spec := getRedisSpecTest()
 	mgmip := "127.0.0.1"
 	mgmport := 123
@@ -1,17 +1,22 @@
 package compose
 
 import (
-	"path/filepath"
+	"database/sql"
+	"fmt"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/Sirupsen/logrus"
-	"github.com/docker/swarm/garden/utils"
+	"github.com/go-sql-driver/mysql"
 	"github.com/pkg/errors"
 )
 
 const defaultTimeout = time.Minute
 
+// MaxReplicationLag is the max Seconds_Behind_Master of a healthy slave.
+var MaxReplicationLag = 60 * time.Second
+
 type mysqlUser struct {
 	user     string
 	password string
@@ -25,70 +30,399 @@ type Mysql struct {
 	Port     int
 	Instance string
 
-	user mysqlUser
+	user  mysqlUser // replication user
+	admin mysqlUser // user to manage the instance
 
 	Weight   int //Weight越高，优先变成master，等值随机
 	RoleType dbRole
+}
+
+// InstanceError is the error of a mysql instance operation.
+type InstanceError struct {
+	Instance string
+	Addr     string
+	Role     dbRole
+	Op       string
+	Err      error
+}
+
+func (e *InstanceError) Error() string {
+	return fmt.Sprintf("mysql %s(%s,%s) %s:%s", e.Instance, e.Addr, e.Role, e.Op, e.Err)
+}
+
+// Cause returns the underlying error,see errors.Cause
+func (e *InstanceError) Cause() error {
+	return e.Err
+}
+
+// InstanceErrors is the errors of several mysql instances.
+type InstanceErrors []*InstanceError
+
+func (errs InstanceErrors) Error() string {
+	out := make([]string, len(errs))
+	for i := range errs {
+		out[i] = errs[i].Error()
+	}
+
+	return strings.Join(out, "\n")
+}
+
+func (errs InstanceErrors) add(err error) InstanceErrors {
+	if err == nil {
+		return errs
+	}
+
+	if ie, ok := err.(*InstanceError); ok {
+		return append(errs, ie)
+	}
+
+	return append(errs, &InstanceError{Err: err})
+}
+
+func (errs InstanceErrors) err() error {
+	if len(errs) == 0 {
+		return nil
+	}
+
+	return errs
+}
+
+// ReplicationStatus is the result of SHOW SLAVE STATUS.
+type ReplicationStatus struct {
+	MasterHost   string
+	MasterPort   int
+	IORunning    bool
+	SQLRunning   bool
+	AutoPosition bool
+	// SecondsBehindMaster is -1 if it's NULL
+	SecondsBehindMaster int
+	LastIOError         string
+	LastSQLError        string
+}
+
+func parseSlaveStatus(cols []string, vals []sql.RawBytes) ReplicationStatus {
+	status := ReplicationStatus{SecondsBehindMaster: -1}
+
+	for i := range cols {
+		if i >= len(vals) {
+			break
+		}
+
+		val := string(vals[i])
+
+		switch cols[i] {
+		case "Master_Host":
+			status.MasterHost = val
+		case "Master_Port":
+			status.MasterPort, _ = strconv.Atoi(val)
+		case "Slave_IO_Running":
+			status.IORunning = val == "Yes"
+		case "Slave_SQL_Running":
+			status.SQLRunning = val == "Yes"
+		case "Auto_Position":
+			status.AutoPosition = val == "1"
+		case "Seconds_Behind_Master":
+			if vals[i] != nil {
+				status.SecondsBehindMaster, _ = strconv.Atoi(val)
+			}
+		case "Last_IO_Error":
+			status.LastIOError = val
+		case "Last_SQL_Error":
+			status.LastSQLError = val
+		}
+	}
+
+	return status
+}
+
+// check returns error if the IO or SQL thread is not running,or the lag exceeds max.
+func (s ReplicationStatus) check(max time.Duration) error {
+	errs := make([]string, 0, 3)
+
+	if !s.IORunning {
+		errs = append(errs, "Slave_IO_Running is not Yes,"+s.LastIOError)
+	}
+
+	if !s.SQLRunning {
+		errs = append(errs, "Slave_SQL_Running is not Yes,"+s.LastSQLError)
+	}
+
+	if len(errs) == 0 && time.Duration(s.SecondsBehindMaster)*time.Second > max {
+		errs = append(errs, fmt.Sprintf("replication lag %ds exceeds %s", s.SecondsBehindMaster, max))
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+
+	return errors.New(strings.Join(errs, ";"))
+}
+
+// mysqlConn is the connection to a mysql instance,replaced by a stand-in in tests.
+type mysqlConn interface {
+	Exec(query string) error
+	Variable(name string) (string, error)
+	// QueryValue returns the first column of the first row
+	QueryValue(query string) (string, error)
+	// SlaveStatus returns nil if the instance is not a slave
+	SlaveStatus() (*ReplicationStatus, error)
+	// GroupMembers returns the group replication members viewed by the instance
+	GroupMembers() ([]GroupMember, error)
+	Close() error
+}
+
+var dialMysql = func(addr string, user mysqlUser) (mysqlConn, error) {
+	cfg := mysql.Config{
+		User:         user.user,
+		Passwd:       user.password,
+		Net:          "tcp",
+		Addr:         addr,
+		Timeout:      10 * time.Second,
+		ReadTimeout:  defaultTimeout,
+		WriteTimeout: defaultTimeout,
+	}
+
+	db, err := sql.Open("mysql", cfg.FormatDSN())
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	db.SetMaxOpenConns(1)
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+
+		return nil, errors.WithStack(err)
+	}
+
+	return sqlConn{db: db}, nil
+}
+
+type sqlConn struct {
+	db *sql.DB
+}
+
+func (c sqlConn) Exec(query string) error {
+	_, err := c.db.Exec(query)
+
+	return errors.WithStack(err)
+}
+
+func (c sqlConn) Variable(name string) (string, error) {
+	return c.QueryValue("SELECT @@GLOBAL." + name)
+}
+
+func (c sqlConn) QueryValue(query string) (string, error) {
+	var val sql.NullString
+
+	err := c.db.QueryRow(query).Scan(&val)
+
+	return val.String, errors.WithStack(err)
+}
+
+func (c sqlConn) SlaveStatus() (*ReplicationStatus, error) {
+	rows, err := c.db.Query("SHOW SLAVE STATUS")
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return nil, errors.WithStack(rows.Err())
+	}
+
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	vals := make([]sql.RawBytes, len(cols))
+	dest := make([]interface{}, len(cols))
+	for i := range vals {
+		dest[i] = &vals[i]
+	}
+
+	if err := rows.Scan(dest...); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	status := parseSlaveStatus(cols, vals)
 
-	MgmPort int
-	MgmIP   string
+	return &status, nil
+}
+
+func (c sqlConn) GroupMembers() ([]GroupMember, error) {
+	var primary sql.NullString
+
+	// empty in multi-primary mode
+	err := c.db.QueryRow("SELECT VARIABLE_VALUE FROM performance_schema.global_status WHERE VARIABLE_NAME='group_replication_primary_member'").Scan(&primary)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, errors.WithStack(err)
+	}
+
+	rows, err := c.db.Query("SELECT MEMBER_ID,MEMBER_HOST,MEMBER_PORT,MEMBER_STATE FROM performance_schema.replication_group_members")
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer rows.Close()
+
+	var out []GroupMember
+
+	for rows.Next() {
+		var (
+			m    GroupMember
+			port sql.NullInt64
+			host sql.NullString
+		)
 
-	scriptDir string
+		if err := rows.Scan(&m.ID, &host, &port, &m.State); err != nil {
+			return nil, errors.WithStack(err)
+		}
+
+		m.Host = host.String
+		m.Port = int(port.Int64)
+		m.Primary = primary.String == "" || primary.String == m.ID
+
+		out = append(out, m)
+	}
+
+	return out, errors.WithStack(rows.Err())
+}
+
+func (c sqlConn) Close() error {
+	return c.db.Close()
+}
+
+// quoteString returns the quoted SQL string literal of s.
+func quoteString(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `'`, `\'`)
+
+	return "'" + r.Replace(s) + "'"
+}
+
+func changeMasterQuery(master Mysql, user mysqlUser) string {
+	return fmt.Sprintf("CHANGE MASTER TO MASTER_HOST=%s,MASTER_PORT=%d,MASTER_USER=%s,MASTER_PASSWORD=%s,MASTER_AUTO_POSITION=1",
+		quoteString(master.IP), master.Port, quoteString(user.user), quoteString(user.password))
 }
 
 func (m Mysql) GetKey() string {
 	return m.IP + ":" + strconv.Itoa(m.Port)
 }
 
-func (m Mysql) Clear() error {
-	args := []string{
-		filepath.Join(m.scriptDir, "mysql-replication-reset.sh"),
-		m.Instance,
-		m.MgmIP,
-		strconv.Itoa(m.MgmPort),
+func (m Mysql) instanceError(op string, err error) error {
+	if err == nil {
+		return nil
 	}
 
-	out, err := utils.ExecContextTimeout(nil, defaultTimeout, args...)
-	logrus.Debugf("exec:%s,output:%s", args, out)
+	return &InstanceError{
+		Instance: m.Instance,
+		Addr:     m.GetKey(),
+		Role:     m.RoleType,
+		Op:       op,
+		Err:      err,
+	}
+}
 
-	return errors.WithStack(err)
+func (m Mysql) connect() (mysqlConn, error) {
+	conn, err := dialMysql(m.GetKey(), m.admin)
+
+	return conn, m.instanceError("connect", err)
+}
+
+type mysqlStep struct {
+	op    string
+	query string
+}
+
+func (m Mysql) execSteps(conn mysqlConn, steps []mysqlStep) error {
+	for _, s := range steps {
+		if err := conn.Exec(s.query); err != nil {
+			return m.instanceError(s.op, err)
+		}
+
+		logrus.Debugf("mysql %s:%s done", m.Instance, s.op)
+	}
+
+	return nil
+}
+
+// Clear stops and resets the replication of the instance,
+// the binlogs and gtid_executed are reset by RESET MASTER as the reset script did.
+func (m Mysql) Clear() error {
+	conn, err := m.connect()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	return m.execSteps(conn, []mysqlStep{
+		{op: "stop slave", query: "STOP SLAVE"},
+		{op: "reset slave", query: "RESET SLAVE ALL"},
+		{op: "reset master", query: "RESET MASTER"},
+	})
 }
 
 func (m Mysql) GetType() dbRole {
 	return m.RoleType
 }
 
+// ChangeMaster makes the master writable,
+// or replicates the slave from master by GTID auto position.
 func (m Mysql) ChangeMaster(master Mysql) error {
 	if m.GetType() != masterRole && m.GetType() != slaveRole {
 		return errors.New(string(m.GetType()) + ":should not call the func")
 	}
 
-	args := []string{
-		filepath.Join(m.scriptDir, "mysql-replication-set.sh"),
-		m.Instance,
-		m.MgmIP,
-		strconv.Itoa(m.MgmPort),
-		string(m.RoleType),
-		master.IP,
-		strconv.Itoa(master.Port),
-		m.user.user,
-		m.user.password,
-		m.IP,
-		strconv.Itoa(m.Port),
+	conn, err := m.connect()
+	if err != nil {
+		return err
 	}
+	defer conn.Close()
 
-	out, err := utils.ExecContextTimeout(nil, defaultTimeout, args...)
+	if m.GetType() == masterRole {
+		return m.execSteps(conn, []mysqlStep{
+			{op: "set read_only", query: "SET GLOBAL read_only=OFF"},
+		})
+	}
 
-	logrus.Debugf("exec:%s,output:%s", args, out)
+	mode, err := conn.Variable("gtid_mode")
+	if err != nil {
+		return m.instanceError("query gtid_mode", err)
+	}
 
-	return errors.WithStack(err)
+	if mode != "ON" {
+		return m.instanceError("check gtid_mode", errors.Errorf("gtid_mode is %q,GTID based replication requires ON", mode))
+	}
+
+	return m.execSteps(conn, []mysqlStep{
+		{op: "stop slave", query: "STOP SLAVE"},
+		{op: "change master", query: changeMasterQuery(master, m.user)},
+		{op: "set read_only", query: "SET GLOBAL read_only=ON"},
+		{op: "start slave", query: "START SLAVE"},
+	})
 }
 
+// CheckStatus checks the replication of the instance,
+// the IO and SQL threads of slave should be running,and the lag not exceeds MaxReplicationLag.
 func (m Mysql) CheckStatus() error {
-	args := filepath.Join(m.scriptDir, "mysqlcheck.sh")
-	out, err := utils.ExecContextTimeout(nil, defaultTimeout, args)
+	conn, err := m.connect()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
 
-	logrus.Debugf("exec:%s,output:%s", args, out)
+	status, err := conn.SlaveStatus()
+	if err != nil {
+		return m.instanceError("show slave status", err)
+	}
 
-	return errors.WithStack(err)
+	if status == nil {
+		if m.GetType() == slaveRole {
+			return m.instanceError("check replication", errors.New("replication is not configured"))
+		}
+
+		return nil
+	}
+
+	return m.instanceError("check replication", status.check(MaxReplicationLag))
 }
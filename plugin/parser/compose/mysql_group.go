@@ -0,0 +1,237 @@
+package compose
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/pkg/errors"
+)
+
+const (
+	// GroupModeOption is the ServiceSpec.Options key of group replication mode
+	GroupModeOption = "group_replication_mode"
+	// SinglePrimaryMode is the default group replication mode
+	SinglePrimaryMode = "single_primary"
+	MultiPrimaryMode  = "multi_primary"
+
+	memberOnline = "ONLINE"
+)
+
+// IsSinglePrimary returns true if the group replication mode in options is single-primary,
+// the default mode is single-primary.
+func IsSinglePrimary(options map[string]interface{}) (bool, error) {
+	v, ok := options[GroupModeOption]
+	if !ok || v == nil {
+		return true, nil
+	}
+
+	switch mode := fmt.Sprintf("%v", v); mode {
+	case "", SinglePrimaryMode:
+		return true, nil
+	case MultiPrimaryMode:
+		return false, nil
+	default:
+		return false, errors.Errorf("unsupported %s:%s", GroupModeOption, mode)
+	}
+}
+
+// GroupMember is a member of mysql group replication.
+type GroupMember struct {
+	ID      string `json:"id"`
+	Host    string `json:"host"`
+	Port    int    `json:"port"`
+	State   string `json:"state"`
+	Primary bool   `json:"primary"`
+}
+
+func (m GroupMember) key() string {
+	return m.Host + ":" + strconv.Itoa(m.Port)
+}
+
+// MysqlGroupManager composes mysql group replication,
+// the member with the highest weight bootstraps the group,others join the group.
+type MysqlGroupManager struct {
+	Mysqls        []Mysql
+	SinglePrimary bool
+}
+
+func newMysqlGroupManager(dbs []Mysql, singlePrimary bool) Composer {
+	mysqls := make([]Mysql, len(dbs))
+	copy(mysqls, dbs)
+
+	sort.SliceStable(mysqls, func(i, j int) bool {
+		return mysqls[i].Weight > mysqls[j].Weight
+	})
+
+	for i := range mysqls {
+		mysqls[i].RoleType = groupRole
+	}
+
+	return &MysqlGroupManager{
+		Mysqls:        mysqls,
+		SinglePrimary: singlePrimary,
+	}
+}
+
+// OnOff returns the MySQL boolean value of on,ON or OFF.
+func OnOff(on bool) string {
+	if on {
+		return "ON"
+	}
+
+	return "OFF"
+}
+
+// groupSteps returns the steps before START GROUP_REPLICATION.
+func (m *MysqlGroupManager) groupSteps(db Mysql) []mysqlStep {
+	return []mysqlStep{
+		{op: "set single_primary_mode", query: "SET GLOBAL group_replication_single_primary_mode=" + OnOff(m.SinglePrimary)},
+		{op: "set enforce_update_everywhere_checks", query: "SET GLOBAL group_replication_enforce_update_everywhere_checks=" + OnOff(!m.SinglePrimary)},
+		{op: "change recovery channel", query: fmt.Sprintf("CHANGE MASTER TO MASTER_USER=%s,MASTER_PASSWORD=%s FOR CHANNEL 'group_replication_recovery'",
+			quoteString(db.user.user), quoteString(db.user.password))},
+	}
+}
+
+func (m *MysqlGroupManager) bootstrap(db Mysql) error {
+	conn, err := db.connect()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	steps := append(m.groupSteps(db),
+		mysqlStep{op: "set bootstrap_group", query: "SET GLOBAL group_replication_bootstrap_group=ON"},
+		mysqlStep{op: "start group_replication", query: "START GROUP_REPLICATION"})
+
+	err = db.execSteps(conn, steps)
+
+	// always turn off,otherwise a restarted member bootstraps another group
+	if e := conn.Exec("SET GLOBAL group_replication_bootstrap_group=OFF"); e != nil && err == nil {
+		err = db.instanceError("reset bootstrap_group", e)
+	}
+
+	return err
+}
+
+func (m *MysqlGroupManager) join(db Mysql) error {
+	conn, err := db.connect()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	steps := append(m.groupSteps(db),
+		mysqlStep{op: "start group_replication", query: "START GROUP_REPLICATION"})
+
+	return db.execSteps(conn, steps)
+}
+
+// ComposeCluster bootstraps the group on the first member,then joins the others.
+func (m *MysqlGroupManager) ComposeCluster() error {
+	if len(m.Mysqls) == 0 {
+		return errors.New("no mysql in the group")
+	}
+
+	if err := m.ClearCluster(); err != nil {
+		return err
+	}
+
+	if err := m.bootstrap(m.Mysqls[0]); err != nil {
+		return err
+	}
+
+	var errs InstanceErrors
+
+	for _, db := range m.Mysqls[1:] {
+		errs = errs.add(m.join(db))
+	}
+
+	if members, err := m.Members(); err == nil {
+		logrus.Debugf("mysql group members:%+v", members)
+	}
+
+	return errs.err()
+}
+
+// ClearCluster stops group replication of all members.
+func (m *MysqlGroupManager) ClearCluster() error {
+	var errs InstanceErrors
+
+	for _, db := range m.Mysqls {
+		conn, err := db.connect()
+		if err != nil {
+			errs = errs.add(err)
+			continue
+		}
+
+		errs = errs.add(db.execSteps(conn, []mysqlStep{
+			{op: "stop group_replication", query: "STOP GROUP_REPLICATION"},
+		}))
+
+		conn.Close()
+	}
+
+	return errs.err()
+}
+
+// Members returns the group members viewed by the first reachable member.
+func (m *MysqlGroupManager) Members() ([]GroupMember, error) {
+	var errs InstanceErrors
+
+	for _, db := range m.Mysqls {
+		conn, err := db.connect()
+		if err != nil {
+			errs = errs.add(err)
+			continue
+		}
+
+		members, err := conn.GroupMembers()
+		conn.Close()
+
+		if err == nil {
+			return members, nil
+		}
+
+		errs = errs.add(db.instanceError("query group members", err))
+	}
+
+	return nil, errs.err()
+}
+
+// CheckCluster checks all members are ONLINE,and only one primary in single-primary mode.
+func (m *MysqlGroupManager) CheckCluster() error {
+	members, err := m.Members()
+	if err != nil {
+		return err
+	}
+
+	states := make(map[string]GroupMember, len(members))
+	primary := 0
+
+	for _, member := range members {
+		states[member.key()] = member
+
+		if member.Primary && member.State == memberOnline {
+			primary++
+		}
+	}
+
+	var errs InstanceErrors
+
+	for _, db := range m.Mysqls {
+		member, ok := states[db.GetKey()]
+		if !ok {
+			errs = errs.add(db.instanceError("check member", errors.New("not a member of the group")))
+		} else if member.State != memberOnline {
+			errs = errs.add(db.instanceError("check member", errors.Errorf("member state is %s", member.State)))
+		}
+	}
+
+	if m.SinglePrimary && primary != 1 && len(errs) == 0 {
+		errs = errs.add(errors.Errorf("expected one primary in single-primary mode but got %d", primary))
+	}
+
+	return errs.err()
+}
@@ -1,28 +1,25 @@
 package compose
 
 import (
+	"fmt"
+	"time"
+
+	"github.com/Sirupsen/logrus"
 	"github.com/pkg/errors"
 )
 
 //master-slave mysql manager
 type MysqlRepManager struct {
-	Mysqls  map[string]Mysql
-	MgmIP   string
-	MgmPort int
+	Mysqls map[string]Mysql
 }
 
-func newMysqlRepManager(dbs []Mysql, dir, ip string, port int) Composer {
+func newMysqlRepManager(dbs []Mysql) Composer {
 
 	ms := &MysqlRepManager{
-		MgmIP:   ip,
-		MgmPort: port,
-		Mysqls:  make(map[string]Mysql),
+		Mysqls: make(map[string]Mysql),
 	}
 
 	for _, db := range dbs {
-		db.MgmIP = ip
-		db.MgmPort = port
-		db.scriptDir = dir
 		ms.Mysqls[db.GetKey()] = db
 	}
 
@@ -45,34 +42,41 @@ func (m *MysqlRepManager) ComposeCluster() error {
 
 	master := m.Mysqls[masterkey]
 
+	if err := master.ChangeMaster(master); err != nil {
+		return err
+	}
+
+	var errs InstanceErrors
+
 	for _, db := range m.Mysqls {
 		if db.GetType() != masterRole {
-			if err := db.ChangeMaster(master); err != nil {
-				return err
-			}
+			errs = errs.add(db.ChangeMaster(master))
 		}
 	}
 
-	return nil
+	return errs.err()
 }
 
+// ClearCluster resets replication of all instances,returns InstanceErrors if any failed.
 func (m *MysqlRepManager) ClearCluster() error {
+	var errs InstanceErrors
+
 	for _, db := range m.Mysqls {
-		if err := db.Clear(); err != nil {
-			return err
-		}
+		errs = errs.add(db.Clear())
 	}
 
-	return nil
+	return errs.err()
 }
 
+// CheckCluster checks replication of all instances,returns InstanceErrors if any unhealthy.
 func (m *MysqlRepManager) CheckCluster() error {
+	var errs InstanceErrors
+
 	for _, db := range m.Mysqls {
-		if err := db.CheckStatus(); err != nil {
-			return err
-		}
+		errs = errs.add(db.CheckStatus())
 	}
-	return nil
+
+	return errs.err()
 }
 
 func (m *MysqlRepManager) preCompose() error {
@@ -137,3 +141,169 @@ func (m *MysqlRepManager) setMysqlType(dbkey string, typ dbRole) error {
 	return nil
 
 }
+
+// SwitchoverTimeout is the max time waiting for the new master applies the relay log.
+var SwitchoverTimeout = 30 * time.Second
+
+// currentMaster returns the key of the only instance which is not a slave.
+func (m *MysqlRepManager) currentMaster() (string, map[string]*ReplicationStatus, error) {
+	var (
+		masters []string
+		errs    InstanceErrors
+	)
+
+	status := make(map[string]*ReplicationStatus, len(m.Mysqls))
+
+	for key, db := range m.Mysqls {
+		conn, err := db.connect()
+		if err != nil {
+			errs = errs.add(err)
+			continue
+		}
+
+		s, err := conn.SlaveStatus()
+		conn.Close()
+
+		if err != nil {
+			errs = errs.add(db.instanceError("show slave status", err))
+			continue
+		}
+
+		status[key] = s
+
+		if s == nil {
+			masters = append(masters, key)
+		}
+	}
+
+	if err := errs.err(); err != nil {
+		return "", nil, err
+	}
+
+	if len(masters) != 1 {
+		return "", nil, errors.Errorf("expected one master but got %d,%s", len(masters), masters)
+	}
+
+	return masters[0], status, nil
+}
+
+// Switchover promotes target to be the new master,returns the old and the new master,
+// target is the ip:port of a slave,the healthy slave with the lowest lag is choosed if target is empty.
+// The old master is fenced(read_only) before the target applied all its transactions,
+// then the others replicate from the new master.
+func (m *MysqlRepManager) Switchover(target string, maxLag time.Duration) (string, string, error) {
+	old, status, err := m.currentMaster()
+	if err != nil {
+		return "", "", err
+	}
+
+	if target == "" {
+		lag := -1
+
+		for key, s := range status {
+			if s == nil || s.check(maxLag) != nil {
+				continue
+			}
+
+			if lag == -1 || s.SecondsBehindMaster < lag {
+				target, lag = key, s.SecondsBehindMaster
+			}
+		}
+
+		if target == "" {
+			return old, target, errors.New("no healthy slave to switchover")
+		}
+	}
+
+	if _, ok := m.Mysqls[target]; !ok {
+		return old, target, errors.Errorf("%s is not a member of the service", target)
+	}
+
+	if target == old {
+		return old, target, errors.Errorf("%s is the master already", target)
+	}
+
+	for key := range m.Mysqls {
+		if key == old {
+			m.setMysqlType(key, masterRole)
+		} else {
+			m.setMysqlType(key, slaveRole)
+		}
+	}
+
+	db := m.Mysqls[target]
+
+	if err := db.instanceError("check replication", status[target].check(maxLag)); err != nil {
+		return old, target, err
+	}
+
+	master := m.Mysqls[old]
+
+	mconn, err := master.connect()
+	if err != nil {
+		return old, target, err
+	}
+	defer mconn.Close()
+
+	err = master.execSteps(mconn, []mysqlStep{
+		{op: "fence", query: "SET GLOBAL read_only=ON"},
+	})
+	if err != nil {
+		return old, target, err
+	}
+
+	if err := m.promote(master, mconn, db); err != nil {
+		// unfence the old master
+		if e := mconn.Exec("SET GLOBAL read_only=OFF"); e != nil {
+			logrus.Warnf("%+v", master.instanceError("unfence", e))
+		}
+
+		return old, target, err
+	}
+
+	m.setMysqlType(old, slaveRole)
+	m.setMysqlType(target, masterRole)
+
+	master = m.Mysqls[target]
+
+	var errs InstanceErrors
+
+	for key, db := range m.Mysqls {
+		if key != target {
+			errs = errs.add(db.ChangeMaster(master))
+		}
+	}
+
+	return old, target, errs.err()
+}
+
+// promote waits until db applied all transactions of the fenced master,then stops its replication.
+func (m *MysqlRepManager) promote(master Mysql, mconn mysqlConn, db Mysql) error {
+	gtids, err := mconn.Variable("gtid_executed")
+	if err != nil {
+		return master.instanceError("query gtid_executed", err)
+	}
+
+	conn, err := db.connect()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	query := fmt.Sprintf("SELECT WAIT_UNTIL_SQL_THREAD_AFTER_GTIDS(%s,%d)", quoteString(gtids), int(SwitchoverTimeout.Seconds()))
+
+	out, err := conn.QueryValue(query)
+	if err != nil {
+		return db.instanceError("wait gtid_executed", err)
+	}
+
+	if out == "" || out == "-1" {
+		return db.instanceError("wait gtid_executed", errors.Errorf("timeout after %s,result:%q", SwitchoverTimeout, out))
+	}
+
+	return db.execSteps(conn, []mysqlStep{
+		{op: "stop slave", query: "STOP SLAVE"},
+		{op: "reset slave", query: "RESET SLAVE ALL"},
+		{op: "set read_only", query: "SET GLOBAL read_only=OFF"},
+	})
+}
@@ -0,0 +1,363 @@
+package compose
+
+import (
+	"database/sql"
+	"errors"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeMysql is a stand-in of mysql instance,records the executed queries.
+type fakeMysql struct {
+	lock    sync.Mutex
+	queries []string
+	vars    map[string]string
+	status  *ReplicationStatus
+	members []GroupMember
+	values  map[string]string // QueryValue results by query prefix
+	fail    string            // query prefix to fail
+}
+
+func (f *fakeMysql) Exec(query string) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.fail != "" && strings.HasPrefix(query, f.fail) {
+		return errors.New("ERROR 1200 (HY000)")
+	}
+
+	f.queries = append(f.queries, query)
+
+	return nil
+}
+
+func (f *fakeMysql) Variable(name string) (string, error) {
+	return f.vars[name], nil
+}
+
+func (f *fakeMysql) QueryValue(query string) (string, error) {
+	for prefix, val := range f.values {
+		if strings.HasPrefix(query, prefix) {
+			return val, nil
+		}
+	}
+
+	return "", nil
+}
+
+func (f *fakeMysql) SlaveStatus() (*ReplicationStatus, error) {
+	return f.status, nil
+}
+
+func (f *fakeMysql) GroupMembers() ([]GroupMember, error) {
+	return f.members, nil
+}
+
+func (f *fakeMysql) Close() error {
+	return nil
+}
+
+func fakeDialMysql(t *testing.T, fakes map[string]*fakeMysql) func() {
+	dial := dialMysql
+
+	dialMysql = func(addr string, user mysqlUser) (mysqlConn, error) {
+		if user.user != "root" {
+			t.Errorf("%s:unexpected admin user %s", addr, user.user)
+		}
+
+		f, ok := fakes[addr]
+		if !ok {
+			return nil, errors.New("dial tcp " + addr + ": connection refused")
+		}
+
+		return f, nil
+	}
+
+	return func() { dialMysql = dial }
+}
+
+func TestParseSlaveStatus(t *testing.T) {
+	cols := []string{"Master_Host", "Master_Port", "Slave_IO_Running", "Slave_SQL_Running",
+		"Last_IO_Error", "Seconds_Behind_Master", "Auto_Position"}
+	vals := []sql.RawBytes{[]byte("192.168.1.1"), []byte("3306"), []byte("Yes"), []byte("No"),
+		[]byte(""), nil, []byte("1")}
+
+	s := parseSlaveStatus(cols, vals)
+
+	if s.MasterHost != "192.168.1.1" || s.MasterPort != 3306 ||
+		!s.IORunning || s.SQLRunning || !s.AutoPosition || s.SecondsBehindMaster != -1 {
+		t.Errorf("unexpected status,%+v", s)
+	}
+
+	if err := s.check(MaxReplicationLag); err == nil {
+		t.Error("expected error when SQL thread is not running")
+	}
+
+	s.SQLRunning = true
+	s.SecondsBehindMaster = 61
+	if err := s.check(MaxReplicationLag); err == nil {
+		t.Error("expected error when lag exceeds")
+	}
+
+	s.SecondsBehindMaster = 0
+	if err := s.check(MaxReplicationLag); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestQuoteString(t *testing.T) {
+	if got := quoteString(`p'a\ss`); got != `'p\'a\\ss'` {
+		t.Errorf("unexpected %s", got)
+	}
+}
+
+func TestMysqlRepManager(t *testing.T) {
+	user := mysqlUser{user: "repl", password: "pwd"}
+	admin := mysqlUser{user: "root", password: "root"}
+
+	dbs := []Mysql{
+		{IP: "192.168.1.1", Port: 3306, Instance: "db1", Weight: 2, user: user, admin: admin},
+		{IP: "192.168.1.2", Port: 3306, Instance: "db2", Weight: 1, user: user, admin: admin},
+		{IP: "192.168.1.3", Port: 3306, Instance: "db3", Weight: 0, user: user, admin: admin},
+	}
+
+	gtid := map[string]string{"gtid_mode": "ON"}
+	fakes := map[string]*fakeMysql{
+		"192.168.1.1:3306": {vars: gtid},
+		"192.168.1.2:3306": {vars: gtid},
+		"192.168.1.3:3306": {vars: gtid},
+	}
+	defer fakeDialMysql(t, fakes)()
+
+	m := newMysqlRepManager(dbs)
+
+	if err := m.ComposeCluster(); err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	master := fakes["192.168.1.1:3306"].queries
+	if got := master[len(master)-1]; got != "SET GLOBAL read_only=OFF" {
+		t.Errorf("master,unexpected query %s", got)
+	}
+
+	if got := strings.Join(master[:3], ";"); got != "STOP SLAVE;RESET SLAVE ALL;RESET MASTER" {
+		t.Errorf("master,unexpected clear queries %s", got)
+	}
+
+	want := "CHANGE MASTER TO MASTER_HOST='192.168.1.1',MASTER_PORT=3306,MASTER_USER='repl',MASTER_PASSWORD='pwd',MASTER_AUTO_POSITION=1"
+	for _, addr := range []string{"192.168.1.2:3306", "192.168.1.3:3306"} {
+		queries := strings.Join(fakes[addr].queries, ";")
+		if !strings.Contains(queries, want) || !strings.HasSuffix(queries, "START SLAVE") {
+			t.Errorf("%s,unexpected queries %s", addr, queries)
+		}
+	}
+
+	// slaves
+	fakes["192.168.1.2:3306"].status = &ReplicationStatus{IORunning: true, SQLRunning: true, SecondsBehindMaster: 0}
+	fakes["192.168.1.3:3306"].status = &ReplicationStatus{IORunning: false, SQLRunning: true, LastIOError: "error connecting to master"}
+
+	err := m.CheckCluster()
+	errs, ok := err.(InstanceErrors)
+	if !ok || len(errs) != 1 || errs[0].Instance != "db3" || errs[0].Role != slaveRole {
+		t.Errorf("unexpected error,%v", err)
+	}
+
+	// gtid_mode OFF and unreachable instance
+	fakes["192.168.1.2:3306"].vars = map[string]string{"gtid_mode": "OFF"}
+	delete(fakes, "192.168.1.3:3306")
+
+	err = m.ComposeCluster()
+	errs, ok = err.(InstanceErrors)
+	if !ok || len(errs) != 1 || errs[0].Op != "connect" {
+		t.Errorf("unexpected error,%v", err)
+	}
+
+	fakes["192.168.1.3:3306"] = &fakeMysql{vars: gtid, fail: "CHANGE MASTER"}
+
+	err = m.ComposeCluster()
+	errs, ok = err.(InstanceErrors)
+	if !ok || len(errs) != 2 {
+		t.Fatalf("unexpected error,%v", err)
+	}
+
+	for _, e := range errs {
+		if (e.Instance == "db2" && e.Op != "check gtid_mode") ||
+			(e.Instance == "db3" && e.Op != "change master") {
+			t.Errorf("unexpected error,%v", e)
+		}
+	}
+}
+
+// TestMysqlLocal runs against a local mysqld if MYSQL_TEST_ADDR is set,
+// MYSQL_TEST_USER and MYSQL_TEST_PASSWORD are the admin user.
+func TestMysqlLocal(t *testing.T) {
+	addr := os.Getenv("MYSQL_TEST_ADDR")
+	if addr == "" {
+		t.Skip("MYSQL_TEST_ADDR is not set")
+	}
+
+	conn, err := dialMysql(addr, mysqlUser{
+		user:     os.Getenv("MYSQL_TEST_USER"),
+		password: os.Getenv("MYSQL_TEST_PASSWORD"),
+	})
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Variable("gtid_mode"); err != nil {
+		t.Errorf("%+v", err)
+	}
+
+	if _, err := conn.SlaveStatus(); err != nil {
+		t.Errorf("%+v", err)
+	}
+}
+
+func TestMysqlGroupManager(t *testing.T) {
+	user := mysqlUser{user: "repl", password: "pwd"}
+	admin := mysqlUser{user: "root", password: "root"}
+
+	dbs := []Mysql{
+		{IP: "192.168.1.1", Port: 3306, Instance: "db1", Weight: 0, user: user, admin: admin},
+		{IP: "192.168.1.2", Port: 3306, Instance: "db2", Weight: 2, user: user, admin: admin},
+		{IP: "192.168.1.3", Port: 3306, Instance: "db3", Weight: 1, user: user, admin: admin},
+	}
+
+	members := []GroupMember{
+		{ID: "1", Host: "192.168.1.1", Port: 3306, State: memberOnline},
+		{ID: "2", Host: "192.168.1.2", Port: 3306, State: memberOnline, Primary: true},
+		{ID: "3", Host: "192.168.1.3", Port: 3306, State: "RECOVERING"},
+	}
+
+	fakes := map[string]*fakeMysql{
+		"192.168.1.1:3306": {members: members},
+		"192.168.1.2:3306": {members: members},
+		"192.168.1.3:3306": {members: members},
+	}
+	defer fakeDialMysql(t, fakes)()
+
+	m := newMysqlGroupManager(dbs, true)
+
+	if err := m.ComposeCluster(); err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	// db2 has the highest weight
+	bootstrap := strings.Join(fakes["192.168.1.2:3306"].queries, ";")
+	if !strings.Contains(bootstrap, "group_replication_bootstrap_group=ON;START GROUP_REPLICATION;SET GLOBAL group_replication_bootstrap_group=OFF") {
+		t.Errorf("unexpected bootstrap queries,%s", bootstrap)
+	}
+
+	for _, addr := range []string{"192.168.1.1:3306", "192.168.1.3:3306"} {
+		queries := strings.Join(fakes[addr].queries, ";")
+		if strings.Contains(queries, "bootstrap_group") ||
+			!strings.Contains(queries, "group_replication_single_primary_mode=ON") ||
+			!strings.HasSuffix(queries, "START GROUP_REPLICATION") {
+			t.Errorf("%s,unexpected queries %s", addr, queries)
+		}
+	}
+
+	err := m.CheckCluster()
+	errs, ok := err.(InstanceErrors)
+	if !ok || len(errs) != 1 || errs[0].Instance != "db3" {
+		t.Errorf("unexpected error,%v", err)
+	}
+
+	members[2].State = memberOnline
+	if err := m.CheckCluster(); err != nil {
+		t.Error(err)
+	}
+
+	members[0].Primary = true
+	if err := m.CheckCluster(); err == nil {
+		t.Error("expected error with two primary in single-primary mode")
+	}
+
+	m = newMysqlGroupManager(dbs, false)
+	if err := m.CheckCluster(); err != nil {
+		t.Error(err)
+	}
+
+	if _, err := IsSinglePrimary(map[string]interface{}{GroupModeOption: "unknown"}); err == nil {
+		t.Error("expected error with unknown mode")
+	}
+}
+
+func TestMysqlSwitchover(t *testing.T) {
+	user := mysqlUser{user: "repl", password: "pwd"}
+	admin := mysqlUser{user: "root", password: "root"}
+
+	dbs := []Mysql{
+		{IP: "192.168.1.1", Port: 3306, Instance: "db1", user: user, admin: admin},
+		{IP: "192.168.1.2", Port: 3306, Instance: "db2", user: user, admin: admin},
+		{IP: "192.168.1.3", Port: 3306, Instance: "db3", user: user, admin: admin},
+	}
+
+	gtid := map[string]string{"gtid_mode": "ON", "gtid_executed": "uuid:1-100"}
+	wait := map[string]string{"SELECT WAIT_UNTIL_SQL_THREAD_AFTER_GTIDS('uuid:1-100',": "0"}
+
+	fakes := map[string]*fakeMysql{
+		"192.168.1.1:3306": {vars: gtid},
+		"192.168.1.2:3306": {vars: gtid, values: wait,
+			status: &ReplicationStatus{IORunning: true, SQLRunning: true, SecondsBehindMaster: 3}},
+		"192.168.1.3:3306": {vars: gtid, values: wait,
+			status: &ReplicationStatus{IORunning: true, SQLRunning: true, SecondsBehindMaster: 1}},
+	}
+	defer fakeDialMysql(t, fakes)()
+
+	m := newMysqlRepManager(dbs).(*MysqlRepManager)
+
+	// lag exceeds
+	if _, _, err := m.Switchover("192.168.1.2:3306", 2*time.Second); err == nil {
+		t.Error("expected error when lag exceeds")
+	}
+
+	if got := strings.Join(fakes["192.168.1.1:3306"].queries, ";"); got != "" {
+		t.Errorf("unexpected fence,%s", got)
+	}
+
+	// timeout waiting for the target
+	fakes["192.168.1.3:3306"].values = map[string]string{"SELECT WAIT_UNTIL": "-1"}
+
+	if _, _, err := m.Switchover("", 2*time.Second); err == nil {
+		t.Error("expected error when timeout")
+	}
+
+	if got := strings.Join(fakes["192.168.1.1:3306"].queries, ";"); got != "SET GLOBAL read_only=ON;SET GLOBAL read_only=OFF" {
+		t.Errorf("unexpected unfence,%s", got)
+	}
+
+	fakes["192.168.1.1:3306"].queries = nil
+	fakes["192.168.1.3:3306"].values = wait
+
+	// db3 has the lowest lag
+	old, master, err := m.Switchover("", 2*time.Second)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	if old != "192.168.1.1:3306" || master != "192.168.1.3:3306" {
+		t.Errorf("unexpected switchover %s -> %s", old, master)
+	}
+
+	promoted := strings.Join(fakes["192.168.1.3:3306"].queries, ";")
+	if promoted != "STOP SLAVE;RESET SLAVE ALL;SET GLOBAL read_only=OFF" {
+		t.Errorf("unexpected promote queries,%s", promoted)
+	}
+
+	want := "CHANGE MASTER TO MASTER_HOST='192.168.1.3'"
+	for _, addr := range []string{"192.168.1.1:3306", "192.168.1.2:3306"} {
+		queries := strings.Join(fakes[addr].queries, ";")
+		if !strings.Contains(queries, want) || !strings.HasSuffix(queries, "START SLAVE") {
+			t.Errorf("%s,unexpected queries %s", addr, queries)
+		}
+	}
+
+	if m.Mysqls["192.168.1.3:3306"].GetType() != masterRole || m.Mysqls["192.168.1.1:3306"].GetType() != slaveRole {
+		t.Errorf("unexpected roles,%+v", m.Mysqls)
+	}
+}
@@ -7,6 +7,7 @@ import (
 type Redis struct {
 	Ip   string
 	Port int
+	Host string // host of the unit,replicas are spread across hosts
 
 	Weight   int //Weight越高，优先变成master，等值随机
 	RoleType dbRole
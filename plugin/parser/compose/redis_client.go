@@ -0,0 +1,196 @@
+package compose
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// redisConn is the connection to a redis instance,replaced by a stand-in in tests.
+type redisConn interface {
+	// Do sends the command and returns the reply,
+	// reply is string,int64,nil,[]interface{} or redisError.
+	Do(args ...string) (interface{}, error)
+	Close() error
+}
+
+// redisError is the error reply of redis.
+type redisError string
+
+func (e redisError) Error() string {
+	return string(e)
+}
+
+var dialRedis = func(addr string) (redisConn, error) {
+	conn, err := net.DialTimeout("tcp", addr, 10*time.Second)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return &respConn{
+		conn: conn,
+		r:    bufio.NewReader(conn),
+	}, nil
+}
+
+// respConn implements redisConn by RESP protocol.
+type respConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func (c *respConn) Do(args ...string) (interface{}, error) {
+	err := c.conn.SetDeadline(time.Now().Add(defaultTimeout))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	buf := make([]byte, 0, 64)
+	buf = append(buf, fmt.Sprintf("*%d\r\n", len(args))...)
+
+	for _, arg := range args {
+		buf = append(buf, fmt.Sprintf("$%d\r\n%s\r\n", len(arg), arg)...)
+	}
+
+	if _, err := c.conn.Write(buf); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	reply, err := readReply(c.r)
+	if err != nil {
+		return nil, err
+	}
+
+	if e, ok := reply.(redisError); ok {
+		return nil, e
+	}
+
+	return reply, nil
+}
+
+func (c *respConn) Close() error {
+	return c.conn.Close()
+}
+
+// redisConns caches the connections by addr.
+type redisConns map[string]redisConn
+
+func (rc redisConns) do(addr string, args ...string) (interface{}, error) {
+	conn, ok := rc[addr]
+	if !ok {
+		var err error
+
+		conn, err = dialRedis(addr)
+		if err != nil {
+			return nil, err
+		}
+
+		rc[addr] = conn
+	}
+
+	reply, err := conn.Do(args...)
+	if err != nil {
+		if _, ok := err.(redisError); !ok {
+			// drop the broken connection
+			conn.Close()
+			delete(rc, addr)
+		}
+
+		return nil, errors.Wrapf(err, "%s %s", addr, strings.Join(args, " "))
+	}
+
+	return reply, nil
+}
+
+func (rc redisConns) close() {
+	for addr, conn := range rc {
+		conn.Close()
+		delete(rc, addr)
+	}
+}
+
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	if len(line) < 2 || line[len(line)-2] != '\r' {
+		return "", errors.Errorf("bad RESP line %q", line)
+	}
+
+	return line[:len(line)-2], nil
+}
+
+func readReply(r *bufio.Reader) (interface{}, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if line == "" {
+		return nil, errors.New("empty RESP line")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+
+	case '-':
+		return redisError(line[1:]), nil
+
+	case ':':
+		n, err := strconv.ParseInt(line[1:], 10, 64)
+		return n, errors.WithStack(err)
+
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, errors.WithStack(err)
+		}
+
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, errors.WithStack(err)
+		}
+
+		return string(buf[:n]), nil
+
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, errors.WithStack(err)
+		}
+
+		out := make([]interface{}, n)
+		for i := range out {
+			out[i], err = readReply(r)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		return out, nil
+	}
+
+	return nil, errors.Errorf("unknown RESP reply %q", line)
+}
+
+func replyString(reply interface{}, err error) (string, error) {
+	if err != nil {
+		return "", err
+	}
+
+	s, ok := reply.(string)
+	if !ok {
+		return "", errors.Errorf("unexpected reply type %T", reply)
+	}
+
+	return s, nil
+}
@@ -3,7 +3,9 @@ package compose
 import (
 	"fmt"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/Sirupsen/logrus"
 	"github.com/docker/swarm/garden/utils"
@@ -199,3 +201,305 @@ func (m *upredisRepManager) CheckCluster() error {
 //	return nil
 
 //}
+
+// redisReplication is the result of INFO replication.
+type redisReplication struct {
+	Role       string
+	MasterHost string
+	MasterPort string
+	LinkUp     bool
+	// LastIO is master_last_io_seconds_ago,-1 if the link is down
+	LastIO int
+	// Offset is master_repl_offset of master,slave_repl_offset of slave
+	Offset int64
+}
+
+func parseInfoReplication(text string) redisReplication {
+	info := redisReplication{LastIO: -1}
+
+	for _, line := range strings.Split(text, "\n") {
+		parts := strings.SplitN(strings.TrimSpace(line), ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+
+		val := parts[1]
+
+		switch parts[0] {
+		case "role":
+			info.Role = val
+		case "master_host":
+			info.MasterHost = val
+		case "master_port":
+			info.MasterPort = val
+		case "master_link_status":
+			info.LinkUp = val == "up"
+		case "master_last_io_seconds_ago":
+			info.LastIO, _ = strconv.Atoi(val)
+		case "master_repl_offset":
+			if info.Role == "master" {
+				info.Offset, _ = strconv.ParseInt(val, 10, 64)
+			}
+		case "slave_repl_offset":
+			info.Offset, _ = strconv.ParseInt(val, 10, 64)
+		}
+	}
+
+	return info
+}
+
+func (info redisReplication) check(max time.Duration) error {
+	if !info.LinkUp {
+		return errors.New("master_link_status is not up")
+	}
+
+	if time.Duration(info.LastIO)*time.Second > max {
+		return errors.Errorf("master_last_io_seconds_ago %ds exceeds %s", info.LastIO, max)
+	}
+
+	return nil
+}
+
+// redisSwitcher switches the master of redis replication.
+type redisSwitcher struct {
+	RedisMap map[string]Redis
+
+	conns redisConns
+}
+
+func newRedisSwitcher(dbs []Redis) *redisSwitcher {
+	rs := &redisSwitcher{
+		RedisMap: make(map[string]Redis),
+		conns:    make(redisConns),
+	}
+
+	for _, db := range dbs {
+		rs.RedisMap[db.GetKey()] = db
+	}
+
+	return rs
+}
+
+func (r *redisSwitcher) info(addr string) (redisReplication, error) {
+	text, err := replyString(r.conns.do(addr, "INFO", "replication"))
+	if err != nil {
+		return redisReplication{}, err
+	}
+
+	return parseInfoReplication(text), nil
+}
+
+func (r *redisSwitcher) instanceError(addr string, role dbRole, op string, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return &InstanceError{
+		Addr: addr,
+		Role: role,
+		Op:   op,
+		Err:  err,
+	}
+}
+
+// Switchover promotes target to be the new master,returns the old and the new master,
+// the healthy slave with the lowest lag is choosed if target is empty.
+// Clients of the old master are paused while the target catches up the replication offset.
+func (r *redisSwitcher) Switchover(target string, maxLag time.Duration) (string, string, error) {
+	defer r.conns.close()
+
+	var (
+		old     string
+		masters int
+		errs    InstanceErrors
+	)
+
+	infos := make(map[string]redisReplication, len(r.RedisMap))
+
+	for addr := range r.RedisMap {
+		info, err := r.info(addr)
+		if err != nil {
+			errs = errs.add(r.instanceError(addr, "", "info replication", err))
+			continue
+		}
+
+		infos[addr] = info
+
+		if info.Role == "master" {
+			old = addr
+			masters++
+		}
+	}
+
+	if err := errs.err(); err != nil {
+		return "", "", err
+	}
+
+	if masters != 1 {
+		return "", "", errors.Errorf("expected one master but got %d", masters)
+	}
+
+	if target == "" {
+		for addr, info := range infos {
+			if addr == old || info.check(maxLag) != nil {
+				continue
+			}
+
+			if target == "" || info.Offset > infos[target].Offset {
+				target = addr
+			}
+		}
+
+		if target == "" {
+			return old, target, errors.New("no healthy slave to switchover")
+		}
+	}
+
+	if _, ok := r.RedisMap[target]; !ok {
+		return old, target, errors.Errorf("%s is not a member of the service", target)
+	}
+
+	if target == old {
+		return old, target, errors.Errorf("%s is the master already", target)
+	}
+
+	if err := r.instanceError(target, slaveRole, "check replication", infos[target].check(maxLag)); err != nil {
+		return old, target, err
+	}
+
+	f, err := r.fence(old)
+	if err != nil {
+		return old, target, err
+	}
+
+	if err := r.promote(target, f); err != nil {
+		r.unfence(old, f)
+
+		return old, target, err
+	}
+
+	host, port, err := splitAddr(target)
+	if err != nil {
+		return old, target, err
+	}
+
+	for addr := range r.RedisMap {
+		if addr == target {
+			continue
+		}
+
+		if addr == old {
+			f.wait()
+		}
+
+		_, err := r.conns.do(addr, "SLAVEOF", host, port)
+		errs = errs.add(r.instanceError(addr, slaveRole, "slaveof", err))
+	}
+
+	r.unfence(old, f)
+
+	return old, target, errs.err()
+}
+
+// redisFence is the pause of the old master clients.
+type redisFence struct {
+	// write is true if paused by CLIENT PAUSE WRITE(redis 6.2+),
+	// INFO,SLAVEOF and CLIENT UNPAUSE are still served during the pause.
+	write bool
+	// offset is master_repl_offset of the old master,
+	// read before the pause if write is false.
+	offset int64
+	until  time.Time
+}
+
+// wait blocks until the old master serves commands again,
+// redis before 6.2 blocks all commands until the pause expires.
+func (f redisFence) wait() {
+	if !f.write {
+		time.Sleep(time.Until(f.until))
+	}
+}
+
+// fence pauses the writes of the master and returns its replication offset,
+// redis before 6.2 rejects CLIENT PAUSE WRITE and pauses all commands include INFO,
+// so the offset is read before the pause.
+func (r *redisSwitcher) fence(master string) (redisFence, error) {
+	timeout := strconv.Itoa(int(SwitchoverTimeout / time.Millisecond))
+	until := time.Now().Add(SwitchoverTimeout)
+
+	_, err := r.conns.do(master, "CLIENT", "PAUSE", timeout, "WRITE")
+	if err == nil {
+		f := redisFence{write: true, until: until}
+
+		info, err := r.info(master)
+		if err != nil {
+			r.unfence(master, f)
+
+			return f, r.instanceError(master, masterRole, "info replication", err)
+		}
+
+		f.offset = info.Offset
+
+		return f, nil
+	}
+
+	if _, ok := errors.Cause(err).(redisError); !ok {
+		return redisFence{}, r.instanceError(master, masterRole, "fence", err)
+	}
+
+	info, err := r.info(master)
+	if err != nil {
+		return redisFence{}, r.instanceError(master, masterRole, "info replication", err)
+	}
+
+	f := redisFence{offset: info.Offset, until: time.Now().Add(SwitchoverTimeout)}
+
+	if _, err := r.conns.do(master, "CLIENT", "PAUSE", timeout); err != nil {
+		return f, r.instanceError(master, masterRole, "fence", err)
+	}
+
+	return f, nil
+}
+
+// unfence ends the pause of CLIENT PAUSE WRITE,
+// the pause of redis before 6.2 cannot be ended and expires by itself.
+func (r *redisSwitcher) unfence(master string, f redisFence) {
+	if !f.write {
+		return
+	}
+
+	if _, err := r.conns.do(master, "CLIENT", "UNPAUSE"); err != nil {
+		logrus.Warnf("%+v", r.instanceError(master, masterRole, "unfence", err))
+	}
+}
+
+// promote waits until target catches up the offset of the fenced master,then stops its replication,
+// the writes accepted between reading the offset and the pause of redis before 6.2 are waited
+// by the offset of target keeping unchanged.
+func (r *redisSwitcher) promote(target string, f redisFence) error {
+	last := int64(-1)
+
+	for {
+		slave, err := r.info(target)
+		if err != nil {
+			return r.instanceError(target, slaveRole, "info replication", err)
+		}
+
+		if slave.Offset >= f.offset && (f.write || slave.Offset == last) {
+			break
+		}
+
+		last = slave.Offset
+
+		if time.Now().After(f.until) {
+			return r.instanceError(target, slaveRole, "wait offset",
+				errors.Errorf("timeout after %s,offset %d behind %d", SwitchoverTimeout, slave.Offset, f.offset))
+		}
+
+		time.Sleep(clusterWaitInterval)
+	}
+
+	_, err := r.conns.do(target, "SLAVEOF", "NO", "ONE")
+
+	return r.instanceError(target, slaveRole, "slaveof no one", err)
+}
@@ -0,0 +1,227 @@
+package compose
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeRedisReplica is a stand-in of redis replication instance,replicates instantly,
+// commands are blocked until timeout during CLIENT PAUSE unless paused by WRITE mode.
+type fakeRedisReplica struct {
+	addr        string
+	master      string // empty if it's a master
+	offset      int64
+	linkUp      bool
+	legacy      bool // redis before 6.2,without CLIENT PAUSE WRITE and CLIENT UNPAUSE
+	pauseWrite  bool
+	pausedUntil time.Time
+	cmds        []string
+	blocked     []string
+	replica     map[string]*fakeRedisReplica
+}
+
+func (f *fakeRedisReplica) paused() bool {
+	return time.Now().Before(f.pausedUntil)
+}
+
+func (f *fakeRedisReplica) Close() error {
+	return nil
+}
+
+func (f *fakeRedisReplica) Do(args ...string) (interface{}, error) {
+	cmd := strings.ToUpper(strings.Join(args, " "))
+	f.cmds = append(f.cmds, cmd)
+
+	if f.paused() && !f.pauseWrite {
+		f.blocked = append(f.blocked, cmd)
+		return nil, errors.New("read tcp " + f.addr + ": i/o timeout")
+	}
+
+	switch {
+	case cmd == "INFO REPLICATION":
+		if f.master == "" {
+			return fmt.Sprintf("# Replication\r\nrole:master\r\nconnected_slaves:2\r\nmaster_repl_offset:%d\r\n", f.offset), nil
+		}
+
+		host, port, _ := splitAddr(f.master)
+		status := "down"
+		if f.linkUp {
+			status = "up"
+		}
+
+		return fmt.Sprintf("# Replication\r\nrole:slave\r\nmaster_host:%s\r\nmaster_port:%s\r\nmaster_link_status:%s\r\nmaster_last_io_seconds_ago:1\r\nslave_repl_offset:%d\r\nmaster_repl_offset:%d\r\n",
+			host, port, status, f.offset, f.offset), nil
+
+	case strings.HasPrefix(cmd, "CLIENT PAUSE "):
+		write := len(args) == 4 && strings.ToUpper(args[3]) == "WRITE"
+		if write && f.legacy {
+			return nil, redisError("ERR syntax error")
+		}
+
+		ms, err := strconv.Atoi(args[2])
+		if err != nil {
+			return nil, redisError("ERR timeout is not an integer or out of range")
+		}
+
+		f.pauseWrite = write
+		f.pausedUntil = time.Now().Add(time.Duration(ms) * time.Millisecond)
+		return "OK", nil
+
+	case cmd == "CLIENT UNPAUSE" && !f.legacy:
+		f.pausedUntil = time.Time{}
+		return "OK", nil
+
+	case cmd == "SLAVEOF NO ONE":
+		f.master = ""
+		return "OK", nil
+
+	case strings.HasPrefix(cmd, "SLAVEOF "):
+		f.master = args[1] + ":" + args[2]
+		f.linkUp = true
+		return "OK", nil
+	}
+
+	return nil, redisError("ERR unknown command " + cmd)
+}
+
+func fakeDialReplicas(replicas map[string]*fakeRedisReplica) func() {
+	dial := dialRedis
+
+	dialRedis = func(addr string) (redisConn, error) {
+		f, ok := replicas[addr]
+		if !ok {
+			return nil, errors.New("dial tcp " + addr + ": connection refused")
+		}
+
+		return f, nil
+	}
+
+	return func() { dialRedis = dial }
+}
+
+func TestParseInfoReplication(t *testing.T) {
+	info := parseInfoReplication("# Replication\r\nrole:slave\r\nmaster_host:192.168.1.1\r\nmaster_port:6379\r\nmaster_link_status:up\r\nmaster_last_io_seconds_ago:3\r\nslave_repl_offset:120\r\nmaster_repl_offset:120\r\n")
+
+	if info.Role != "slave" || info.MasterHost != "192.168.1.1" || info.MasterPort != "6379" ||
+		!info.LinkUp || info.LastIO != 3 || info.Offset != 120 {
+		t.Errorf("unexpected info,%+v", info)
+	}
+
+	if err := info.check(2 * time.Second); err == nil {
+		t.Error("expected error when last io exceeds")
+	}
+
+	info.LinkUp = false
+	if err := info.check(time.Minute); err == nil {
+		t.Error("expected error when link is down")
+	}
+}
+
+func TestRedisSwitchover(t *testing.T) {
+	replicas := map[string]*fakeRedisReplica{
+		"192.168.1.1:6379": {offset: 100},
+		"192.168.1.2:6379": {master: "192.168.1.1:6379", linkUp: true, offset: 90},
+		"192.168.1.3:6379": {master: "192.168.1.1:6379", linkUp: false, offset: 100},
+	}
+	defer fakeDialReplicas(replicas)()
+
+	dbs := []Redis{
+		{Ip: "192.168.1.1", Port: 6379},
+		{Ip: "192.168.1.2", Port: 6379},
+		{Ip: "192.168.1.3", Port: 6379},
+	}
+
+	interval, timeout := clusterWaitInterval, SwitchoverTimeout
+	clusterWaitInterval, SwitchoverTimeout = time.Millisecond, 10*time.Millisecond
+	defer func() { clusterWaitInterval, SwitchoverTimeout = interval, timeout }()
+
+	// link of 192.168.1.3 is down
+	if _, _, err := newRedisSwitcher(dbs).Switchover("192.168.1.3:6379", time.Minute); err == nil {
+		t.Error("expected error when link is down")
+	}
+
+	// 192.168.1.2 never catches up
+	_, _, err := newRedisSwitcher(dbs).Switchover("", time.Minute)
+	if err == nil || !strings.Contains(err.Error(), "wait offset") {
+		t.Errorf("unexpected error,%v", err)
+	}
+
+	if old := replicas["192.168.1.1:6379"]; old.paused() || old.master != "" {
+		t.Errorf("old master should be unfenced,%+v", old)
+	}
+
+	replicas["192.168.1.2:6379"].offset = 100
+
+	old, master, err := newRedisSwitcher(dbs).Switchover("", time.Minute)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	if old != "192.168.1.1:6379" || master != "192.168.1.2:6379" {
+		t.Errorf("unexpected switchover %s -> %s", old, master)
+	}
+
+	if replicas["192.168.1.2:6379"].master != "" {
+		t.Error("new master is still a slave")
+	}
+
+	for _, addr := range []string{"192.168.1.1:6379", "192.168.1.3:6379"} {
+		if got := replicas[addr].master; got != "192.168.1.2:6379" {
+			t.Errorf("%s,unexpected master %s", addr, got)
+		}
+	}
+
+	if !strings.Contains(strings.Join(replicas["192.168.1.1:6379"].cmds, ";"), "CLIENT PAUSE 10 WRITE;INFO REPLICATION") {
+		t.Errorf("old master is not fenced,%s", replicas["192.168.1.1:6379"].cmds)
+	}
+
+	if old := replicas["192.168.1.1:6379"]; old.paused() {
+		t.Errorf("old master should be unfenced,%+v", old)
+	}
+}
+
+func TestRedisSwitchoverLegacyPause(t *testing.T) {
+	replicas := map[string]*fakeRedisReplica{
+		"192.168.1.1:6379": {addr: "192.168.1.1:6379", offset: 100, legacy: true},
+		"192.168.1.2:6379": {addr: "192.168.1.2:6379", master: "192.168.1.1:6379", linkUp: true, offset: 100, legacy: true},
+		"192.168.1.3:6379": {addr: "192.168.1.3:6379", master: "192.168.1.1:6379", linkUp: true, offset: 100, legacy: true},
+	}
+	defer fakeDialReplicas(replicas)()
+
+	dbs := []Redis{
+		{Ip: "192.168.1.1", Port: 6379},
+		{Ip: "192.168.1.2", Port: 6379},
+		{Ip: "192.168.1.3", Port: 6379},
+	}
+
+	interval, timeout := clusterWaitInterval, SwitchoverTimeout
+	clusterWaitInterval, SwitchoverTimeout = time.Millisecond, 20*time.Millisecond
+	defer func() { clusterWaitInterval, SwitchoverTimeout = interval, timeout }()
+
+	old, master, err := newRedisSwitcher(dbs).Switchover("192.168.1.2:6379", time.Minute)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	if old != "192.168.1.1:6379" || master != "192.168.1.2:6379" {
+		t.Errorf("unexpected switchover %s -> %s", old, master)
+	}
+
+	fenced := replicas["192.168.1.1:6379"]
+
+	if got := strings.Join(fenced.cmds, ";"); !strings.Contains(got, "INFO REPLICATION;CLIENT PAUSE 20") {
+		t.Errorf("offset of old master should be read before the pause,%s", got)
+	}
+
+	if len(fenced.blocked) > 0 {
+		t.Errorf("commands sent to the paused master,%s", fenced.blocked)
+	}
+
+	if fenced.master != "192.168.1.2:6379" {
+		t.Errorf("unexpected master of old master %s", fenced.master)
+	}
+}
@@ -1,31 +1,154 @@
 package compose
 
 import (
-	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/Sirupsen/logrus"
-	"github.com/docker/swarm/garden/utils"
 	"github.com/pkg/errors"
 )
 
-//master-slave redis manager
+const clusterSlots = 16384
+
+var (
+	clusterWaitInterval = time.Second
+	clusterWaitTimeout  = 30 * time.Second
+)
+
+// clusterNode is a line of CLUSTER NODES.
+type clusterNode struct {
+	ID        string
+	Addr      string
+	Flags     []string
+	MasterID  string
+	Connected bool
+	Slots     []int
+}
+
+func (n clusterNode) hasFlag(flag string) bool {
+	for i := range n.Flags {
+		if n.Flags[i] == flag {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (n clusterNode) isMaster() bool {
+	return n.hasFlag("master")
+}
+
+func (n clusterNode) failed() bool {
+	return n.hasFlag("fail") || n.hasFlag("fail?")
+}
+
+func (n clusterNode) role() dbRole {
+	if n.isMaster() {
+		return masterRole
+	}
+
+	return slaveRole
+}
+
+// parseSlots parses "0-5460" or "5461",skips importing and migrating slots "[...]".
+func parseSlots(field string) ([]int, error) {
+	if strings.HasPrefix(field, "[") {
+		return nil, nil
+	}
+
+	parts := strings.SplitN(field, "-", 2)
+
+	start, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return nil, errors.Wrap(err, "parse slot "+field)
+	}
+
+	end := start
+	if len(parts) == 2 {
+		end, err = strconv.Atoi(parts[1])
+		if err != nil {
+			return nil, errors.Wrap(err, "parse slot "+field)
+		}
+	}
+
+	if start < 0 || end >= clusterSlots || start > end {
+		return nil, errors.Errorf("invalid slot %s", field)
+	}
+
+	out := make([]int, 0, end-start+1)
+	for i := start; i <= end; i++ {
+		out = append(out, i)
+	}
+
+	return out, nil
+}
+
+// parseClusterNodes parses the output of CLUSTER NODES.
+func parseClusterNodes(text string) ([]clusterNode, error) {
+	lines := strings.Split(strings.TrimSpace(text), "\n")
+	out := make([]clusterNode, 0, len(lines))
+
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		if len(fields) < 8 {
+			return nil, errors.Errorf("bad CLUSTER NODES line:%s", line)
+		}
+
+		addr := fields[1]
+		if i := strings.IndexAny(addr, "@,"); i >= 0 {
+			addr = addr[:i]
+		}
+
+		n := clusterNode{
+			ID:        fields[0],
+			Addr:      addr,
+			Flags:     strings.Split(fields[2], ","),
+			Connected: fields[7] == "connected",
+		}
+
+		if fields[3] != "-" {
+			n.MasterID = fields[3]
+		}
+
+		for _, f := range fields[8:] {
+			slots, err := parseSlots(f)
+			if err != nil {
+				return nil, err
+			}
+
+			n.Slots = append(n.Slots, slots...)
+		}
+
+		out = append(out, n)
+	}
+
+	return out, nil
+}
+
+// RedisShardingManager manages redis cluster natively,
+// nodes are added or removed online,slots are migrated to balance the masters.
 type RedisShardingManager struct {
 	RedisMap map[string]Redis
 
 	Master int
 	Slave  int
 
-	scriptDir string
+	conns redisConns
 }
 
-func newRedisShardingManager(dbs []Redis, master int, slave int, dir string) Composer {
+func newRedisShardingManager(dbs []Redis, master int, slave int) Composer {
 	rs := &RedisShardingManager{
-		RedisMap:  make(map[string]Redis),
-		Master:    master,
-		Slave:     slave,
-		scriptDir: dir,
+		RedisMap: make(map[string]Redis),
+		Master:   master,
+		Slave:    slave,
+		conns:    make(redisConns),
 	}
 
 	for _, db := range dbs {
@@ -35,46 +158,599 @@ func newRedisShardingManager(dbs []Redis, master int, slave int, dir string) Com
 	return rs
 }
 
-func (r *RedisShardingManager) getRedisAddrs() string {
-	addrs := []string{}
-	for _, redis := range r.RedisMap {
-		addr := redis.Ip + ":" + strconv.Itoa(redis.Port)
+func (r *RedisShardingManager) addrs() []string {
+	addrs := make([]string, 0, len(r.RedisMap))
+	for addr := range r.RedisMap {
 		addrs = append(addrs, addr)
 	}
 
-	return strings.Join(addrs, ",")
+	sort.Strings(addrs)
+
+	return addrs
 }
 
-func (r *RedisShardingManager) ClearCluster() error {
-	filepath := filepath.Join(r.scriptDir, "redis-sharding_replication-reset.sh")
-	addrs := r.getRedisAddrs()
-	args := []string{filepath, addrs}
+func (r *RedisShardingManager) do(addr string, args ...string) (interface{}, error) {
+	return r.conns.do(addr, args...)
+}
 
-	out, err := utils.ExecContextTimeout(nil, defaultTimeout*2, args...)
+func (r *RedisShardingManager) close() {
+	r.conns.close()
+}
 
-	logrus.Debugf("exec:%s,output:%s", args, out)
+// nodes returns the cluster nodes viewed by addr.
+func (r *RedisShardingManager) nodes(addr string) ([]clusterNode, error) {
+	text, err := replyString(r.do(addr, "CLUSTER", "NODES"))
+	if err != nil {
+		return nil, err
+	}
 
-	return errors.WithStack(err)
+	return parseClusterNodes(text)
 }
 
-func (r *RedisShardingManager) CheckCluster() error {
+func (r *RedisShardingManager) instanceError(n clusterNode, op string, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return &InstanceError{
+		Instance: n.ID,
+		Addr:     n.Addr,
+		Role:     n.role(),
+		Op:       op,
+		Err:      err,
+	}
+}
+
+// entry returns the node which owns slots,new nodes meet the cluster through it.
+func (r *RedisShardingManager) entry() (string, error) {
+	var (
+		entry string
+		errs  InstanceErrors
+	)
+
+	for _, addr := range r.addrs() {
+		nodes, err := r.nodes(addr)
+		if err != nil {
+			errs = errs.add(&InstanceError{Addr: addr, Op: "cluster nodes", Err: err})
+			continue
+		}
+
+		if entry == "" {
+			entry = addr
+		}
+
+		for _, n := range nodes {
+			if n.hasFlag("myself") && len(n.Slots) > 0 {
+				return addr, nil
+			}
+		}
+	}
+
+	if entry == "" {
+		return "", errs.err()
+	}
+
+	return entry, nil
+}
+
+// meet makes all nodes join the cluster of entry,waits until they known each other.
+func (r *RedisShardingManager) meet(entry string) ([]clusterNode, error) {
+	nodes, err := r.nodes(entry)
+	if err != nil {
+		return nil, err
+	}
+
+	known := make(map[string]bool, len(nodes))
+	for _, n := range nodes {
+		known[n.Addr] = true
+	}
+
+	host, port, err := splitAddr(entry)
+	if err != nil {
+		return nil, err
+	}
+
+	var errs InstanceErrors
+
+	for _, addr := range r.addrs() {
+		if known[addr] {
+			continue
+		}
+
+		_, err := r.do(addr, "CLUSTER", "MEET", host, port)
+		errs = errs.add(err)
+	}
+
+	if err := errs.err(); err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(clusterWaitTimeout)
+
+	for {
+		nodes, err = r.nodes(entry)
+		if err != nil {
+			return nil, err
+		}
+
+		pending := r.pending(nodes)
+		if len(pending) == 0 {
+			return nodes, nil
+		}
+
+		if time.Now().After(deadline) {
+			return nil, errors.Errorf("timeout waiting for nodes join the cluster,%s", pending)
+		}
+
+		time.Sleep(clusterWaitInterval)
+	}
+}
+
+// pending returns the addrs which not joined the cluster yet.
+func (r *RedisShardingManager) pending(nodes []clusterNode) []string {
+	joined := make(map[string]bool, len(nodes))
+
+	for _, n := range nodes {
+		if !n.hasFlag("handshake") && (n.Connected || n.hasFlag("myself")) {
+			joined[n.Addr] = true
+		}
+	}
+
+	var out []string
+	for _, addr := range r.addrs() {
+		if !joined[addr] {
+			out = append(out, addr)
+		}
+	}
+
+	return out
+}
+
+func splitAddr(addr string) (string, string, error) {
+	i := strings.LastIndex(addr, ":")
+	if i < 0 {
+		return "", "", errors.Errorf("bad addr %s", addr)
+	}
+
+	return addr[:i], addr[i+1:], nil
+}
+
+// electMasters keeps the masters owning most slots,
+// elects new masters from empty masters,spreads masters across hosts.
+func (r *RedisShardingManager) electMasters(nodes []clusterNode) ([]clusterNode, error) {
+	var owners, empties []clusterNode
+
+	for _, n := range nodes {
+		if _, ok := r.RedisMap[n.Addr]; !ok || !n.isMaster() || n.failed() {
+			continue
+		}
+
+		if len(n.Slots) > 0 {
+			owners = append(owners, n)
+		} else {
+			empties = append(empties, n)
+		}
+	}
+
+	sort.SliceStable(owners, func(i, j int) bool {
+		return len(owners[i].Slots) > len(owners[j].Slots)
+	})
+
+	if len(owners) >= r.Master {
+		return owners[:r.Master], nil
+	}
+
+	masters := owners
+	hosts := make(map[string]int)
+
+	for _, m := range masters {
+		hosts[r.RedisMap[m.Addr].Host]++
+	}
+
+	sort.SliceStable(empties, func(i, j int) bool {
+		return r.RedisMap[empties[i].Addr].Weight > r.RedisMap[empties[j].Addr].Weight
+	})
+
+	for len(masters) < r.Master && len(empties) > 0 {
+		best := 0
+		for i := range empties {
+			if hosts[r.RedisMap[empties[i].Addr].Host] < hosts[r.RedisMap[empties[best].Addr].Host] {
+				best = i
+			}
+		}
+
+		masters = append(masters, empties[best])
+		hosts[r.RedisMap[empties[best].Addr].Host]++
+		empties = append(empties[:best], empties[best+1:]...)
+	}
+
+	if len(masters) < r.Master {
+		return nil, errors.Errorf("expected %d masters but only %d nodes available", r.Master, len(masters))
+	}
+
+	return masters, nil
+}
+
+type slotMove struct {
+	slot     int
+	src, dst clusterNode
+}
+
+// planSlots returns the slots to add and the slots to migrate,
+// masters get balanced slots,slots of other nodes are migrated to masters.
+func planSlots(nodes, masters []clusterNode) (map[string][]int, []slotMove) {
+	owned := make(map[int]bool, clusterSlots)
+	for _, n := range nodes {
+		for _, s := range n.Slots {
+			owned[s] = true
+		}
+	}
+
+	sort.SliceStable(masters, func(i, j int) bool {
+		return len(masters[i].Slots) > len(masters[j].Slots)
+	})
+
+	target := make(map[string]int, len(masters))
+	for i := range masters {
+		target[masters[i].ID] = clusterSlots / len(masters)
+		if i < clusterSlots%len(masters) {
+			target[masters[i].ID]++
+		}
+	}
+
+	// surplus slots of masters and other nodes
+	var surplus []slotMove
+
+	for _, n := range nodes {
+		keep := target[n.ID]
+		if len(n.Slots) <= keep {
+			continue
+		}
+
+		for _, s := range n.Slots[keep:] {
+			surplus = append(surplus, slotMove{slot: s, src: n})
+		}
+	}
+
+	var unowned []int
+	for s := 0; s < clusterSlots; s++ {
+		if !owned[s] {
+			unowned = append(unowned, s)
+		}
+	}
+
+	adds := make(map[string][]int)
+	moves := make([]slotMove, 0, len(surplus))
+
+	for _, m := range masters {
+		need := target[m.ID] - len(m.Slots)
+
+		for ; need > 0 && len(unowned) > 0; need-- {
+			adds[m.Addr] = append(adds[m.Addr], unowned[0])
+			unowned = unowned[1:]
+		}
+
+		for ; need > 0 && len(surplus) > 0; need-- {
+			move := surplus[0]
+			move.dst = m
+			moves = append(moves, move)
+			surplus = surplus[1:]
+		}
+	}
+
+	return adds, moves
+}
+
+func (r *RedisShardingManager) addSlots(addr string, slots []int) error {
+	const batch = 1000
+
+	for len(slots) > 0 {
+		n := batch
+		if n > len(slots) {
+			n = len(slots)
+		}
+
+		args := make([]string, 0, n+2)
+		args = append(args, "CLUSTER", "ADDSLOTS")
+
+		for _, s := range slots[:n] {
+			args = append(args, strconv.Itoa(s))
+		}
+
+		if _, err := r.do(addr, args...); err != nil {
+			return err
+		}
+
+		slots = slots[n:]
+	}
+
 	return nil
 }
 
+// migrateSlot migrates the slot and keys in it from src to dst.
+func (r *RedisShardingManager) migrateSlot(move slotMove) error {
+	slot := strconv.Itoa(move.slot)
+
+	host, port, err := splitAddr(move.dst.Addr)
+	if err != nil {
+		return err
+	}
+
+	if _, err := r.do(move.dst.Addr, "CLUSTER", "SETSLOT", slot, "IMPORTING", move.src.ID); err != nil {
+		return err
+	}
+
+	if _, err := r.do(move.src.Addr, "CLUSTER", "SETSLOT", slot, "MIGRATING", move.dst.ID); err != nil {
+		return err
+	}
+
+	for {
+		reply, err := r.do(move.src.Addr, "CLUSTER", "GETKEYSINSLOT", slot, "100")
+		if err != nil {
+			return err
+		}
+
+		keys, _ := reply.([]interface{})
+		if len(keys) == 0 {
+			break
+		}
+
+		args := []string{"MIGRATE", host, port, "", "0", strconv.Itoa(int(defaultTimeout / time.Millisecond)), "KEYS"}
+		for _, k := range keys {
+			if key, ok := k.(string); ok {
+				args = append(args, key)
+			}
+		}
+
+		if _, err := r.do(move.src.Addr, args...); err != nil {
+			return err
+		}
+	}
+
+	for _, addr := range []string{move.dst.Addr, move.src.Addr} {
+		if _, err := r.do(addr, "CLUSTER", "SETSLOT", slot, "NODE", move.dst.ID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// rebalance assigns the unowned slots and migrates slots to balance the masters.
+func (r *RedisShardingManager) rebalance(nodes, masters []clusterNode) error {
+	for _, n := range nodes {
+		if len(n.Slots) > 0 && n.failed() {
+			return r.instanceError(n, "rebalance", errors.Errorf("failed node owns %d slots", len(n.Slots)))
+		}
+	}
+
+	adds, moves := planSlots(nodes, masters)
+
+	for addr, slots := range adds {
+		if err := r.addSlots(addr, slots); err != nil {
+			return err
+		}
+	}
+
+	for i := range moves {
+		if err := r.migrateSlot(moves[i]); err != nil {
+			return r.instanceError(moves[i].src, "migrate slot "+strconv.Itoa(moves[i].slot), err)
+		}
+	}
+
+	if len(moves) > 0 {
+		logrus.Debugf("redis cluster:%d slots migrated", len(moves))
+	}
+
+	return nil
+}
+
+// replicate assigns the nodes except masters as replicas,
+// a replica prefers the master on another host with the fewest replicas.
+func (r *RedisShardingManager) replicate(nodes, masters []clusterNode) error {
+	replicas := make(map[string]int, len(masters))
+	for _, m := range masters {
+		replicas[m.ID] = 0
+	}
+
+	var pending []clusterNode
+
+	for _, n := range nodes {
+		if _, ok := r.RedisMap[n.Addr]; !ok {
+			continue
+		}
+
+		if _, ok := replicas[n.ID]; ok {
+			continue
+		}
+
+		if _, ok := replicas[n.MasterID]; ok && !n.isMaster() {
+			replicas[n.MasterID]++
+			continue
+		}
+
+		pending = append(pending, n)
+	}
+
+	var errs InstanceErrors
+
+	for _, n := range pending {
+		host := r.RedisMap[n.Addr].Host
+		best := -1
+
+		for i, m := range masters {
+			if best < 0 {
+				best = i
+				continue
+			}
+
+			same, bestSame := r.RedisMap[m.Addr].Host == host, r.RedisMap[masters[best].Addr].Host == host
+			if (!same && bestSame) || (same == bestSame && replicas[m.ID] < replicas[masters[best].ID]) {
+				best = i
+			}
+		}
+
+		if best < 0 {
+			return errors.New("no master to replicate")
+		}
+
+		_, err := r.do(n.Addr, "CLUSTER", "REPLICATE", masters[best].ID)
+		if err != nil {
+			errs = errs.add(r.instanceError(n, "replicate", err))
+			continue
+		}
+
+		replicas[masters[best].ID]++
+	}
+
+	return errs.err()
+}
+
+// forget removes the nodes not in RedisMap from the cluster.
+func (r *RedisShardingManager) forget(nodes []clusterNode) error {
+	var errs InstanceErrors
+
+	for _, n := range nodes {
+		if _, ok := r.RedisMap[n.Addr]; ok {
+			continue
+		}
+
+		if !n.failed() {
+			// best effort,the node is going to be removed
+			if _, err := r.do(n.Addr, "CLUSTER", "RESET", "SOFT"); err != nil {
+				logrus.Debugf("redis cluster reset %s:%s", n.Addr, err)
+			}
+		}
+
+		for _, addr := range r.addrs() {
+			_, err := r.do(addr, "CLUSTER", "FORGET", n.ID)
+			if err != nil && !strings.Contains(err.Error(), "Unknown node") {
+				errs = errs.add(r.instanceError(n, "forget", err))
+			}
+		}
+	}
+
+	return errs.err()
+}
+
+// ClearCluster resets all nodes,the data are flushed.
+func (r *RedisShardingManager) ClearCluster() error {
+	defer r.close()
+
+	var errs InstanceErrors
+
+	for _, addr := range r.addrs() {
+		for _, args := range [][]string{{"FLUSHALL"}, {"CLUSTER", "RESET", "HARD"}} {
+			if _, err := r.do(addr, args...); err != nil {
+				errs = errs.add(&InstanceError{Addr: addr, Op: strings.ToLower(strings.Join(args, " ")), Err: err})
+				break
+			}
+		}
+	}
+
+	return errs.err()
+}
+
+// ComposeCluster builds the cluster online,new nodes join the cluster,
+// slots are balanced across masters,nodes not in RedisMap are removed.
 func (r *RedisShardingManager) ComposeCluster() error {
-	err := r.ClearCluster()
+	defer r.close()
+
+	if r.Master <= 0 {
+		return errors.New("redis cluster requires at least one master")
+	}
+
+	entry, err := r.entry()
 	if err != nil {
 		return err
 	}
 
-	filepath := filepath.Join(r.scriptDir, "redis-sharding_replication-set.sh")
+	nodes, err := r.meet(entry)
+	if err != nil {
+		return err
+	}
 
-	addrs := r.getRedisAddrs()
-	args := []string{filepath, strconv.Itoa(r.Slave), addrs}
+	masters, err := r.electMasters(nodes)
+	if err != nil {
+		return err
+	}
+
+	if err := r.rebalance(nodes, masters); err != nil {
+		return err
+	}
+
+	if nodes, err = r.nodes(entry); err != nil {
+		return err
+	}
+
+	if err := r.replicate(nodes, masters); err != nil {
+		return err
+	}
+
+	return r.forget(nodes)
+}
+
+// CheckCluster checks the cluster state,slots coverage and nodes health.
+func (r *RedisShardingManager) CheckCluster() error {
+	defer r.close()
+
+	var (
+		errs  InstanceErrors
+		nodes []clusterNode
+	)
 
-	out, err := utils.ExecContextTimeout(nil, defaultTimeout*2, args...)
+	for _, addr := range r.addrs() {
+		info, err := replyString(r.do(addr, "CLUSTER", "INFO"))
+		if err != nil {
+			errs = errs.add(&InstanceError{Addr: addr, Op: "cluster info", Err: err})
+			continue
+		}
 
-	logrus.Debugf("exec:%s,output:%s", args, out)
+		if !strings.Contains(info, "cluster_state:ok") {
+			errs = errs.add(&InstanceError{Addr: addr, Op: "cluster info", Err: errors.New("cluster_state is not ok")})
+		}
+
+		if nodes == nil {
+			nodes, err = r.nodes(addr)
+			errs = errs.add(err)
+		}
+	}
+
+	if nodes == nil {
+		return errs.err()
+	}
+
+	covered, masters := 0, 0
+	views := make(map[string]clusterNode, len(nodes))
+
+	for _, n := range nodes {
+		views[n.Addr] = n
+
+		if n.isMaster() && !n.failed() {
+			covered += len(n.Slots)
+
+			if len(n.Slots) > 0 {
+				masters++
+			}
+		}
+	}
+
+	for _, addr := range r.addrs() {
+		n, ok := views[addr]
+		if !ok {
+			errs = errs.add(&InstanceError{Addr: addr, Op: "check node", Err: errors.New("not a node of the cluster")})
+		} else if n.failed() || !(n.Connected || n.hasFlag("myself")) {
+			errs = errs.add(r.instanceError(n, "check node", errors.Errorf("node flags %s", strings.Join(n.Flags, ","))))
+		}
+	}
+
+	if covered != clusterSlots {
+		errs = errs.add(errors.Errorf("%d slots are not covered", clusterSlots-covered))
+	}
+
+	if masters != r.Master {
+		errs = errs.add(errors.Errorf("expected %d masters but got %d", r.Master, masters))
+	}
 
-	return errors.WithStack(err)
+	return errs.err()
 }
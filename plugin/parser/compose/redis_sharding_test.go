@@ -0,0 +1,420 @@
+package compose
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// fakeRedisCluster is a stand-in of redis cluster nodes,gossip is instant.
+type fakeRedisCluster struct {
+	nodes map[string]*fakeRedisNode
+}
+
+type fakeRedisNode struct {
+	id     string
+	addr   string
+	master string
+	slots  map[int]bool
+	keys   map[int][]string
+	known  map[string]bool
+}
+
+func newFakeRedisCluster(addrs ...string) *fakeRedisCluster {
+	c := &fakeRedisCluster{nodes: make(map[string]*fakeRedisNode)}
+
+	for i, addr := range addrs {
+		c.add(fmt.Sprintf("node%02d", i), addr)
+	}
+
+	return c
+}
+
+func (c *fakeRedisCluster) add(id, addr string) {
+	c.nodes[addr] = &fakeRedisNode{
+		id:    id,
+		addr:  addr,
+		slots: make(map[int]bool),
+		keys:  make(map[int][]string),
+		known: map[string]bool{id: true},
+	}
+}
+
+func (c *fakeRedisCluster) byID(id string) *fakeRedisNode {
+	for _, n := range c.nodes {
+		if n.id == id {
+			return n
+		}
+	}
+
+	return nil
+}
+
+func (c *fakeRedisCluster) dial(addr string) (redisConn, error) {
+	n, ok := c.nodes[addr]
+	if !ok {
+		return nil, errors.New("dial tcp " + addr + ": connection refused")
+	}
+
+	return fakeRedisConn{c: c, n: n}, nil
+}
+
+type fakeRedisConn struct {
+	c *fakeRedisCluster
+	n *fakeRedisNode
+}
+
+func (f fakeRedisConn) Close() error {
+	return nil
+}
+
+func (f fakeRedisConn) Do(args ...string) (interface{}, error) {
+	c, n := f.c, f.n
+	cmd := strings.ToUpper(strings.Join(args[:1], ""))
+	if cmd == "CLUSTER" {
+		cmd += " " + strings.ToUpper(args[1])
+		args = args[2:]
+	} else {
+		args = args[1:]
+	}
+
+	switch cmd {
+	case "CLUSTER NODES":
+		ids := make([]string, 0, len(n.known))
+		for id := range n.known {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+
+		lines := make([]string, 0, len(ids))
+		for _, id := range ids {
+			o := c.byID(id)
+			flags, master := "master", "-"
+			if o.master != "" {
+				flags, master = "slave", o.master
+			}
+			if o == n {
+				flags = "myself," + flags
+			}
+
+			fields := []string{fmt.Sprintf("%s %s@1%s %s %s 0 0 0 connected", o.id, o.addr, o.addr[strings.LastIndex(o.addr, ":")+1:], flags, master)}
+			for s := 0; s < clusterSlots; s++ {
+				if o.slots[s] {
+					fields = append(fields, strconv.Itoa(s))
+				}
+			}
+
+			lines = append(lines, strings.Join(fields, " "))
+		}
+
+		return strings.Join(lines, "\n") + "\n", nil
+
+	case "CLUSTER INFO":
+		covered := 0
+		for id := range n.known {
+			covered += len(c.byID(id).slots)
+		}
+		if covered == clusterSlots {
+			return "cluster_state:ok\r\n", nil
+		}
+		return "cluster_state:fail\r\n", nil
+
+	case "CLUSTER MEET":
+		o, ok := c.nodes[args[0]+":"+args[1]]
+		if !ok {
+			return nil, redisError("ERR unknown address")
+		}
+		all := make(map[string]bool)
+		for id := range n.known {
+			all[id] = true
+		}
+		for id := range o.known {
+			all[id] = true
+		}
+		for id := range all {
+			m := c.byID(id)
+			m.known = make(map[string]bool)
+			for k := range all {
+				m.known[k] = true
+			}
+		}
+		return "OK", nil
+
+	case "CLUSTER ADDSLOTS":
+		for _, a := range args {
+			s, _ := strconv.Atoi(a)
+			for _, o := range c.nodes {
+				if o.slots[s] {
+					return nil, redisError("ERR Slot " + a + " is already busy")
+				}
+			}
+			n.slots[s] = true
+		}
+		return "OK", nil
+
+	case "CLUSTER SETSLOT":
+		s, _ := strconv.Atoi(args[0])
+		if strings.ToUpper(args[1]) == "NODE" {
+			dst := c.byID(args[2])
+			for _, o := range c.nodes {
+				delete(o.slots, s)
+			}
+			dst.slots[s] = true
+		}
+		return "OK", nil
+
+	case "CLUSTER GETKEYSINSLOT":
+		s, _ := strconv.Atoi(args[0])
+		out := make([]interface{}, 0, len(n.keys[s]))
+		for _, k := range n.keys[s] {
+			out = append(out, k)
+		}
+		return out, nil
+
+	case "MIGRATE":
+		dst := c.nodes[args[0]+":"+args[1]]
+		for _, k := range args[6:] {
+			for s, keys := range n.keys {
+				for i := range keys {
+					if keys[i] == k {
+						dst.keys[s] = append(dst.keys[s], k)
+						n.keys[s] = append(keys[:i], keys[i+1:]...)
+						break
+					}
+				}
+			}
+		}
+		return "OK", nil
+
+	case "CLUSTER REPLICATE":
+		if len(n.slots) > 0 {
+			return nil, redisError("ERR To set a master the node must be empty and without assigned slots.")
+		}
+		n.master = args[0]
+		return "OK", nil
+
+	case "CLUSTER FORGET":
+		if args[0] == n.id {
+			return nil, redisError("ERR I tried hard but I can't forget myself...")
+		}
+		if !n.known[args[0]] {
+			return nil, redisError("ERR Unknown node " + args[0])
+		}
+		delete(n.known, args[0])
+		return "OK", nil
+
+	case "CLUSTER RESET":
+		n.known = map[string]bool{n.id: true}
+		n.slots = make(map[int]bool)
+		n.master = ""
+		return "OK", nil
+
+	case "FLUSHALL":
+		n.keys = make(map[int][]string)
+		return "OK", nil
+	}
+
+	return nil, redisError("ERR unknown command " + cmd)
+}
+
+func fakeDialRedis(c *fakeRedisCluster) func() {
+	dial := dialRedis
+	dialRedis = c.dial
+
+	return func() { dialRedis = dial }
+}
+
+func newTestRedis(c *fakeRedisCluster, hosts map[string]string) []Redis {
+	out := make([]Redis, 0, len(hosts))
+
+	for addr, host := range hosts {
+		if _, ok := c.nodes[addr]; !ok {
+			c.add(fmt.Sprintf("node%02d", len(c.nodes)), addr)
+		}
+
+		ip, port, _ := splitAddr(addr)
+		n, _ := strconv.Atoi(port)
+
+		out = append(out, Redis{Ip: ip, Port: n, Host: host})
+	}
+
+	return out
+}
+
+func slotsOf(c *fakeRedisCluster) map[string]int {
+	out := make(map[string]int)
+
+	for addr, n := range c.nodes {
+		if len(n.slots) > 0 {
+			out[addr] = len(n.slots)
+		}
+	}
+
+	return out
+}
+
+func TestReadReply(t *testing.T) {
+	r := bufio.NewReader(strings.NewReader("*4\r\n+OK\r\n:12\r\n$5\r\nhello\r\n$-1\r\n-ERR bad\r\n"))
+
+	reply, err := readReply(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	list, ok := reply.([]interface{})
+	if !ok || len(list) != 4 || list[0] != "OK" || list[1] != int64(12) || list[2] != "hello" || list[3] != nil {
+		t.Errorf("unexpected reply %#v", reply)
+	}
+
+	reply, err = readReply(r)
+	if err != nil || reply != redisError("ERR bad") {
+		t.Errorf("unexpected reply %#v,%v", reply, err)
+	}
+}
+
+func TestParseClusterNodes(t *testing.T) {
+	text := `07c37dfeb235213a872192d90877d0cd55635b91 127.0.0.1:30004@31004 slave e7d1eecce10fd6bb5eb35b9f99a514335d9ba9ca 0 1426238317239 4 connected
+67ed2db8d677e59ec4a4cefb06858cf2a1a89fa1 127.0.0.1:30002@31002 master - 0 1426238316232 2 connected 5461-10922 [10923->-e7d1eecce10fd6bb5eb35b9f99a514335d9ba9ca]
+e7d1eecce10fd6bb5eb35b9f99a514335d9ba9ca 127.0.0.1:30001@31001 myself,master - 0 0 1 connected 0-5460 10923
+6ec23923021cf3ffec47632106199cb7f496ce01 127.0.0.1:30005@31005 slave,fail 67ed2db8d677e59ec4a4cefb06858cf2a1a89fa1 0 1426238316232 5 disconnected`
+
+	nodes, err := parseClusterNodes(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(nodes) != 4 {
+		t.Fatalf("expected 4 nodes but got %d", len(nodes))
+	}
+
+	if n := nodes[0]; n.Addr != "127.0.0.1:30004" || n.isMaster() || n.MasterID != nodes[2].ID {
+		t.Errorf("unexpected node %+v", n)
+	}
+
+	if n := nodes[1]; len(n.Slots) != 10922-5461+1 {
+		t.Errorf("unexpected slots %d", len(n.Slots))
+	}
+
+	if n := nodes[2]; !n.hasFlag("myself") || len(n.Slots) != 5462 {
+		t.Errorf("unexpected node %+v", n.Flags)
+	}
+
+	if n := nodes[3]; !n.failed() || n.Connected {
+		t.Errorf("unexpected node %+v", n)
+	}
+
+	if _, err := parseClusterNodes("abc 127.0.0.1:1 master"); err == nil {
+		t.Error("expected error with bad line")
+	}
+}
+
+func TestRedisShardingManager(t *testing.T) {
+	c := newFakeRedisCluster()
+	defer fakeDialRedis(c)()
+
+	hosts := map[string]string{
+		"10.0.0.1:6379": "h1",
+		"10.0.0.2:6379": "h2",
+		"10.0.0.3:6379": "h3",
+	}
+
+	// create
+	m := newRedisShardingManager(newTestRedis(c, hosts), 3, 0)
+	if err := m.ComposeCluster(); err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	if err := m.CheckCluster(); err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	if got := slotsOf(c); len(got) != 3 {
+		t.Errorf("unexpected slots %v", got)
+	}
+
+	// add replicas
+	hosts["10.0.0.4:6379"] = "h1"
+	hosts["10.0.0.5:6379"] = "h2"
+	hosts["10.0.0.6:6379"] = "h3"
+
+	m = newRedisShardingManager(newTestRedis(c, hosts), 3, 1)
+	if err := m.ComposeCluster(); err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	for _, addr := range []string{"10.0.0.4:6379", "10.0.0.5:6379", "10.0.0.6:6379"} {
+		n := c.nodes[addr]
+		master := c.byID(n.master)
+		if master == nil || hosts[master.addr] == hosts[addr] {
+			t.Errorf("%s,unexpected master %v", addr, master)
+		}
+	}
+
+	// add a master and a replica,keys are migrated with slots
+	owner := c.nodes["10.0.0.1:6379"]
+	for s := range owner.slots {
+		owner.keys[s] = []string{"key" + strconv.Itoa(s)}
+	}
+
+	hosts["10.0.0.7:6379"] = "h4"
+	hosts["10.0.0.8:6379"] = "h4"
+
+	m = newRedisShardingManager(newTestRedis(c, hosts), 4, 1)
+	if err := m.ComposeCluster(); err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	if err := m.CheckCluster(); err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	for addr, n := range slotsOf(c) {
+		if n != clusterSlots/4 {
+			t.Errorf("%s,expected %d slots but got %d", addr, clusterSlots/4, n)
+		}
+	}
+
+	for _, n := range c.nodes {
+		for s, keys := range n.keys {
+			if len(keys) > 0 && !n.slots[s] {
+				t.Errorf("%s,keys of slot %d not migrated", n.addr, s)
+			}
+		}
+	}
+
+	// remove a master
+	delete(hosts, "10.0.0.1:6379")
+
+	m = newRedisShardingManager(newTestRedis(c, hosts), 3, 1)
+	if err := m.ComposeCluster(); err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	if err := m.CheckCluster(); err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	for s, keys := range owner.keys {
+		if len(keys) > 0 {
+			t.Errorf("keys of slot %d remain on the removed node", s)
+		}
+	}
+
+	for addr := range hosts {
+		if c.nodes[addr].known[owner.id] {
+			t.Errorf("%s,the removed node is not forgotten", addr)
+		}
+	}
+
+	// slots not covered
+	c.nodes["10.0.0.2:6379"].slots = make(map[int]bool)
+
+	err := m.CheckCluster()
+	if _, ok := err.(InstanceErrors); !ok {
+		t.Errorf("expected InstanceErrors but got %v", err)
+	}
+}
@@ -0,0 +1,318 @@
+package compose
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/docker/swarm/garden/structs"
+	"github.com/pkg/errors"
+)
+
+// Topology probes the current role of the service units,
+// an unreachable unit is reported with the probe error instead of failing the whole topology.
+func Topology(req *structs.ServiceSpec) ([]structs.UnitTopology, error) {
+	if req.Image.Name == "sentinel" {
+		port, err := getRedisPortBySpec(req)
+		if err != nil {
+			return nil, err
+		}
+
+		return sentinelTopology(req.Units, port), nil
+	}
+
+	if err := valicateServiceSpec(req); err != nil {
+		return nil, err
+	}
+
+	switch arch := getDbType(req); arch {
+	case mysqlRepArch:
+		return mysqlRepTopology(req.Units, getMysqls(req)), nil
+
+	case mysqlGroupArch:
+		return mysqlGroupTopology(req.Units, getMysqls(req)), nil
+
+	case redisRepArch, upredisRepArch:
+		return redisRepTopology(req.Units, getRedis(req)), nil
+
+	case redisShardingArch, upredisShardingArch:
+		return redisShardingTopology(req.Units, getRedis(req)), nil
+
+	default:
+		return nil, errors.Errorf("%s:topology is not supported", arch)
+	}
+}
+
+func newUnitTopology(u structs.UnitSpec, addr string) structs.UnitTopology {
+	return structs.UnitTopology{
+		ID:   u.ID,
+		Name: u.Name,
+		Addr: addr,
+		Lag:  -1,
+	}
+}
+
+func (r dbRole) topology() string {
+	return strings.ToLower(string(r))
+}
+
+func errString(err error) string {
+	if err == nil {
+		return ""
+	}
+
+	return err.Error()
+}
+
+// probe returns the replication status of the instance and sets its role,
+// the instance is a master if it's not a slave.
+func (m *Mysql) probe() (*ReplicationStatus, error) {
+	conn, err := m.connect()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	status, err := conn.SlaveStatus()
+	if err != nil {
+		return nil, m.instanceError("show slave status", err)
+	}
+
+	if status == nil {
+		m.RoleType = masterRole
+	} else {
+		m.RoleType = slaveRole
+	}
+
+	return status, nil
+}
+
+// mysqlRepTopology probes the units,dbs are in the same order of units.
+func mysqlRepTopology(units []structs.UnitSpec, dbs []Mysql) []structs.UnitTopology {
+	out := make([]structs.UnitTopology, len(dbs))
+
+	for i := range dbs {
+		t := newUnitTopology(units[i], dbs[i].GetKey())
+
+		status, err := dbs[i].probe()
+		if err != nil {
+			t.Error = err.Error()
+			out[i] = t
+			continue
+		}
+
+		t.Role = dbs[i].GetType().topology()
+
+		if status != nil {
+			t.Source = fmt.Sprintf("%s:%d", status.MasterHost, status.MasterPort)
+			t.Lag = status.SecondsBehindMaster
+			t.State = fmt.Sprintf("io_running=%t,sql_running=%t", status.IORunning, status.SQLRunning)
+			t.Error = errString(status.check(MaxReplicationLag))
+		}
+
+		out[i] = t
+	}
+
+	return out
+}
+
+// mysqlGroupTopology reports the primary as master and the secondaries as slave,
+// members are queried from the first reachable member.
+func mysqlGroupTopology(units []structs.UnitSpec, dbs []Mysql) []structs.UnitTopology {
+	out := make([]structs.UnitTopology, len(dbs))
+
+	members, err := (&MysqlGroupManager{Mysqls: dbs}).Members()
+
+	states := make(map[string]GroupMember, len(members))
+	for _, m := range members {
+		states[m.key()] = m
+	}
+
+	for i := range dbs {
+		t := newUnitTopology(units[i], dbs[i].GetKey())
+
+		if err != nil {
+			t.Error = err.Error()
+			out[i] = t
+			continue
+		}
+
+		m, ok := states[dbs[i].GetKey()]
+		if !ok {
+			t.Error = "not a member of the group"
+			out[i] = t
+			continue
+		}
+
+		if m.Primary {
+			dbs[i].RoleType = masterRole
+		} else {
+			dbs[i].RoleType = slaveRole
+		}
+
+		t.Role = dbs[i].GetType().topology()
+		t.State = m.State
+
+		out[i] = t
+	}
+
+	return out
+}
+
+// redisRepTopology reports master_last_io_seconds_ago as the lag of slave.
+func redisRepTopology(units []structs.UnitSpec, dbs []Redis) []structs.UnitTopology {
+	r := newRedisSwitcher(dbs)
+	defer r.conns.close()
+
+	out := make([]structs.UnitTopology, len(dbs))
+
+	for i := range dbs {
+		t := newUnitTopology(units[i], dbs[i].GetKey())
+
+		info, err := r.info(dbs[i].GetKey())
+		if err != nil {
+			t.Error = err.Error()
+			out[i] = t
+			continue
+		}
+
+		if info.Role == "master" {
+			dbs[i].RoleType = masterRole
+		} else {
+			dbs[i].RoleType = slaveRole
+		}
+
+		t.Role = dbs[i].GetType().topology()
+
+		if dbs[i].GetType() == slaveRole {
+			t.Source = info.MasterHost + ":" + info.MasterPort
+			t.Lag = info.LastIO
+			t.State = fmt.Sprintf("link_up=%t,offset=%d", info.LinkUp, info.Offset)
+			t.Error = errString(info.check(MaxReplicationLag))
+		}
+
+		out[i] = t
+	}
+
+	return out
+}
+
+// redisShardingTopology reports the view of each node about itself.
+func redisShardingTopology(units []structs.UnitSpec, dbs []Redis) []structs.UnitTopology {
+	r := newRedisShardingManager(dbs, 0, 0).(*RedisShardingManager)
+	defer r.close()
+
+	out := make([]structs.UnitTopology, len(dbs))
+
+	for i := range dbs {
+		t := newUnitTopology(units[i], dbs[i].GetKey())
+
+		nodes, err := r.nodes(dbs[i].GetKey())
+		if err != nil {
+			t.Error = err.Error()
+			out[i] = t
+			continue
+		}
+
+		addrs := make(map[string]string, len(nodes))
+		for _, n := range nodes {
+			addrs[n.ID] = n.Addr
+		}
+
+		for _, n := range nodes {
+			if !n.hasFlag("myself") {
+				continue
+			}
+
+			dbs[i].RoleType = n.role()
+
+			t.Role = dbs[i].GetType().topology()
+			t.Source = addrs[n.MasterID]
+			t.Slots = slotRanges(n.Slots)
+			t.State = strings.Join(n.Flags, ",")
+
+			if n.failed() {
+				t.Error = "node is failed"
+			}
+		}
+
+		out[i] = t
+	}
+
+	return out
+}
+
+// slotRanges compacts the sorted slots to ranges,"0-5460".
+func slotRanges(slots []int) []string {
+	var out []string
+
+	for i := 0; i < len(slots); {
+		j := i
+		for j+1 < len(slots) && slots[j+1] == slots[j]+1 {
+			j++
+		}
+
+		if i == j {
+			out = append(out, strconv.Itoa(slots[i]))
+		} else {
+			out = append(out, fmt.Sprintf("%d-%d", slots[i], slots[j]))
+		}
+
+		i = j + 1
+	}
+
+	return out
+}
+
+// sentinelTopology reports the monitored masters of each sentinel as backends,"name ip:port".
+func sentinelTopology(units []structs.UnitSpec, port int) []structs.UnitTopology {
+	conns := make(redisConns)
+	defer conns.close()
+
+	out := make([]structs.UnitTopology, 0, len(units))
+
+	for _, u := range units {
+		if len(u.Networking) == 0 {
+			t := newUnitTopology(u, "")
+			t.Error = "unit has no networking"
+			out = append(out, t)
+			continue
+		}
+
+		addr := u.Networking[0].IP + ":" + strconv.Itoa(port)
+		t := newUnitTopology(u, addr)
+
+		reply, err := conns.do(addr, "SENTINEL", "MASTERS")
+		if err != nil {
+			t.Error = err.Error()
+			out = append(out, t)
+			continue
+		}
+
+		t.Role = sentinelRole.topology()
+
+		masters, _ := reply.([]interface{})
+		for _, m := range masters {
+			fields := replyMap(m)
+			t.Backends = append(t.Backends, fmt.Sprintf("%s %s:%s", fields["name"], fields["ip"], fields["port"]))
+		}
+
+		out = append(out, t)
+	}
+
+	return out
+}
+
+// replyMap converts the flat key-value array reply to map.
+func replyMap(reply interface{}) map[string]string {
+	list, _ := reply.([]interface{})
+	out := make(map[string]string, len(list)/2)
+
+	for i := 0; i+1 < len(list); i += 2 {
+		key, _ := list[i].(string)
+		val, _ := list[i+1].(string)
+		out[key] = val
+	}
+
+	return out
+}
@@ -0,0 +1,119 @@
+package compose
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/docker/swarm/garden/structs"
+)
+
+func testUnits(n int) []structs.UnitSpec {
+	units := make([]structs.UnitSpec, n)
+	for i := range units {
+		units[i].ID = string(rune('a' + i))
+	}
+
+	return units
+}
+
+func TestSlotRanges(t *testing.T) {
+	got := slotRanges([]int{0, 1, 2, 5, 7, 8})
+	want := []string{"0-2", "5", "7-8"}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v but got %v", want, got)
+	}
+
+	if got := slotRanges(nil); len(got) != 0 {
+		t.Errorf("unexpected %v", got)
+	}
+}
+
+func TestMysqlRepTopology(t *testing.T) {
+	admin := mysqlUser{user: "root", password: "root"}
+
+	dbs := []Mysql{
+		{IP: "192.168.1.1", Port: 3306, Instance: "db1", admin: admin},
+		{IP: "192.168.1.2", Port: 3306, Instance: "db2", admin: admin},
+		{IP: "192.168.1.3", Port: 3306, Instance: "db3", admin: admin},
+	}
+
+	fakes := map[string]*fakeMysql{
+		"192.168.1.1:3306": {},
+		"192.168.1.2:3306": {status: &ReplicationStatus{MasterHost: "192.168.1.1", MasterPort: 3306,
+			IORunning: true, SQLRunning: true, SecondsBehindMaster: 2}},
+	}
+	defer fakeDialMysql(t, fakes)()
+
+	out := mysqlRepTopology(testUnits(3), dbs)
+
+	if out[0].Role != "master" || out[0].Source != "" || out[0].Error != "" {
+		t.Errorf("unexpected master,%+v", out[0])
+	}
+
+	if out[1].Role != "slave" || out[1].Source != "192.168.1.1:3306" || out[1].Lag != 2 || out[1].Error != "" {
+		t.Errorf("unexpected slave,%+v", out[1])
+	}
+
+	if out[2].Role != "" || out[2].Error == "" || out[2].ID != "c" {
+		t.Errorf("unexpected unreachable unit,%+v", out[2])
+	}
+}
+
+func TestRedisShardingTopology(t *testing.T) {
+	c := newFakeRedisCluster()
+	defer fakeDialRedis(c)()
+
+	hosts := map[string]string{
+		"10.0.0.1:6379": "h1",
+		"10.0.0.2:6379": "h2",
+		"10.0.0.3:6379": "h1",
+		"10.0.0.4:6379": "h2",
+	}
+
+	dbs := newTestRedis(c, hosts)
+	sort.Slice(dbs, func(i, j int) bool { return dbs[i].GetKey() < dbs[j].GetKey() })
+
+	if err := newRedisShardingManager(dbs, 2, 1).ComposeCluster(); err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	out := redisShardingTopology(testUnits(len(dbs)), dbs)
+
+	masters, slots := 0, 0
+	for _, u := range out {
+		switch u.Role {
+		case "master":
+			masters++
+			slots += len(u.Slots)
+
+			if len(u.Slots) == 0 || u.Source != "" {
+				t.Errorf("unexpected master,%+v", u)
+			}
+
+		case "slave":
+			if u.Source == "" || len(u.Slots) != 0 {
+				t.Errorf("unexpected slave,%+v", u)
+			}
+
+		default:
+			t.Errorf("unexpected unit,%+v", u)
+		}
+	}
+
+	if masters != 2 || slots != 2 {
+		t.Errorf("unexpected topology,%+v", out)
+	}
+}
+
+func TestTopologyUnsupported(t *testing.T) {
+	spec := &structs.ServiceSpec{
+		Image: structs.ImageVersion{Name: "switch_manager"},
+		Arch:  structs.Arch{Replicas: 0, Code: "M:1"},
+	}
+
+	if _, err := Topology(spec); err == nil {
+		t.Error("expected error for unsupported arch")
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"net/http"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
@@ -50,14 +51,17 @@ func NewRouter(c kvstore.Client, kvpath, dir, ip string, port int) *mux.Router {
 			"/commands/{service:.*}":       getCommands,
 		},
 		"POST": {
-			"/configs":           generateConfigs,
-			"/configs/{unit:.*}": generateConfig,
-			"/image/template":    postTemplate,
+			"/configs":                            generateConfigs,
+			"/configs/{unit:.*}":                  generateConfig,
+			"/image/template":                     postTemplate,
+			"/services/{service}/topology":        topologyService,
+			"/services/{service}/configs/migrate": migrateConfigs,
 		},
 		"PUT": {
-			"/configs/{service:.*}":       updateConfigs,
-			"/services/{service}/compose": composeService,
-			"/services/link":              linkServices,
+			"/configs/{service:.*}":          updateConfigs,
+			"/services/{service}/compose":    composeService,
+			"/services/{service}/switchover": switchoverService,
+			"/services/link":                 linkServices,
 		},
 	}
 
@@ -245,6 +249,14 @@ func postTemplate(ctx *_Context, w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if req.Upgrade != nil {
+		err = req.Upgrade.Validate()
+		if err != nil {
+			httpError(w, err, http.StatusBadRequest)
+			return
+		}
+	}
+
 	if !strings.HasPrefix(req.ConfigFile, req.DataMount) {
 		req.ConfigFile = filepath.Join(req.DataMount, req.ConfigFile)
 	}
@@ -494,6 +506,71 @@ func composeService(ctx *_Context, w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 }
 
+func switchoverService(ctx *_Context, w http.ResponseWriter, r *http.Request) {
+	var req structs.ServiceSwitchover
+
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		httpError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	old, master, err := compose.Switchover(&req.Spec, req.Target, time.Duration(req.MaxLag)*time.Second)
+	if err != nil {
+		httpError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(structs.ServiceSwitchoverResponse{
+		Old: old,
+		New: master,
+	})
+}
+
+func topologyService(ctx *_Context, w http.ResponseWriter, r *http.Request) {
+	var req structs.ServiceSpec
+
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		httpError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	out, err := serviceTopology(ctx.context, ctx.client, req)
+	if err != nil {
+		httpError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(out)
+}
+
+func migrateConfigs(ctx *_Context, w http.ResponseWriter, r *http.Request) {
+	var req structs.ServiceSpec
+
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		httpError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dry_run"))
+
+	out, err := migrateServiceConfigs(ctx.context, ctx.client, req, dryRun)
+	if err != nil {
+		httpError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(out)
+}
+
 func linkServices(ctx *_Context, w http.ResponseWriter, r *http.Request) {
 	req := structs.ServicesLink{}
 
@@ -668,6 +745,8 @@ func generateUnitConfig(unitID string, pr parser, t structs.ConfigTemplate, spec
 		return cc, err
 	}
 
+	r.Prometheus = prometheusRegistration(unitID, spec)
+
 	return structs.ConfigCmds{
 		ID:           unitID,
 		LogMount:     t.LogMount,
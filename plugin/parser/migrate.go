@@ -0,0 +1,152 @@
+package parser
+
+import (
+	"github.com/docker/swarm/garden/kvstore"
+	"github.com/docker/swarm/garden/structs"
+	"github.com/pkg/errors"
+	"golang.org/x/net/context"
+)
+
+// migrateServiceConfigs generates the units configs by the template of the new image of spec,
+// the keysets values modified in the old configs are carried to the new configs,
+// renamed keys are carried to the new keys and removed keys are dropped as the template Upgrade declared.
+// The new configs aren't stored if dryRun.
+func migrateServiceConfigs(ctx context.Context, kvc kvstore.Store, spec structs.ServiceSpec, dryRun bool) (structs.ConfigsMap, error) {
+	pr, err := factoryByImage(spec.Service.Image)
+	if err != nil {
+		return nil, err
+	}
+
+	image, version := spec.Service.Image.Name, spec.Service.Image.Version()
+
+	template, err := getTemplateFromStore(ctx, kvc, image, version)
+	if err != nil {
+		return nil, err
+	}
+
+	cm, err := getConfigMapFromStore(ctx, kvc, spec.Service.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(spec.Options) == 0 {
+		spec.Options = make(map[string]interface{})
+	}
+
+	resp := make(structs.ConfigsMap, len(spec.Units))
+
+	for i := range spec.Units {
+		id := spec.Units[i].ID
+
+		old, ok := cm[id]
+		if !ok {
+			return nil, errors.Errorf("unit %s config is not exist", id)
+		}
+
+		carry, err := carryKeysets(ctx, kvc, old, template)
+		if err != nil {
+			return nil, err
+		}
+
+		t := template
+		t.DataMount = old.DataMount
+		t.LogMount = old.LogMount
+
+		spec.Options[units_prefix+id] = unit_exist
+
+		cc, err := generateUnitConfig(id, pr, t, spec, carry)
+		if err != nil {
+			return nil, errors.WithMessage(err, "migrate unit "+id+" config")
+		}
+
+		cc.Name = image
+		cc.Version = version
+
+		resp[id] = cc
+	}
+
+	if dryRun {
+		return resp, nil
+	}
+
+	err = putConfigsToStore(ctx, kvc, spec.Service.ID, resp)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// carryKeysets returns the keysets values of the old config should be kept by the new template,
+// the values equal to the defaults of the old template are not carried,so new defaults take effect.
+func carryKeysets(ctx context.Context, kvc kvstore.Store, old structs.ConfigCmds, t structs.ConfigTemplate) (map[string]interface{}, error) {
+	pr, err := factory(old.Name + ":" + old.Version)
+	if err != nil {
+		return nil, err
+	}
+
+	pr = pr.clone(nil)
+
+	err = pr.ParseData([]byte(old.Content))
+	if err != nil {
+		return nil, err
+	}
+
+	defaults := make(map[string]string)
+	if ot, err := getTemplateFromStore(ctx, kvc, old.Name, old.Version); err == nil {
+		for _, ks := range ot.Keysets {
+			defaults[ks.Key] = ks.Default
+		}
+	}
+
+	var upgrade structs.ImageUpgrade
+	if t.Upgrade != nil {
+		upgrade = *t.Upgrade
+	}
+
+	removed := make(map[string]bool, len(upgrade.RemovedKeys))
+	for _, key := range upgrade.RemovedKeys {
+		removed[key] = true
+	}
+
+	from := make(map[string]string, len(upgrade.RenamedKeys))
+	for o, n := range upgrade.RenamedKeys {
+		from[n] = o
+	}
+
+	carry := make(map[string]interface{}, len(t.Keysets))
+
+	value := func(src, dst string) {
+		if removed[src] {
+			return
+		}
+
+		val, ok := pr.get(src)
+		if !ok {
+			return
+		}
+
+		if def, ok := defaults[src]; ok && def == val {
+			return
+		}
+
+		carry[dst] = val
+	}
+
+	for _, ks := range t.Keysets {
+		src := ks.Key
+		if o, ok := from[ks.Key]; ok {
+			src = o
+		}
+
+		value(src, ks.Key)
+	}
+
+	for o, n := range upgrade.RenamedKeys {
+		if _, ok := carry[n]; !ok {
+			value(o, n)
+		}
+	}
+
+	return carry, nil
+}
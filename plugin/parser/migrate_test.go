@@ -0,0 +1,122 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/docker/swarm/cluster"
+	"github.com/docker/swarm/garden/kvstore"
+	"github.com/docker/swarm/garden/structs"
+	"golang.org/x/net/context"
+)
+
+func TestMigrateServiceConfigs(t *testing.T) {
+	ctx := context.Background()
+	kvc := kvstore.NewMockClient()
+
+	old := structs.ConfigTemplate{
+		Image:     "redis:3.2.8.0",
+		LogMount:  "/usr/local/log",
+		DataMount: "/usr/local/data",
+		Keysets: []structs.Keyset{
+			{Key: "timeout", Default: "0"},
+			{Key: "maxmemory-policy", Default: "noeviction"},
+			{Key: "keepalive", Default: "0"},
+			{Key: "slave-read-only", Default: "yes"},
+		},
+	}
+	tmpl := structs.ConfigTemplate{
+		Image:     "redis:3.2.9.0",
+		LogMount:  "/usr/local/log",
+		DataMount: "/usr/local/data",
+		Content:   "timeout 0\nmaxmemory-policy allkeys-lru\ntcp-keepalive 0\nslave-read-only yes\n",
+		Keysets: []structs.Keyset{
+			{Key: "timeout", Default: "0"},
+			{Key: "maxmemory-policy", Default: "allkeys-lru"},
+			{Key: "tcp-keepalive", Default: "0"},
+			{Key: "slave-read-only", Default: "yes"},
+		},
+		Upgrade: &structs.ImageUpgrade{
+			From:        []string{"3.2.*"},
+			RenamedKeys: map[string]string{"keepalive": "tcp-keepalive"},
+			RemovedKeys: []string{"slave-read-only"},
+		},
+	}
+
+	for _, ct := range []structs.ConfigTemplate{old, tmpl} {
+		if err := putTemplateToStore(ctx, kvc, ct); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err := putConfigsToStore(ctx, kvc, "service0002", structs.ConfigsMap{
+		"unit001": {
+			ID:        "unit001",
+			Name:      "redis",
+			Version:   "3.2.8.0",
+			DataMount: "/DATA",
+			LogMount:  "/LOG",
+			Content:   "bind 192.168.4.141\nport 8327\ntimeout 300\nmaxmemory-policy noeviction\nkeepalive 60\nslave-read-only no\n",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	spec := structs.ServiceSpec{
+		Service: structs.Service{
+			ID:    "service0002",
+			Image: structs.ImageVersion{Name: "redis", Major: 3, Minor: 2, Patch: 9},
+		},
+		Options: map[string]interface{}{"port": 8327},
+		Units: []structs.UnitSpec{
+			{
+				Unit:       structs.Unit{ID: "unit001", Name: "unit001_redis"},
+				Config:     &cluster.ContainerConfig{},
+				Networking: []structs.UnitIP{{IP: "192.168.4.141"}},
+			},
+		},
+	}
+
+	cm, err := migrateServiceConfigs(ctx, kvc, spec, true)
+	if err != nil || cm["unit001"].Version != "3.2.9.0" {
+		t.Fatalf("unexpected dry run configs %+v,%+v", cm, err)
+	}
+
+	stored, err := getConfigMapFromStore(ctx, kvc, "service0002")
+	if err != nil || stored["unit001"].Version != "3.2.8.0" {
+		t.Fatalf("expected configs unchanged by dry run,%v", err)
+	}
+
+	cm, err = migrateServiceConfigs(ctx, kvc, spec, false)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	cc, ok := cm["unit001"]
+	if !ok || cc.Version != "3.2.9.0" || cc.DataMount != "/DATA" {
+		t.Fatalf("unexpected config %+v", cc)
+	}
+
+	pr := &redisConfig{}
+	if err := pr.ParseData([]byte(cc.Content)); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		"timeout":          "300",
+		"maxmemory-policy": "allkeys-lru",
+		"tcp-keepalive":    "60",
+		"slave-read-only":  "yes",
+		"dir":              "/DATA",
+	}
+	for key, val := range want {
+		if got, _ := pr.get(key); got != val {
+			t.Errorf("%s expected '%s' but got '%s'", key, val, got)
+		}
+	}
+
+	stored, err = getConfigMapFromStore(ctx, kvc, "service0002")
+	if err != nil || stored["unit001"].Version != "3.2.9.0" {
+		t.Errorf("expected migrated configs stored,%v", err)
+	}
+}
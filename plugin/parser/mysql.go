@@ -1,6 +1,7 @@
 package parser
 
 import (
+	"crypto/md5"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -11,6 +12,7 @@ import (
 	"github.com/astaxie/beego/config"
 	"github.com/docker/swarm/garden/structs"
 	"github.com/docker/swarm/garden/utils"
+	"github.com/docker/swarm/plugin/parser/compose"
 	"github.com/docker/swarm/vars"
 	"github.com/hashicorp/consul/api"
 	"github.com/pkg/errors"
@@ -36,6 +38,8 @@ func init() {
 const (
 	monitorRole = "monitor"
 	rootRole    = "root"
+
+	groupReplicationMode = "group_replication"
 )
 
 const (
@@ -220,6 +224,17 @@ func (c *mysqlConfig) GenerateConfig(id string, desc structs.ServiceSpec) error
 		}
 	}
 
+	if desc.Arch.Mode == groupReplicationMode {
+		gr, err := groupReplicationConfig(id, desc)
+		if err != nil {
+			return err
+		}
+
+		for key, val := range gr {
+			m[key] = val
+		}
+	}
+
 	for key, val := range m {
 		err = c.set(key, val)
 	}
@@ -228,7 +243,7 @@ func (c *mysqlConfig) GenerateConfig(id string, desc structs.ServiceSpec) error
 }
 
 func (c mysqlConfig) GenerateCommands(id string, desc structs.ServiceSpec) (structs.CmdsMap, error) {
-	cmds := make(structs.CmdsMap, 6)
+	cmds := make(structs.CmdsMap, 7)
 
 	cmds[structs.StartContainerCmd] = []string{"/bin/bash"}
 
@@ -244,6 +259,10 @@ func (c mysqlConfig) GenerateCommands(id string, desc structs.ServiceSpec) (stru
 
 	cmds[structs.BackupCmd] = []string{"/root/mysql-backup.sh"}
 
+	if cmd := templateCmd(c.template, structs.RestoreCheckCmd); len(cmd) > 0 {
+		cmds[structs.RestoreCheckCmd] = cmd
+	}
+
 	return cmds, nil
 }
 
@@ -350,8 +369,8 @@ func (upsqlConfig) clone(t *structs.ConfigTemplate) parser {
 	return pr
 }
 
-func (upsqlConfig) GenerateCommands(id string, desc structs.ServiceSpec) (structs.CmdsMap, error) {
-	cmds := make(structs.CmdsMap, 7)
+func (c upsqlConfig) GenerateCommands(id string, desc structs.ServiceSpec) (structs.CmdsMap, error) {
+	cmds := make(structs.CmdsMap, 8)
 
 	cmds[structs.StartContainerCmd] = []string{"/bin/bash"}
 
@@ -369,6 +388,10 @@ func (upsqlConfig) GenerateCommands(id string, desc structs.ServiceSpec) (struct
 
 	cmds[structs.MigrateRebuildCmd] = []string{"/root/upsql-config-init.sh"}
 
+	if cmd := templateCmd(c.template, structs.RestoreCheckCmd); len(cmd) > 0 {
+		cmds[structs.RestoreCheckCmd] = cmd
+	}
+
 	return cmds, nil
 }
 
@@ -380,9 +403,91 @@ func (c *upsqlConfig) GenerateConfig(id string, desc structs.ServiceSpec) error
 
 	if c.template != nil {
 		err = c.set("mysqld::socket", filepath.Join(c.template.DataMount, "/upsql.sock"))
+		if err != nil {
+			return errors.WithStack(err)
+		}
+	}
+
+	return nil
+}
+
+// groupReplicationPort returns the port for group communication of the mysqld port.
+func groupReplicationPort(port int) int {
+	if port+10000 <= 65535 {
+		return port + 10000
+	}
+
+	return port - 10000
+}
+
+// groupReplicationName returns the group name in UUID format,derived from the service ID.
+func groupReplicationName(service string) string {
+	sum := fmt.Sprintf("%x", md5.Sum([]byte(service)))
+
+	return fmt.Sprintf("%s-%s-%s-%s-%s", sum[:8], sum[8:12], sum[12:16], sum[16:20], sum[20:])
+}
+
+// groupReplicationConfig returns the group_replication_* settings of unit,
+// loose- prefix makes mysqld start before the plugin is installed.
+func groupReplicationConfig(id string, desc structs.ServiceSpec) (map[string]interface{}, error) {
+	single, err := compose.IsSinglePrimary(desc.Options)
+	if err != nil {
+		return nil, err
 	}
 
-	return errors.WithStack(err)
+	port, err := atoi(desc.Options["mysqld::port"])
+	if err != nil {
+		return nil, errors.Wrap(err, "miss mysqld::port")
+	}
+	if port == 0 {
+		return nil, errors.New("miss mysqld::port")
+	}
+
+	gport := groupReplicationPort(port)
+	local := ""
+	seeds := make([]string, 0, len(desc.Units))
+	whitelist := make([]string, 0, len(desc.Units))
+
+	for i := range desc.Units {
+		if len(desc.Units[i].Networking) == 0 {
+			return nil, errors.Errorf("unit '%s' miss networking", desc.Units[i].ID)
+		}
+
+		ip := desc.Units[i].Networking[0].IP
+		addr := fmt.Sprintf("%s:%d", ip, gport)
+
+		if desc.Units[i].ID == id {
+			local = addr
+		}
+
+		seeds = append(seeds, addr)
+		whitelist = append(whitelist, ip)
+	}
+
+	if local == "" {
+		return nil, errors.Errorf("not found unit '%s'", id)
+	}
+
+	return map[string]interface{}{
+		"mysqld::gtid_mode":                 "ON",
+		"mysqld::enforce_gtid_consistency":  "ON",
+		"mysqld::binlog_checksum":           "NONE",
+		"mysqld::binlog_format":             "ROW",
+		"mysqld::log_slave_updates":         "ON",
+		"mysqld::master_info_repository":    "TABLE",
+		"mysqld::relay_log_info_repository": "TABLE",
+
+		"mysqld::transaction_write_set_extraction":                         "XXHASH64",
+		"mysqld::loose-plugin_load_add":                                    "group_replication.so",
+		"mysqld::loose-group_replication_group_name":                       groupReplicationName(desc.ID),
+		"mysqld::loose-group_replication_start_on_boot":                    "OFF",
+		"mysqld::loose-group_replication_bootstrap_group":                  "OFF",
+		"mysqld::loose-group_replication_local_address":                    local,
+		"mysqld::loose-group_replication_group_seeds":                      strings.Join(seeds, ","),
+		"mysqld::loose-group_replication_ip_whitelist":                     strings.Join(whitelist, ","),
+		"mysqld::loose-group_replication_single_primary_mode":              compose.OnOff(single),
+		"mysqld::loose-group_replication_enforce_update_everywhere_checks": compose.OnOff(!single),
+	}, nil
 }
 
 func (c upsqlConfig) HealthCheck(id string, desc structs.ServiceSpec) (structs.ServiceRegistration, error) {
@@ -0,0 +1,85 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/docker/swarm/garden/structs"
+	"github.com/docker/swarm/plugin/parser/compose"
+)
+
+func TestGroupReplicationConfig(t *testing.T) {
+	desc := structs.ServiceSpec{
+		Arch:    structs.Arch{Mode: groupReplicationMode, Replicas: 2},
+		Options: map[string]interface{}{"mysqld::port": float64(3306), compose.GroupModeOption: compose.MultiPrimaryMode},
+		Units: []structs.UnitSpec{
+			{Networking: []structs.UnitIP{{IP: "192.168.1.1"}}},
+			{Networking: []structs.UnitIP{{IP: "192.168.1.2"}}},
+		},
+	}
+	desc.ID = "2d1f9c7e3b6a4e8f9a0b1c2d3e4f5a6b"
+	desc.Units[0].ID = "unit0"
+	desc.Units[1].ID = "unit1"
+
+	m, err := groupReplicationConfig("unit1", desc)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	want := map[string]string{
+		"mysqld::loose-group_replication_local_address":       "192.168.1.2:13306",
+		"mysqld::loose-group_replication_group_seeds":         "192.168.1.1:13306,192.168.1.2:13306",
+		"mysqld::loose-group_replication_single_primary_mode": "OFF",
+		"mysqld::gtid_mode": "ON",
+	}
+
+	for key, val := range want {
+		if m[key] != val {
+			t.Errorf("%s:expected %s but got %v", key, val, m[key])
+		}
+	}
+
+	name, _ := m["mysqld::loose-group_replication_group_name"].(string)
+	if len(name) != 36 || strings.Count(name, "-") != 4 || name != groupReplicationName(desc.ID) {
+		t.Errorf("unexpected group name %s", name)
+	}
+
+	if _, err := groupReplicationConfig("unit2", desc); err == nil {
+		t.Error("expected error with unknown unit")
+	}
+}
+
+func TestMysqlGroupReplicationConfig(t *testing.T) {
+	desc := structs.ServiceSpec{
+		Arch:    structs.Arch{Mode: groupReplicationMode, Replicas: 2},
+		Options: map[string]interface{}{"mysqld::port": float64(3306)},
+		Units: []structs.UnitSpec{
+			{Networking: []structs.UnitIP{{IP: "192.168.1.1"}}},
+			{Networking: []structs.UnitIP{{IP: "192.168.1.2"}}},
+		},
+	}
+	desc.ID = "2d1f9c7e3b6a4e8f9a0b1c2d3e4f5a6b"
+	desc.Units[0].ID = "unit0"
+	desc.Units[1].ID = "unit1"
+
+	c := &mysqlConfig{}
+	if err := c.ParseData([]byte("[mysqld]\nport = 3306\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.GenerateConfig("unit0", desc); err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	want := map[string]string{
+		"mysqld::loose-group_replication_local_address":       "192.168.1.1:13306",
+		"mysqld::loose-group_replication_single_primary_mode": "ON",
+		"mysqld::gtid_mode": "ON",
+	}
+
+	for key, val := range want {
+		if got, _ := c.get(key); got != val {
+			t.Errorf("%s:expected %s but got %s", key, val, got)
+		}
+	}
+}
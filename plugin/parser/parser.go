@@ -77,3 +77,12 @@ func factoryByImage(im structs.ImageVersion) (parser, error) {
 
 	return nil, errors.Errorf("Unsupported image %s yet", im.Image())
 }
+
+// templateCmd returns the check command provided by the image template,nil if absent.
+func templateCmd(t *structs.ConfigTemplate, typ string) []string {
+	if t == nil {
+		return nil
+	}
+
+	return t.CheckCmds.Get(typ)
+}
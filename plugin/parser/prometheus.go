@@ -0,0 +1,66 @@
+package parser
+
+import (
+	"net"
+	"strconv"
+
+	"github.com/docker/swarm/garden/structs"
+)
+
+// exporterPortOption is the service option of the exporter port,overrides exporterPorts.
+const exporterPortOption = "exporter_port"
+
+// unit roles known without probing,the role of database units is labeled after composed.
+var imageRoles = map[string]string{
+	"proxy":          proxyRole,
+	"upproxy":        proxyRole,
+	"urproxy":        proxyRole,
+	"sentinel":       "sentinel",
+	"switch_manager": "switch_manager",
+}
+
+// default ports of the Prometheus exporters running beside the units,
+// mysqld_exporter and redis_exporter.
+var exporterPorts = map[string]int{
+	"mysql": 9104,
+	"upsql": 9104,
+	"redis": 9121,
+}
+
+// exporterPort returns the exporter port of the service,0 if the image has no exporter.
+func exporterPort(spec structs.ServiceSpec) int {
+	if v, ok := spec.Options[exporterPortOption]; ok {
+		if port, err := atoi(v); err == nil && port > 0 {
+			return port
+		}
+	}
+
+	return exporterPorts[spec.Image.Name]
+}
+
+// prometheusRegistration returns the Prometheus target group of the unit,
+// the target is the exporter address of the unit,
+// returns nil if the unit has no exporter or the address is unknown.
+func prometheusRegistration(unitID string, spec structs.ServiceSpec) *structs.PrometheusRegistration {
+	u, err := getUnitSpec(spec.Units, unitID)
+	if err != nil || len(u.Networking) == 0 || u.Networking[0].IP == "" {
+		return nil
+	}
+
+	port := exporterPort(spec)
+	if port == 0 {
+		return nil
+	}
+
+	return &structs.PrometheusRegistration{
+		Key:     u.ID,
+		Targets: []string{net.JoinHostPort(u.Networking[0].IP, strconv.Itoa(port))},
+		Labels: map[string]string{
+			"service": spec.Name,
+			"unit":    u.Name,
+			"role":    imageRoles[spec.Image.Name],
+			"tag":     spec.Tag,
+			"image":   spec.Image.Name,
+		},
+	}
+}
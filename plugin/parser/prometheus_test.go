@@ -0,0 +1,36 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/docker/swarm/garden/structs"
+)
+
+func TestPrometheusRegistration(t *testing.T) {
+	spec := structs.ServiceSpec{}
+	spec.Name = "svc01"
+	spec.Image.Name = "mysql"
+	spec.Units = []structs.UnitSpec{{}}
+	spec.Units[0].ID = "unit01"
+	spec.Units[0].Networking = []structs.UnitIP{{IP: "192.168.1.10"}}
+	spec.Options = map[string]interface{}{"mysqld::port": 3306}
+
+	r := prometheusRegistration("unit01", spec)
+	if r == nil || len(r.Targets) != 1 || r.Targets[0] != "192.168.1.10:9104" {
+		t.Errorf("expected exporter target 192.168.1.10:9104 but got %+v", r)
+	}
+
+	spec.Options[exporterPortOption] = float64(9200)
+
+	r = prometheusRegistration("unit01", spec)
+	if r == nil || r.Targets[0] != "192.168.1.10:9200" {
+		t.Errorf("expected exporter target 192.168.1.10:9200 but got %+v", r)
+	}
+
+	spec.Image.Name = "switch_manager"
+	delete(spec.Options, exporterPortOption)
+
+	if r = prometheusRegistration("unit01", spec); r != nil {
+		t.Errorf("expected no target without exporter but got %+v", r)
+	}
+}
@@ -0,0 +1,130 @@
+package parser
+
+import (
+	"strings"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/swarm/garden/kvstore"
+	"github.com/docker/swarm/garden/structs"
+	"github.com/docker/swarm/plugin/parser/compose"
+	"github.com/pkg/errors"
+	"golang.org/x/net/context"
+)
+
+const proxyRole = "proxy"
+
+// proxy address and backends keys in unit config
+var proxyKeys = map[string]struct{ addr, backends string }{
+	"proxy":   {addr: "upsql-proxy::proxy-address", backends: "adm-cli::adm-svr-address"},
+	"upproxy": {addr: "upsql-proxy::proxy-address", backends: "adm-cli::adm-svr-address"},
+	"urproxy": {addr: "listen", backends: "sentinels"},
+}
+
+// port key in unit config and ServiceSpec.Options
+var portKeys = map[string]string{
+	"mysql":    "mysqld::port",
+	"upsql":    "mysqld::port",
+	"redis":    "port",
+	"upredis":  "port",
+	"sentinel": "port",
+}
+
+// serviceTopology probes the current role of the service units,
+// proxy backends are read from the unit configs,others are probed by compose.
+func serviceTopology(ctx context.Context, kvc kvstore.Store, spec structs.ServiceSpec) ([]structs.UnitTopology, error) {
+	if _, ok := proxyKeys[spec.Image.Name]; ok {
+		return proxyTopology(ctx, kvc, spec)
+	}
+
+	if err := setPortOption(ctx, kvc, &spec); err != nil {
+		logrus.Warnf("service %s port,%+v", spec.Name, err)
+	}
+
+	return compose.Topology(&spec)
+}
+
+// setPortOption sets the port option from the unit config if it's not in spec.Options.
+func setPortOption(ctx context.Context, kvc kvstore.Store, spec *structs.ServiceSpec) error {
+	key, ok := portKeys[spec.Image.Name]
+	if !ok || len(spec.Units) == 0 {
+		return nil
+	}
+
+	if _, ok := spec.Options[key]; ok {
+		return nil
+	}
+
+	cms, pr, err := getServiceConfigParser(ctx, kvc, spec.ID, spec.Image)
+	if err != nil {
+		return err
+	}
+
+	cc, ok := cms[spec.Units[0].ID]
+	if !ok {
+		return errors.Errorf("not found unit %s config", spec.Units[0].Name)
+	}
+
+	pr = pr.clone(nil)
+
+	if err := pr.ParseData([]byte(cc.Content)); err != nil {
+		return err
+	}
+
+	port, _ := pr.get(key)
+	if port == "" {
+		return errors.Errorf("unit %s config %s is empty", spec.Units[0].Name, key)
+	}
+
+	if spec.Options == nil {
+		spec.Options = make(map[string]interface{})
+	}
+
+	spec.Options[key] = port
+
+	return nil
+}
+
+func proxyTopology(ctx context.Context, kvc kvstore.Store, spec structs.ServiceSpec) ([]structs.UnitTopology, error) {
+	keys := proxyKeys[spec.Image.Name]
+
+	cms, pr, err := getServiceConfigParser(ctx, kvc, spec.ID, spec.Image)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]structs.UnitTopology, 0, len(spec.Units))
+
+	for _, u := range spec.Units {
+		t := structs.UnitTopology{
+			ID:   u.ID,
+			Name: u.Name,
+			Role: proxyRole,
+			Lag:  -1,
+		}
+
+		cc, ok := cms[u.ID]
+		if !ok {
+			t.Error = "not found unit config"
+			out = append(out, t)
+			continue
+		}
+
+		up := pr.clone(nil)
+
+		if err := up.ParseData([]byte(cc.Content)); err != nil {
+			t.Error = err.Error()
+			out = append(out, t)
+			continue
+		}
+
+		t.Addr, _ = up.get(keys.addr)
+
+		if val, _ := up.get(keys.backends); val != "" {
+			t.Backends = strings.Split(val, stringAndString)
+		}
+
+		out = append(out, t)
+	}
+
+	return out, nil
+}
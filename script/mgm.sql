@@ -17,6 +17,26 @@
 SET @MYSQLDUMP_TEMP_LOG_BIN = @@SESSION.SQL_LOG_BIN;
 SET @@SESSION.SQL_LOG_BIN= 0;
 
+--
+-- Table structure for table `tbl_backup_callback`
+--
+
+DROP TABLE IF EXISTS `tbl_backup_callback`;
+/*!40101 SET @saved_cs_client     = @@character_set_client */;
+/*!40101 SET character_set_client = utf8 */;
+CREATE TABLE `tbl_backup_callback` (
+  `task_id` varchar(128) NOT NULL COMMENT '关联tbl_task.id',
+  `unit_id` varchar(128) NOT NULL COMMENT '执行备份的单元id',
+  `secret` varchar(128) NOT NULL COMMENT '回调令牌签名密钥',
+  `backup_file_id` varchar(128) NOT NULL DEFAULT '' COMMENT '首次回调生成的备份文件，关联tbl_backup_files.id',
+  `path` varchar(1024) NOT NULL DEFAULT '' COMMENT '首次回调的备份文件路径',
+  `expires_at` datetime NOT NULL COMMENT '令牌过期时间',
+  `used_at` datetime DEFAULT NULL COMMENT '首次回调时间',
+  `created_at` datetime NOT NULL COMMENT '创建时间',
+  PRIMARY KEY (`task_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8 COMMENT='备份任务回调令牌表';
+/*!40101 SET character_set_client = @saved_cs_client */;
+
 --
 -- Table structure for table `tbl_backup_files`
 --
@@ -34,6 +54,9 @@ CREATE TABLE `tbl_backup_files` (
   `path` varchar(1024) DEFAULT NULL COMMENT '备份文件路径(包含文件名)',
   `nfs_mount_src` varchar(512) DEFAULT NULL COMMENT '备份文件nfs源目录',
   `size` bigint(128) unsigned DEFAULT NULL COMMENT '备份文件大小，单位：byte',
+  `checksum` varchar(128) DEFAULT NULL COMMENT '备份文件sha256校验和',
+  `verify_status` varchar(45) DEFAULT NULL COMMENT '校验结果\npassed/failed，空表示未校验',
+  `verified_at` datetime DEFAULT NULL COMMENT '最近校验时间',
   `retention` datetime DEFAULT NULL COMMENT '到期日期',
   `remark` varchar(256) DEFAULT NULL COMMENT '备注',
   `tag` varchar(256) DEFAULT NULL,
@@ -61,6 +84,28 @@ CREATE TABLE `tbl_cluster` (
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8 COMMENT='集群表';
 /*!40101 SET character_set_client = @saved_cs_client */;
 
+--
+-- Table structure for table `tbl_credential`
+--
+
+DROP TABLE IF EXISTS `tbl_credential`;
+/*!40101 SET @saved_cs_client     = @@character_set_client */;
+/*!40101 SET character_set_client = utf8 */;
+CREATE TABLE `tbl_credential` (
+  `id` varchar(128) NOT NULL COMMENT '主键',
+  `name` varchar(128) NOT NULL COMMENT '凭证名称',
+  `type` varchar(45) NOT NULL COMMENT '凭证类型\npassword	密码\nssh_key	SSH私钥',
+  `username` varchar(128) NOT NULL COMMENT 'SSH登录用户',
+  `secret` text NOT NULL COMMENT '加密后的密码或私钥',
+  `port` int(11) unsigned NOT NULL DEFAULT '0' COMMENT 'SSH端口，0表示默认端口',
+  `version` int(11) unsigned NOT NULL DEFAULT '1' COMMENT '版本，每次轮换加1',
+  `created_at` datetime NOT NULL COMMENT '创建时间',
+  `updated_at` datetime NOT NULL COMMENT '更新时间',
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `name_UNIQUE` (`name`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8 COMMENT='主机SSH登录凭证表';
+/*!40101 SET character_set_client = @saved_cs_client */;
+
 --
 -- Table structure for table `tbl_host`
 --
@@ -75,6 +120,7 @@ CREATE TABLE `tbl_host` (
   `engine_id` varchar(128) DEFAULT NULL COMMENT 'docker engine id',
   `room` varchar(128) NOT NULL COMMENT '机房编号',
   `seat` varchar(128) NOT NULL COMMENT '机架编号',
+  `labels` varchar(1024) NOT NULL DEFAULT '' COMMENT '拓扑标签,JSON格式\n例如{"rack":"r1","zone":"z1"}',
   `storage` varchar(128) DEFAULT NULL COMMENT '存储系统ID',
   `max_container` int(11) NOT NULL COMMENT '可容纳最大容器数量',
   `nfs_ip` varchar(45) NOT NULL COMMENT 'nfs IP地址',
@@ -83,6 +129,7 @@ CREATE TABLE `tbl_host` (
   `nfs_mount_opts` varchar(256) DEFAULT NULL COMMENT 'nfs 挂载参数',
   `status` tinyint(4) unsigned DEFAULT NULL COMMENT '状态\n目前不传送给前端，原因是前端通过主机入库时的任务状态描述入库状态',
   `enabled` tinyint(1) unsigned NOT NULL DEFAULT '1' COMMENT '是否可用\n0	fasle\n1	true',
+  `credential_id` varchar(128) NOT NULL DEFAULT '' COMMENT 'SSH登录凭证ID',
   `register_at` datetime DEFAULT NULL COMMENT '注册入库完成时间',
   PRIMARY KEY (`id`,`admin_ip`),
   UNIQUE KEY `id_UNIQUE` (`id`),
@@ -108,11 +155,32 @@ CREATE TABLE `tbl_ip` (
   `unit_id` varchar(128) DEFAULT NULL COMMENT '所属单元ID',
   `enabled` tinyint(1) NOT NULL DEFAULT '1' COMMENT '是否被可用\n0	fasle\n1	true',
   `bandwidth` int(11) DEFAULT NULL,
+  `reserved` varchar(128) NOT NULL DEFAULT '' COMMENT '预留给指定服务（服务名称）',
   PRIMARY KEY (`ip_addr`),
   UNIQUE KEY `ip_addr_UNIQUE` (`ip_addr`)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8 COMMENT='IP地址表';
 /*!40101 SET character_set_client = @saved_cs_client */;
 
+--
+-- Table structure for table `tbl_restore_drill`
+--
+
+DROP TABLE IF EXISTS `tbl_restore_drill`;
+/*!40101 SET @saved_cs_client     = @@character_set_client */;
+/*!40101 SET character_set_client = utf8 */;
+CREATE TABLE `tbl_restore_drill` (
+  `service_id` varchar(128) NOT NULL COMMENT '关联tbl_service.id',
+  `backup_file_id` varchar(128) NOT NULL DEFAULT '' COMMENT '演练使用的备份文件，空表示最新的备份文件',
+  `cmd` varchar(1024) NOT NULL DEFAULT '' COMMENT '恢复后的校验命令，空表示镜像默认命令',
+  `interval` int(11) unsigned NOT NULL DEFAULT '0' COMMENT '演练周期，单位：秒，0表示不定期演练',
+  `last_task_id` varchar(128) NOT NULL DEFAULT '' COMMENT '最近演练任务，关联tbl_task.id',
+  `last_result` varchar(45) NOT NULL DEFAULT '' COMMENT '最近演练结果\npassed/failed',
+  `last_run_at` datetime NOT NULL COMMENT '最近演练时间',
+  `created_at` datetime NOT NULL COMMENT '创建时间',
+  PRIMARY KEY (`service_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8 COMMENT='服务备份恢复演练表';
+/*!40101 SET character_set_client = @saved_cs_client */;
+
 --
 -- Table structure for table `tbl_san`
 --
@@ -244,6 +312,8 @@ CREATE TABLE `tbl_software_image` (
   `docker_image_id` varchar(128) DEFAULT NULL COMMENT 'docker image id',
   `size` int(11) DEFAULT NULL,
   `label` varchar(4096) DEFAULT NULL COMMENT '预留备注',
+  `deprecated` tinyint(1) unsigned NOT NULL DEFAULT '0' COMMENT '是否已废弃，废弃的镜像不能用于新建服务和版本升级',
+  `deprecated_reason` varchar(256) NOT NULL DEFAULT '' COMMENT '废弃原因',
   `upload_at` datetime NOT NULL COMMENT '上传日期',
   PRIMARY KEY (`id`),
   UNIQUE KEY `id_UNIQUE` (`id`),
@@ -284,6 +354,13 @@ CREATE TABLE `tbl_system_config` (
   `clean_script_name` varchar(45) NOT NULL COMMENT '物理机出库清理脚本名',
   `ca_crt_name` varchar(45) NOT NULL COMMENT '证书文件名称',
   `backup_dir` varchar(128) NOT NULL COMMENT '挂载到容器内的备份目录',
+  `backup_target` varchar(45) NOT NULL DEFAULT 'nfs' COMMENT '备份文件存储\nnfs/s3',
+  `s3_endpoint` varchar(256) NOT NULL DEFAULT '' COMMENT 'S3兼容对象存储地址，如 http://127.0.0.1:9000',
+  `s3_region` varchar(45) NOT NULL DEFAULT '' COMMENT 'S3 region',
+  `s3_bucket` varchar(128) NOT NULL DEFAULT '' COMMENT 'S3 bucket',
+  `s3_access_key` varchar(128) NOT NULL DEFAULT '' COMMENT 'S3 access key',
+  `s3_secret_key` varchar(256) NOT NULL DEFAULT '' COMMENT 'deprecated,the S3 secret key is kept in the credential vault',
+  `s3_quota` bigint(20) unsigned NOT NULL DEFAULT '0' COMMENT 'S3 bucket容量，单位：byte，0表示不限制',
   PRIMARY KEY (`dc_id`)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8;
 /*!40101 SET character_set_client = @saved_cs_client */;
@@ -314,6 +391,24 @@ CREATE TABLE `tbl_task` (
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8;
 /*!40101 SET character_set_client = @saved_cs_client */;
 
+--
+-- Table structure for table `tbl_task_step`
+--
+
+DROP TABLE IF EXISTS `tbl_task_step`;
+/*!40101 SET @saved_cs_client     = @@character_set_client */;
+/*!40101 SET character_set_client = utf8 */;
+CREATE TABLE `tbl_task_step` (
+  `task_id` varchar(128) NOT NULL COMMENT '任务ID',
+  `name` varchar(128) NOT NULL COMMENT '步骤名称',
+  `status` varchar(45) NOT NULL COMMENT '步骤状态\nrunning	执行中\ndone	完成\nfailed	失败\nskipped	已完成，跳过',
+  `output` longtext COMMENT '步骤日志',
+  `started_at` datetime NOT NULL COMMENT '开始时间',
+  `finished_at` datetime DEFAULT NULL COMMENT '完成时间',
+  PRIMARY KEY (`task_id`,`name`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+/*!40101 SET character_set_client = @saved_cs_client */;
+
 --
 -- Table structure for table `tbl_unit`
 --
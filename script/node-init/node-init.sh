@@ -350,6 +350,9 @@ install_docker() {
 	
 	wwn=${wwn:1}
 
+	# cpu topology,"cpu,node,core" of each logical cpu joined by ';'
+	cpu_topology=`lscpu -p=CPU,NODE,CORE | grep -v '^#' | paste -sd ';'`
+
 	if [ "${release}" == "RedHatEnterpriseServer" ] || [ "${release}" == "CentOS" ]; then
 		if [ "${san_id}" != '' ]; then
 			systemctl enable multipathd.service
@@ -374,7 +377,7 @@ install_docker() {
 ## ServiceRestart : docker
 
 #
-DOCKER_OPTS=--host=tcp://0.0.0.0:${docker_port} --host=unix:///var/run/docker.sock --label="NODE_ID=${node_id}" --label="HBA_WWN=${wwn}" --label="HDD_VG=${hdd_vgname}" --label="HDD_VG_SIZE=${hdd_vg_size}" --label="SSD_VG=${ssd_vgname}" --label="SSD_VG_SIZE=${ssd_vg_size}" --label="CONTAINER_NIC=${container_nic}" --label PF_DEV_BW=${pf_dev_bw}
+DOCKER_OPTS=--host=tcp://0.0.0.0:${docker_port} --host=unix:///var/run/docker.sock --label="NODE_ID=${node_id}" --label="HBA_WWN=${wwn}" --label="HDD_VG=${hdd_vgname}" --label="HDD_VG_SIZE=${hdd_vg_size}" --label="SSD_VG=${ssd_vgname}" --label="SSD_VG_SIZE=${ssd_vg_size}" --label="CONTAINER_NIC=${container_nic}" --label PF_DEV_BW=${pf_dev_bw} --label="CPU_TOPOLOGY=${cpu_topology}"
 
 EOF
 
@@ -3,9 +3,11 @@ package sdk
 import (
 	"crypto/tls"
 	"encoding/json"
+	"io/ioutil"
 	"net"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	httpclient "github.com/docker/swarm/plugin/client"
@@ -134,6 +136,30 @@ type ClientAPI interface {
 
 	CreateNetwork(ctx context.Context, opt NetworkConfig) error
 	UpdateNetwork(ctx context.Context, opt NetworkConfig) error
+
+	Version(ctx context.Context) (string, error)
+}
+
+// Version returns the seed server API version,
+// used to check the seed server is reachable.
+func (c client) Version(ctx context.Context) (string, error) {
+	const url = "/version"
+
+	resp, err := httpclient.RequireOK(c.c.Get(ctx, url))
+	if err != nil {
+		return "", err
+	}
+
+	defer httpclient.EnsureBodyClose(resp)
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", errors.Errorf("%s:%s%s,%s", http.MethodGet, c.addr, url, err)
+	}
+
+	version := strings.TrimSpace(string(body))
+
+	return strings.TrimPrefix(version, "version:"), nil
 }
 
 //create network for container(use pipework),which network mode is none